@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
@@ -12,40 +13,164 @@ import (
 type (
 	Status = job.Status
 	Job    = job.Job
+	Page   = job.Page
 )
 
 const (
 	defaultTimeout = 30 * time.Second
 	defaultURL     = "http://localhost:8080"
+	defaultBackoff = 500 * time.Millisecond
+	defaultPoll    = time.Second
+	maxPoll        = 30 * time.Second
 )
 
+// Client calls the transcoding api. Every call but Create is idempotent,
+// and is retried up to Retry times after a network error or a 5xx or 429
+// response, waiting Backoff before the first retry and twice as long
+// before each one after that.
 type Client struct {
 	Base   *url.URL
 	Client *http.Client
+
+	Retry   int
+	Backoff time.Duration // defaults to 500ms
+	Poll    time.Duration // the first interval Wait polls at, defaults to 1s
 }
 
-// Create a job
+// Create a job. If the job has no id, the api assigns one.
 func (c *Client) Create(ctx context.Context, job Job) (r Status, err error) {
-	return r, c.do(ctx, "POST", "/job", job, &r)
+	path := "/jobs"
+	if job.ID != "" {
+		path += "/" + url.PathEscape(job.ID)
+	}
+	body, err := encode(job)
+	if err != nil {
+		return r, err
+	}
+	return r, c.do1(ctx, "POST", path, body, &r)
+}
+
+// DryRun returns the request the job's provider would be sent to create
+// it, in the provider's own format, usually json or xml. Nothing is
+// submitted.
+func (c *Client) DryRun(ctx context.Context, job Job) (r []byte, err error) {
+	return r, c.do(ctx, "POST", "/dry-run", job, &r)
 }
 
 // Status for the job id
 func (c *Client) Status(ctx context.Context, id string) (r Status, err error) {
-	return r, c.do(ctx, "GET", "/job/"+id, nil, &r)
+	return r, c.do(ctx, "GET", "/jobs/"+url.PathEscape(id), nil, &r)
 }
 
 // Cancel a job
 func (c *Client) Cancel(ctx context.Context, id string) (r Status, err error) {
-	return r, c.do(ctx, "DELETE", "/job/"+id, nil, &r)
+	return r, c.do(ctx, "DELETE", "/jobs/"+url.PathEscape(id), nil, &r)
 }
 
-func (c *Client) ensure() {
-	if c.Client == nil {
-		c.Client = &http.Client{Timeout: defaultTimeout}
+// List returns a page of at most limit jobs, newest first, skipping the
+// first offset jobs. A limit of zero uses the api's default. The next
+// page starts at the returned page's Next, unless that's zero.
+func (c *Client) List(ctx context.Context, offset, limit int) (r Page, err error) {
+	q := url.Values{}
+	if offset > 0 {
+		q.Set("offset", strconv.Itoa(offset))
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
 	}
+	path := "/jobs"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	return r, c.do(ctx, "GET", path, nil, &r)
+}
+
+// Wait polls the job's status until it's finished, failed or canceled,
+// or ctx is done. The interval starts at Poll and doubles up to 30s.
+func (c *Client) Wait(ctx context.Context, id string) (Status, error) {
+	return c.Watch(ctx, id, nil)
+}
+
+// Watch is Wait, calling fn, if it isn't nil, with every status it polls
+func (c *Client) Watch(ctx context.Context, id string, fn func(Status)) (Status, error) {
+	poll := c.poll()
+	for {
+		r, err := c.Status(ctx, id)
+		if err != nil {
+			return r, err
+		}
+		if fn != nil {
+			fn(r)
+		}
+		if r.State.Terminal() {
+			return r, nil
+		}
+		if err := sleep(ctx, poll); err != nil {
+			return r, err
+		}
+		if poll < maxPoll {
+			if poll *= 2; poll > maxPoll {
+				poll = maxPoll
+			}
+		}
+	}
+}
+
+// Providers returns the names of the enabled providers
+func (c *Client) Providers(ctx context.Context) (r []string, err error) {
+	return r, c.do(ctx, "GET", "/providers", nil, &r)
+}
+
+// Describe returns the capabilities and health of a provider
+func (c *Client) Describe(ctx context.Context, name string) (r Description, err error) {
+	return r, c.do(ctx, "GET", "/providers/"+url.PathEscape(name), nil, &r)
+}
+
+// The defaults of unset fields are returned rather than stored, so a
+// Client can be shared by goroutines.
+var (
+	defaultBase   = urlMust(url.Parse(defaultURL))
+	defaultClient = &http.Client{Timeout: defaultTimeout}
+)
+
+func (c *Client) base() *url.URL {
 	if c.Base == nil {
-		c.Base = urlMust(url.Parse(defaultURL))
+		return defaultBase
 	}
+	return c.Base
+}
+
+func (c *Client) client() *http.Client {
+	if c.Client == nil {
+		return defaultClient
+	}
+	return c.Client
+}
+
+func (c *Client) backoff() time.Duration {
+	if c.Backoff <= 0 {
+		return defaultBackoff
+	}
+	return c.Backoff
+}
+
+func (c *Client) poll() time.Duration {
+	if c.Poll <= 0 {
+		return defaultPoll
+	}
+	return c.Poll
 }
 
 func urlMust(u *url.URL, _ error) *url.URL { return u }
+
+// sleep waits for d, or returns early with ctx's error
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,240 @@
+package transcoding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/google/go-cmp/cmp"
+)
+
+// testClient returns a client for a server that answers with h
+func testClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL)
+	return &Client{Base: u, Client: srv.Client(), Backoff: time.Millisecond, Poll: time.Millisecond}
+}
+
+func TestRoutes(t *testing.T) {
+	var have []string
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		have = append(have, r.Method+" "+r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/providers":
+			w.Write([]byte(`["hybrik"]`))
+		case "/providers/hybrik":
+			w.Write([]byte(`{"name": "hybrik", "enabled": true, "health": {"ok": true}, "capabilities": {"destinations": ["s3"]}}`))
+		case "/jobs":
+			w.Write([]byte(`{"jobs": [{"id": "j1"}], "next": 1}`))
+		case "/dry-run":
+			w.Write([]byte(`<job/>`))
+		default:
+			w.Write([]byte(`{"jobID": "j1", "status": "queued"}`))
+		}
+	})
+	ctx := context.Background()
+	c.Create(ctx, Job{ID: "j1"})
+	c.Create(ctx, Job{})
+	c.Status(ctx, "j1")
+	c.Cancel(ctx, "j/1")
+	page, err := c.List(ctx, 20, 10)
+	if err != nil || len(page.Jobs) != 1 || page.Next != 1 {
+		t.Fatalf("list: have %+v, %v", page, err)
+	}
+	c.List(ctx, 0, 0)
+	names, err := c.Providers(ctx)
+	if err != nil || len(names) != 1 || names[0] != "hybrik" {
+		t.Fatalf("providers: have %v, %v", names, err)
+	}
+	desc, err := c.Describe(ctx, "hybrik")
+	if err != nil || !desc.Enabled || !desc.Health.OK || desc.Capabilities.Destinations[0] != "s3" {
+		t.Fatalf("describe: have %+v, %v", desc, err)
+	}
+	req, err := c.DryRun(ctx, Job{Provider: "hybrik"})
+	if err != nil || string(req) != "<job/>" {
+		t.Fatalf("dry run: have %s, %v", req, err)
+	}
+
+	want := []string{
+		"POST /jobs/j1",
+		"POST /jobs",
+		"GET /jobs/j1",
+		"DELETE /jobs/j%2F1",
+		"GET /jobs?limit=10&offset=20",
+		"GET /jobs",
+		"GET /providers",
+		"GET /providers/hybrik",
+		"POST /dry-run",
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Fatalf("routes (-want +have):\n%s", diff)
+	}
+}
+
+func TestPlatformError(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		code int
+		body string
+		want PlatformError
+	}{
+		{
+			name: "JSON",
+			code: 400,
+			body: `{"ok": false, "status": 400, "rid": 9, "msg": "get job failed"}`,
+			want: PlatformError{Status: 400, Rid: 9, Msg: "get job failed"},
+		},
+		{
+			name: "Text",
+			code: 502,
+			body: "bad gateway\n",
+			want: PlatformError{Status: 502, Msg: "bad gateway"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.code)
+				w.Write([]byte(tt.body))
+			})
+			_, err := c.Status(context.Background(), "j1")
+			var pe *PlatformError
+			if !errors.As(err, &pe) {
+				t.Fatalf("have %v, want a platform error", err)
+			}
+			if diff := cmp.Diff(tt.want, *pe); diff != "" {
+				t.Fatalf("error (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		codes []int // the status of each response, then 200
+		retry int
+		call  func(*Client) error
+		tries int
+		ok    bool
+	}{
+		{name: "Status", codes: []int{503, 429}, retry: 2, tries: 3, ok: true, call: func(c *Client) error {
+			_, err := c.Status(context.Background(), "j1")
+			return err
+		}},
+		{name: "Exhausted", codes: []int{500, 500, 500}, retry: 2, tries: 3, call: func(c *Client) error {
+			_, err := c.Cancel(context.Background(), "j1")
+			return err
+		}},
+		{name: "BadRequest", codes: []int{400}, retry: 2, tries: 1, call: func(c *Client) error {
+			_, err := c.Status(context.Background(), "j1")
+			return err
+		}},
+		{name: "Create", codes: []int{503}, retry: 2, tries: 1, call: func(c *Client) error {
+			_, err := c.Create(context.Background(), Job{ID: "j1"})
+			return err
+		}},
+		{name: "DryRun", codes: []int{502}, retry: 1, tries: 2, ok: true, call: func(c *Client) error {
+			_, err := c.DryRun(context.Background(), Job{})
+			return err
+		}},
+		{name: "Disabled", codes: []int{503}, tries: 1, call: func(c *Client) error {
+			_, err := c.Providers(context.Background())
+			return err
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tries := 0
+			c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if tries++; tries <= len(tt.codes) {
+					w.WriteHeader(tt.codes[tries-1])
+					return
+				}
+				w.Write([]byte(`{}`))
+			})
+			c.Retry = tt.retry
+			if err := tt.call(c); (err == nil) != tt.ok {
+				t.Fatalf("have %v, want ok=%v", err, tt.ok)
+			}
+			if tries != tt.tries {
+				t.Fatalf("have %d tries, want %d", tries, tt.tries)
+			}
+		})
+	}
+}
+
+func TestRetryNetwork(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	u, _ := url.Parse(srv.URL)
+	srv.Close()
+	c := &Client{Base: u, Retry: 1, Backoff: time.Millisecond}
+	start := time.Now()
+	if _, err := c.Status(context.Background(), "j1"); err == nil {
+		t.Fatal("no error from a closed server")
+	}
+	if time.Since(start) < time.Millisecond {
+		t.Fatal("network error wasn't retried")
+	}
+}
+
+func TestWait(t *testing.T) {
+	states := []job.State{job.StateQueued, job.StateStarted, job.StateStarted, job.StateFinished}
+	polls := 0
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Status{ID: "j1", State: states[polls]})
+		polls++
+	})
+	stat, err := c.Wait(context.Background(), "j1")
+	if err != nil || stat.State != job.StateFinished || polls != len(states) {
+		t.Fatalf("have %q after %d polls, %v", stat.State, polls, err)
+	}
+
+	polls = 0
+	var seen []job.State
+	stat, err = c.Watch(context.Background(), "j1", func(s Status) { seen = append(seen, s.State) })
+	if err != nil || stat.State != job.StateFinished {
+		t.Fatalf("watch: have %q, %v", stat.State, err)
+	}
+	if diff := cmp.Diff(states, seen); diff != "" {
+		t.Fatalf("watch: statuses: %s", diff)
+	}
+
+	c = testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "started"}`))
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	stat, err = c.Wait(ctx, "j1")
+	if !errors.Is(err, context.DeadlineExceeded) || stat.State != job.StateStarted {
+		t.Fatalf("have %q, %v, want the last status and %v", stat.State, err, context.DeadlineExceeded)
+	}
+}
+
+func TestDefaultsShared(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jobID": "j1", "status": "finished"}`))
+	})
+	c = &Client{Base: c.Base}
+	errc := make(chan error)
+	for i := 0; i < 4; i++ {
+		go func() {
+			_, err := c.Wait(context.Background(), "j1")
+			errc <- err
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.Client != nil || c.Backoff != 0 || c.Poll != 0 {
+		t.Fatalf("defaults were stored: %+v", c)
+	}
+}
@@ -0,0 +1,118 @@
+package job
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Encryption methods. AES-128 encrypts whole HLS transport stream
+// segments, SAMPLE-AES encrypts the samples in HLS segments, as FairPlay
+// does, and CENC is common encryption of fragmented mp4 segments.
+const (
+	EncryptionAES128    = "aes-128"
+	EncryptionSampleAES = "sample-aes"
+	EncryptionCENC      = "cenc"
+)
+
+// Encryption protects a job's streaming packages, with a static key or
+// with keys from a SPEKE key provider
+type Encryption struct {
+	// Method is aes-128, sample-aes or cenc
+	Method string `json:"method"`
+
+	// Key is a static 16 byte content key in hex, and KeyID its id, which
+	// CENC needs.
+	Key   string `json:"key,omitempty"`
+	KeyID string `json:"keyId,omitempty"`
+
+	// IV is a constant 16 byte initialization vector in hex. Without it
+	// the provider picks one per segment.
+	IV string `json:"iv,omitempty"`
+
+	// KeyURL is where players get the key, or a license for it, from
+	KeyURL string `json:"keyUrl,omitempty"`
+
+	// KeyProvider gets keys from a SPEKE server instead of Key
+	KeyProvider *KeyProvider `json:"keyProvider,omitempty"`
+}
+
+// KeyProvider is a SPEKE key server
+type KeyProvider struct {
+	URL string `json:"url"`
+
+	// ResourceID identifies the content to the key server
+	ResourceID string `json:"resourceId,omitempty"`
+
+	// SystemIDs are the DRM systems to get keys for, as the UUIDs
+	// registered with the DASH-IF
+	SystemIDs []string `json:"systemIds,omitempty"`
+
+	// CertificateARN is the ACM certificate MediaConvert encrypts keys
+	// with, and RoleARN the IAM role Bitmovin assumes to call the server.
+	CertificateARN string `json:"certificateArn,omitempty"`
+	RoleARN        string `json:"roleArn,omitempty"`
+}
+
+// Static reports whether e uses a static key
+func (e *Encryption) Static() bool {
+	return e != nil && e.KeyProvider == nil
+}
+
+// Check reports what's wrong with e, if anything
+func (e *Encryption) Check() error {
+	if e == nil {
+		return nil
+	}
+	switch strings.ToLower(e.Method) {
+	case EncryptionAES128, EncryptionSampleAES, EncryptionCENC:
+	default:
+		return fmt.Errorf("encryption: unknown method %q", e.Method)
+	}
+	if e.KeyProvider != nil {
+		if e.Key != "" {
+			return errors.New("encryption: static key and key provider")
+		}
+		if e.KeyProvider.URL == "" {
+			return errors.New("encryption: key provider without url")
+		}
+	} else if !hex16(e.Key) {
+		return errors.New("encryption: key is not 16 bytes of hex")
+	} else if e.Is(EncryptionCENC) && !hex16(e.KeyID) {
+		return errors.New("encryption: key id is not 16 bytes of hex")
+	}
+	if e.IV != "" && !hex16(e.IV) {
+		return errors.New("encryption: iv is not 16 bytes of hex")
+	}
+	return nil
+}
+
+// Is reports whether e encrypts with method
+func (e *Encryption) Is(method string) bool {
+	return e != nil && strings.EqualFold(e.Method, method)
+}
+
+// Redacted returns a copy of e without its key material, or the
+// credentials in its urls
+func (e *Encryption) Redacted() *Encryption {
+	if e == nil {
+		return nil
+	}
+	r := *e
+	if r.Key != "" {
+		r.Key = "redacted"
+	}
+	r.KeyURL = RedactURL(r.KeyURL)
+	if p := r.KeyProvider; p != nil {
+		p := *p
+		p.URL = RedactURL(p.URL)
+		r.KeyProvider = &p
+	}
+	return &r
+}
+
+func hex16(s string) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == 16
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/cbsinteractive/pkg/timecode"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
 )
 
 // File
@@ -43,8 +44,14 @@ func (f File) URL() url.URL {
 	}
 	return *u
 }
+// Provider returns the normalized storage scheme of the file, or
+// nothing if it isn't a storage url
 func (f File) Provider() string {
-	return f.URL().Scheme
+	l, err := storage.Parse(f.Name)
+	if err != nil {
+		return ""
+	}
+	return l.Scheme
 }
 func (f File) Type() string {
 	return strings.TrimPrefix(path.Ext(f.URL().Path), ".")
@@ -38,9 +38,14 @@ type Job struct {
 
 	Provider      string `json:"provider"`
 	ProviderJobID string
+	State         State `json:"state,omitempty"`
 
-	Input  File
-	Output Dir
+	// Msg is the status message the job ended with, as when it failed
+	Msg string `json:"msg,omitempty"`
+
+	Input     File
+	Output    Dir
+	Streaming Streaming `json:"streaming,omitempty"`
 
 	Features Features
 	Env      Env
@@ -48,6 +53,13 @@ type Job struct {
 	ExtraFiles map[string]string
 }
 
+// Page is a page of jobs, newest first. Next is the offset of the
+// next page, or zero on the last one.
+type Page struct {
+	Jobs []Job `json:"jobs"`
+	Next int   `json:"next,omitempty"`
+}
+
 func (j *Job) Asset(sidecar string) *File {
 	loc := j.ExtraFiles[sidecar]
 	if loc == "" {
@@ -0,0 +1,76 @@
+package job
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Redacted returns a copy of the job that is safe to log or report.
+// Passwords and query strings, which carry the signature of a signed
+// url, are removed from every location. Tags and features named like
+// credentials are masked, and so are encryption keys.
+func (j Job) Redacted() Job {
+	j.Input.Name = RedactURL(j.Input.Name)
+	j.Streaming.Encryption = j.Streaming.Encryption.Redacted()
+	j.Output.Path = RedactURL(j.Output.Path)
+	j.Output.File = append([]File(nil), j.Output.File...)
+	for i := range j.Output.File {
+		j.Output.File[i].Name = RedactURL(j.Output.File[i].Name)
+	}
+	if j.ExtraFiles != nil {
+		extra := make(map[string]string, len(j.ExtraFiles))
+		for k, v := range j.ExtraFiles {
+			extra[k] = RedactURL(v)
+		}
+		j.ExtraFiles = extra
+	}
+	if j.Env.Tags != nil {
+		tags := make(map[string]string, len(j.Env.Tags))
+		for k, v := range j.Env.Tags {
+			if secret(k) {
+				v = "redacted"
+			}
+			tags[k] = v
+		}
+		j.Env.Tags = tags
+	}
+	if j.Features != nil {
+		f := make(Features, len(j.Features))
+		for k, v := range j.Features {
+			if secret(k) {
+				v = "redacted"
+			}
+			f[k] = v
+		}
+		j.Features = f
+	}
+	return j
+}
+
+func secret(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range []string{"key", "secret", "token", "password", "credential"} {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactURL masks the password and query string of s. Strings
+// that aren't urls are returned as is.
+func RedactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || (u.User == nil && u.RawQuery == "") {
+		return s
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "redacted")
+		}
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "redacted"
+	}
+	return u.String()
+}
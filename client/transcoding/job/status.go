@@ -12,6 +12,11 @@ const (
 	StateCanceled = State("canceled")
 )
 
+// Terminal returns true if the state can no longer change
+func (s State) Terminal() bool {
+	return s == StateFinished || s == StateFailed || s == StateCanceled
+}
+
 type Provider struct {
 	Name   string                 `json:"name,omitempty"`
 	JobID  string                 `json:"job_id,omitempty"`
@@ -27,8 +32,9 @@ type Status struct {
 	Msg      string  `json:"msg,omitempty"`
 	Progress float64 `json:"progress"`
 
-	Input  File `json:"input"`
-	Output Dir  `json:"output"`
+	Input    File      `json:"input"`
+	Output   Dir       `json:"output"`
+	Packages []Package `json:"packages,omitempty"`
 
 	Provider       string                 `json:"providerName,omitempty"`
 	ProviderJobID  string                 `json:"providerJobId,omitempty"`
@@ -0,0 +1,115 @@
+package job
+
+import "strings"
+
+// Containers of outputs that are renditions in an adaptive streaming
+// package rather than files of their own. They double as the names of
+// the protocols.
+const (
+	ContainerHLS  = "hls"
+	ContainerDASH = "dash"
+	ContainerCMAF = "cmaf"
+)
+
+// HLS segment formats. DASH and CMAF segments are always fragmented mp4.
+const (
+	SegmentsTS   = "ts"
+	SegmentsFMP4 = "fmp4"
+)
+
+const (
+	defaultManifest   = "master"
+	defaultAudioGroup = "audio"
+)
+
+// Streaming describes the adaptive streaming packages of a job. Every
+// output whose container is hls, dash or cmaf is a rendition in the
+// package for its protocol, which is written under a directory named
+// after the protocol, e.g. hls/master.m3u8.
+type Streaming struct {
+	// SegmentDuration is the target length of a segment in seconds. Zero
+	// uses the service's DEFAULT_SEGMENT_DURATION.
+	SegmentDuration uint `json:"segmentDuration,omitempty"`
+
+	// Manifest names the master playlist or mpd, without its extension.
+	// It defaults to "master".
+	Manifest string `json:"manifest,omitempty"`
+
+	// Segments is the format of HLS segments: ts, the default, or fmp4
+	Segments string `json:"segments,omitempty"`
+
+	// AudioGroup names the rendition group audio only outputs are put
+	// in, which video only outputs play with. It defaults to "audio".
+	AudioGroup string `json:"audioGroup,omitempty"`
+
+	// Encryption protects the packages, which are in the clear without it
+	Encryption *Encryption `json:"encryption,omitempty"`
+}
+
+// Protocol returns the streaming protocol of the package f is a rendition
+// in, or nothing if it's a file of its own. The m3u8 and mpd containers
+// are aliases of hls and dash.
+func (f File) Protocol() string {
+	switch c := strings.ToLower(f.Container); c {
+	case ContainerHLS, ContainerDASH, ContainerCMAF:
+		return c
+	case "m3u8":
+		return ContainerHLS
+	case "mpd":
+		return ContainerDASH
+	}
+	return ""
+}
+
+// Streamed reports whether f is a rendition in a streaming package
+func (f File) Streamed() bool {
+	return f.Protocol() != ""
+}
+
+// Duration returns the segment duration, or def if it isn't set
+func (s Streaming) Duration(def uint) uint {
+	if s.SegmentDuration == 0 {
+		return def
+	}
+	return s.SegmentDuration
+}
+
+// ManifestName returns the manifest's name without its extension
+func (s Streaming) ManifestName() string {
+	if s.Manifest == "" {
+		return defaultManifest
+	}
+	return s.Manifest
+}
+
+// AudioGroupName returns the name of the audio rendition group
+func (s Streaming) AudioGroupName() string {
+	if s.AudioGroup == "" {
+		return defaultAudioGroup
+	}
+	return s.AudioGroup
+}
+
+// FMP4 reports whether HLS segments are fragmented mp4
+func (s Streaming) FMP4() bool {
+	return strings.ToLower(s.Segments) == SegmentsFMP4
+}
+
+// Package is an adaptive streaming package produced by a job
+type Package struct {
+	Protocol   string      `json:"protocol"`
+	Manifest   string      `json:"manifest"`
+	Renditions []Rendition `json:"renditions,omitempty"`
+}
+
+// Rendition is a single encoding in a package. Playlist is its media
+// playlist, for HLS, and Segments is zero when the provider doesn't
+// report it.
+type Rendition struct {
+	Name     string `json:"name"`
+	Playlist string `json:"playlist,omitempty"`
+	Group    string `json:"group,omitempty"`
+	Video    Video  `json:"video,omitempty"`
+	Audio    Audio  `json:"audio,omitempty"`
+	Segments int    `json:"segments,omitempty"`
+}
@@ -4,41 +4,111 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
-func encode(v interface{}) (io.Reader, error) {
+// PlatformError is the error the api responds with. Responses that
+// aren't json keep their body in Msg.
+type PlatformError struct {
+	Ok     bool   `json:"ok"`
+	Status int    `json:"status"`
+	Rid    uint64 `json:"rid"`
+	Msg    string `json:"msg,omitempty"`
+}
+
+func (e *PlatformError) Error() string {
+	if e.Rid == 0 {
+		return fmt.Sprintf("http status: %d: %s", e.Status, e.Msg)
+	}
+	return fmt.Sprintf("http status: %d: %s (rid %d)", e.Status, e.Msg, e.Rid)
+}
+
+// Temporary reports whether the request may succeed if retried
+func (e *PlatformError) Temporary() bool {
+	return e.Status >= 500 || e.Status == http.StatusTooManyRequests
+}
+
+func encode(v interface{}) ([]byte, error) {
 	if v == nil {
 		return nil, nil
 	}
-	b := new(bytes.Buffer)
-	return b, json.NewEncoder(b).Encode(v)
+	return json.Marshal(v)
 }
 
+// do sends the request, retrying it up to c.Retry times. Requests that
+// aren't idempotent are sent with do1.
 func (c *Client) do(ctx context.Context, method string, path string, in, out interface{}) error {
-	c.ensure()
 	body, err := encode(in)
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequestWithContext(ctx, method, c.Base.String()+path, body)
+	wait := c.backoff()
+	for try := 0; ; try++ {
+		err = c.do1(ctx, method, path, body, out)
+		if try >= c.Retry || !temporary(ctx, err) {
+			return err
+		}
+		if sleep(ctx, wait) != nil {
+			return err
+		}
+		wait *= 2
+	}
+}
+
+func (c *Client) do1(ctx context.Context, method string, path string, body []byte, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base().String()+path, r)
 	if err != nil {
 		return err
 	}
-	resp, err := c.Client.Do(req)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client().Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
-	data, _ := io.ReadAll(resp.Body)
-	// TODO(as): a short read killed accurate delivery of error messages
-	// need to handle this case delicately
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
 
-	if c := resp.StatusCode; c < 200 || c > 299 {
-		return fmt.Errorf("http status: %d: body %q", c, string(data))
+	if code := resp.StatusCode; code < 200 || code > 299 {
+		e := &PlatformError{}
+		if json.Unmarshal(data, e) != nil || e.Status == 0 {
+			e = &PlatformError{Msg: strings.TrimSpace(string(data))}
+		}
+		e.Status = code
+		return e
 	}
 
+	if raw, ok := out.(*[]byte); ok {
+		*raw = data
+		return nil
+	}
 	return json.Unmarshal(data, out)
 }
+
+// temporary reports whether err is a network error or a temporary api
+// error, and ctx isn't done
+func temporary(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var pe *PlatformError
+	if errors.As(err, &pe) {
+		return pe.Temporary()
+	}
+	var ue *url.Error
+	return errors.As(err, &ue)
+}
@@ -118,7 +118,6 @@ func Test_reqWithMethodAndPayload(t *testing.T) {
 			}
 
 			client := Client{Base: backendURL}
-			client.ensure()
 
 			respObj := testResp{}
 			err = client.do(context.Background(), tt.method, tt.path, tt.reqBody, &respObj)
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// config is where the api is and how to authenticate to it. The api
+// itself doesn't check credentials, but a proxy in front of it may.
+type config struct {
+	URL      string `json:"url"`
+	Token    string `json:"token,omitempty"`    // sent as a bearer token
+	Username string `json:"username,omitempty"` // with Password, sent as basic auth
+	Password string `json:"password,omitempty"`
+}
+
+// defaultConfigFile returns the config file read when none is given,
+// which need not exist
+func defaultConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "transcodectl", "config.json")
+}
+
+// loadConfig reads the config file, if any, then overrides it with
+// the environment. Only a missing default file is ignored.
+func loadConfig(file string, getenv func(string) string) (config, error) {
+	var c config
+	explicit := file != ""
+	if !explicit {
+		file = defaultConfigFile()
+	}
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(data, &c); err != nil {
+				return c, fmt.Errorf("config %s: %w", file, err)
+			}
+		case explicit || !os.IsNotExist(err):
+			return c, fmt.Errorf("config: %w", err)
+		}
+	}
+	for v, key := range map[*string]string{
+		&c.URL:      "TRANSCODECTL_URL",
+		&c.Token:    "TRANSCODECTL_TOKEN",
+		&c.Username: "TRANSCODECTL_USERNAME",
+		&c.Password: "TRANSCODECTL_PASSWORD",
+	} {
+		if s := getenv(key); s != "" {
+			*v = s
+		}
+	}
+	return c, nil
+}
+
+// auth adds the configured credentials to every request
+type auth struct {
+	config
+	next http.RoundTripper
+}
+
+func (a *auth) RoundTrip(r *http.Request) (*http.Response, error) {
+	if a.Token == "" && a.Username == "" {
+		return a.next.RoundTrip(r)
+	}
+	r = r.Clone(r.Context())
+	if a.Token != "" {
+		r.Header.Set("Authorization", "Bearer "+a.Token)
+	} else {
+		r.SetBasicAuth(a.Username, a.Password)
+	}
+	return a.next.RoundTrip(r)
+}
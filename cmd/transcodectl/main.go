@@ -0,0 +1,281 @@
+// Command transcodectl submits and manages jobs through the transcoding api.
+//
+// Usage:
+//
+//	transcodectl [flags] command [args]
+//
+// The commands are:
+//
+//	submit [file]          create the job in file, or read from stdin
+//	status id...           print the status of jobs
+//	watch id               print a job's progress until it's done
+//	cancel id...           cancel jobs
+//	list                   list jobs, newest first
+//	providers [name...]    list the providers, or describe some
+//	dry-run [file]         print the request a job's provider would be sent
+//
+// The api's url and credentials come from a json config file, by default
+// transcodectl/config.json in the user's config directory:
+//
+//	{"url": "https://transcode.example.com", "token": "..."}
+//
+// TRANSCODECTL_URL, TRANSCODECTL_TOKEN, TRANSCODECTL_USERNAME and
+// TRANSCODECTL_PASSWORD override the file.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+)
+
+const defaultURL = "http://localhost:8080"
+
+// errUsage is returned for bad arguments, after printing the usage
+var errUsage = errors.New("usage")
+
+// errTerminal is returned by watch when the job failed or was canceled
+var errTerminal = errors.New("job did not finish")
+
+type cli struct {
+	c   *transcoding.Client
+	out printer
+	in  io.Reader
+}
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		cancel()
+	}()
+	code := run(ctx, os.Args[1:], os.Getenv, os.Stdin, os.Stdout, os.Stderr)
+	cancel()
+	os.Exit(code)
+}
+
+// run executes the command line and returns the exit code: 2 for bad
+// usage, 1 for any other error
+func run(ctx context.Context, args []string, getenv func(string) string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("transcodectl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		cfgfile = fs.String("config", getenv("TRANSCODECTL_CONFIG"), "json config file (default: transcodectl/config.json in the user config dir)")
+		base    = fs.String("url", "", "api url, overriding the config")
+		output  = fs.String("o", "table", "output format: table or json")
+		timeout = fs.Duration("timeout", 30*time.Second, "timeout for each api request")
+		retry   = fs.Int("retry", 3, "times to retry failed idempotent requests")
+		poll    = fs.Duration("poll", 5*time.Second, "how often watch first polls, backing off to 30s")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: transcodectl [flags] submit|status|watch|cancel|list|providers|dry-run [args]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 || (*output != "table" && *output != "json") {
+		fs.Usage()
+		return 2
+	}
+
+	cfg, err := loadConfig(*cfgfile, getenv)
+	if err != nil {
+		fmt.Fprintln(stderr, "transcodectl:", err)
+		return 1
+	}
+	if *base != "" {
+		cfg.URL = *base
+	}
+	if cfg.URL == "" {
+		cfg.URL = defaultURL
+	}
+	u, err := url.Parse(strings.TrimSuffix(cfg.URL, "/"))
+	if err != nil {
+		fmt.Fprintln(stderr, "transcodectl: bad url:", err)
+		return 1
+	}
+
+	x := &cli{
+		c: &transcoding.Client{
+			Base:   u,
+			Client: &http.Client{Timeout: *timeout, Transport: &auth{config: cfg, next: http.DefaultTransport}},
+			Retry:  *retry,
+			Poll:   *poll,
+		},
+		out: printer{w: stdout, json: *output == "json"},
+		in:  stdin,
+	}
+	switch err := x.run(ctx, fs.Arg(0), fs.Args()[1:]); {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage):
+		fs.Usage()
+		return 2
+	case errors.Is(err, errTerminal):
+		return 1
+	default:
+		fmt.Fprintln(stderr, "transcodectl:", err)
+		return 1
+	}
+}
+
+func (x *cli) run(ctx context.Context, cmd string, args []string) error {
+	switch cmd {
+	case "submit":
+		j, err := x.job(args)
+		if err != nil {
+			return err
+		}
+		s, err := x.c.Create(ctx, j)
+		if err != nil {
+			return err
+		}
+		return x.out.status(s)
+	case "status", "cancel":
+		if len(args) == 0 {
+			return errUsage
+		}
+		call := x.c.Status
+		if cmd == "cancel" {
+			call = x.c.Cancel
+		}
+		var all []transcoding.Status
+		for _, id := range args {
+			s, err := call(ctx, id)
+			if err != nil {
+				return fmt.Errorf("%s: %w", id, err)
+			}
+			all = append(all, s)
+		}
+		return x.out.status(all...)
+	case "watch":
+		if len(args) != 1 {
+			return errUsage
+		}
+		return x.watch(ctx, args[0])
+	case "list":
+		fs := flag.NewFlagSet("list", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		offset := fs.Int("offset", 0, "jobs to skip")
+		limit := fs.Int("limit", 0, "most jobs to list (default: the api's)")
+		if fs.Parse(args) != nil || fs.NArg() != 0 {
+			return errUsage
+		}
+		pg, err := x.c.List(ctx, *offset, *limit)
+		if err != nil {
+			return err
+		}
+		return x.out.page(pg)
+	case "providers":
+		if len(args) == 0 {
+			names, err := x.c.Providers(ctx)
+			if err != nil {
+				return err
+			}
+			return x.out.names(names)
+		}
+		var all []transcoding.Description
+		for _, name := range args {
+			d, err := x.c.Describe(ctx, name)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			all = append(all, d)
+		}
+		return x.out.descriptions(all)
+	case "dry-run":
+		j, err := x.job(args)
+		if err != nil {
+			return err
+		}
+		data, err := x.c.DryRun(ctx, j)
+		if err != nil {
+			return err
+		}
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			data = append(data, '\n')
+		}
+		_, err = x.out.w.Write(data)
+		return err
+	}
+	return errUsage
+}
+
+// job reads the job in the file named by args, or stdin if there's
+// none or it's "-"
+func (x *cli) job(args []string) (j transcoding.Job, err error) {
+	if len(args) > 1 {
+		return j, errUsage
+	}
+	r := x.in
+	if len(args) == 1 && args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return j, err
+		}
+		defer f.Close()
+		r = f
+	}
+	if err := json.NewDecoder(r).Decode(&j); err != nil {
+		return j, fmt.Errorf("reading job: %w", err)
+	}
+	return j, nil
+}
+
+// watch prints the job's status each time it changes until it's done.
+// It returns errTerminal if the job failed or was canceled.
+func (x *cli) watch(ctx context.Context, id string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		last    *transcoding.Status
+		printed error
+	)
+	s, err := x.c.Watch(ctx, id, func(s transcoding.Status) {
+		if last == nil || s.State != last.State || s.Progress != last.Progress || s.Msg != last.Msg {
+			if printed = x.progress(s); printed != nil {
+				cancel()
+			}
+		}
+		last = &s
+	})
+	switch {
+	case printed != nil:
+		return printed
+	case err != nil:
+		return err
+	case s.State != job.StateFinished:
+		return errTerminal
+	}
+	return nil
+}
+
+// progress prints a line of watch output: the status as compact json,
+// or the time, state, progress and message
+func (x *cli) progress(s transcoding.Status) error {
+	if x.out.json {
+		return json.NewEncoder(x.out.w).Encode(s)
+	}
+	line := fmt.Sprintf("%s  %-8s  %3.0f%%", time.Now().Format("15:04:05"), s.State, s.Progress)
+	if s.Msg != "" {
+		line += "  " + s.Msg
+	}
+	_, err := fmt.Fprintln(x.out.w, line)
+	return err
+}
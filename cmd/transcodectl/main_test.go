@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/google/go-cmp/cmp"
+)
+
+// api stands in for the transcoding api. It records each request and
+// answers jobs with the next of its states, holding the last one.
+type api struct {
+	*httptest.Server
+	reqs   []string
+	auth   []string
+	states []job.State
+}
+
+func newAPI(t *testing.T, states ...job.State) *api {
+	a := &api{states: states}
+	a.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.reqs = append(a.reqs, r.Method+" "+r.URL.RequestURI())
+		a.auth = append(a.auth, r.Header.Get("Authorization"))
+		switch {
+		case r.URL.Path == "/jobs" && r.Method == "GET":
+			w.Write([]byte(`{"jobs": [{"id": "j1", "provider": "hybrik", "state": "started", "Input": {"name": "s3://in/a.mov"}}], "next": 1}`))
+		case r.URL.Path == "/providers":
+			w.Write([]byte(`["hybrik", "local"]`))
+		case r.URL.Path == "/providers/hybrik":
+			w.Write([]byte(`{"name": "hybrik", "enabled": true, "health": {"ok": true}, "capabilities": {"destinations": ["s3", "gs"]}}`))
+		case r.URL.Path == "/dry-run":
+			var j job.Job
+			json.NewDecoder(r.Body).Decode(&j)
+			w.Write([]byte(`<job provider="` + j.Provider + `"/>`))
+		case strings.HasPrefix(r.URL.Path, "/jobs/missing"):
+			w.WriteHeader(400)
+			w.Write([]byte(`{"status": 400, "msg": "get job failed"}`))
+		default:
+			s := job.StateQueued
+			if len(a.states) > 0 {
+				s = a.states[0]
+			}
+			if len(a.states) > 1 {
+				a.states = a.states[1:]
+			}
+			json.NewEncoder(w).Encode(transcoding.Status{ID: "j1", State: s, Provider: "hybrik", Progress: progress[s]})
+		}
+	}))
+	t.Cleanup(a.Close)
+	return a
+}
+
+// tempDir returns a directory that's removed when the test ends
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "transcodectl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// setenv sets the environment variable until the test ends
+func setenv(t *testing.T, k, v string) {
+	old, ok := os.LookupEnv(k)
+	os.Setenv(k, v)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(k, old)
+		} else {
+			os.Unsetenv(k)
+		}
+	})
+}
+
+var progress = map[job.State]float64{job.StateStarted: 50, job.StateFinished: 100}
+
+// exec runs the command line against the api and returns its exit code
+// and output
+func (a *api) exec(t *testing.T, stdin string, env map[string]string, args ...string) (int, string, string) {
+	t.Helper()
+	if env == nil {
+		env = map[string]string{}
+	}
+	if _, ok := env["TRANSCODECTL_URL"]; !ok {
+		env["TRANSCODECTL_URL"] = a.URL
+	}
+	if _, ok := env["TRANSCODECTL_CONFIG"]; !ok {
+		env["TRANSCODECTL_CONFIG"] = filepath.Join(tempDir(t), "none.json")
+		ioutil.WriteFile(env["TRANSCODECTL_CONFIG"], []byte(`{}`), 0600)
+	}
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	args = append([]string{"-poll", "1ms", "-retry", "0"}, args...)
+	code := run(context.Background(), args, func(k string) string { return env[k] }, strings.NewReader(stdin), stdout, stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCommands(t *testing.T) {
+	jobFile := filepath.Join(tempDir(t), "job.json")
+	ioutil.WriteFile(jobFile, []byte(`{"id": "j1", "provider": "hybrik"}`), 0600)
+
+	for _, tt := range []struct {
+		name  string
+		args  []string
+		stdin string
+		code  int
+		reqs  []string
+		out   string // the start of the output
+	}{
+		{
+			name: "SubmitFile", args: []string{"submit", jobFile},
+			reqs: []string{"POST /jobs/j1"},
+			out:  "ID  STATE   PROGRESS  PROVIDER  PROVIDER JOB  MESSAGE\nj1  queued  0%        hybrik                  \n",
+		},
+		{
+			name: "SubmitStdin", args: []string{"submit", "-"}, stdin: `{"provider": "hybrik"}`,
+			reqs: []string{"POST /jobs"},
+		},
+		{
+			name: "SubmitBadJob", args: []string{"submit"}, stdin: `{`, code: 1,
+		},
+		{
+			name: "Status", args: []string{"-o", "json", "status", "j1"},
+			reqs: []string{"GET /jobs/j1"},
+			out:  "{\n  \"jobID\": \"j1\",\n  \"status\": \"queued\",\n",
+		},
+		{
+			name: "StatusError", args: []string{"status", "j1", "missing"}, code: 1,
+			reqs: []string{"GET /jobs/j1", "GET /jobs/missing"},
+		},
+		{
+			name: "Cancel", args: []string{"cancel", "j1", "j2"},
+			reqs: []string{"DELETE /jobs/j1", "DELETE /jobs/j2"},
+		},
+		{
+			name: "List", args: []string{"list", "-offset", "5", "-limit", "1"},
+			reqs: []string{"GET /jobs?limit=1&offset=5"},
+			out:  "ID  PROVIDER  STATE    CREATED  INPUT\nj1  hybrik    started           s3://in/a.mov\n",
+		},
+		{
+			name: "Providers", args: []string{"providers"},
+			reqs: []string{"GET /providers"},
+			out:  "NAME\nhybrik\nlocal\n",
+		},
+		{
+			name: "Describe", args: []string{"providers", "hybrik"},
+			reqs: []string{"GET /providers/hybrik"},
+			out:  "NAME    ENABLED  HEALTHY  DESTINATIONS  MESSAGE\nhybrik  yes      yes      s3,gs         \n",
+		},
+		{
+			name: "DryRun", args: []string{"-o", "json", "dry-run", jobFile},
+			reqs: []string{"POST /dry-run"},
+			out:  "<job provider=\"hybrik\"/>\n",
+		},
+		{name: "NoCommand", code: 2},
+		{name: "BadCommand", args: []string{"launch"}, code: 2},
+		{name: "BadOutput", args: []string{"-o", "yaml", "list"}, code: 2},
+		{name: "StatusNoID", args: []string{"status"}, code: 2},
+		{name: "ListArgs", args: []string{"list", "extra"}, code: 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAPI(t)
+			code, out, stderr := a.exec(t, tt.stdin, nil, tt.args...)
+			if code != tt.code {
+				t.Fatalf("exit %d, want %d: %s", code, tt.code, stderr)
+			}
+			if diff := cmp.Diff(tt.reqs, a.reqs); diff != "" {
+				t.Fatalf("requests (-want +have):\n%s", diff)
+			}
+			if !strings.HasPrefix(out, tt.out) {
+				t.Fatalf("have output:\n%s\nwant it to start with:\n%s", out, tt.out)
+			}
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		states []job.State
+		code   int
+		lines  int
+	}{
+		{"Finished", []job.State{job.StateQueued, job.StateQueued, job.StateStarted, job.StateStarted, job.StateFinished}, 0, 3},
+		{"Failed", []job.State{job.StateStarted, job.StateFailed}, 1, 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAPI(t, tt.states...)
+			code, out, _ := a.exec(t, "", nil, "-o", "json", "watch", "j1")
+			if code != tt.code {
+				t.Fatalf("exit %d, want %d", code, tt.code)
+			}
+			if len(a.reqs) != len(tt.states) {
+				t.Fatalf("have %d polls, want %d", len(a.reqs), len(tt.states))
+			}
+			lines := strings.Split(strings.TrimSpace(out), "\n")
+			if len(lines) != tt.lines {
+				t.Fatalf("have %d lines, want %d:\n%s", len(lines), tt.lines, out)
+			}
+			var last transcoding.Status
+			if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil || last.State != tt.states[len(tt.states)-1] {
+				t.Fatalf("last line: %s, %v", lines[len(lines)-1], err)
+			}
+		})
+	}
+}
+
+func TestConfig(t *testing.T) {
+	dir := tempDir(t)
+	file := filepath.Join(dir, "config.json")
+	ioutil.WriteFile(file, []byte(`{"url": "https://file.example.com", "token": "file-token"}`), 0600)
+
+	c, err := loadConfig(file, func(k string) string {
+		return map[string]string{"TRANSCODECTL_TOKEN": "env-token"}[k]
+	})
+	want := config{URL: "https://file.example.com", Token: "env-token"}
+	if err != nil || c != want {
+		t.Fatalf("have %+v, %v, want %+v", c, err, want)
+	}
+	if _, err := loadConfig(filepath.Join(dir, "missing.json"), os.Getenv); err == nil {
+		t.Fatal("no error for a missing config file")
+	}
+	setenv(t, "XDG_CONFIG_HOME", dir)
+	setenv(t, "HOME", dir)
+	if _, err := loadConfig("", func(string) string { return "" }); err != nil {
+		t.Fatalf("missing default config file: %v", err)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"None", map[string]string{}, ""},
+		{"Token", map[string]string{"TRANSCODECTL_TOKEN": "t0k"}, "Bearer t0k"},
+		{"Basic", map[string]string{"TRANSCODECTL_USERNAME": "ops", "TRANSCODECTL_PASSWORD": "pw"}, "Basic b3BzOnB3"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAPI(t)
+			if code, _, stderr := a.exec(t, "", tt.env, "providers"); code != 0 {
+				t.Fatalf("exit %d: %s", code, stderr)
+			}
+			if a.auth[0] != tt.want {
+				t.Fatalf("have %q, want %q", a.auth[0], tt.want)
+			}
+		})
+	}
+}
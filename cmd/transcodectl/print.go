@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding"
+)
+
+// printer writes results as indented json or as tables
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func (p printer) encode(v interface{}) error {
+	e := json.NewEncoder(p.w)
+	e.SetIndent("", "  ")
+	return e.Encode(v)
+}
+
+// table writes the header and rows as aligned columns
+func (p printer) table(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(p.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, r := range rows {
+		fmt.Fprintln(tw, strings.Join(r, "\t"))
+	}
+	return tw.Flush()
+}
+
+func (p printer) status(s ...transcoding.Status) error {
+	if p.json {
+		if len(s) == 1 {
+			return p.encode(s[0])
+		}
+		return p.encode(s)
+	}
+	rows := [][]string{}
+	for _, s := range s {
+		rows = append(rows, []string{
+			s.ID, string(s.State), fmt.Sprintf("%.0f%%", s.Progress), s.Provider, s.ProviderJobID, s.Msg,
+		})
+	}
+	return p.table([]string{"ID", "STATE", "PROGRESS", "PROVIDER", "PROVIDER JOB", "MESSAGE"}, rows)
+}
+
+func (p printer) page(pg transcoding.Page) error {
+	if p.json {
+		return p.encode(pg)
+	}
+	rows := [][]string{}
+	for _, j := range pg.Jobs {
+		created := ""
+		if !j.CreatedAt.IsZero() {
+			created = j.CreatedAt.Local().Format(time.RFC3339)
+		}
+		rows = append(rows, []string{j.ID, j.Provider, string(j.State), created, j.Input.Name})
+	}
+	return p.table([]string{"ID", "PROVIDER", "STATE", "CREATED", "INPUT"}, rows)
+}
+
+func (p printer) names(names []string) error {
+	if p.json {
+		return p.encode(names)
+	}
+	rows := [][]string{}
+	for _, n := range names {
+		rows = append(rows, []string{n})
+	}
+	return p.table([]string{"NAME"}, rows)
+}
+
+func (p printer) descriptions(d []transcoding.Description) error {
+	if p.json {
+		if len(d) == 1 {
+			return p.encode(d[0])
+		}
+		return p.encode(d)
+	}
+	rows := [][]string{}
+	for _, d := range d {
+		rows = append(rows, []string{
+			d.Name, yes(d.Enabled), yes(d.Health.OK), strings.Join(d.Capabilities.Destinations, ","), d.Health.Message,
+		})
+	}
+	return p.table([]string{"NAME", "ENABLED", "HEALTHY", "DESTINATIONS", "MESSAGE"}, rows)
+}
+
+func yes(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
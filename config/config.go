@@ -1,6 +1,12 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
 	"github.com/kelseyhightower/envconfig"
 	"github.com/zsiec/pkg/tracing"
 )
@@ -13,6 +19,8 @@ type Config struct {
 	Env                    string `envconfig:"ENV" default:"dev"`
 	EnableXray             bool   `envconfig:"ENABLE_XRAY"`
 	EnableXrayAWSPlugins   bool   `envconfig:"ENABLE_XRAYAWSPLUGINS"`
+	TraceExporter          string `envconfig:"TRACE_EXPORTER" default:"noop"`
+	LogLevel               string `envconfig:"APP_LOG_LEVEL"`
 	EncodingCom            *EncodingCom
 	ElasticTranscoder      *ElasticTranscoder
 	ElementalConductor     *ElementalConductor
@@ -21,7 +29,16 @@ type Config struct {
 	Bitmovin               *Bitmovin
 	MediaConvert           *MediaConvert
 	Flock                  *Flock
-	Tracer                 tracing.Tracer `ignored:"true"`
+	GoogleTranscoder       *GoogleTranscoder
+	Local                  *Local
+	Simulator              *Simulator
+	Store                  *Store
+	Redis                  *Redis
+	Retention              *Retention
+	Secrets                *Secrets
+	Verify                 *Verify
+	Instances              map[string]*Instance `ignored:"true"`
+	Tracer                 tracing.Tracer       `ignored:"true" json:"-"`
 }
 
 // EncodingCom represents the set of configurations for the Encoding.com
@@ -32,6 +49,7 @@ type EncodingCom struct {
 	Destination    string `envconfig:"ENCODINGCOM_DESTINATION"`
 	Region         string `envconfig:"ENCODINGCOM_REGION"`
 	StatusEndpoint string `envconfig:"ENCODINGCOM_STATUS_ENDPOINT" default:"http://status.encoding.com"`
+	Endpoint       string `envconfig:"ENCODINGCOM_ENDPOINT" default:"https://manage.encoding.com"`
 }
 
 // Zencoder represents the set of configurations for the Zencoder
@@ -39,6 +57,7 @@ type EncodingCom struct {
 type Zencoder struct {
 	APIKey      string `envconfig:"ZENCODER_API_KEY"`
 	Destination string `envconfig:"ZENCODER_DESTINATION"`
+	Endpoint    string `envconfig:"ZENCODER_ENDPOINT" default:"https://app.zencoder.com/api/v2"`
 }
 
 // ElasticTranscoder represents the set of configurations for the Elastic
@@ -113,9 +132,160 @@ type Flock struct {
 	Credential string `envconfig:"FLOCK_CREDENTIAL"`
 }
 
+// GoogleTranscoder represents the set of configurations for the Google
+// Cloud Transcoder API provider. Without a credentials key, it uses the
+// service account of the instance it runs on.
+type GoogleTranscoder struct {
+	ProjectID      string `envconfig:"GOOGLETRANSCODER_PROJECT_ID"`
+	Location       string `envconfig:"GOOGLETRANSCODER_LOCATION" default:"us-central1"`
+	CredentialsKey string `envconfig:"GOOGLETRANSCODER_CREDENTIALS_KEY"`
+	Destination    string `envconfig:"GOOGLETRANSCODER_DESTINATION"`
+	Endpoint       string `envconfig:"GOOGLETRANSCODER_ENDPOINT" default:"https://transcoder.googleapis.com/v1"`
+}
+
+// Local represents the set of configurations for the local provider,
+// which runs ffmpeg on the orchestrator's own host. It's enabled by
+// setting the path to the ffmpeg binary.
+type Local struct {
+	FFmpegPath  string `envconfig:"LOCAL_FFMPEG_PATH"`
+	Destination string `envconfig:"LOCAL_DESTINATION"`
+}
+
+// Simulator represents the set of configurations for the simulator
+// provider, which transcodes nothing and moves jobs through their states
+// on a clock, for testing. Jobs wait in the queue and then run for the
+// given milliseconds. The rates are the share of jobs, from 0 to 1, that
+// fail, cancel themselves or answer every request after the latency.
+type Simulator struct {
+	Enabled     bool    `envconfig:"SIMULATOR_ENABLED"`
+	QueueTime   int     `envconfig:"SIMULATOR_QUEUE_MS" default:"2000"`
+	RunTime     int     `envconfig:"SIMULATOR_RUN_MS" default:"10000"`
+	Latency     int     `envconfig:"SIMULATOR_LATENCY_MS" default:"1000"`
+	FailRate    float64 `envconfig:"SIMULATOR_FAIL_RATE"`
+	CancelRate  float64 `envconfig:"SIMULATOR_CANCEL_RATE"`
+	LatencyRate float64 `envconfig:"SIMULATOR_LATENCY_RATE"`
+	Destination string  `envconfig:"SIMULATOR_DESTINATION" default:"s3://simulator"`
+}
+
+// Store represents the set of configurations for where jobs and their
+// audit events are kept. Type is "redis", the default, "sql", which uses
+// the database/sql driver named by SQLDriver, or "memory", which loses
+// every job on restart and isn't shared between instances. The sql driver
+// has to be linked into the binary.
+type Store struct {
+	Type      string `envconfig:"STORE" default:"redis"`
+	SQLDriver string `envconfig:"SQL_DRIVER"`
+	SQLDSN    string `envconfig:"SQL_DSN"`
+}
+
+// Redis represents the set of configurations for the Redis job store.
+// Setting SentinelMasterName selects sentinel mode and setting
+// ClusterAddrs selects cluster mode; otherwise Addr is used directly.
+type Redis struct {
+	Addr     string `envconfig:"REDIS_ADDR" default:"localhost:6379"`
+	Password string `envconfig:"REDIS_PASSWORD"`
+	DB       int    `envconfig:"REDIS_DB"`
+
+	TLS           bool   `envconfig:"REDIS_TLS"`
+	TLSCAFile     string `envconfig:"REDIS_TLS_CA_FILE"`
+	TLSServerName string `envconfig:"REDIS_TLS_SERVER_NAME"`
+
+	SentinelAddrs      []string `envconfig:"SENTINEL_ADDRS"`
+	SentinelMasterName string   `envconfig:"SENTINEL_MASTER_NAME"`
+	ClusterAddrs       []string `envconfig:"REDIS_CLUSTER_ADDRS"`
+
+	PoolSize           int `envconfig:"REDIS_POOL_SIZE"`
+	MinIdleConns       int `envconfig:"REDIS_MIN_IDLE_CONNS"`
+	PoolTimeout        int `envconfig:"REDIS_POOL_TIMEOUT_SECONDS"`
+	IdleTimeout        int `envconfig:"REDIS_IDLE_TIMEOUT_SECONDS"`
+	IdleCheckFrequency int `envconfig:"REDIS_IDLE_CHECK_FREQUENCY_SECONDS"`
+	DialTimeout        int `envconfig:"REDIS_DIAL_TIMEOUT_SECONDS"`
+	ReadTimeout        int `envconfig:"REDIS_READ_TIMEOUT_SECONDS"`
+	WriteTimeout       int `envconfig:"REDIS_WRITE_TIMEOUT_SECONDS"`
+}
+
+// Retention represents the set of configurations for how long jobs
+// are stored. Jobs that aren't finished, failed or canceled are kept
+// indefinitely. A TTL of zero keeps every job. Unless SweepInterval is
+// zero, the unfinished jobs created at least SweepAge minutes ago are
+// polled every SweepInterval minutes, at most SweepRate a second, so
+// they expire even if nobody asks for their status.
+type Retention struct {
+	TTL           int    `envconfig:"JOB_TTL_HOURS" default:"720"`
+	Archive       string `envconfig:"JOB_ARCHIVE_PATH"`
+	SweepInterval int    `envconfig:"JOB_SWEEP_INTERVAL_MINUTES" default:"15"`
+	SweepAge      int    `envconfig:"JOB_SWEEP_AGE_MINUTES" default:"60"`
+	SweepRate     int    `envconfig:"JOB_SWEEP_RATE" default:"1"`
+}
+
+// Secrets represents the set of configurations for resolving secret
+// references, like secret://hybrik/oapi, in the config and in job
+// storage aliases. Source is one of "env", "file" or "http". Job aliases
+// may only reference paths under AliasPrefix, so a job can't ask for the
+// provider credentials; an empty AliasPrefix allows them none.
+type Secrets struct {
+	Source      string `envconfig:"SECRETS_SOURCE" default:"env"`
+	Dir         string `envconfig:"SECRETS_DIR"`
+	URL         string `envconfig:"SECRETS_URL"`
+	Token       string `envconfig:"SECRETS_TOKEN"`
+	TTL         int    `envconfig:"SECRETS_TTL_SECONDS" default:"300"`
+	AliasPrefix string `envconfig:"SECRETS_ALIAS_PREFIX" default:"storage/"`
+}
+
+// Verify represents the set of configurations for checking that a
+// finished job's outputs exist. S3 is read with the default aws
+// credentials, and GCS with the service account key or the instance's
+// service account.
+type Verify struct {
+	Enabled           bool   `envconfig:"VERIFY_OUTPUTS"`
+	S3Region          string `envconfig:"VERIFY_S3_REGION"`
+	GCSCredentialsKey string `envconfig:"VERIFY_GCS_CREDENTIALS_KEY"`
+}
+
 // LoadConfig loads the configuration of the API using environment variables.
+// It doesn't validate it, use Load for that.
 func LoadConfig() *Config {
+	cfg, _ := load("")
+	return cfg
+}
+
+// Load loads the configuration from environment variables and then the json
+// file at path, if path isn't empty. Values in the file take precedence. The
+// configuration is validated and every problem found is returned as Errors.
+func Load(path string) (*Config, error) {
+	cfg, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func load(path string) (*Config, error) {
 	var cfg Config
-	envconfig.Process("", &cfg)
-	return &cfg
+	err := envconfig.Process("", &cfg)
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = os.Getenv("LOG_LEVEL")
+	}
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = "info"
+	}
+	if err != nil {
+		return &cfg, fmt.Errorf("config: env: %w", err)
+	}
+	if path == "" {
+		return &cfg, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return &cfg, fmt.Errorf("config: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return &cfg, fmt.Errorf("config: %s: %w", path, err)
+	}
+	return &cfg, nil
 }
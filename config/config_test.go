@@ -1,7 +1,10 @@
 package config
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -13,10 +16,19 @@ func TestLoadConfigFromEnv(t *testing.T) {
 	setEnvs(map[string]string{
 		"ENV":                                      "some_env",
 		"SENTRY_DSN":                               "some_dsn",
+		"TRACE_EXPORTER":                           "stdout",
+		"APP_LOG_LEVEL":                            "warn",
+		"LOG_LEVEL":                                "debug",
+		"STORE":                                    "sql",
+		"SQL_DRIVER":                               "postgres",
+		"SQL_DSN":                                  "postgres://db/jobs",
 		"SENTINEL_ADDRS":                           "10.10.10.10:26379,10.10.10.11:26379,10.10.10.12:26379",
 		"SENTINEL_MASTER_NAME":                     "super-master",
 		"REDIS_ADDR":                               "localhost:6379",
 		"REDIS_PASSWORD":                           "super-secret",
+		"REDIS_DB":                                 "2",
+		"REDIS_TLS":                                "true",
+		"REDIS_TLS_CA_FILE":                        "/etc/redis/ca.pem",
 		"REDIS_POOL_SIZE":                          "100",
 		"REDIS_POOL_TIMEOUT_SECONDS":               "10",
 		"ENCODINGCOM_USER_ID":                      "myuser",
@@ -54,10 +66,29 @@ func TestLoadConfigFromEnv(t *testing.T) {
 		"MEDIACONVERT_DESTINATION":                 "s3://mc-destination/",
 		"FLOCK_ENDPOINT":                           "https://flock.domain",
 		"FLOCK_CREDENTIAL":                         "secret-token",
+		"GOOGLETRANSCODER_PROJECT_ID":              "my-project",
+		"GOOGLETRANSCODER_LOCATION":                "europe-west1",
+		"GOOGLETRANSCODER_DESTINATION":             "gs://gt-destination/",
+		"LOCAL_FFMPEG_PATH":                        "/usr/bin/ffmpeg",
+		"LOCAL_DESTINATION":                        "file:///var/media/",
+		"SIMULATOR_ENABLED":                        "true",
+		"SIMULATOR_RUN_MS":                         "500",
+		"SIMULATOR_FAIL_RATE":                      "0.25",
 		"SWAGGER_MANIFEST_PATH":                    "/opt/video-transcoding-api-swagger.json",
 		"HTTP_ACCESS_LOG":                          accessLog,
 		"HTTP_PORT":                                "8080",
 		"DEFAULT_SEGMENT_DURATION":                 "3",
+		"JOB_TTL_HOURS":                            "24",
+		"JOB_ARCHIVE_PATH":                         "/var/lib/transcode-orchestrator/archive",
+		"JOB_SWEEP_INTERVAL_MINUTES":               "0",
+		"JOB_SWEEP_AGE_MINUTES":                    "10",
+		"JOB_SWEEP_RATE":                           "5",
+		"SECRETS_SOURCE":                           "file",
+		"SECRETS_DIR":                              "/run/secrets",
+		"SECRETS_TTL_SECONDS":                      "60",
+		"SECRETS_ALIAS_PREFIX":                     "jobs/",
+		"VERIFY_OUTPUTS":                           "true",
+		"VERIFY_S3_REGION":                         "us-west-2",
 		"LOGGING_LEVEL":                            "debug",
 	})
 	cfg := LoadConfig()
@@ -65,18 +96,21 @@ func TestLoadConfigFromEnv(t *testing.T) {
 		DefaultSegmentDuration: 3,
 		Env:                    "some_env",
 		SentryDSN:              "some_dsn",
+		TraceExporter:          "stdout",
+		LogLevel:               "warn",
 		EncodingCom: &EncodingCom{
 			UserID:         "myuser",
 			UserKey:        "secret-key",
 			Destination:    "https://safe-stuff",
 			StatusEndpoint: "https://safe-status",
 			Region:         "sa-east-1",
+			Endpoint:       "https://manage.encoding.com",
 		},
 		Hybrik: &Hybrik{
 			ComplianceDate: "20170601",
 			PresetPath:     "transcoding-api-presets",
 		},
-		Zencoder: &Zencoder{},
+		Zencoder: &Zencoder{Endpoint: "https://app.zencoder.com/api/v2"},
 		ElasticTranscoder: &ElasticTranscoder{
 			AccessKeyID:     "AKIANOTREALLY",
 			SecretAccessKey: "secret-key",
@@ -117,6 +151,54 @@ func TestLoadConfigFromEnv(t *testing.T) {
 			Endpoint:   "https://flock.domain",
 			Credential: "secret-token",
 		},
+		GoogleTranscoder: &GoogleTranscoder{
+			ProjectID:   "my-project",
+			Location:    "europe-west1",
+			Destination: "gs://gt-destination/",
+			Endpoint:    "https://transcoder.googleapis.com/v1",
+		},
+		Local: &Local{
+			FFmpegPath:  "/usr/bin/ffmpeg",
+			Destination: "file:///var/media/",
+		},
+		Simulator: &Simulator{
+			Enabled:     true,
+			QueueTime:   2000,
+			RunTime:     500,
+			Latency:     1000,
+			FailRate:    0.25,
+			Destination: "s3://simulator",
+		},
+		Store: &Store{
+			Type:      "sql",
+			SQLDriver: "postgres",
+			SQLDSN:    "postgres://db/jobs",
+		},
+		Redis: &Redis{
+			Addr:               "localhost:6379",
+			Password:           "super-secret",
+			DB:                 2,
+			TLS:                true,
+			TLSCAFile:          "/etc/redis/ca.pem",
+			SentinelAddrs:      []string{"10.10.10.10:26379", "10.10.10.11:26379", "10.10.10.12:26379"},
+			SentinelMasterName: "super-master",
+			PoolSize:           100,
+			PoolTimeout:        10,
+		},
+		Retention: &Retention{
+			TTL:           24,
+			Archive:       "/var/lib/transcode-orchestrator/archive",
+			SweepInterval: 0,
+			SweepAge:      10,
+			SweepRate:     5,
+		},
+		Secrets: &Secrets{
+			Source:      "file",
+			Dir:         "/run/secrets",
+			TTL:         60,
+			AliasPrefix: "jobs/",
+		},
+		Verify: &Verify{Enabled: true, S3Region: "us-west-2"},
 	}
 	diff := cmp.Diff(*cfg, expectedCfg)
 	if diff != "" {
@@ -161,11 +243,14 @@ func TestLoadConfigFromEnvWithDefaults(t *testing.T) {
 	expectedCfg := Config{
 		Env:                    "dev",
 		DefaultSegmentDuration: 5,
+		TraceExporter:          "noop",
+		LogLevel:               "info",
 		EncodingCom: &EncodingCom{
 			UserID:         "myuser",
 			UserKey:        "secret-key",
 			Destination:    "https://safe-stuff",
 			StatusEndpoint: "http://status.encoding.com",
+			Endpoint:       "https://manage.encoding.com",
 		},
 		ElasticTranscoder: &ElasticTranscoder{
 			AccessKeyID:     "AKIANOTREALLY",
@@ -186,7 +271,7 @@ func TestLoadConfigFromEnvWithDefaults(t *testing.T) {
 			ComplianceDate: "20170601",
 			PresetPath:     "transcoding-api-presets",
 		},
-		Zencoder: &Zencoder{},
+		Zencoder: &Zencoder{Endpoint: "https://app.zencoder.com/api/v2"},
 		Bitmovin: &Bitmovin{
 			APIKey:           "secret-key",
 			Endpoint:         "https://api.bitmovin.com/v1/",
@@ -200,6 +285,31 @@ func TestLoadConfigFromEnvWithDefaults(t *testing.T) {
 		},
 		MediaConvert: &MediaConvert{},
 		Flock:        &Flock{},
+		GoogleTranscoder: &GoogleTranscoder{
+			Location: "us-central1",
+			Endpoint: "https://transcoder.googleapis.com/v1",
+		},
+		Local: &Local{},
+		Simulator: &Simulator{
+			QueueTime:   2000,
+			RunTime:     10000,
+			Latency:     1000,
+			Destination: "s3://simulator",
+		},
+		Store: &Store{Type: "redis"},
+		Redis: &Redis{
+			Addr:               "localhost:6379",
+			Password:           "super-secret",
+			SentinelAddrs:      []string{"10.10.10.10:26379", "10.10.10.11:26379", "10.10.10.12:26379"},
+			SentinelMasterName: "super-master",
+			PoolSize:           100,
+			PoolTimeout:        10,
+			IdleTimeout:        30,
+			IdleCheckFrequency: 20,
+		},
+		Retention: &Retention{TTL: 720, SweepInterval: 15, SweepAge: 60, SweepRate: 1},
+		Secrets:   &Secrets{Source: "env", TTL: 300, AliasPrefix: "storage/"},
+		Verify:    &Verify{},
 	}
 	diff := cmp.Diff(*cfg, expectedCfg)
 	if diff != "" {
@@ -207,8 +317,186 @@ func TestLoadConfigFromEnvWithDefaults(t *testing.T) {
 	}
 }
 
+func TestLoadConfigLogLevel(t *testing.T) {
+	for _, tt := range []struct {
+		env  map[string]string
+		want string
+	}{
+		{map[string]string{}, "info"},
+		{map[string]string{"LOG_LEVEL": "debug"}, "debug"},
+		{map[string]string{"APP_LOG_LEVEL": "error", "LOG_LEVEL": "debug"}, "error"},
+	} {
+		os.Clearenv()
+		setEnvs(tt.env)
+		if have := LoadConfig().LogLevel; have != tt.want {
+			t.Errorf("%v: have %q, want %q", tt.env, have, tt.want)
+		}
+	}
+}
+
 func setEnvs(envs map[string]string) {
 	for k, v := range envs {
 		os.Setenv(k, v)
 	}
 }
+
+func TestLoadFile(t *testing.T) {
+	os.Clearenv()
+	setEnvs(map[string]string{
+		"FLOCK_ENDPOINT":   "https://flock.env",
+		"FLOCK_CREDENTIAL": "env-token",
+		"JOB_TTL_HOURS":    "24",
+	})
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	ioutil.WriteFile(path, []byte(`{"Flock": {"Credential": "file-token"}, "Hybrik": {"PresetPath": "p"}}`), 0600)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Flock.Endpoint != "https://flock.env" || cfg.Flock.Credential != "file-token" {
+		t.Errorf("flock: file should override env: %+v", cfg.Flock)
+	}
+	if cfg.Hybrik.PresetPath != "p" || cfg.Hybrik.ComplianceDate != "20170601" || cfg.Retention.TTL != 24 {
+		t.Errorf("defaults and env lost: %+v %+v", cfg.Hybrik, cfg.Retention)
+	}
+
+	ioutil.WriteFile(path, []byte(`{"Flock": {"Credentail": "typo"}}`), 0600)
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "Credentail") {
+		t.Errorf("unknown field: have %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	os.Clearenv()
+	cfg := LoadConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("empty config: %v", err)
+	}
+	cfg.LogLevel = "loud"
+	cfg.DefaultSegmentDuration = 0
+	cfg.Hybrik.OAPIKey = "key"
+	cfg.MediaConvert.Endpoint = "mc-endpoint"
+	cfg.MediaConvert.AccessKeyID = "id"
+	cfg.Redis.ClusterAddrs = []string{"a:6379"}
+	cfg.Redis.DB = 1
+	cfg.Redis.ReadTimeout = -1
+	cfg.Redis.PoolSize = -1
+	cfg.Simulator.Enabled = true
+	cfg.Simulator.FailRate = 2
+	cfg.Simulator.RunTime = -1
+	cfg.Simulator.QueueTime = -1
+	cfg.Secrets.AliasPrefix = "storage"
+	cfg.Retention.TTL = -1
+
+	err := cfg.Validate()
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("want Errors, have %T: %v", err, err)
+	}
+	want := []string{
+		`log: bad level "loud"`,
+		"segment duration: must be positive",
+		"hybrik: url is required",
+		"hybrik: oapi secret is required",
+		"hybrik: auth key is required",
+		"hybrik: auth secret is required",
+		"mediaconvert: queue arn is required",
+		"mediaconvert: role arn is required",
+		`mediaconvert: bad endpoint "mc-endpoint"`,
+		"mediaconvert: access key id and secret access key must be set together",
+		"simulator: negative queue time",
+		"simulator: negative run time",
+		"simulator: fail rate 2 is not between 0 and 1",
+		"redis: cluster mode only supports db 0",
+		"redis: negative pool size",
+		"redis: negative read timeout",
+		`secrets: alias prefix "storage" doesn't end in /`,
+		"retention: negative ttl",
+	}
+	have := make([]string, len(errs))
+	for i, err := range errs {
+		have[i] = err.Error()
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("errors: -want +have\n%s", diff)
+	}
+}
+
+func TestValidateStore(t *testing.T) {
+	os.Clearenv()
+	for _, tt := range []struct {
+		store *Store
+		redis bool
+		want  string
+	}{
+		{&Store{Type: "redis"}, true, ""},
+		{&Store{Type: "redis"}, false, "redis: missing"},
+		{&Store{Type: "memory"}, false, ""},
+		{&Store{Type: "sql"}, false, "store: sql driver is required; store: sql dsn is required"},
+		{&Store{Type: "sql", SQLDriver: "nodb", SQLDSN: "x"}, false, `store: sql driver "nodb" isn't linked in`},
+		{&Store{Type: "etcd"}, true, `store: unknown type "etcd"`},
+	} {
+		cfg := LoadConfig()
+		cfg.Store = tt.store
+		if !tt.redis {
+			cfg.Redis = nil
+		}
+		have := ""
+		if err := cfg.Validate(); err != nil {
+			have = err.Error()
+		}
+		if have != tt.want {
+			t.Errorf("%+v: have %q, want %q", tt.store, have, tt.want)
+		}
+	}
+}
+
+func TestInstance(t *testing.T) {
+	os.Clearenv()
+	setEnvs(map[string]string{
+		"MEDIACONVERT_ENDPOINT":  "https://mc.us-east-1",
+		"MEDIACONVERT_QUEUE_ARN": "arn:east",
+		"MEDIACONVERT_ROLE_ARN":  "arn:role",
+	})
+	cfg := LoadConfig()
+	cfg.Instances = map[string]*Instance{
+		"mediaconvert-west": {Driver: "mediaconvert", MediaConvert: &MediaConvert{
+			Endpoint:        "https://mc.us-west-2",
+			DefaultQueueARN: "arn:west",
+		}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	west, err := cfg.Instance("mediaconvert-west")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := MediaConvert{Endpoint: "https://mc.us-west-2", DefaultQueueARN: "arn:west", Role: "arn:role"}
+	if *west.MediaConvert != want {
+		t.Errorf("instance: have %+v, want %+v", *west.MediaConvert, want)
+	}
+	if cfg.MediaConvert.Endpoint != "https://mc.us-east-1" {
+		t.Errorf("base section was modified: %+v", cfg.MediaConvert)
+	}
+
+	cfg.Instances["flock"] = &Instance{Driver: "flock", Flock: &Flock{Endpoint: "https://f", Credential: "c"}}
+	cfg.Instances["bogus"] = &Instance{Driver: "bogus"}
+	cfg.Instances["hybrik-2"] = &Instance{Driver: "hybrik"}
+	err = cfg.Validate()
+	for _, want := range []string{
+		`instance flock: name is taken by a driver`,
+		`instance bogus: unknown driver "bogus"`,
+		`instance hybrik-2: no credentials`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in %v", want, err)
+		}
+	}
+}
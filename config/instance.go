@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Drivers names the provider drivers that can have instances
+var Drivers = []string{"bitmovin", "elastictranscoder", "elementalconductor", "encodingcom", "flock", "googletranscoder", "hybrik", "local", "mediaconvert", "simulator", "zencoder"}
+
+// Instance is an extra, named instance of a provider driver, like
+// "mediaconvert-west". Only the section for its driver is used, and
+// fields left empty there are taken from the top-level section.
+type Instance struct {
+	Driver             string
+	Bitmovin           *Bitmovin           `json:",omitempty"`
+	ElasticTranscoder  *ElasticTranscoder  `json:",omitempty"`
+	ElementalConductor *ElementalConductor `json:",omitempty"`
+	EncodingCom        *EncodingCom        `json:",omitempty"`
+	Flock              *Flock              `json:",omitempty"`
+	GoogleTranscoder   *GoogleTranscoder   `json:",omitempty"`
+	Hybrik             *Hybrik             `json:",omitempty"`
+	Local              *Local              `json:",omitempty"`
+	MediaConvert       *MediaConvert       `json:",omitempty"`
+	Simulator          *Simulator          `json:",omitempty"`
+	Zencoder           *Zencoder           `json:",omitempty"`
+}
+
+// Instance returns a copy of c where the section for the named
+// instance's driver is replaced by the instance's section
+func (c *Config) Instance(name string) (*Config, error) {
+	in := c.Instances[name]
+	if in == nil {
+		return nil, fmt.Errorf("no instance %q", name)
+	}
+	cp := *c
+	switch in.Driver {
+	case "bitmovin":
+		cp.Bitmovin = &Bitmovin{}
+		inherit(cp.Bitmovin, in.Bitmovin, c.Bitmovin)
+	case "flock":
+		cp.Flock = &Flock{}
+		inherit(cp.Flock, in.Flock, c.Flock)
+	case "googletranscoder":
+		cp.GoogleTranscoder = &GoogleTranscoder{}
+		inherit(cp.GoogleTranscoder, in.GoogleTranscoder, c.GoogleTranscoder)
+	case "hybrik":
+		cp.Hybrik = &Hybrik{}
+		inherit(cp.Hybrik, in.Hybrik, c.Hybrik)
+	case "local":
+		cp.Local = &Local{}
+		inherit(cp.Local, in.Local, c.Local)
+	case "mediaconvert":
+		cp.MediaConvert = &MediaConvert{}
+		inherit(cp.MediaConvert, in.MediaConvert, c.MediaConvert)
+	case "simulator":
+		cp.Simulator = &Simulator{}
+		inherit(cp.Simulator, in.Simulator, c.Simulator)
+	case "elastictranscoder":
+		cp.ElasticTranscoder = &ElasticTranscoder{}
+		inherit(cp.ElasticTranscoder, in.ElasticTranscoder, c.ElasticTranscoder)
+	case "elementalconductor":
+		cp.ElementalConductor = &ElementalConductor{}
+		inherit(cp.ElementalConductor, in.ElementalConductor, c.ElementalConductor)
+	case "encodingcom":
+		cp.EncodingCom = &EncodingCom{}
+		inherit(cp.EncodingCom, in.EncodingCom, c.EncodingCom)
+	case "zencoder":
+		cp.Zencoder = &Zencoder{}
+		inherit(cp.Zencoder, in.Zencoder, c.Zencoder)
+	default:
+		return nil, fmt.Errorf("unknown driver %q", in.Driver)
+	}
+	return &cp, nil
+}
+
+// inherit sets dst to src, with its zero fields taken from base.
+// All three are pointers to the same struct type, src and base
+// may be nil.
+func inherit(dst, src, base interface{}) {
+	d := reflect.ValueOf(dst).Elem()
+	for _, v := range []interface{}{src, base} {
+		v := reflect.ValueOf(v)
+		if v.IsNil() {
+			continue
+		}
+		v = v.Elem()
+		for i := 0; i < d.NumField(); i++ {
+			if f := d.Field(i); f.IsZero() {
+				f.Set(v.Field(i))
+			}
+		}
+	}
+}
+
+// InstanceNames returns the names of the instances, sorted
+func (c *Config) InstanceNames() []string {
+	names := make([]string, 0, len(c.Instances))
+	for name := range c.Instances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
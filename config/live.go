@@ -0,0 +1,105 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Live holds the current configuration and reloads it from the same
+// environment and file when asked. A configuration that fails to load
+// or validate is rejected and the current one is kept. Fields that
+// aren't loaded, like Tracer, carry over to the new configuration.
+type Live struct {
+	path string
+	cur  atomic.Value
+	seen fileinfo // the file as it was at the first load
+}
+
+// NewLive loads the configuration from path, see Load
+func NewLive(path string) (*Live, error) {
+	seen := stat(path)
+	cfg, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	l := &Live{path: path, seen: seen}
+	l.cur.Store(cfg)
+	return l, nil
+}
+
+// Config returns the current configuration. Callers must
+// not modify it.
+func (l *Live) Config() *Config {
+	return l.cur.Load().(*Config)
+}
+
+// Set replaces the current configuration
+func (l *Live) Set(cfg *Config) {
+	l.cur.Store(cfg)
+}
+
+// Reload loads and validates the configuration again
+func (l *Live) Reload() error {
+	cfg, err := Load(l.path)
+	if err != nil {
+		return err
+	}
+	cfg.Tracer = l.Config().Tracer
+	l.Set(cfg)
+	return nil
+}
+
+// Watch reloads the configuration on SIGHUP, and when the file's size or
+// modification time changes, checking every poll. A failed reload, as of a
+// partly written file, is retried every poll until one succeeds. After each
+// reload fn, which may be nil, is called with the new configuration or the
+// error. Watch returns when ctx is done.
+func (l *Live) Watch(ctx context.Context, poll time.Duration, fn func(*Config, error)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	tick := time.NewTicker(poll)
+	defer tick.Stop()
+
+	last := l.seen
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		case <-tick.C:
+			if l.path == "" {
+				continue
+			}
+			if fi := stat(l.path); fi.size == last.size && fi.mod.Equal(last.mod) {
+				continue
+			}
+		}
+		fi := stat(l.path)
+		err := l.Reload()
+		if err == nil {
+			last = fi
+		}
+		if fn != nil {
+			fn(l.Config(), err)
+		}
+	}
+}
+
+type fileinfo struct {
+	size int64
+	mod  time.Time
+}
+
+func stat(path string) fileinfo {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileinfo{}
+	}
+	return fileinfo{fi.Size(), fi.ModTime()}
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zsiec/pkg/tracing"
+)
+
+func TestLive(t *testing.T) {
+	os.Clearenv()
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	write := func(endpoint, cred string) {
+		data := `{"Flock": {"Endpoint": "` + endpoint + `", "Credential": "` + cred + `"}}`
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("https://flock", "one")
+	live, err := NewLive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	traced := *live.Config()
+	traced.Tracer = tracing.NoopTracer{}
+	live.Set(&traced)
+
+	errc := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloaded := make(chan string, 10)
+	go live.Watch(ctx, 10*time.Millisecond, func(cfg *Config, err error) {
+		if err == nil {
+			reloaded <- cfg.Flock.Credential
+			return
+		}
+		select {
+		case errc <- err:
+		default:
+		}
+	})
+
+	write("https://flock", "second")
+	for i := 0; live.Config().Flock.Credential != "second"; i++ {
+		if i == 100 {
+			t.Fatalf("not reloaded: have %q", live.Config().Flock.Credential)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if live.Config().Tracer == nil {
+		t.Fatal("tracer was not carried over")
+	}
+	if have := <-reloaded; have != "second" {
+		t.Fatalf("fn got credential %q, want second", have)
+	}
+
+	write("not a url", "third")
+	select {
+	case err := <-errc:
+		if _, ok := err.(Errors); !ok {
+			t.Fatalf("want Errors, have %T: %v", err, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("invalid config was not reported")
+	}
+	if have := live.Config().Flock.Credential; have != "second" {
+		t.Fatalf("invalid config was applied: have %q", have)
+	}
+
+	// a fixed file with the size and time of the invalid one is still reloaded
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("https://a", "third")
+	if err := os.Chtimes(path, fi.ModTime(), fi.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; live.Config().Flock.Credential != "third"; i++ {
+		if i == 100 {
+			t.Fatalf("failed reload was not retried: have %q", live.Config().Flock.Credential)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
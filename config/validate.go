@@ -0,0 +1,403 @@
+package config
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// Errors is every problem found validating a configuration
+type Errors []error
+
+func (e Errors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+type report func(section, format string, v ...interface{})
+
+// Validate checks every section of the configuration, and every
+// instance, and returns all the problems it finds as Errors, or nil.
+// A provider section with none of its credentials set is disabled
+// and isn't checked.
+func (c *Config) Validate() error {
+	var e Errors
+	bad := func(section, format string, v ...interface{}) {
+		e = append(e, fmt.Errorf("%s: %s", section, fmt.Sprintf(format, v...)))
+	}
+	if _, err := logrus.ParseLevel(c.LogLevel); c.LogLevel != "" && err != nil {
+		bad("log", "bad level %q", c.LogLevel)
+	}
+	if c.DefaultSegmentDuration == 0 {
+		bad("segment duration", "must be positive")
+	}
+
+	for _, d := range Drivers {
+		drivers[d](bad, d, c)
+	}
+	for _, name := range c.InstanceNames() {
+		section := "instance " + name
+		if _, ok := drivers[name]; ok {
+			bad(section, "name is taken by a driver")
+		}
+		ic, err := c.Instance(name)
+		if err != nil {
+			bad(section, "%v", err)
+			continue
+		}
+		if !drivers[c.Instances[name].Driver](bad, section, ic) {
+			bad(section, "no credentials")
+		}
+	}
+
+	store := "redis"
+	if s := c.Store; s != nil && s.Type != "" {
+		store = s.Type
+	}
+	switch store {
+	case "redis", "memory":
+	case "sql":
+		required(bad, "store",
+			"sql driver", c.Store.SQLDriver,
+			"sql dsn", c.Store.SQLDSN,
+		)
+		if d := c.Store.SQLDriver; d != "" && !linked(d) {
+			bad("store", "sql driver %q isn't linked in", d)
+		}
+	default:
+		bad("store", "unknown type %q", store)
+	}
+
+	if r := c.Redis; r == nil {
+		if store == "redis" {
+			bad("redis", "missing")
+		}
+	} else {
+		if len(r.SentinelAddrs) > 0 != (r.SentinelMasterName != "") {
+			bad("redis", "sentinel addrs and master name must be set together")
+		}
+		if len(r.ClusterAddrs) > 0 && r.DB != 0 {
+			bad("redis", "cluster mode only supports db 0")
+		}
+		if r.TLSCAFile != "" && !r.TLS {
+			bad("redis", "tls ca file is set but tls is off")
+		}
+		for _, f := range []struct {
+			name string
+			v    int
+		}{
+			{"pool size", r.PoolSize},
+			{"min idle conns", r.MinIdleConns},
+			{"pool timeout", r.PoolTimeout},
+			{"idle timeout", r.IdleTimeout},
+			{"dial timeout", r.DialTimeout},
+			{"read timeout", r.ReadTimeout},
+			{"write timeout", r.WriteTimeout},
+		} {
+			if f.v < 0 {
+				bad("redis", "negative %s", f.name)
+			}
+		}
+	}
+
+	if s := c.Secrets; s != nil {
+		switch s.Source {
+		case "", "env":
+		case "file":
+			required(bad, "secrets", "dir", s.Dir)
+		case "http":
+			required(bad, "secrets", "url", s.URL)
+			endpoint(bad, "secrets", s.URL)
+		default:
+			bad("secrets", "unknown source %q", s.Source)
+		}
+		if s.TTL < 0 {
+			bad("secrets", "negative ttl")
+		}
+		if p := s.AliasPrefix; p != "" && !strings.HasSuffix(p, "/") {
+			bad("secrets", "alias prefix %q doesn't end in /", p)
+		}
+	}
+
+	if r := c.Retention; r != nil {
+		if r.TTL < 0 {
+			bad("retention", "negative ttl")
+		}
+		if r.SweepInterval < 0 || r.SweepAge < 0 {
+			bad("retention", "negative sweep interval or age")
+		}
+		if r.SweepRate <= 0 {
+			bad("retention", "sweep rate must be positive")
+		}
+	}
+
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// drivers check the section of the config for their driver
+// and report whether it's enabled
+var drivers = map[string]func(bad report, section string, c *Config) bool{
+	"hybrik": func(bad report, section string, c *Config) bool {
+		h := c.Hybrik
+		if h == nil {
+			bad(section, "missing")
+			return false
+		}
+		if !set(h.URL, h.OAPIKey, h.OAPISecret, h.AuthKey, h.AuthSecret) {
+			return false
+		}
+		required(bad, section,
+			"url", h.URL,
+			"oapi key", h.OAPIKey,
+			"oapi secret", h.OAPISecret,
+			"auth key", h.AuthKey,
+			"auth secret", h.AuthSecret,
+		)
+		endpoint(bad, section, h.URL)
+		if _, err := time.Parse("20060102", h.ComplianceDate); err != nil {
+			bad(section, "compliance date %q is not YYYYMMDD", h.ComplianceDate)
+		}
+		return true
+	},
+	"mediaconvert": func(bad report, section string, c *Config) bool {
+		m := c.MediaConvert
+		if m == nil {
+			bad(section, "missing")
+			return false
+		}
+		if !set(m.Endpoint, m.DefaultQueueARN, m.Role, m.AccessKeyID, m.SecretAccessKey) {
+			return false
+		}
+		required(bad, section,
+			"endpoint", m.Endpoint,
+			"queue arn", m.DefaultQueueARN,
+			"role arn", m.Role,
+		)
+		endpoint(bad, section, m.Endpoint)
+		pair(bad, section, m.AccessKeyID, m.SecretAccessKey)
+		return true
+	},
+	"bitmovin": func(bad report, section string, c *Config) bool {
+		b := c.Bitmovin
+		if b == nil {
+			bad(section, "missing")
+			return false
+		}
+		if !set(b.APIKey, b.AccessKeyID, b.SecretAccessKey, b.GCSAccessKeyID, b.GCSSecretAccessKey) {
+			return false
+		}
+		required(bad, section, "api key", b.APIKey)
+		endpoint(bad, section, b.Endpoint)
+		pair(bad, section, b.AccessKeyID, b.SecretAccessKey)
+		pair(bad, section+" gcs", b.GCSAccessKeyID, b.GCSSecretAccessKey)
+		return true
+	},
+	"elastictranscoder": func(bad report, section string, c *Config) bool {
+		e := c.ElasticTranscoder
+		if e == nil {
+			bad(section, "missing")
+			return false
+		}
+		// the aws credentials are shared with other clients, so
+		// only the pipeline enables the section
+		if !set(e.PipelineID) {
+			return false
+		}
+		pair(bad, section, e.AccessKeyID, e.SecretAccessKey)
+		return true
+	},
+	"elementalconductor": func(bad report, section string, c *Config) bool {
+		e := c.ElementalConductor
+		if e == nil {
+			bad(section, "missing")
+			return false
+		}
+		if !set(e.Host, e.UserLogin, e.APIKey) {
+			return false
+		}
+		required(bad, section, "host", e.Host)
+		if (e.UserLogin == "") != (e.APIKey == "") {
+			bad(section, "user login and api key must be set together")
+		}
+		if e.AuthExpires < 0 {
+			bad(section, "negative auth expires")
+		}
+		pair(bad, section, e.AccessKeyID, e.SecretAccessKey)
+		return true
+	},
+	"encodingcom": func(bad report, section string, c *Config) bool {
+		e := c.EncodingCom
+		if e == nil {
+			bad(section, "missing")
+			return false
+		}
+		if !set(e.UserID, e.UserKey) {
+			return false
+		}
+		required(bad, section,
+			"user id", e.UserID,
+			"user key", e.UserKey,
+		)
+		endpoint(bad, section, e.Endpoint)
+		endpoint(bad, section, e.StatusEndpoint)
+		return true
+	},
+	"zencoder": func(bad report, section string, c *Config) bool {
+		z := c.Zencoder
+		if z == nil {
+			bad(section, "missing")
+			return false
+		}
+		if !set(z.APIKey) {
+			return false
+		}
+		endpoint(bad, section, z.Endpoint)
+		return true
+	},
+	"flock": func(bad report, section string, c *Config) bool {
+		f := c.Flock
+		if f == nil {
+			bad(section, "missing")
+			return false
+		}
+		if !set(f.Endpoint, f.Credential) {
+			return false
+		}
+		required(bad, section,
+			"endpoint", f.Endpoint,
+			"credential", f.Credential,
+		)
+		endpoint(bad, section, f.Endpoint)
+		return true
+	},
+	"googletranscoder": func(bad report, section string, c *Config) bool {
+		g := c.GoogleTranscoder
+		if g == nil {
+			bad(section, "missing")
+			return false
+		}
+		if !set(g.ProjectID, g.CredentialsKey) {
+			return false
+		}
+		required(bad, section,
+			"project id", g.ProjectID,
+			"location", g.Location,
+		)
+		destination(bad, section, g.Destination, storage.GCS)
+		endpoint(bad, section, g.Endpoint)
+		return true
+	},
+	"local": func(bad report, section string, c *Config) bool {
+		l := c.Local
+		if l == nil {
+			bad(section, "missing")
+			return false
+		}
+		if !set(l.FFmpegPath, l.Destination) {
+			return false
+		}
+		required(bad, section, "ffmpeg path", l.FFmpegPath)
+		destination(bad, section, l.Destination, storage.File)
+		return true
+	},
+	"simulator": func(bad report, section string, c *Config) bool {
+		s := c.Simulator
+		if s == nil {
+			bad(section, "missing")
+			return false
+		}
+		if !s.Enabled {
+			return false
+		}
+		for _, f := range []struct {
+			name string
+			v    int
+		}{
+			{"queue time", s.QueueTime},
+			{"run time", s.RunTime},
+			{"latency", s.Latency},
+		} {
+			if f.v < 0 {
+				bad(section, "negative %s", f.name)
+			}
+		}
+		for _, f := range []struct {
+			name string
+			v    float64
+		}{
+			{"fail rate", s.FailRate},
+			{"cancel rate", s.CancelRate},
+			{"latency rate", s.LatencyRate},
+		} {
+			if f.v < 0 || f.v > 1 {
+				bad(section, "%s %v is not between 0 and 1", f.name, f.v)
+			}
+		}
+		destination(bad, section, s.Destination, storage.Schemes...)
+		return true
+	},
+}
+
+// linked reports whether the database/sql driver is registered
+func linked(driver string) bool {
+	for _, d := range sql.Drivers() {
+		if d == driver {
+			return true
+		}
+	}
+	return false
+}
+
+func set(v ...string) bool {
+	for _, v := range v {
+		if v != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// required reports every empty value in the name-value pairs
+func required(bad report, section string, kv ...string) {
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i+1] == "" {
+			bad(section, "%s is required", kv[i])
+		}
+	}
+}
+
+func pair(bad report, section, id, secret string) {
+	if (id == "") != (secret == "") {
+		bad(section, "access key id and secret access key must be set together")
+	}
+}
+
+// destination reports a destination url that isn't in one of schemes
+func destination(bad report, section, s string, schemes ...string) {
+	if s == "" {
+		return
+	}
+	if _, err := storage.Check(s, schemes...); err != nil {
+		bad(section, "destination: %v", err)
+	}
+}
+
+func endpoint(bad report, section, s string) {
+	if s == "" {
+		return
+	}
+	if u, err := url.Parse(s); err != nil || u.Scheme == "" || u.Host == "" {
+		bad(section, "bad endpoint %q", s)
+	}
+}
@@ -0,0 +1,202 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+)
+
+// Record is a job and its final status, archived before
+// the job is removed from the Store
+type Record struct {
+	Job      *job.Job    `json:"job"`
+	Status   *job.Status `json:"status"`
+	Archived time.Time   `json:"archived"`
+}
+
+// Archive keeps records of jobs that have left the Store
+type Archive interface {
+	Put(r Record) error
+
+	// Get returns the most recent record for the job id
+	Get(id string) (*Record, error)
+}
+
+// NewArchive returns an archive at the given destination. Only
+// local directories are supported, either as a path or a file:// url.
+func NewArchive(dst string) (*FileArchive, error) {
+	u, err := url.Parse(dst)
+	if err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+	switch u.Scheme {
+	case "":
+	case "file":
+		dst = u.Path
+	default:
+		return nil, fmt.Errorf("archive: unsupported destination %q", u.Scheme)
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+	return &FileArchive{Dir: dst}, nil
+}
+
+// FileArchive appends records as newline-delimited json to one
+// file per day. It indexes the file with the newest record of each
+// job, reading only what was appended since the last lookup, so a
+// lookup reads at most one file and a job that isn't archived none.
+type FileArchive struct {
+	Dir string
+
+	mu    sync.Mutex
+	read  map[string]int64  // bytes of each file already indexed
+	index map[string]string // job id to the newest file with its record
+}
+
+const archiveExt = ".ndjson"
+
+func (a *FileArchive) Put(r Record) error {
+	if r.Job == nil {
+		return fmt.Errorf("archive: no job")
+	}
+	if r.Archived.IsZero() {
+		r.Archived = time.Now()
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(a.Dir, "jobs-"+r.Archived.UTC().Format("2006-01-02")+archiveExt)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	fd, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = fd.Write(append(data, '\n')); err != nil {
+		fd.Close()
+		return err
+	}
+	return fd.Close()
+}
+
+func (a *FileArchive) Get(id string) (*Record, error) {
+	a.mu.Lock()
+	err := a.refresh()
+	name, ok := a.index[id]
+	size := a.read[name]
+	a.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	r, err := a.scan(filepath.Join(a.Dir, name), size, id)
+	if err == nil && r == nil {
+		err = ErrJobNotFound
+	}
+	return r, err
+}
+
+// refresh indexes the records appended to the files since it last ran,
+// by this or another process. The caller must hold the lock.
+func (a *FileArchive) refresh() error {
+	if a.index == nil {
+		a.read, a.index = map[string]int64{}, map[string]string{}
+	}
+	dir, err := ioutil.ReadDir(a.Dir)
+	if err != nil {
+		return err
+	}
+	files := []os.FileInfo{}
+	for _, fi := range dir {
+		if strings.HasSuffix(fi.Name(), archiveExt) && fi.Size() > a.read[fi.Name()] {
+			files = append(files, fi)
+		}
+	}
+	// the date in the name sorts lexically, oldest first so newer
+	// records replace older ones
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+	for _, fi := range files {
+		if err := a.indexFile(fi.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexFile indexes the complete lines of the file past what was
+// already read
+func (a *FileArchive) indexFile(name string) error {
+	fd, err := os.Open(filepath.Join(a.Dir, name))
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	if _, err = fd.Seek(a.read[name], io.SeekStart); err != nil {
+		return err
+	}
+	br := bufio.NewReader(fd)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			// a partial line is still being written
+			return nil
+		} else if err != nil {
+			return err
+		}
+		a.read[name] += int64(len(line))
+		var r struct {
+			Job struct {
+				ID string `json:"id"`
+			} `json:"job"`
+		}
+		if err := json.Unmarshal(line, &r); err != nil {
+			return fmt.Errorf("archive: %s: %w", name, err)
+		}
+		a.index[r.Job.ID] = name
+	}
+}
+
+// scan returns the last record for id in the first size bytes of the
+// file, if any
+func (a *FileArchive) scan(name string, size int64, id string) (*Record, error) {
+	fd, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	var last *Record
+	sc := bufio.NewScanner(io.LimitReader(fd, size))
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if !bytes.Contains(line, []byte(id)) {
+			continue
+		}
+		r := &Record{}
+		if err := json.Unmarshal(line, r); err != nil {
+			return nil, fmt.Errorf("archive: %s: %w", filepath.Base(name), err)
+		}
+		if r.Job != nil && r.Job.ID == id {
+			last = r
+		}
+	}
+	return last, sc.Err()
+}
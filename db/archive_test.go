@@ -0,0 +1,82 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+)
+
+func TestFileArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, err := NewArchive("file://" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	put := func(id string, state job.State, when time.Time) {
+		t.Helper()
+		err := a.Put(Record{
+			Job:      &job.Job{ID: id},
+			Status:   &job.Status{ID: id, State: state},
+			Archived: when,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("a", job.StateFailed, day)
+	put("ab", job.StateFinished, day)
+	put("a", job.StateFinished, day.Add(24*time.Hour))
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 2 {
+		t.Fatalf("want one file per day, have %d files", len(files))
+	}
+
+	r, err := a.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Job.ID != "a" || r.Status.State != job.StateFinished {
+		t.Fatalf("get: want newest record, have %+v", r.Status)
+	}
+	if r, err = a.Get("ab"); err != nil || r.Job.ID != "ab" {
+		t.Fatalf("get ab: %v %+v", err, r)
+	}
+	if _, err = a.Get("b"); err != ErrJobNotFound {
+		t.Fatalf("get missing: have %v, want %v", err, ErrJobNotFound)
+	}
+	for _, fi := range files {
+		if n := a.read[fi.Name()]; n != fi.Size() {
+			t.Fatalf("%s: indexed %d of %d bytes", fi.Name(), n, fi.Size())
+		}
+	}
+
+	// records appended by another process, and a partly written one
+	other := &FileArchive{Dir: dir}
+	if err := other.Put(Record{Job: &job.Job{ID: "b"}, Status: &job.Status{ID: "b", State: job.StateCanceled}, Archived: day}); err != nil {
+		t.Fatal(err)
+	}
+	fd, _ := os.OpenFile(filepath.Join(dir, files[0].Name()), os.O_APPEND|os.O_WRONLY, 0644)
+	fd.WriteString(`{"job":{"id":"c"`)
+	fd.Close()
+	if r, err = a.Get("b"); err != nil || r.Status.State != job.StateCanceled {
+		t.Fatalf("get b from another process: %v %+v", err, r)
+	}
+	if _, err = a.Get("c"); err != ErrJobNotFound {
+		t.Fatalf("get partly written: have %v, want %v", err, ErrJobNotFound)
+	}
+
+	if _, err = NewArchive("s3://bucket/archive"); err == nil {
+		t.Fatalf("s3 destination: expected error")
+	}
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event is an audit record of a state-changing API call on a job
+type Event struct {
+	JobID  string    `json:"jobID"`
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+
+	Rid  uint64 `json:"rid"`
+	IP   string `json:"ip,omitempty"`
+	Port string `json:"port,omitempty"`
+	UA   string `json:"ua,omitempty"`
+
+	// User is the X-Forwarded-User header as the request had it. It's
+	// unauthenticated, and only as trustworthy as the proxy that set it.
+	User string `json:"user,omitempty"`
+
+	Payload json.RawMessage `json:"payload,omitempty"`
+	Err     string          `json:"err,omitempty"`
+}
+
+// Audit is an append-only log of events
+type Audit interface {
+	Append(e Event) error
+
+	// Events returns every event for the job, oldest first
+	Events(jobID string) ([]Event, error)
+}
+
+func (m *Memory) Append(e Event) error {
+	m.Lock()
+	defer m.Unlock()
+	if m.audit == nil {
+		m.audit = map[string][]Event{}
+	}
+	m.audit[e.JobID] = append(m.audit[e.JobID], e)
+	return nil
+}
+
+func (m *Memory) Events(jobID string) ([]Event, error) {
+	m.Lock()
+	defer m.Unlock()
+	return append([]Event{}, m.audit[jobID]...), nil
+}
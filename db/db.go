@@ -1,58 +1,59 @@
 package db
 
 import (
-	"encoding/json"
 	"errors"
-	"net"
+	"sort"
+	"time"
 
-	"github.com/go-redis/redis"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
 )
 
 var (
 	ErrJobNotFound = errors.New("job not found")
 )
 
-type Options struct {
-	Addr string
-	DB   int
-}
+// Store persists jobs and their last known state
+type Store interface {
+	Get(id string) (*job.Job, error)
 
-func NewClient(opt *Options) (*Client, error) {
-	if opt == nil {
-		opt = &Options{}
-	}
-	if opt.Addr == "" {
-		opt.Addr = "localhost:6379"
-	}
-	_, _, err := net.SplitHostPort(opt.Addr)
-	if err != nil {
-		opt.Addr = net.JoinHostPort(opt.Addr, "6379")
-	}
-	f := &Client{
-		rc: redis.NewClient(&redis.Options{
-			Addr:     opt.Addr,
-			DB:       opt.DB,
-			Password: "",
-		}),
-	}
-	return f, nil
-}
+	// Put stores the job, replacing any job with its id,
+	// and clears its expiry
+	Put(j *job.Job) error
 
-type Client struct {
-	rc *redis.Client
-}
+	// List returns at most limit jobs, newest first, skipping
+	// the first offset jobs. A limit <= 0 returns every job.
+	// Expired jobs are neither returned nor counted in offset,
+	// so only the last page is short.
+	List(offset, limit int) ([]*job.Job, error)
 
-func (c *Client) Get(key string, dst interface{}) (err error) {
-	val, err := c.rc.Get(key).Result()
-	if err == redis.Nil {
-		return ErrJobNotFound
-	} else if err != nil {
-		return err
-	}
-	return json.Unmarshal([]byte(val), dst)
+	// SetState updates the state of an existing job,
+	// keeping its expiry
+	SetState(id string, state job.State) error
+
+	// Expire removes the job, and its audit events if the store
+	// keeps them, after ttl elapses. A ttl <= 0 removes them
+	// immediately.
+	Expire(id string, ttl time.Duration) error
 }
 
-func (c *Client) Put(key string, val interface{}) (err error) {
-	data, _ := json.Marshal(val)
-	return c.rc.Set(key, string(data), 0).Err()
+// page sorts the jobs newest first and slices the result
+// according to offset and limit
+func page(list []*job.Job, offset, limit int) []*job.Job {
+	sort.Slice(list, func(i, j int) bool {
+		if a, b := list[i].CreatedAt, list[j].CreatedAt; !a.Equal(b) {
+			return a.After(b)
+		}
+		return list[i].ID < list[j].ID
+	})
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(list) {
+		return []*job.Job{}
+	}
+	list = list[offset:]
+	if limit > 0 && limit < len(list) {
+		list = list[:limit]
+	}
+	return list
 }
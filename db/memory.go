@@ -0,0 +1,111 @@
+package db
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+)
+
+// NewMemory returns an empty in-memory Store. It is meant for
+// tests and local development, nothing is persisted.
+func NewMemory() *Memory {
+	return &Memory{
+		jobs:     map[string][]byte{},
+		deadline: map[string]time.Time{},
+	}
+}
+
+// Memory is an in-memory Store. Jobs are kept serialized so
+// callers never share state with the store.
+type Memory struct {
+	sync.Mutex
+	jobs     map[string][]byte
+	deadline map[string]time.Time
+	audit    map[string][]Event
+}
+
+// sweep removes expired jobs, the caller must hold the lock
+func (m *Memory) sweep() {
+	now := time.Now()
+	for id, t := range m.deadline {
+		if !now.Before(t) {
+			delete(m.jobs, id)
+			delete(m.deadline, id)
+			delete(m.audit, id)
+		}
+	}
+}
+
+func (m *Memory) Get(id string) (*job.Job, error) {
+	m.Lock()
+	m.sweep()
+	data, ok := m.jobs[id]
+	m.Unlock()
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	j := &job.Job{}
+	return j, json.Unmarshal(data, j)
+}
+
+func (m *Memory) Put(j *job.Job) error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	m.Lock()
+	m.jobs[j.ID] = data
+	delete(m.deadline, j.ID)
+	m.Unlock()
+	return nil
+}
+
+func (m *Memory) List(offset, limit int) ([]*job.Job, error) {
+	m.Lock()
+	defer m.Unlock()
+	m.sweep()
+	list := make([]*job.Job, 0, len(m.jobs))
+	for _, data := range m.jobs {
+		j := &job.Job{}
+		if err := json.Unmarshal(data, j); err != nil {
+			return nil, err
+		}
+		list = append(list, j)
+	}
+	return page(list, offset, limit), nil
+}
+
+func (m *Memory) SetState(id string, state job.State) error {
+	m.Lock()
+	defer m.Unlock()
+	m.sweep()
+	data, ok := m.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	j := &job.Job{}
+	if err := json.Unmarshal(data, j); err != nil {
+		return err
+	}
+	j.State = state
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	m.jobs[id] = data
+	return nil
+}
+
+func (m *Memory) Expire(id string, ttl time.Duration) error {
+	m.Lock()
+	defer m.Unlock()
+	m.sweep()
+	if _, ok := m.jobs[id]; !ok {
+		return ErrJobNotFound
+	}
+	m.deadline[id] = time.Now().Add(ttl)
+	m.sweep()
+	return nil
+}
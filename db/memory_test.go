@@ -0,0 +1,94 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+)
+
+func TestMemory(t *testing.T) {
+	var s Store = NewMemory()
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a", "b", "c"} {
+		err := s.Put(&job.Job{ID: id, Provider: "fake", CreatedAt: t0.Add(time.Duration(i) * time.Hour)})
+		if err != nil {
+			t.Fatalf("put %q: %v", id, err)
+		}
+	}
+
+	if _, err := s.Get("missing"); err != ErrJobNotFound {
+		t.Fatalf("get missing: have %v, want %v", err, ErrJobNotFound)
+	}
+	if err := s.SetState("missing", job.StateFailed); err != ErrJobNotFound {
+		t.Fatalf("setstate missing: have %v, want %v", err, ErrJobNotFound)
+	}
+
+	if err := s.SetState("b", job.StateStarted); err != nil {
+		t.Fatalf("setstate: %v", err)
+	}
+	j, err := s.Get("b")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if j.State != job.StateStarted || j.Provider != "fake" {
+		t.Fatalf("get: bad job: %+v", j)
+	}
+
+	for _, tt := range []struct {
+		offset, limit int
+		want          string
+	}{
+		{0, 0, "cba"},
+		{0, 2, "cb"},
+		{1, 1, "b"},
+		{2, 5, "a"},
+		{3, 1, ""},
+	} {
+		list, err := s.List(tt.offset, tt.limit)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		have := ""
+		for _, j := range list {
+			have += j.ID
+		}
+		if have != tt.want {
+			t.Errorf("list(%d, %d): have %q, want %q", tt.offset, tt.limit, have, tt.want)
+		}
+	}
+}
+
+func TestMemoryExpire(t *testing.T) {
+	s := NewMemory()
+	for _, id := range []string{"keep", "soon", "now"} {
+		s.Put(&job.Job{ID: id})
+		s.Append(Event{JobID: id, Action: "create"})
+	}
+	if err := s.Expire("missing", time.Hour); err != ErrJobNotFound {
+		t.Fatalf("expire missing: have %v, want %v", err, ErrJobNotFound)
+	}
+	if err := s.Expire("soon", 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Expire("now", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("now"); err != ErrJobNotFound {
+		t.Fatalf("get expired: have %v, want %v", err, ErrJobNotFound)
+	}
+	if events, _ := s.Events("now"); len(events) != 0 {
+		t.Fatalf("audit left after the job expired: %+v", events)
+	}
+	if events, _ := s.Events("keep"); len(events) != 1 {
+		t.Fatalf("audit of a kept job: %+v", events)
+	}
+	if _, err := s.Get("soon"); err != nil {
+		t.Fatalf("get before deadline: %v", err)
+	}
+	time.Sleep(80 * time.Millisecond)
+	list, _ := s.List(0, 0)
+	if len(list) != 1 || list[0].ID != "keep" {
+		t.Fatalf("list after deadline: have %v, want [keep]", list)
+	}
+}
@@ -0,0 +1,371 @@
+package db
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/go-redis/redis"
+)
+
+// index is a sorted set of job ids scored by their creation time, and
+// expiring a sorted set of the ids of jobs with a ttl, scored by the time
+// they expire
+const (
+	index    = "jobs"
+	expiring = "jobs:expiring"
+)
+
+// Options configures the Redis client. Setting MasterName selects
+// sentinel mode and setting ClusterAddrs selects cluster mode.
+type Options struct {
+	Addr     string
+	DB       int
+	Password string
+	TLS      *tls.Config
+
+	SentinelAddrs []string
+	MasterName    string
+	ClusterAddrs  []string
+
+	PoolSize           int
+	MinIdleConns       int
+	PoolTimeout        time.Duration
+	IdleTimeout        time.Duration
+	IdleCheckFrequency time.Duration
+	DialTimeout        time.Duration
+	ReadTimeout        time.Duration
+	WriteTimeout       time.Duration
+}
+
+// OptionsFrom converts the redis configuration into client options,
+// loading the custom certificate authority if there is one
+func OptionsFrom(c *config.Redis) (*Options, error) {
+	if c == nil {
+		return &Options{}, nil
+	}
+	sec := func(n int) time.Duration { return time.Duration(n) * time.Second }
+	opt := &Options{
+		Addr:               c.Addr,
+		DB:                 c.DB,
+		Password:           c.Password,
+		SentinelAddrs:      c.SentinelAddrs,
+		MasterName:         c.SentinelMasterName,
+		ClusterAddrs:       c.ClusterAddrs,
+		PoolSize:           c.PoolSize,
+		MinIdleConns:       c.MinIdleConns,
+		PoolTimeout:        sec(c.PoolTimeout),
+		IdleTimeout:        sec(c.IdleTimeout),
+		IdleCheckFrequency: sec(c.IdleCheckFrequency),
+		DialTimeout:        sec(c.DialTimeout),
+		ReadTimeout:        sec(c.ReadTimeout),
+		WriteTimeout:       sec(c.WriteTimeout),
+	}
+	if c.TLS || c.TLSCAFile != "" {
+		opt.TLS = &tls.Config{ServerName: c.TLSServerName}
+		if c.TLSCAFile != "" {
+			pem, err := ioutil.ReadFile(c.TLSCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("redis: tls: %w", err)
+			}
+			opt.TLS.RootCAs = x509.NewCertPool()
+			if !opt.TLS.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("redis: tls: no certificates in %q", c.TLSCAFile)
+			}
+		}
+	}
+	return opt, nil
+}
+
+func NewClient(opt *Options) (*Client, error) {
+	if opt == nil {
+		opt = &Options{}
+	}
+	if opt.Addr == "" {
+		opt.Addr = "localhost:6379"
+	}
+	_, _, err := net.SplitHostPort(opt.Addr)
+	if err != nil {
+		opt.Addr = net.JoinHostPort(opt.Addr, "6379")
+	}
+
+	var rc redis.UniversalClient
+	switch {
+	case opt.MasterName != "":
+		rc = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:         opt.MasterName,
+			SentinelAddrs:      opt.SentinelAddrs,
+			DB:                 opt.DB,
+			Password:           opt.Password,
+			TLSConfig:          opt.TLS,
+			PoolSize:           opt.PoolSize,
+			MinIdleConns:       opt.MinIdleConns,
+			PoolTimeout:        opt.PoolTimeout,
+			IdleTimeout:        opt.IdleTimeout,
+			IdleCheckFrequency: opt.IdleCheckFrequency,
+			DialTimeout:        opt.DialTimeout,
+			ReadTimeout:        opt.ReadTimeout,
+			WriteTimeout:       opt.WriteTimeout,
+		})
+	case len(opt.ClusterAddrs) > 0:
+		if opt.DB != 0 {
+			return nil, errors.New("redis: cluster mode only supports database 0")
+		}
+		rc = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:              opt.ClusterAddrs,
+			Password:           opt.Password,
+			TLSConfig:          opt.TLS,
+			PoolSize:           opt.PoolSize,
+			MinIdleConns:       opt.MinIdleConns,
+			PoolTimeout:        opt.PoolTimeout,
+			IdleTimeout:        opt.IdleTimeout,
+			IdleCheckFrequency: opt.IdleCheckFrequency,
+			DialTimeout:        opt.DialTimeout,
+			ReadTimeout:        opt.ReadTimeout,
+			WriteTimeout:       opt.WriteTimeout,
+		})
+	default:
+		rc = redis.NewClient(&redis.Options{
+			Addr:               opt.Addr,
+			DB:                 opt.DB,
+			Password:           opt.Password,
+			TLSConfig:          opt.TLS,
+			PoolSize:           opt.PoolSize,
+			MinIdleConns:       opt.MinIdleConns,
+			PoolTimeout:        opt.PoolTimeout,
+			IdleTimeout:        opt.IdleTimeout,
+			IdleCheckFrequency: opt.IdleCheckFrequency,
+			DialTimeout:        opt.DialTimeout,
+			ReadTimeout:        opt.ReadTimeout,
+			WriteTimeout:       opt.WriteTimeout,
+		})
+	}
+	return &Client{rc: rc}, nil
+}
+
+// Client is a Redis backed Store
+type Client struct {
+	rc redis.UniversalClient
+}
+
+// Ping returns an error if the server is unreachable
+func (c *Client) Ping() error {
+	return c.rc.Ping().Err()
+}
+
+// Close releases the client's connections
+func (c *Client) Close() error {
+	return c.rc.Close()
+}
+
+func (c *Client) Get(id string) (*job.Job, error) {
+	val, err := c.rc.Get(id).Result()
+	if err == redis.Nil {
+		return nil, ErrJobNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	j := &job.Job{}
+	return j, json.Unmarshal([]byte(val), j)
+}
+
+func (c *Client) Put(j *job.Job) error {
+	return c.put(j, 0)
+}
+
+// put stores the job, expiring it after ttl if it's positive
+func (c *Client) put(j *job.Job, ttl time.Duration) error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	// not a transaction: the job and the index can live
+	// on different slots in cluster mode
+	_, err = c.rc.Pipelined(func(tx redis.Pipeliner) error {
+		tx.Set(j.ID, string(data), ttl)
+		tx.ZAdd(index, redis.Z{Score: float64(j.CreatedAt.UnixNano()), Member: j.ID})
+		if ttl > 0 {
+			tx.ZAdd(expiring, expiry(j.ID, ttl))
+			tx.PExpire(auditKey(j.ID), ttl)
+		} else {
+			tx.ZRem(expiring, j.ID)
+			tx.Persist(auditKey(j.ID))
+		}
+		return nil
+	})
+	return err
+}
+
+// expiry is the member of expiring for a job that expires after ttl
+func expiry(id string, ttl time.Duration) redis.Z {
+	return redis.Z{Score: float64(time.Now().Add(ttl).UnixNano()), Member: id}
+}
+
+// List drops the expired jobs from the index before it reads it, so
+// offsets count only the jobs that are still stored. A job missing
+// anyway, as after an eviction, is skipped and the page is filled from
+// the jobs after it.
+func (c *Client) List(offset, limit int) ([]*job.Job, error) {
+	if err := c.prune(); err != nil {
+		return nil, err
+	}
+	list := []*job.Job{}
+	var missing []interface{}
+	for start := int64(offset); limit <= 0 || len(list) < limit; {
+		stop := int64(-1)
+		if limit > 0 {
+			stop = start + int64(limit-len(list)) - 1
+		}
+		ids, err := c.rc.ZRevRange(index, start, stop).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		start += int64(len(ids))
+		// MGET fails across cluster slots, so pipeline the reads
+		cmds := make([]*redis.StringCmd, len(ids))
+		_, err = c.rc.Pipelined(func(p redis.Pipeliner) error {
+			for i, id := range ids {
+				cmds[i] = p.Get(id)
+			}
+			return nil
+		})
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		for i, cmd := range cmds {
+			s, err := cmd.Result()
+			if err == redis.Nil {
+				missing = append(missing, ids[i])
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			j := &job.Job{}
+			if err := json.Unmarshal([]byte(s), j); err != nil {
+				return nil, err
+			}
+			list = append(list, j)
+		}
+		if stop < 0 {
+			break
+		}
+	}
+	if len(missing) > 0 {
+		c.rc.ZRem(index, missing...)
+	}
+	return list, nil
+}
+
+// prune removes the jobs past their expiry from the index
+func (c *Client) prune() error {
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	ids, err := c.rc.ZRangeByScore(expiring, redis.ZRangeBy{Min: "-inf", Max: now}).Result()
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	members := make([]interface{}, len(ids))
+	for i, id := range ids {
+		members[i] = id
+	}
+	_, err = c.rc.Pipelined(func(p redis.Pipeliner) error {
+		p.ZRem(index, members...)
+		p.ZRem(expiring, members...)
+		return nil
+	})
+	return err
+}
+
+func (c *Client) SetState(id string, state job.State) error {
+	j, err := c.Get(id)
+	if err != nil {
+		return err
+	}
+	// SET clears the ttl, so set what's left of it again. PTTL
+	// is negative if the job has none.
+	ttl, err := c.rc.PTTL(id).Result()
+	if err != nil {
+		return err
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	j.State = state
+	return c.put(j, ttl)
+}
+
+func (c *Client) Expire(id string, ttl time.Duration) error {
+	if ttl <= 0 {
+		_, err := c.rc.Pipelined(func(p redis.Pipeliner) error {
+			// a DEL of both keys fails across cluster slots
+			p.Del(id)
+			p.Del(auditKey(id))
+			p.ZRem(index, id)
+			p.ZRem(expiring, id)
+			return nil
+		})
+		return err
+	}
+	ok, err := c.rc.Expire(id, ttl).Result()
+	if err == nil && !ok {
+		return ErrJobNotFound
+	}
+	if err != nil {
+		return err
+	}
+	_, err = c.rc.Pipelined(func(p redis.Pipeliner) error {
+		p.ZAdd(expiring, expiry(id, ttl))
+		p.PExpire(auditKey(id), ttl)
+		return nil
+	})
+	return err
+}
+
+// the audit log is a list per job, it expires with the job
+func auditKey(jobID string) string { return "audit:" + jobID }
+
+func (c *Client) Append(e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	var ttl *redis.DurationCmd
+	_, err = c.rc.Pipelined(func(p redis.Pipeliner) error {
+		p.RPush(auditKey(e.JobID), string(data))
+		ttl = p.PTTL(e.JobID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// an event for a job that's expiring, as a cancel after it
+	// finished, mustn't outlive it
+	if d := ttl.Val(); d > 0 {
+		return c.rc.PExpire(auditKey(e.JobID), d).Err()
+	}
+	return nil
+}
+
+func (c *Client) Events(jobID string) ([]Event, error) {
+	vals, err := c.rc.LRange(auditKey(jobID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Event, len(vals))
+	for i, v := range vals {
+		if err := json.Unmarshal([]byte(v), &list[i]); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,415 @@
+package db
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOptionsFrom(t *testing.T) {
+	opt, err := OptionsFrom(&config.Redis{
+		Addr:               "redis:6380",
+		Password:           "p4ssw0rd",
+		DB:                 3,
+		SentinelAddrs:      []string{"a:26379", "b:26379"},
+		SentinelMasterName: "master",
+		PoolSize:           10,
+		PoolTimeout:        5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opt.Addr != "redis:6380" || opt.Password != "p4ssw0rd" || opt.DB != 3 {
+		t.Errorf("bad connection options: %+v", opt)
+	}
+	if opt.MasterName != "master" || len(opt.SentinelAddrs) != 2 {
+		t.Errorf("bad sentinel options: %+v", opt)
+	}
+	if opt.PoolSize != 10 || opt.PoolTimeout != 5*time.Second {
+		t.Errorf("bad pool options: %+v", opt)
+	}
+	if opt.TLS != nil {
+		t.Errorf("tls enabled without being configured")
+	}
+}
+
+func TestOptionsFromTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bad := filepath.Join(dir, "bad.pem")
+	ioutil.WriteFile(bad, []byte("not a certificate"), 0600)
+
+	opt, err := OptionsFrom(&config.Redis{TLS: true, TLSServerName: "redis.local"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opt.TLS == nil || opt.TLS.ServerName != "redis.local" {
+		t.Fatalf("bad tls config: %+v", opt.TLS)
+	}
+
+	if _, err = OptionsFrom(&config.Redis{TLSCAFile: filepath.Join(dir, "missing.pem")}); err == nil {
+		t.Errorf("missing ca file: expected error")
+	}
+	if _, err = OptionsFrom(&config.Redis{TLSCAFile: bad}); err == nil {
+		t.Errorf("bad ca file: expected error")
+	}
+}
+
+// fakeRedis is a redis server that keeps its data in memory and knows
+// the commands the store sends. Keys only expire when the test says.
+type fakeRedis struct {
+	net.Listener
+	sync.Mutex
+	str  map[string]string
+	list map[string][]string
+	zset map[string]map[string]float64
+	ttl  map[string]time.Duration
+}
+
+func newFakeRedis(t *testing.T) (*fakeRedis, *Client) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &fakeRedis{
+		Listener: ln,
+		str:      map[string]string{},
+		list:     map[string][]string{},
+		zset:     map[string]map[string]float64{},
+		ttl:      map[string]time.Duration{},
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+	c, err := NewClient(&Options{Addr: ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		ln.Close()
+	})
+	return r, c
+}
+
+// expire drops the key as redis would when its ttl runs out, and moves
+// its expiry in the store's expiring set, if it's there, to the past
+func (r *fakeRedis) expire(key string) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.str, key)
+	delete(r.list, key)
+	delete(r.ttl, key)
+	if _, ok := r.zset[expiring][key]; ok {
+		r.zset[expiring][key] = 0
+	}
+}
+
+// expiring reports whether the key is in the store's expiring set and
+// has a ttl
+func (r *fakeRedis) expiring(key string) (indexed, ttl bool) {
+	r.Lock()
+	defer r.Unlock()
+	_, indexed = r.zset[expiring][key]
+	_, ttl = r.ttl[key]
+	return indexed, ttl
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	for {
+		var n int
+		if _, err := fmt.Fscanf(br, "*%d\r\n", &n); err != nil {
+			return
+		}
+		args := make([]string, n)
+		for i := range args {
+			var size int
+			if _, err := fmt.Fscanf(br, "$%d\r\n", &size); err != nil {
+				return
+			}
+			buf := make([]byte, size+2)
+			if _, err := io.ReadFull(br, buf); err != nil {
+				return
+			}
+			args[i] = string(buf[:size])
+		}
+		r.Lock()
+		reply := r.do(strings.ToLower(args[0]), args[1:])
+		r.Unlock()
+		if _, err := conn.Write([]byte(resp(reply))); err != nil {
+			return
+		}
+	}
+}
+
+// resp encodes the reply: nil as a null bulk string, and strings
+// starting with + or - as status and error replies
+func resp(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "$-1\r\n"
+	case int:
+		return fmt.Sprintf(":%d\r\n", v)
+	case []string:
+		s := fmt.Sprintf("*%d\r\n", len(v))
+		for _, e := range v {
+			s += resp(e)
+		}
+		return s
+	case string:
+		if strings.HasPrefix(v, "+") || strings.HasPrefix(v, "-") {
+			return v + "\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	}
+	panic(fmt.Sprintf("resp: %T", v))
+}
+
+func (r *fakeRedis) exists(key string) bool {
+	_, s := r.str[key]
+	_, l := r.list[key]
+	return s || l
+}
+
+func (r *fakeRedis) do(cmd string, a []string) interface{} {
+	switch cmd {
+	case "ping":
+		return "+PONG"
+	case "get":
+		if v, ok := r.str[a[0]]; ok {
+			return v
+		}
+		return nil
+	case "set":
+		r.str[a[0]] = a[1]
+		delete(r.ttl, a[0])
+		if len(a) == 4 {
+			n, _ := strconv.Atoi(a[3])
+			unit := time.Second
+			if strings.EqualFold(a[2], "px") {
+				unit = time.Millisecond
+			}
+			r.ttl[a[0]] = time.Duration(n) * unit
+		}
+		return "+OK"
+	case "del":
+		n := 0
+		for _, k := range a {
+			if r.exists(k) {
+				n++
+			}
+			delete(r.str, k)
+			delete(r.list, k)
+			delete(r.ttl, k)
+		}
+		return n
+	case "expire", "pexpire":
+		if !r.exists(a[0]) {
+			return 0
+		}
+		n, _ := strconv.Atoi(a[1])
+		unit := time.Second
+		if cmd == "pexpire" {
+			unit = time.Millisecond
+		}
+		r.ttl[a[0]] = time.Duration(n) * unit
+		return 1
+	case "persist":
+		if _, ok := r.ttl[a[0]]; !ok {
+			return 0
+		}
+		delete(r.ttl, a[0])
+		return 1
+	case "pttl":
+		if !r.exists(a[0]) {
+			return -2
+		}
+		if d, ok := r.ttl[a[0]]; ok {
+			return int(d / time.Millisecond)
+		}
+		return -1
+	case "zadd":
+		z := r.zset[a[0]]
+		if z == nil {
+			z = map[string]float64{}
+			r.zset[a[0]] = z
+		}
+		score, _ := strconv.ParseFloat(a[1], 64)
+		_, ok := z[a[2]]
+		z[a[2]] = score
+		if ok {
+			return 0
+		}
+		return 1
+	case "zrem":
+		n := 0
+		for _, m := range a[1:] {
+			if _, ok := r.zset[a[0]][m]; ok {
+				delete(r.zset[a[0]], m)
+				n++
+			}
+		}
+		return n
+	case "zrevrange":
+		all := r.sorted(a[0])
+		for i, j := 0, len(all)-1; i < j; i, j = i+1, j-1 {
+			all[i], all[j] = all[j], all[i]
+		}
+		start, _ := strconv.Atoi(a[1])
+		stop, _ := strconv.Atoi(a[2])
+		if stop < 0 || stop >= len(all) {
+			stop = len(all) - 1
+		}
+		if start > stop {
+			return []string{}
+		}
+		return all[start : stop+1]
+	case "zrangebyscore":
+		max, _ := strconv.ParseFloat(a[2], 64)
+		ids := []string{}
+		for _, m := range r.sorted(a[0]) {
+			if r.zset[a[0]][m] <= max {
+				ids = append(ids, m)
+			}
+		}
+		return ids
+	case "rpush":
+		r.list[a[0]] = append(r.list[a[0]], a[1:]...)
+		return len(r.list[a[0]])
+	case "lrange":
+		return append([]string{}, r.list[a[0]]...)
+	}
+	return "-ERR unknown command " + cmd
+}
+
+// sorted returns the members of the sorted set, lowest score first
+func (r *fakeRedis) sorted(key string) []string {
+	z := r.zset[key]
+	all := make([]string, 0, len(z))
+	for m := range z {
+		all = append(all, m)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if z[all[i]] != z[all[j]] {
+			return z[all[i]] < z[all[j]]
+		}
+		return all[i] < all[j]
+	})
+	return all
+}
+
+func TestRedisAuditExpires(t *testing.T) {
+	r, c := newFakeRedis(t)
+	ttl := func(key string) bool {
+		r.Lock()
+		defer r.Unlock()
+		_, ok := r.ttl[key]
+		return ok
+	}
+	c.Put(&job.Job{ID: "a"})
+	c.Append(Event{JobID: "a", Action: "create"})
+	if ttl(auditKey("a")) {
+		t.Fatal("audit of a stored job expires")
+	}
+	c.Expire("a", time.Hour)
+	if !ttl(auditKey("a")) {
+		t.Fatal("audit outlives its expiring job")
+	}
+	c.Append(Event{JobID: "a", Action: "cancel"})
+	c.Put(&job.Job{ID: "a"})
+	if ttl(auditKey("a")) {
+		t.Fatal("audit of a job put again still expires")
+	}
+
+	c.Put(&job.Job{ID: "b"})
+	c.Expire("b", time.Hour)
+	c.Append(Event{JobID: "b", Action: "cancel"})
+	if !ttl(auditKey("b")) {
+		t.Fatal("audit started after the job's expiry doesn't expire")
+	}
+	c.Expire("b", 0)
+	if events, _ := c.Events("b"); len(events) != 0 {
+		t.Fatalf("audit left after the job was removed: %+v", events)
+	}
+}
+
+func TestRedisList(t *testing.T) {
+	r, c := newFakeRedis(t)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		j := &job.Job{ID: fmt.Sprint("j", i), CreatedAt: start.Add(time.Duration(i) * time.Minute)}
+		if err := c.Put(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids := func(list []*job.Job, err error) []string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := []string{}
+		for _, j := range list {
+			s = append(s, j.ID)
+		}
+		return s
+	}
+
+	// j4 expires by its ttl, and j3 goes missing without one
+	if err := c.Expire("j4", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	r.expire("j4")
+	r.expire("j3")
+
+	if diff := cmp.Diff([]string{"j5", "j2"}, ids(c.List(0, 2))); diff != "" {
+		t.Fatalf("first page: %s", diff)
+	}
+	if diff := cmp.Diff([]string{"j1", "j0"}, ids(c.List(2, 2))); diff != "" {
+		t.Fatalf("second page: %s", diff)
+	}
+	if diff := cmp.Diff([]string{"j5", "j2", "j1", "j0"}, ids(c.List(0, 0))); diff != "" {
+		t.Fatalf("every job: %s", diff)
+	}
+	if indexed, _ := r.expiring("j4"); indexed {
+		t.Fatal("expired job left in the expiring set")
+	}
+
+	// a job given a ttl and put again is no longer expiring
+	c.Expire("j2", time.Hour)
+	j, _ := c.Get("j2")
+	c.Put(j)
+	if indexed, ttl := r.expiring("j2"); indexed || ttl {
+		t.Fatal("put kept the expiry")
+	}
+	c.Expire("j1", time.Hour)
+	c.SetState("j1", job.StateFinished)
+	if indexed, ttl := r.expiring("j1"); !indexed || !ttl {
+		t.Fatal("set state dropped the expiry")
+	}
+}
@@ -0,0 +1,456 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+)
+
+func TestBind(t *testing.T) {
+	for _, tt := range []struct {
+		dollar bool
+		q      string
+		want   string
+	}{
+		{false, `SELECT data FROM jobs WHERE id = ?`, `SELECT data FROM jobs WHERE id = ?`},
+		{true, `SELECT data FROM jobs WHERE id = ?`, `SELECT data FROM jobs WHERE id = $1`},
+		{true, `UPDATE jobs SET state = ?, data = ? WHERE id = ?`, `UPDATE jobs SET state = $1, data = $2 WHERE id = $3`},
+		{true, `SELECT data FROM jobs WHERE ` + live, `SELECT data FROM jobs WHERE (expires_at = 0 OR expires_at > $1)`},
+		{true, `DELETE FROM jobs`, `DELETE FROM jobs`},
+	} {
+		s := &SQL{dollar: tt.dollar}
+		if have := s.bind(tt.q); have != tt.want {
+			t.Errorf("bind(%q) dollar=%v: have %q, want %q", tt.q, tt.dollar, have, tt.want)
+		}
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	for i, m := range migrations {
+		if strings.Contains(m, "?") {
+			t.Errorf("migration %d has a placeholder: %q", i+1, m)
+		}
+		if strings.Contains(m, ";") {
+			t.Errorf("migration %d is more than one statement: %q", i+1, m)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		version int64
+		fail    int // the migration that fails, from 1, or 0
+		want    []string
+		err     string
+	}{
+		{name: "new", want: applied(0, len(migrations))},
+		{name: "current", version: int64(len(migrations))},
+		{name: "behind", version: 3, want: applied(3, len(migrations))},
+		{
+			name: "failed", version: 3, fail: 5,
+			want: append(applied(3, 4), "BEGIN", migrations[4], "ROLLBACK"),
+			err:  "version 5: syntax error",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fakeOpen(t, func(q string, args []driver.Value) ([][]driver.Value, int64, error) {
+				switch {
+				case strings.HasPrefix(q, "SELECT COALESCE"):
+					return [][]driver.Value{{tt.version}}, 0, nil
+				case tt.fail > 0 && q == migrations[tt.fail-1]:
+					return nil, 0, errors.New("syntax error")
+				}
+				return nil, 1, nil
+			})
+			s := &SQL{db: c.db}
+			err := s.Migrate()
+			if have := fmt.Sprint(err); tt.err == "" && err != nil || tt.err != "" && have != tt.err {
+				t.Fatalf("have error %v, want %q", err, tt.err)
+			}
+			have := c.statements()
+			if !strings.HasPrefix(have[0], "CREATE TABLE IF NOT EXISTS schema_migrations") {
+				t.Fatalf("schema_migrations was not created first: %q", have)
+			}
+			if have = have[2:]; len(have) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(have, tt.want) {
+				t.Fatalf("statements:\nhave %q\nwant %q", have, tt.want)
+			}
+		})
+	}
+}
+
+// applied returns the statements that apply migrations from up to to
+func applied(from, to int) (s []string) {
+	for i := from; i < to; i++ {
+		s = append(s, "BEGIN", migrations[i], fmt.Sprintf("INSERT %d", i+1), "COMMIT")
+	}
+	return s
+}
+
+func TestOpenSQL(t *testing.T) {
+	c := fakeOpen(t, func(q string, args []driver.Value) ([][]driver.Value, int64, error) {
+		if strings.HasPrefix(q, "SELECT COALESCE") {
+			return [][]driver.Value{{int64(0)}}, 0, nil
+		}
+		return nil, 1, nil
+	})
+	s, err := OpenSQL("fake", c.dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.dollar {
+		t.Fatal("fake driver uses ? placeholders")
+	}
+	n := 0
+	for _, q := range c.statements() {
+		if strings.HasPrefix(q, "INSERT ") {
+			n++
+		}
+	}
+	if n != len(migrations) {
+		t.Fatalf("applied %d migrations, want %d", n, len(migrations))
+	}
+
+	bad := fakeOpen(t, func(q string, args []driver.Value) ([][]driver.Value, int64, error) {
+		return nil, 0, errors.New("no such table")
+	})
+	if _, err := OpenSQL("fake", bad.dsn); err == nil || !strings.HasPrefix(err.Error(), "migrate: ") {
+		t.Fatalf("have error %v, want a migrate error", err)
+	}
+	if !bad.closed() {
+		t.Fatal("connection was not closed after a failed migration")
+	}
+}
+
+func TestSQLGet(t *testing.T) {
+	c := fakeOpen(t, func(q string, args []driver.Value) ([][]driver.Value, int64, error) {
+		if args[0] == "a" {
+			return [][]driver.Value{{`{"id":"a","provider":"fake","state":"started"}`}}, 0, nil
+		}
+		return nil, 0, nil
+	})
+	s := &SQL{db: c.db, dollar: true}
+	j, err := s.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.ID != "a" || j.Provider != "fake" || j.State != job.StateStarted {
+		t.Fatalf("bad job: %+v", j)
+	}
+	if q := c.statements()[0]; q != `SELECT data FROM jobs WHERE id = $1 AND (expires_at = 0 OR expires_at > $2)` {
+		t.Fatalf("bad query: %q", q)
+	}
+	if _, err := s.Get("missing"); err != ErrJobNotFound {
+		t.Fatalf("get missing: have %v, want %v", err, ErrJobNotFound)
+	}
+}
+
+func TestSQLPut(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		exists bool
+		want   []string
+	}{
+		{"insert", false, []string{"BEGIN", "UPDATE jobs", "INSERT INTO jobs", "COMMIT"}},
+		{"update", true, []string{"BEGIN", "UPDATE jobs", "COMMIT"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var args []driver.Value
+			c := fakeOpen(t, func(q string, a []driver.Value) ([][]driver.Value, int64, error) {
+				if strings.HasPrefix(q, "INSERT") {
+					args = a
+				}
+				if tt.exists {
+					return nil, 1, nil
+				}
+				return nil, 0, nil
+			})
+			s := &SQL{db: c.db}
+			created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+			if err := s.Put(&job.Job{ID: "a", State: job.StateQueued, CreatedAt: created}); err != nil {
+				t.Fatal(err)
+			}
+			have := c.statements()
+			if len(have) != len(tt.want) {
+				t.Fatalf("statements: have %q, want %q", have, tt.want)
+			}
+			for i := range have {
+				if !strings.HasPrefix(have[i], tt.want[i]) {
+					t.Fatalf("statements: have %q, want %q", have, tt.want)
+				}
+			}
+			if tt.exists {
+				return
+			}
+			if len(args) != 4 || args[0] != "a" || args[1] != "queued" || args[2] != created.UnixNano() {
+				t.Fatalf("bad insert args: %v", args)
+			}
+		})
+	}
+}
+
+func TestSQLList(t *testing.T) {
+	var args []driver.Value
+	var query string
+	c := fakeOpen(t, func(q string, a []driver.Value) ([][]driver.Value, int64, error) {
+		query, args = q, a
+		return [][]driver.Value{{`{"id":"b"}`}, {`{"id":"a"}`}}, 0, nil
+	})
+	s := &SQL{db: c.db}
+	for _, tt := range []struct {
+		offset, limit int
+		limited       bool
+		want          []driver.Value
+	}{
+		{0, 0, false, nil},
+		{0, 2, true, []driver.Value{int64(2), int64(0)}},
+		{4, 2, true, []driver.Value{int64(2), int64(4)}},
+		{3, 0, true, []driver.Value{int64(1 << 62), int64(3)}},
+	} {
+		list, err := s.List(tt.offset, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].ID != "b" || list[1].ID != "a" {
+			t.Fatalf("list(%d, %d): bad jobs: %v", tt.offset, tt.limit, list)
+		}
+		if !strings.Contains(query, "ORDER BY created_at DESC, id ASC") {
+			t.Fatalf("list is not newest first: %q", query)
+		}
+		if have := strings.HasSuffix(query, "LIMIT ? OFFSET ?"); have != tt.limited {
+			t.Fatalf("list(%d, %d): limited %v, want %v: %q", tt.offset, tt.limit, have, tt.limited, query)
+		}
+		if have := args[1:]; len(have) != len(tt.want) || len(have) > 0 && !reflect.DeepEqual(have, tt.want) {
+			t.Fatalf("list(%d, %d): args %v, want %v", tt.offset, tt.limit, have, tt.want)
+		}
+	}
+}
+
+func TestSQLSetState(t *testing.T) {
+	var data driver.Value
+	c := fakeOpen(t, func(q string, a []driver.Value) ([][]driver.Value, int64, error) {
+		switch {
+		case strings.HasPrefix(q, "SELECT") && a[0] == "a":
+			return [][]driver.Value{{`{"id":"a","provider":"fake","state":"queued"}`}}, 0, nil
+		case strings.HasPrefix(q, "UPDATE"):
+			data = a[1]
+		}
+		return nil, 1, nil
+	})
+	s := &SQL{db: c.db}
+	if err := s.SetState("missing", job.StateFailed); err != ErrJobNotFound {
+		t.Fatalf("setstate missing: have %v, want %v", err, ErrJobNotFound)
+	}
+	if err := s.SetState("a", job.StateFinished); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := data.(string); !strings.Contains(d, `"state":"finished"`) || !strings.Contains(d, `"provider":"fake"`) {
+		t.Fatalf("bad data: %v", data)
+	}
+}
+
+func TestSQLExpire(t *testing.T) {
+	var update []driver.Value
+	c := fakeOpen(t, func(q string, a []driver.Value) ([][]driver.Value, int64, error) {
+		if strings.HasPrefix(q, "UPDATE") {
+			update = a
+			if a[1] == "missing" {
+				return nil, 0, nil
+			}
+		}
+		return nil, 1, nil
+	})
+	s := &SQL{db: c.db}
+	if err := s.Expire("missing", time.Hour); err != ErrJobNotFound {
+		t.Fatalf("expire missing: have %v, want %v", err, ErrJobNotFound)
+	}
+	before := now()
+	if err := s.Expire("a", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if at, _ := update[0].(int64); at < before+int64(time.Hour) || at > now()+int64(time.Hour) {
+		t.Fatalf("bad expiry: %v", update[0])
+	}
+	if err := s.Expire("b", 0); err != nil {
+		t.Fatal(err)
+	}
+	q := c.statements()
+	if want := []string{`DELETE FROM audit WHERE job_id = ?`, `DELETE FROM jobs WHERE id = ?`}; !reflect.DeepEqual(q[len(q)-2:], want) {
+		t.Fatalf("expire now: have %q, want %q", q[len(q)-2:], want)
+	}
+	for i := 0; i < len(q)-2; i += 3 {
+		if !strings.HasPrefix(q[i], "DELETE FROM audit WHERE job_id IN") || !strings.HasPrefix(q[i+1], "DELETE FROM jobs WHERE expires_at > 0") {
+			t.Fatalf("expired rows were not deleted first: %q", q)
+		}
+	}
+}
+
+func TestSQLAudit(t *testing.T) {
+	var appended []driver.Value
+	c := fakeOpen(t, func(q string, a []driver.Value) ([][]driver.Value, int64, error) {
+		if strings.HasPrefix(q, "INSERT") {
+			appended = a
+			return nil, 1, nil
+		}
+		return [][]driver.Value{
+			{`{"jobID":"a","action":"create"}`},
+			{`{"jobID":"a","action":"cancel"}`},
+		}, 0, nil
+	})
+	s := &SQL{db: c.db}
+	at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := s.Append(Event{JobID: "a", Time: at, Action: "create"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(appended) != 3 || appended[0] != "a" || appended[1] != at.UnixNano() {
+		t.Fatalf("bad append args: %v", appended)
+	}
+	list, err := s.Events("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Action != "create" || list[1].Action != "cancel" {
+		t.Fatalf("bad events: %+v", list)
+	}
+	if q := c.statements()[1]; !strings.HasSuffix(q, "ORDER BY time ASC") {
+		t.Fatalf("events are not oldest first: %q", q)
+	}
+}
+
+// fakeConn is a database/sql driver connection that records the
+// statements it runs, and answers them with reply, which returns the
+// rows of a query or the rows affected by an exec. Transactions are
+// recorded as BEGIN, COMMIT and ROLLBACK, and migration version inserts
+// as INSERT n.
+type fakeConn struct {
+	sync.Mutex
+	dsn    string
+	db     *sql.DB
+	reply  func(q string, args []driver.Value) ([][]driver.Value, int64, error)
+	log    []string
+	closes int
+}
+
+var (
+	fakeOnce  sync.Once
+	fakeMu    sync.Mutex
+	fakeConns = map[string]*fakeConn{}
+)
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	c, ok := fakeConns[dsn]
+	if !ok {
+		return nil, fmt.Errorf("fake: no connection %q", dsn)
+	}
+	return c, nil
+}
+
+// fakeOpen returns a connection answered by reply, and a *sql.DB on it
+func fakeOpen(t *testing.T, reply func(q string, args []driver.Value) ([][]driver.Value, int64, error)) *fakeConn {
+	fakeOnce.Do(func() { sql.Register("fake", fakeDriver{}) })
+	c := &fakeConn{dsn: t.Name(), reply: reply}
+	fakeMu.Lock()
+	fakeConns[c.dsn] = c
+	fakeMu.Unlock()
+	db, err := sql.Open("fake", c.dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	c.db = db
+	t.Cleanup(func() { db.Close() })
+	return c
+}
+
+func (c *fakeConn) statements() []string {
+	c.Lock()
+	defer c.Unlock()
+	return append([]string{}, c.log...)
+}
+
+func (c *fakeConn) closed() bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.closes > 0
+}
+
+func (c *fakeConn) record(q string) {
+	c.Lock()
+	c.log = append(c.log, q)
+	c.Unlock()
+}
+
+func (c *fakeConn) Prepare(q string) (driver.Stmt, error) { return &fakeStmt{c, q}, nil }
+func (c *fakeConn) Begin() (driver.Tx, error)             { c.record("BEGIN"); return c, nil }
+func (c *fakeConn) Commit() error                         { c.record("COMMIT"); return nil }
+func (c *fakeConn) Rollback() error                       { c.record("ROLLBACK"); return nil }
+
+func (c *fakeConn) Close() error {
+	c.Lock()
+	c.closes++
+	c.Unlock()
+	return nil
+}
+
+type fakeStmt struct {
+	c *fakeConn
+	q string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	q := s.q
+	if strings.HasPrefix(q, "INSERT INTO schema_migrations") {
+		q = fmt.Sprintf("INSERT %v", args[0])
+	}
+	s.c.record(q)
+	_, n, err := s.c.reply(s.q, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(n), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.c.record(s.q)
+	rows, _, err := s.c.reply(s.q, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{rows: rows}, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return []string{"data"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,187 @@
+// Package gcloud authenticates requests to the Google Cloud apis with a
+// service account key, or with the instance's service account when there
+// is no key.
+package gcloud
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	scope       = "https://www.googleapis.com/auth/cloud-platform"
+	metadataURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// TokenSource returns an oauth2 access token for the cloud apis
+type TokenSource interface {
+	Token(context.Context) (string, error)
+}
+
+// token is an access token with its expiry
+type token struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// cached reuses a token until shortly before it expires
+type cached struct {
+	fetch func(context.Context) (*token, error)
+	now   func() time.Time
+
+	sync.Mutex
+	tok    string
+	expiry time.Time
+}
+
+func (c *cached) Token(ctx context.Context) (string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.tok != "" && c.now().Before(c.expiry) {
+		return c.tok, nil
+	}
+	t, err := c.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.tok = t.AccessToken
+	c.expiry = c.now().Add(time.Duration(t.ExpiresIn)*time.Second - time.Minute)
+	return c.tok, nil
+}
+
+// serviceAccount is the part of a service account key used to sign assertions
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	key *rsa.PrivateKey
+}
+
+func parseKey(data string) (*serviceAccount, error) {
+	sa := &serviceAccount{}
+	if err := json.Unmarshal([]byte(data), sa); err != nil {
+		return nil, fmt.Errorf("parsing credentials key: %w", err)
+	}
+	if sa.ClientEmail == "" || sa.PrivateKey == "" {
+		return nil, errors.New("credentials key has no client email or private key")
+	}
+	if sa.TokenURI == "" {
+		sa.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return nil, errors.New("credentials key has no pem private key")
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if k, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("parsing private key: %w", err)
+		}
+	}
+	var ok bool
+	if sa.key, ok = k.(*rsa.PrivateKey); !ok {
+		return nil, errors.New("private key is not rsa")
+	}
+	return sa, nil
+}
+
+// assertion is the signed jwt exchanged for an access token
+func (sa *serviceAccount) assertion(now time.Time) (string, error) {
+	enc := base64.RawURLEncoding.EncodeToString
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   sa.ClientEmail,
+		"scope": scope,
+		"aud":   sa.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	msg := enc(header) + "." + enc(claims)
+	sum := sha256.Sum256([]byte(msg))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, sa.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return msg + "." + enc(sig), nil
+}
+
+// Tokens returns the token source for the service account key, a json
+// key file's contents, or for the instance's service account if key is
+// empty
+func Tokens(c *http.Client, key string) (TokenSource, error) {
+	if key == "" {
+		return metadata(c, time.Now), nil
+	}
+	sa, err := parseKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return sa.source(c, time.Now), nil
+}
+
+func (sa *serviceAccount) source(c *http.Client, now func() time.Time) TokenSource {
+	return &cached{now: now, fetch: func(ctx context.Context) (*token, error) {
+		a, err := sa.assertion(now())
+		if err != nil {
+			return nil, fmt.Errorf("signing assertion: %w", err)
+		}
+		form := url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {a},
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, sa.TokenURI, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return fetch(c, req)
+	}}
+}
+
+// metadata fetches tokens for the instance's service account
+func metadata(c *http.Client, now func() time.Time) TokenSource {
+	return &cached{now: now, fetch: func(ctx context.Context) (*token, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+		return fetch(c, req)
+	}}
+}
+
+func fetch(c *http.Client, req *http.Request) (*token, error) {
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching token: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching token: status %d: %s", resp.StatusCode, data)
+	}
+	t := &token{}
+	if err := json.Unmarshal(data, t); err != nil || t.AccessToken == "" {
+		return nil, fmt.Errorf("fetching token: no access token in response")
+	}
+	return t, nil
+}
@@ -0,0 +1,112 @@
+package gcloud
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServiceAccount(t *testing.T) {
+	k, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(k)
+	now := time.Unix(1600000000, 0)
+
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if g := r.FormValue("grant_type"); g != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			t.Errorf("grant type: %q", g)
+		}
+		part := strings.Split(r.FormValue("assertion"), ".")
+		if len(part) != 3 {
+			t.Fatalf("bad assertion: %q", r.FormValue("assertion"))
+		}
+		sig, _ := base64.RawURLEncoding.DecodeString(part[2])
+		sum := sha256.Sum256([]byte(part[0] + "." + part[1]))
+		if err := rsa.VerifyPKCS1v15(&k.PublicKey, crypto.SHA256, sum[:], sig); err != nil {
+			t.Errorf("bad signature: %v", err)
+		}
+		var claims map[string]interface{}
+		data, _ := base64.RawURLEncoding.DecodeString(part[1])
+		json.Unmarshal(data, &claims)
+		if claims["iss"] != "sa@proj.iam.gserviceaccount.com" || claims["scope"] != scope || claims["exp"].(float64)-claims["iat"].(float64) != 3600 {
+			t.Errorf("bad claims: %v", claims)
+		}
+		w.Write([]byte(`{"access_token": "tok", "expires_in": 3600, "token_type": "Bearer"}`))
+	}))
+	defer srv.Close()
+
+	key, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "sa@proj.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    srv.URL,
+	})
+	sa, err := parseKey(string(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := now
+	ts := sa.source(srv.Client(), func() time.Time { return clock })
+	for i := 0; i < 2; i++ {
+		if tok, err := ts.Token(context.Background()); err != nil || tok != "tok" {
+			t.Fatalf("have %q, %v", tok, err)
+		}
+	}
+	if fetches != 1 {
+		t.Fatalf("token fetched %d times, want once", fetches)
+	}
+	clock = clock.Add(time.Hour)
+	ts.Token(context.Background())
+	if fetches != 2 {
+		t.Fatal("expired token wasn't refreshed")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	for name, key := range map[string]string{
+		"JSON":  `not json`,
+		"Email": `{"private_key": "x"}`,
+		"PEM":   `{"client_email": "a", "private_key": "not pem"}`,
+	} {
+		if _, err := parseKey(key); err == nil {
+			t.Errorf("%s: parsed a bad key", name)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	for _, tt := range []struct {
+		code int
+		body string
+		ok   bool
+	}{
+		{http.StatusOK, `{"access_token": "tok", "expires_in": 10}`, true},
+		{http.StatusOK, `{}`, false},
+		{http.StatusForbidden, `{"error": "denied"}`, false},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.code)
+			w.Write([]byte(tt.body))
+		}))
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if _, err := fetch(srv.Client(), req); (err == nil) != tt.ok {
+			t.Errorf("%d %s: have %v", tt.code, tt.body, err)
+		}
+		srv.Close()
+	}
+}
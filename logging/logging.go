@@ -0,0 +1,63 @@
+// Package logging carries a leveled, structured logger in a
+// context.Context. The service adds the request id, job id and
+// provider to the logger in the request context as it learns them,
+// so every line the providers log for that request carries them too.
+package logging
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// New returns a logger writing json lines at the named level,
+// an empty level means info
+func New(level string) (*logrus.Logger, error) {
+	l := logrus.New()
+	l.SetFormatter(&logrus.JSONFormatter{})
+	if level == "" {
+		return l, nil
+	}
+	lv, err := logrus.ParseLevel(level)
+	if err != nil {
+		return nil, fmt.Errorf("logging: %w", err)
+	}
+	l.SetLevel(lv)
+	return l, nil
+}
+
+type key struct{}
+
+// From returns the logger in ctx, or the standard logger
+func From(ctx context.Context) *logrus.Entry {
+	if e, ok := ctx.Value(key{}).(*logrus.Entry); ok {
+		return e
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// With returns a copy of ctx carrying e
+func With(ctx context.Context, e *logrus.Entry) context.Context {
+	return context.WithValue(ctx, key{}, e)
+}
+
+// Set returns a copy of ctx whose logger adds the key-value pairs
+// to every line
+func Set(ctx context.Context, kv ...interface{}) context.Context {
+	f := logrus.Fields{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		f[fmt.Sprint(kv[i])] = kv[i+1]
+	}
+	return With(ctx, From(ctx).WithFields(f))
+}
+
+// Done logs the outcome of op, at debug level if it succeeded
+// and warn if it failed
+func Done(ctx context.Context, op string, err error) {
+	if err != nil {
+		From(ctx).WithError(err).Warn(op)
+		return
+	}
+	From(ctx).Debug(op)
+}
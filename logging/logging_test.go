@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	l, err := New("info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	l.SetOutput(buf)
+
+	ctx := With(context.Background(), l.WithField("rid", 1))
+	ctx = Set(ctx, "job", "j1", "provider", "p")
+	Done(ctx, "create", nil)
+	if buf.Len() != 0 {
+		t.Fatalf("debug line logged at info level: %s", buf)
+	}
+	Done(ctx, "create", errors.New("boom"))
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("bad line %q: %v", buf, err)
+	}
+	for k, v := range map[string]interface{}{
+		"rid": 1.0, "job": "j1", "provider": "p",
+		"msg": "create", "error": "boom", "level": "warning",
+	} {
+		if line[k] != v {
+			t.Errorf("%s: have %v, want %v", k, line[k], v)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New("loud"); err == nil {
+		t.Fatal("bad level: want error")
+	}
+	l, err := New("")
+	if err != nil || l.GetLevel().String() != "info" {
+		t.Fatalf("default level: have %v, %v", l, err)
+	}
+}
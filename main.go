@@ -1,34 +1,171 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/cbsinteractive/transcode-orchestrator/config"
 	"github.com/cbsinteractive/transcode-orchestrator/db"
+	"github.com/cbsinteractive/transcode-orchestrator/logging"
+	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/secrets"
 	"github.com/cbsinteractive/transcode-orchestrator/service"
+	"github.com/cbsinteractive/transcode-orchestrator/service/exceptions"
+	objects "github.com/cbsinteractive/transcode-orchestrator/storage/store"
+	"github.com/cbsinteractive/transcode-orchestrator/trace"
+	"github.com/sirupsen/logrus"
 
 	_ "github.com/cbsinteractive/transcode-orchestrator/provider/bitmovin"
+	_ "github.com/cbsinteractive/transcode-orchestrator/provider/elastictranscoder"
+	_ "github.com/cbsinteractive/transcode-orchestrator/provider/elementalconductor"
+	_ "github.com/cbsinteractive/transcode-orchestrator/provider/encodingcom"
 	_ "github.com/cbsinteractive/transcode-orchestrator/provider/flock"
+	_ "github.com/cbsinteractive/transcode-orchestrator/provider/googletranscoder"
 	_ "github.com/cbsinteractive/transcode-orchestrator/provider/hybrik"
+	_ "github.com/cbsinteractive/transcode-orchestrator/provider/local"
 	_ "github.com/cbsinteractive/transcode-orchestrator/provider/mediaconvert"
+	_ "github.com/cbsinteractive/transcode-orchestrator/provider/simulator"
+	_ "github.com/cbsinteractive/transcode-orchestrator/provider/zencoder"
 )
 
-var addr = flag.String("addr", ":"+os.Getenv("HTTP_PORT"), "http listen address")
+var (
+	addr    = flag.String("addr", ":"+os.Getenv("HTTP_PORT"), "http listen address")
+	cfgfile = flag.String("config", os.Getenv("CONFIG_FILE"), "json config file, reloaded on change or SIGHUP")
+)
 
 func main() {
 	flag.Parse()
 
-	cfg := config.LoadConfig()
-	store, err := db.NewClient(nil)
+	live, err := config.NewLive(*cfgfile)
+	if err != nil {
+		log.Fatalf("loading config: %v", err)
+	}
+	cfg := live.Config()
+	if err = provider.RegisterInstances(cfg); err != nil {
+		log.Fatalf("registering provider instances: %v", err)
+	}
+	logger, err := logging.New(cfg.LogLevel)
+	if err != nil {
+		log.Fatalf("configuring logging: %v", err)
+	}
+	exporter, err := trace.NewExporter(cfg.TraceExporter)
+	if err != nil {
+		log.Fatalf("configuring tracing: %v", err)
+	}
+	// the live config is shared, so set the tracer on a copy
+	traced := *cfg
+	traced.Tracer = trace.New(exporter)
+	live.Set(&traced)
+	cfg = &traced
+
+	store, audit, err := openStore(cfg)
 	if err != nil {
 		log.Fatalf("initializing db: %v", err)
 	}
+	reporter, err := exceptions.New(cfg.SentryDSN, cfg.Env)
+	if err != nil {
+		log.Fatalf("initializing exception reporter: %v", err)
+	}
+	secretSource, err := secrets.New(cfg.Secrets)
+	if err != nil {
+		log.Fatalf("initializing secrets: %v", err)
+	}
+	var secretTTL time.Duration
+	if cfg.Secrets != nil {
+		secretTTL = time.Duration(cfg.Secrets.TTL) * time.Second
+	}
 	srv := service.Server{
-		Config: cfg,
-		DB:     store,
+		Config:   cfg,
+		Live:     live,
+		DB:       store,
+		Audit:    audit,
+		Reporter: exceptions.NewDedup(reporter, 5*time.Minute),
+		Logger:   logger,
+		Secrets:  secretSource,
+		Resolver: &secrets.Resolver{
+			Source: secretSource,
+			TTL:    secretTTL,
+			Log: func(err error) {
+				logger.WithError(err).Error("resolving secrets")
+			},
+		},
+	}
+	if r := cfg.Retention; r != nil && r.Archive != "" {
+		if srv.Archive, err = db.NewArchive(r.Archive); err != nil {
+			log.Fatalf("initializing archive: %v", err)
+		}
+	}
+	if v := cfg.Verify; v != nil && v.Enabled {
+		if srv.Objects, err = objects.New(v); err != nil {
+			log.Fatalf("initializing output verification: %v", err)
+		}
+	}
+	if r := cfg.Retention; r != nil && r.TTL > 0 && r.SweepInterval > 0 {
+		go srv.Sweep(context.Background(), time.Duration(r.SweepInterval)*time.Minute)
+	}
+	go live.Watch(context.Background(), 5*time.Second, func(cfg *config.Config, err error) {
+		if err != nil {
+			logger.WithError(err).Error("reloading config")
+			return
+		}
+		if lv, err := logrus.ParseLevel(cfg.LogLevel); err == nil {
+			logger.SetLevel(lv)
+		}
+		if err := provider.RegisterInstances(cfg); err != nil {
+			logger.WithError(err).Error("registering provider instances")
+		}
+	})
+	hs := &http.Server{Addr: *addr, Handler: srv}
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		hs.Shutdown(ctx)
+	}()
+	if err := hs.ListenAndServe(); err != http.ErrServerClosed {
+		log.Println(err)
+	}
+	// errors are reported in the background, send what's left
+	reporter.Flush(5 * time.Second)
+}
+
+// openStore opens the job store the config selects, which keeps the
+// audit log too
+func openStore(cfg *config.Config) (db.Store, db.Audit, error) {
+	typ := "redis"
+	if s := cfg.Store; s != nil && s.Type != "" {
+		typ = s.Type
+	}
+	switch typ {
+	case "memory":
+		m := db.NewMemory()
+		return m, m, nil
+	case "sql":
+		s, err := db.OpenSQL(cfg.Store.SQLDriver, cfg.Store.SQLDSN)
+		if err != nil {
+			return nil, nil, err
+		}
+		return s, s, nil
+	}
+	opt, err := db.OptionsFrom(cfg.Redis)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := db.NewClient(opt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = c.Ping(); err != nil {
+		return nil, nil, fmt.Errorf("connecting: %w", err)
 	}
-	log.Println(http.ListenAndServe(*addr, srv))
+	return c, c, nil
 }
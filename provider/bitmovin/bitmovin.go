@@ -10,6 +10,7 @@ import (
 
 	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
 	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/logging"
 	"github.com/cbsinteractive/transcode-orchestrator/provider"
 	"github.com/cbsinteractive/transcode-orchestrator/provider/bitmovin/codec"
 	"github.com/cbsinteractive/transcode-orchestrator/provider/bitmovin/storage"
@@ -51,6 +52,9 @@ const (
 	containerMOV  = "mov"
 )
 
+// ErrUnsupported is returned for jobs Bitmovin can't encode
+var ErrUnsupported = errors.New("unsupported")
+
 func factory(cfg *config.Config) (provider.Provider, error) {
 	if cfg.Bitmovin.APIKey == "" {
 		return nil, fmt.Errorf("%w: no api key", provider.ErrConfig)
@@ -78,21 +82,30 @@ func factory(cfg *config.Config) (provider.Provider, error) {
 	}
 
 	return &driver{
-		api:    api,
-		cfg:    cfg.Bitmovin,
-		tracer: tracer,
+		api:             api,
+		cfg:             cfg.Bitmovin,
+		tracer:          tracer,
+		segmentDuration: cfg.DefaultSegmentDuration,
 	}, nil
 }
 
 type driver struct {
-	api    *bitmovin.BitmovinApi
-	cfg    *config.Bitmovin
-	tracer tracing.Tracer
+	api             *bitmovin.BitmovinApi
+	cfg             *config.Bitmovin
+	tracer          tracing.Tracer
+	segmentDuration uint
 }
 
 func (p *driver) Create(ctx context.Context, j *Job) (*Status, error) {
+	if err := p.validate(j); err != nil {
+		return nil, err
+	}
+
 	presets := make([]Preset, len(j.Output.File))
 	for i, f := range j.Output.File {
+		if f.Streamed() {
+			f.Container = f.Protocol()
+		}
 		if err := p.createPreset(ctx, f, &presets[i]); err != nil {
 			return nil, fmt.Errorf("output[%d]: preset: %w", i, err)
 		}
@@ -159,22 +172,52 @@ func (p *driver) Create(ctx context.Context, j *Job) (*Status, error) {
 		return nil, fmt.Errorf("splice: %w", err)
 	}
 
+	manifests, err := p.createManifests(ctx, j, outputID, destPath)
+	if err != nil {
+		return nil, err
+	}
+	var audioGroup string
+	for _, f := range j.Output.File {
+		if f.Protocol() == job.ContainerHLS && f.Video.Codec == "" {
+			audioGroup = j.Streaming.AudioGroupName()
+		}
+	}
+	defaultAudio := true
+
 	var wg sync.WaitGroup
 	errorc := make(chan error)
 
 	subSeg = p.tracer.BeginSubsegment(ctx, "bitmovin-create-outputs")
 	for idx, o := range j.Output.File {
+		m := manifests[o.Protocol()]
+		cfg := outputCfg{
+			preset:             presets[idx],
+			encodingID:         enc.Id,
+			audioIn:            inputID,
+			videoIn:            inputID,
+			outputID:           outputID,
+			outputFilename:     o.Name,
+			destPath:           destPath,
+			manifestID:         m.id,
+			manifestMasterPath: m.dir,
+			job:                j,
+		}
+		if o.Streamed() {
+			cfg.stream = streamCfg{
+				segDuration:        j.Streaming.Duration(p.segmentDuration),
+				fmp4:               j.Streaming.FMP4(),
+				audioGroup:         audioGroup,
+				periodID:           m.period,
+				vidAdaptationSetID: m.video,
+				audAdaptationSetID: m.audio,
+				encryption:         j.Streaming.Encryption,
+			}
+			if o.Video.Codec == "" {
+				cfg.stream.defaultAudio, defaultAudio = defaultAudio, false
+			}
+		}
 		wg.Add(1)
-		go p.createOutput(outputCfg{
-			preset:         presets[idx],
-			encodingID:     enc.Id,
-			audioIn:        inputID,
-			videoIn:        inputID,
-			outputID:       outputID,
-			outputFilename: o.Name,
-			destPath:       destPath,
-			job:            j,
-		}, &wg, errorc)
+		go p.createOutput(cfg, &wg, errorc)
 	}
 
 	go func() {
@@ -200,7 +243,8 @@ func (p *driver) Create(ctx context.Context, j *Job) (*Status, error) {
 	}
 
 	subSeg = p.tracer.BeginSubsegment(ctx, "bitmovin-start-encoding")
-	encResp, err := p.api.Encoding.Encodings.Start(enc.Id, model.StartEncodingRequest{})
+	encResp, err := p.api.Encoding.Encodings.Start(enc.Id, startRequest(manifests))
+	logging.Done(ctx, "bitmovin-start-encoding", err)
 	if err != nil {
 		subSeg.Close(err)
 		return nil, errors.Wrap(err, "starting encoding job")
@@ -217,6 +261,7 @@ func (p *driver) Create(ctx context.Context, j *Job) (*Status, error) {
 func (p *driver) Status(ctx context.Context, j *Job) (*Status, error) {
 	subSeg := p.tracer.BeginSubsegment(ctx, "bitmovin-create-get-encoding-status")
 	task, err := p.api.Encoding.Encodings.Status(j.ProviderJobID)
+	logging.Done(ctx, "bitmovin-get-encoding-status", err)
 	if err != nil {
 		subSeg.Close(err)
 		return nil, errors.Wrap(err, "retrieving encoding status")
@@ -242,6 +287,15 @@ func (p *driver) Status(ctx context.Context, j *Job) (*Status, error) {
 		},
 	}
 
+	if s.State == job.StateFinished {
+		subSeg := p.tracer.BeginSubsegment(ctx, "bitmovin-get-manifest-status")
+		s, err = p.manifestState(s)
+		subSeg.Close(err)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if s.State == job.StateFinished {
 		subSeg := p.tracer.BeginSubsegment(ctx, "bitmovin-get-output-info")
 		s, err = p.enrichStreams(s)
@@ -280,9 +334,21 @@ type outputCfg struct {
 	outputFilename     string
 	manifestID         string
 	manifestMasterPath string
+	stream             streamCfg
 	job                *Job
 }
 
+// streamCfg holds the settings of a streaming rendition
+type streamCfg struct {
+	segDuration                            uint
+	fmp4                                   bool
+	audioGroup                             string
+	defaultAudio                           bool
+	periodID                               string
+	vidAdaptationSetID, audAdaptationSetID string
+	encryption                             *job.Encryption
+}
+
 func (p *driver) createOutput(cfg outputCfg, wg *sync.WaitGroup, errorc chan error) {
 	defer wg.Done()
 	var audioMuxingStream, videoMuxingStream model.MuxingStream
@@ -342,12 +408,39 @@ func (p *driver) createOutput(cfg outputCfg, wg *sync.WaitGroup, errorc chan err
 		VidMuxingStream:    videoMuxingStream,
 		ManifestID:         cfg.manifestID,
 		ManifestMasterPath: cfg.manifestMasterPath,
+		SegDuration:        cfg.stream.segDuration,
+		FMP4:               cfg.stream.fmp4,
+		AudioGroup:         cfg.stream.audioGroup,
+		DefaultAudio:       cfg.stream.defaultAudio,
+		PeriodID:           cfg.stream.periodID,
+		VidAdaptationSetID: cfg.stream.vidAdaptationSetID,
+		AudAdaptationSetID: cfg.stream.audAdaptationSetID,
+		Encryption:         cfg.stream.encryption,
 	}); err != nil {
 		errorc <- err
 		return
 	}
 }
 
+// validate rejects storage Bitmovin can't use before anything is created
+// for the job. Aliased inputs and outputs already exist.
+func (p *driver) validate(j *Job) error {
+	if err := validateStreaming(j); err != nil {
+		return err
+	}
+	if j.Env.InputAlias == "" {
+		if _, err := storage.CheckInput(j.Input.Name); err != nil {
+			return err
+		}
+	}
+	if j.Env.OutputAlias == "" {
+		if _, err := storage.CheckOutput(p.path(*j)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (p *driver) inputFrom(ctx context.Context, job *Job) (inputID string, err error) {
 	defer p.trace(ctx, "bitmovin-create-input", &err)()
 
@@ -562,8 +655,8 @@ func (p *driver) Healthcheck() error {
 func (p *driver) Capabilities() provider.Capabilities {
 	return provider.Capabilities{
 		InputFormats:  []string{"prores", "h264"},
-		OutputFormats: []string{containerMP4, containerMOV, containerWebM},
-		Destinations:  []string{"s3", "gcs"},
+		OutputFormats: []string{containerMP4, containerMOV, containerWebM, job.ContainerHLS, job.ContainerDASH},
+		Destinations:  storage.Outputs,
 	}
 }
 
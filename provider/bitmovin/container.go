@@ -2,6 +2,7 @@ package bitmovin
 
 import (
 	"path"
+	"strings"
 
 	"github.com/bitmovin/bitmovin-api-sdk-go"
 	"github.com/bitmovin/bitmovin-api-sdk-go/model"
@@ -18,6 +19,8 @@ var containers = map[string]interface {
 	"webm": &WEBM{},
 	"mp4":  &MP4{},
 	"mov":  &MOV{},
+	"hls":  &HLS{},
+	"dash": &DASH{},
 }
 
 // AssemblerCfg holds properties any individual assembler might need when creating resources
@@ -31,6 +34,14 @@ type AssemblerCfg struct {
 	ManifestID                       string
 	ManifestMasterPath               string
 	SegDuration                      uint
+
+	// for HLS and DASH renditions
+	FMP4                                   bool
+	AudioGroup                             string
+	DefaultAudio                           bool
+	PeriodID                               string
+	VidAdaptationSetID, AudAdaptationSetID string
+	Encryption                             *job.Encryption
 }
 
 type MOV struct{}
@@ -48,6 +59,15 @@ func (a AssemblerCfg) Streams() (s []model.MuxingStream) {
 	return s
 }
 func (a AssemblerCfg) Filename() string { return path.Base(a.OutputFilename) }
+
+// Rendition is the name of a streaming rendition: its filename without
+// the extension
+func (a AssemblerCfg) Rendition() string {
+	return strings.TrimSuffix(a.Filename(), path.Ext(a.Filename()))
+}
+func (a AssemblerCfg) segmentDir() string {
+	return job.File{Name: a.ManifestMasterPath}.Join(a.Rendition()).Name
+}
 func (a AssemblerCfg) Outputs() []model.EncodingOutput {
 	path := job.File{Name: a.DestPath}.Join(a.OutputFilename).Dir()
 	return []model.EncodingOutput{
@@ -91,6 +111,9 @@ func (e *MOV) Enrich(api *bitmovin.BitmovinApi, s job.Status) (job.Status, error
 		return s, err
 	}
 	for _, mux := range mux {
+		if mux.Segmented() {
+			continue
+		}
 		info, err := get(s.ProviderJobID, mux.Id)
 		if err != nil {
 			return s, nil
@@ -108,6 +131,9 @@ func (e *MP4) Enrich(api *bitmovin.BitmovinApi, s job.Status) (job.Status, error
 		return s, err
 	}
 	for _, mux := range mux {
+		if mux.Segmented() {
+			continue
+		}
 		info, err := get(s.ProviderJobID, mux.Id)
 		if err != nil {
 			return s, nil
@@ -125,6 +151,9 @@ func (e *WEBM) Enrich(api *bitmovin.BitmovinApi, s job.Status) (job.Status, erro
 		return s, err
 	}
 	for _, mux := range mux {
+		if mux.Segmented() {
+			continue
+		}
 		info, err := get(s.ProviderJobID, mux.Id)
 		if err != nil {
 			return s, nil
@@ -140,6 +169,12 @@ type Muxing struct {
 	Type     string `json:"type,omitempty"`
 }
 
+// Segmented reports whether the muxing holds a streaming rendition's
+// segments rather than a file
+func (m Muxing) Segmented() bool {
+	return m.Type == string(model.MuxingType_TS) || m.Type == string(model.MuxingType_FMP4)
+}
+
 func ListMuxing(api *bitmovin.BitmovinApi, jobID string) ([]Muxing, error) {
 	list := api.Encoding.Encodings.Muxings.List
 
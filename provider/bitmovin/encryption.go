@@ -0,0 +1,115 @@
+package bitmovin
+
+import (
+	"fmt"
+
+	"github.com/bitmovin/bitmovin-api-sdk-go"
+	"github.com/bitmovin/bitmovin-api-sdk-go/model"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+)
+
+// validateEncryption rejects encryption Bitmovin can't apply to a muxing
+// of transport stream or fMP4 segments. It encrypts whole segments with
+// AES-128 only in transport streams, and CENC only in fMP4.
+func validateEncryption(e *job.Encryption, fmp4 bool) error {
+	if e == nil {
+		return nil
+	}
+	if err := e.Check(); err != nil {
+		return err
+	}
+	switch {
+	case fmp4 && e.Is(job.EncryptionAES128):
+		return fmt.Errorf("%w: aes-128 of fragmented mp4", ErrUnsupported)
+	case !fmp4 && e.Is(job.EncryptionCENC):
+		return fmt.Errorf("%w: cenc of transport streams", ErrUnsupported)
+	}
+	return nil
+}
+
+// drmFrom returns the DRM that encrypts a muxing's segments and writes
+// them to outputs: CENC or FairPlay for fMP4, AES-128 or FairPlay for
+// transport streams, or keys from a SPEKE server for either
+func drmFrom(e *job.Encryption, fmp4 bool, outputs []model.EncodingOutput) (model.Drm, error) {
+	if err := validateEncryption(e, fmp4); err != nil {
+		return nil, err
+	}
+	if p := e.KeyProvider; p != nil {
+		return model.SpekeDrm{
+			Outputs:   outputs,
+			ContentId: p.ResourceID,
+			Kid:       e.KeyID,
+			Iv:        e.IV,
+			Provider:  &model.SpekeDrmProvider{Url: p.URL, RoleArn: p.RoleARN},
+			SystemIds: p.SystemIDs,
+		}, nil
+	}
+	switch {
+	case e.Is(job.EncryptionCENC):
+		d := model.CencDrm{
+			Outputs:        outputs,
+			Key:            e.Key,
+			Kid:            e.KeyID,
+			EncryptionMode: model.EncryptionMode_CTR,
+		}
+		if e.KeyURL != "" {
+			d.PlayReady = &model.CencPlayReady{LaUrl: e.KeyURL}
+		}
+		return d, nil
+	case e.Is(job.EncryptionAES128):
+		return model.AesEncryptionDrm{
+			Outputs:    outputs,
+			Key:        e.Key,
+			Iv:         e.IV,
+			KeyFileUri: e.KeyURL,
+			Method:     model.AesEncryptionMethod_AES_128,
+		}, nil
+	}
+	return model.FairPlayDrm{Outputs: outputs, Key: e.Key, Iv: e.IV, Uri: e.KeyURL}, nil
+}
+
+// encrypt adds the DRM to the muxing
+func encrypt(api *bitmovin.BitmovinApi, encID, muxID string, drm model.Drm, fmp4 bool) (id string, err error) {
+	var (
+		fm = api.Encoding.Encodings.Muxings.Fmp4.Drm
+		ts = api.Encoding.Encodings.Muxings.Ts.Drm
+	)
+	switch d := drm.(type) {
+	case model.SpekeDrm:
+		var r *model.SpekeDrm
+		if fmp4 {
+			r, err = fm.Speke.Create(encID, muxID, d)
+		} else {
+			r, err = ts.Speke.Create(encID, muxID, d)
+		}
+		if r != nil {
+			id = r.Id
+		}
+	case model.CencDrm:
+		var r *model.CencDrm
+		if r, err = fm.Cenc.Create(encID, muxID, d); r != nil {
+			id = r.Id
+		}
+	case model.AesEncryptionDrm:
+		var r *model.AesEncryptionDrm
+		if r, err = ts.Aes.Create(encID, muxID, d); r != nil {
+			id = r.Id
+		}
+	case model.FairPlayDrm:
+		var r *model.FairPlayDrm
+		if fmp4 {
+			r, err = fm.Fairplay.Create(encID, muxID, d)
+		} else {
+			r, err = ts.Fairplay.Create(encID, muxID, d)
+		}
+		if r != nil {
+			id = r.Id
+		}
+	default:
+		return "", fmt.Errorf("%w: drm %T", ErrUnsupported, drm)
+	}
+	if err != nil {
+		return "", fmt.Errorf("adding drm: %w", err)
+	}
+	return id, nil
+}
@@ -0,0 +1,73 @@
+package bitmovin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bitmovin/bitmovin-api-sdk-go/model"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDrmFrom(t *testing.T) {
+	const key = "00112233445566778899aabbccddeeff"
+	outputs := []model.EncodingOutput{{OutputId: "out", OutputPath: "abc/hls/1080p"}}
+	speke := &job.KeyProvider{URL: "https://keys/speke", ResourceID: "abc", SystemIDs: []string{"94ce86fb-07ff-4f43-adb8-93d2fa968ca2"}, RoleARN: "arn:aws:iam::1:role/speke"}
+
+	for _, tt := range []struct {
+		name string
+		e    job.Encryption
+		fmp4 bool
+		want model.Drm
+	}{
+		{
+			name: "CENC", fmp4: true,
+			e: job.Encryption{Method: "cenc", Key: key, KeyID: key, KeyURL: "https://license"},
+			want: model.CencDrm{Outputs: outputs, Key: key, Kid: key, EncryptionMode: model.EncryptionMode_CTR,
+				PlayReady: &model.CencPlayReady{LaUrl: "https://license"}},
+		},
+		{
+			name: "FairPlayTS",
+			e:    job.Encryption{Method: "sample-aes", Key: key, IV: key, KeyURL: "skd://key"},
+			want: model.FairPlayDrm{Outputs: outputs, Key: key, Iv: key, Uri: "skd://key"},
+		},
+		{
+			name: "FairPlayFMP4", fmp4: true,
+			e:    job.Encryption{Method: "sample-aes", Key: key, KeyURL: "skd://key"},
+			want: model.FairPlayDrm{Outputs: outputs, Key: key, Uri: "skd://key"},
+		},
+		{
+			name: "AES128",
+			e:    job.Encryption{Method: "aes-128", Key: key, KeyURL: "https://keys/k"},
+			want: model.AesEncryptionDrm{Outputs: outputs, Key: key, KeyFileUri: "https://keys/k", Method: model.AesEncryptionMethod_AES_128},
+		},
+		{
+			name: "SPEKE", fmp4: true,
+			e: job.Encryption{Method: "cenc", KeyProvider: speke},
+			want: model.SpekeDrm{Outputs: outputs, ContentId: "abc", SystemIds: speke.SystemIDs,
+				Provider: &model.SpekeDrmProvider{Url: "https://keys/speke", RoleArn: speke.RoleARN}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := drmFrom(&tt.e, tt.fmp4, outputs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, have); diff != "" {
+				t.Fatalf("drm (-want +have):\n%s", diff)
+			}
+		})
+	}
+
+	for name, tt := range map[string]struct {
+		e    job.Encryption
+		fmp4 bool
+	}{
+		"AES128FMP4": {job.Encryption{Method: "aes-128", Key: key}, true},
+		"CENCTS":     {job.Encryption{Method: "cenc", Key: key, KeyID: key}, false},
+	} {
+		if _, err := drmFrom(&tt.e, tt.fmp4, outputs); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s: have %v, want %v", name, err, ErrUnsupported)
+		}
+	}
+}
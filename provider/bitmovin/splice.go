@@ -7,6 +7,7 @@ import (
 
 	"github.com/bitmovin/bitmovin-api-sdk-go/model"
 	"github.com/cbsinteractive/pkg/timecode"
+	"github.com/cbsinteractive/transcode-orchestrator/logging"
 )
 
 func (p *driver) trace(ctx context.Context, name string, err *error) func() {
@@ -14,8 +15,10 @@ func (p *driver) trace(ctx context.Context, name string, err *error) func() {
 	return func() {
 		if err == nil {
 			x.Close(nil)
+			logging.Done(ctx, name, nil)
 		} else {
 			x.Close(*err)
+			logging.Done(ctx, name, *err)
 		}
 	}
 }
@@ -2,48 +2,48 @@ package storage
 
 import (
 	"fmt"
-	"net/url"
 
 	"github.com/bitmovin/bitmovin-api-sdk-go/model"
 	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
 	"github.com/pkg/errors"
 )
 
-const (
-	schemeHTTP  = "http"
-	schemeHTTPS = "https"
-)
+// Inputs are the storage schemes Bitmovin reads media from
+var Inputs = []string{storage.S3, storage.GCS, storage.HTTP, storage.HTTPS}
 
-type inputCreator func(*url.URL, InputAPI, *config.Bitmovin) (inputID string, err error)
+type inputCreator func(storage.Location, InputAPI, *config.Bitmovin) (inputID string, err error)
 
 var inputCreators = map[string]inputCreator{
-	schemeS3:    s3Input,
-	schemeGCS:   gcsInput,
-	schemeHTTP:  httpInput,
-	schemeHTTPS: httpsInput,
+	storage.S3:    s3Input,
+	storage.GCS:   gcsInput,
+	storage.HTTP:  httpInput,
+	storage.HTTPS: httpsInput,
 }
 
 // NewInput creates an input and returns an inputID and the media path or an error
 func NewInput(srcMediaLoc string, api InputAPI, cfg *config.Bitmovin) (inputID string, err error) {
-	mediaURL, err := url.Parse(srcMediaLoc)
+	loc, err := CheckInput(srcMediaLoc)
 	if err != nil {
-		return "", fmt.Errorf("could not parse source media location %q", srcMediaLoc)
-	}
-
-	creator, found := inputCreators[mediaURL.Scheme]
-	if !found {
-		return "", fmt.Errorf("invalid scheme %q, only s3, gcs, http, and https urls are supported", mediaURL.Scheme)
+		return "", err
 	}
 
-	return creator(mediaURL, api, cfg)
+	return inputCreators[loc.Scheme](loc, api, cfg)
 }
 
-func s3Input(srcMediaURL *url.URL, api InputAPI, cfg *config.Bitmovin) (inputID string, err error) {
-	bucket, _ := parseS3URL(srcMediaURL)
+// CheckInput returns an error unless Bitmovin can read media from srcMediaLoc
+func CheckInput(srcMediaLoc string) (storage.Location, error) {
+	loc, err := storage.Check(srcMediaLoc, Inputs...)
+	if err != nil {
+		return loc, fmt.Errorf("source media location: %w", err)
+	}
+	return loc, nil
+}
 
+func s3Input(src storage.Location, api InputAPI, cfg *config.Bitmovin) (inputID string, err error) {
 	input, err := api.S3.Create(model.S3Input{
 		CloudRegion: model.AwsCloudRegion(cfg.AWSStorageRegion),
-		BucketName:  bucket,
+		BucketName:  src.Bucket,
 		AccessKey:   cfg.AccessKeyID,
 		SecretKey:   cfg.SecretAccessKey,
 	})
@@ -54,12 +54,10 @@ func s3Input(srcMediaURL *url.URL, api InputAPI, cfg *config.Bitmovin) (inputID
 	return input.Id, nil
 }
 
-func gcsInput(srcMediaURL *url.URL, api InputAPI, cfg *config.Bitmovin) (inputID string, err error) {
-	bucket, _ := parseGCSURL(srcMediaURL)
-
+func gcsInput(src storage.Location, api InputAPI, cfg *config.Bitmovin) (inputID string, err error) {
 	input, err := api.GCS.Create(model.GcsInput{
 		CloudRegion: model.GoogleCloudRegion(cfg.GCSStorageRegion),
-		BucketName:  bucket,
+		BucketName:  src.Bucket,
 		AccessKey:   cfg.GCSAccessKeyID,
 		SecretKey:   cfg.GCSSecretAccessKey,
 	})
@@ -70,9 +68,9 @@ func gcsInput(srcMediaURL *url.URL, api InputAPI, cfg *config.Bitmovin) (inputID
 	return input.Id, nil
 }
 
-func httpInput(srcMediaURL *url.URL, api InputAPI, _ *config.Bitmovin) (inputID string, err error) {
+func httpInput(src storage.Location, api InputAPI, _ *config.Bitmovin) (inputID string, err error) {
 	input, err := api.HTTP.Create(model.HttpInput{
-		Host: srcMediaURL.Host,
+		Host: src.Host,
 	})
 	if err != nil {
 		return "", errors.Wrap(err, "creating http input")
@@ -81,9 +79,9 @@ func httpInput(srcMediaURL *url.URL, api InputAPI, _ *config.Bitmovin) (inputID
 	return input.Id, nil
 }
 
-func httpsInput(srcMediaURL *url.URL, api InputAPI, _ *config.Bitmovin) (inputID string, err error) {
+func httpsInput(src storage.Location, api InputAPI, _ *config.Bitmovin) (inputID string, err error) {
 	input, err := api.HTTPS.Create(model.HttpsInput{
-		Host: srcMediaURL.Host,
+		Host: src.Host,
 	})
 	if err != nil {
 		return "", errors.Wrap(err, "creating https input")
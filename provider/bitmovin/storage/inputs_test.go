@@ -110,7 +110,8 @@ func TestNewInput(t *testing.T) {
 			name:     "an unsupported src url results in an error",
 			srcMedia: "cbscloud://some-bucket/some/path/file.mp4",
 			api:      fakeInputAPIReturningInputID("some-https-input-id"),
-			wantErr:  `invalid scheme "cbscloud", only s3, gcs, http, and https urls are supported`,
+			wantErr: `source media location: unsupported storage: scheme "cbscloud" in "cbscloud://some-bucket/some/path/file.mp4", ` +
+				`want one of s3, gs, http, https`,
 		},
 		{
 			name:     "if the bitmovin api is erroring, we get a useful error when creating s3 inputs",
@@ -142,7 +143,8 @@ func TestNewInput(t *testing.T) {
 			name:     "an unparsable src url results in a useful error",
 			srcMedia: "s3://%%some-bucket/some/path/file.mp4",
 			api:      fakeInputAPIReturningInputID("some-input-id"),
-			wantErr:  `could not parse source media location "s3://%%some-bucket/some/path/file.mp4"`,
+			wantErr: `source media location: invalid storage location: ` +
+				`parse "s3://%%some-bucket": invalid URL escape "%%s"`,
 		},
 	}
 
@@ -1,10 +1,9 @@
 package storage
 
 import (
-	"net/url"
-
 	"github.com/bitmovin/bitmovin-api-sdk-go/model"
 	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
 	"github.com/pkg/errors"
 )
 
@@ -13,26 +12,33 @@ const (
 	defaultOutputACL = model.AclPermission_PRIVATE
 )
 
-type outputCreator func(*url.URL, OutputAPI, *config.Bitmovin) (outputID string, err error)
+// Outputs are the storage schemes Bitmovin writes media to
+var Outputs = []string{storage.S3, storage.GCS}
+
+type outputCreator func(storage.Location, OutputAPI, *config.Bitmovin) (outputID string, err error)
 
 var outputCreators = map[string]outputCreator{
-	schemeS3:  s3Output,
-	schemeGCS: gcsOutput,
+	storage.S3:  s3Output,
+	storage.GCS: gcsOutput,
 }
 
 // NewOutput creates an output and returns an outputId and the folder path or an error
 func NewOutput(destLoc string, api OutputAPI, cfg *config.Bitmovin) (outputID string, err error) {
-	mediaURL, err := url.Parse(destLoc)
+	loc, err := CheckOutput(destLoc)
 	if err != nil {
-		return "", errors.Errorf("could not parse destination media location %q: %v", destLoc, err)
+		return "", err
 	}
 
-	creator, found := outputCreators[mediaURL.Scheme]
-	if !found {
-		return "", errors.Errorf("invalid scheme %q, only s3 and gcs outputs are supported", mediaURL.Scheme)
-	}
+	return outputCreators[loc.Scheme](loc, api, cfg)
+}
 
-	return creator(mediaURL, api, cfg)
+// CheckOutput returns an error unless Bitmovin can write media to destLoc
+func CheckOutput(destLoc string) (storage.Location, error) {
+	loc, err := storage.Check(destLoc, Outputs...)
+	if err != nil {
+		return loc, errors.Wrap(err, "destination media location")
+	}
+	return loc, nil
 }
 
 // EncodingOutputFrom returns an encoding output from an output ID and path
@@ -44,11 +50,9 @@ func EncodingOutputFrom(outputID, path string) model.EncodingOutput {
 	}
 }
 
-func s3Output(destURL *url.URL, api OutputAPI, cfg *config.Bitmovin) (string, error) {
-	bucket, _ := parseS3URL(destURL)
-
+func s3Output(dst storage.Location, api OutputAPI, cfg *config.Bitmovin) (string, error) {
 	output, err := api.S3.Create(model.S3Output{
-		BucketName:  bucket,
+		BucketName:  dst.Bucket,
 		AccessKey:   cfg.AccessKeyID,
 		SecretKey:   cfg.SecretAccessKey,
 		CloudRegion: model.AwsCloudRegion(cfg.AWSStorageRegion),
@@ -61,11 +65,9 @@ func s3Output(destURL *url.URL, api OutputAPI, cfg *config.Bitmovin) (string, er
 	return output.Id, nil
 }
 
-func gcsOutput(destURL *url.URL, api OutputAPI, cfg *config.Bitmovin) (string, error) {
-	bucket, _ := parseGCSURL(destURL)
-
+func gcsOutput(dst storage.Location, api OutputAPI, cfg *config.Bitmovin) (string, error) {
 	output, err := api.GCS.Create(model.GcsOutput{
-		BucketName:  bucket,
+		BucketName:  dst.Bucket,
 		AccessKey:   cfg.GCSAccessKeyID,
 		SecretKey:   cfg.GCSSecretAccessKey,
 		CloudRegion: model.GoogleCloudRegion(cfg.GCSStorageRegion),
@@ -93,7 +93,8 @@ func TestNewOutput(t *testing.T) {
 			name:    "an unsupported src url results in an error",
 			destLoc: "cbscloud://some-bucket/some/path/file.mp4",
 			api:     fakeOutputAPIReturningOutputID("some-https-output-id"),
-			wantErr: `invalid scheme "cbscloud", only s3 and gcs outputs are supported`,
+			wantErr: `destination media location: unsupported storage: scheme "cbscloud" in "cbscloud://some-bucket/some/path/file.mp4", ` +
+				`want one of s3, gs`,
 		},
 		{
 			name:    "if the bitmovin api is erroring, we get a useful error when creating s3 outputs",
@@ -111,8 +112,8 @@ func TestNewOutput(t *testing.T) {
 			name:    "an unparsable src url results in a useful error",
 			destLoc: "s3://%%some-bucket/some/path/file.mp4",
 			api:     fakeOutputAPIReturningOutputID("some-output-id"),
-			wantErr: `could not parse destination media location "s3://%%some-bucket/some/path/file.mp4": ` +
-				`parse "s3://%%some-bucket/some/path/file.mp4": invalid URL escape "%%s"`,
+			wantErr: `destination media location: invalid storage location: ` +
+				`parse "s3://%%some-bucket": invalid URL escape "%%s"`,
 		},
 	}
 
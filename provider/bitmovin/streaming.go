@@ -0,0 +1,473 @@
+package bitmovin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bitmovin/bitmovin-api-sdk-go"
+	"github.com/bitmovin/bitmovin-api-sdk-go/model"
+	"github.com/bitmovin/bitmovin-api-sdk-go/query"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/provider/bitmovin/storage"
+)
+
+const (
+	segmentsTS   = "seg_%number%.ts"
+	segmentsFMP4 = "seg_%number%.m4s"
+	initSegment  = "init.mp4"
+	undetermined = "und"
+)
+
+type HLS struct{}
+type DASH struct{}
+
+// manifest is a package's manifest, written to dir. DASH manifests have
+// a period with an adaptation set for video and one for audio.
+type manifest struct {
+	id, dir      string
+	period       string
+	video, audio string
+}
+
+// validateStreaming rejects packages Bitmovin can't make. It muxes fMP4
+// segments of a single stream, so DASH renditions and HLS renditions
+// with fMP4 segments carry either video or audio.
+func validateStreaming(j *Job) error {
+	if s := strings.ToLower(j.Streaming.Segments); s != "" && s != job.SegmentsTS && s != job.SegmentsFMP4 {
+		return fmt.Errorf("%w: hls segments: %q", ErrUnsupported, j.Streaming.Segments)
+	}
+	for i, f := range j.Output.File {
+		fmp4 := j.Streaming.FMP4()
+		switch f.Protocol() {
+		case job.ContainerCMAF:
+			return fmt.Errorf("output[%d]: %w: cmaf packages", i, ErrUnsupported)
+		case job.ContainerDASH:
+			fmp4 = true
+		case job.ContainerHLS:
+		default:
+			continue
+		}
+		if err := validateEncryption(j.Streaming.Encryption, fmp4); err != nil {
+			return fmt.Errorf("output[%d]: %w", i, err)
+		}
+		if fmp4 && f.Video.Codec != "" && f.Audio.Codec != "" {
+			return fmt.Errorf("output[%d]: %w: fragmented mp4 renditions carry either video or audio", i, ErrUnsupported)
+		}
+	}
+	return nil
+}
+
+// createManifests creates a manifest for each streaming protocol the
+// job's outputs use, named by the job and written to a directory named
+// after the protocol. Encoding the renditions adds them to it.
+func (p *driver) createManifests(ctx context.Context, j *Job, outputID, destPath string) (all map[string]manifest, err error) {
+	defer p.trace(ctx, "bitmovin-create-manifests", &err)()
+
+	var video, audio bool
+	for _, f := range j.Output.File {
+		if f.Protocol() == job.ContainerDASH {
+			video = video || f.Video.Codec != ""
+			audio = audio || f.Audio.Codec != ""
+		}
+	}
+
+	all = map[string]manifest{}
+	for _, f := range j.Output.File {
+		protocol := f.Protocol()
+		if _, ok := all[protocol]; ok || protocol == "" {
+			continue
+		}
+		m := manifest{dir: job.File{Name: destPath}.Join(protocol).Name}
+		outputs := []model.EncodingOutput{storage.EncodingOutputFrom(outputID, m.dir)}
+		name := j.Streaming.ManifestName()
+
+		switch protocol {
+		case job.ContainerHLS:
+			hls, err := p.api.Encoding.Manifests.Hls.Create(model.HlsManifest{
+				Name:         j.ID,
+				ManifestName: name + ".m3u8",
+				Outputs:      outputs,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("hls manifest: %w", err)
+			}
+			m.id = hls.Id
+		case job.ContainerDASH:
+			dash := p.api.Encoding.Manifests.Dash
+			mpd, err := dash.Create(model.DashManifest{
+				Name:         j.ID,
+				ManifestName: name + ".mpd",
+				Profile:      model.DashProfile_LIVE,
+				Outputs:      outputs,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("dash manifest: %w", err)
+			}
+			m.id = mpd.Id
+			period, err := dash.Periods.Create(m.id, model.Period{})
+			if err != nil {
+				return nil, fmt.Errorf("dash period: %w", err)
+			}
+			m.period = period.Id
+			if video {
+				set, err := dash.Periods.Adaptationsets.Video.Create(m.id, m.period, model.VideoAdaptationSet{})
+				if err != nil {
+					return nil, fmt.Errorf("dash video adaptation set: %w", err)
+				}
+				m.video = set.Id
+			}
+			if audio {
+				set, err := dash.Periods.Adaptationsets.Audio.Create(m.id, m.period, model.AudioAdaptationSet{Lang: undetermined})
+				if err != nil {
+					return nil, fmt.Errorf("dash audio adaptation set: %w", err)
+				}
+				m.audio = set.Id
+			}
+		}
+		all[protocol] = m
+	}
+	return all, nil
+}
+
+// startRequest starts the encoding and has Bitmovin write the manifests
+// once it finishes
+func startRequest(manifests map[string]manifest) (req model.StartEncodingRequest) {
+	if m, ok := manifests[job.ContainerHLS]; ok {
+		req.VodHlsManifests = []model.ManifestResource{{ManifestId: m.id}}
+	}
+	if m, ok := manifests[job.ContainerDASH]; ok {
+		req.VodDashManifests = []model.ManifestResource{{ManifestId: m.id}}
+	}
+	return req
+}
+
+// manifestState holds a finished encoding at started until its
+// manifests are written, and fails it if one can't be
+func (p *driver) manifestState(s Status) (Status, error) {
+	hls, err := p.api.Encoding.Manifests.Hls.List(func(q *query.HlsManifestListQueryParams) {
+		q.EncodingId = s.ProviderJobID
+		q.Limit = 100
+	})
+	if err != nil {
+		return s, fmt.Errorf("listing hls manifests: %w", err)
+	}
+	dash, err := p.api.Encoding.Manifests.Dash.List(func(q *query.DashManifestListQueryParams) {
+		q.EncodingId = s.ProviderJobID
+		q.Limit = 100
+	})
+	if err != nil {
+		return s, fmt.Errorf("listing dash manifests: %w", err)
+	}
+
+	type check struct {
+		protocol, id string
+		status       func(string) (*model.ModelTask, error)
+	}
+	var checks []check
+	for _, m := range hls.Items {
+		checks = append(checks, check{job.ContainerHLS, m.Id, p.api.Encoding.Manifests.Hls.Status})
+	}
+	for _, m := range dash.Items {
+		checks = append(checks, check{job.ContainerDASH, m.Id, p.api.Encoding.Manifests.Dash.Status})
+	}
+	for _, c := range checks {
+		task, err := c.status(c.id)
+		if err != nil {
+			return s, fmt.Errorf("%s manifest status: %w", c.protocol, err)
+		}
+		switch state(task.Status) {
+		case job.StateFinished:
+		case job.StateFailed, job.StateCanceled:
+			s.State = job.StateFailed
+			s.Msg = fmt.Sprintf("%s manifest %s: %s", c.protocol, c.id, task.Status)
+			return s, nil
+		default:
+			s.State = job.StateStarted
+		}
+	}
+	return s, nil
+}
+
+// muxSegments creates a muxing of the rendition's segments in a directory
+// named after it next to the manifest. Encrypted segments are written by
+// the muxing's DRM instead, and the muxing's description names their
+// directory.
+func muxSegments(api *bitmovin.BitmovinApi, cfg AssemblerCfg, fmp4 bool) (id, drmID string, err error) {
+	var (
+		seg         = float64(cfg.SegDuration)
+		outputs     = []model.EncodingOutput{storage.EncodingOutputFrom(cfg.OutputID, cfg.segmentDir())}
+		drm         model.Drm
+		description string
+	)
+	if cfg.Encryption != nil {
+		if drm, err = drmFrom(cfg.Encryption, fmp4, outputs); err != nil {
+			return "", "", err
+		}
+		outputs, description = nil, cfg.segmentDir()
+	}
+	if fmp4 {
+		mux, err := api.Encoding.Encodings.Muxings.Fmp4.Create(cfg.EncID, model.Fmp4Muxing{
+			Name:                 cfg.Rendition(),
+			Description:          description,
+			Streams:              cfg.Streams(),
+			StreamConditionsMode: model.StreamConditionsMode_DROP_STREAM,
+			Outputs:              outputs,
+			SegmentLength:        &seg,
+			SegmentNaming:        segmentsFMP4,
+			InitSegmentName:      initSegment,
+		})
+		if err != nil {
+			return "", "", err
+		}
+		id = mux.Id
+	} else {
+		mux, err := api.Encoding.Encodings.Muxings.Ts.Create(cfg.EncID, model.TsMuxing{
+			Name:                 cfg.Rendition(),
+			Description:          description,
+			Streams:              cfg.Streams(),
+			StreamConditionsMode: model.StreamConditionsMode_DROP_STREAM,
+			Outputs:              outputs,
+			SegmentLength:        &seg,
+			SegmentNaming:        segmentsTS,
+		})
+		if err != nil {
+			return "", "", err
+		}
+		id = mux.Id
+	}
+	if drm == nil {
+		return id, "", nil
+	}
+	drmID, err = encrypt(api, cfg.EncID, id, drm, fmp4)
+	return id, drmID, err
+}
+
+// Assemble muxes the rendition's segments and adds it to the master
+// playlist. Audio only renditions are alternates in the audio group, and
+// video only ones play with it.
+func (a *HLS) Assemble(api *bitmovin.BitmovinApi, cfg AssemblerCfg) error {
+	muxID, drmID, err := muxSegments(api, cfg, cfg.FMP4)
+	if err != nil {
+		return fmt.Errorf("hls: %s: %w", cfg.Rendition(), err)
+	}
+	name := cfg.Rendition()
+	empty := model.MuxingStream{}
+
+	if cfg.VidMuxingStream == empty {
+		yes := true
+		_, err = api.Encoding.Manifests.Hls.Media.Audio.Create(cfg.ManifestID, model.AudioMediaInfo{
+			Name:        name,
+			GroupId:     cfg.AudioGroup,
+			Language:    undetermined,
+			IsDefault:   &cfg.DefaultAudio,
+			Autoselect:  &yes,
+			SegmentPath: name,
+			Uri:         name + ".m3u8",
+			EncodingId:  cfg.EncID,
+			StreamId:    cfg.AudMuxingStream.StreamId,
+			MuxingId:    muxID,
+			DrmId:       drmID,
+		})
+	} else {
+		group := ""
+		if cfg.AudMuxingStream == empty {
+			group = cfg.AudioGroup
+		}
+		_, err = api.Encoding.Manifests.Hls.Streams.Create(cfg.ManifestID, model.StreamInfo{
+			Audio:       group,
+			SegmentPath: name,
+			Uri:         name + ".m3u8",
+			EncodingId:  cfg.EncID,
+			StreamId:    cfg.VidMuxingStream.StreamId,
+			MuxingId:    muxID,
+			DrmId:       drmID,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("hls: %s: adding to manifest: %w", name, err)
+	}
+	return nil
+}
+
+// Assemble muxes the rendition's fMP4 segments and adds it to the video
+// or audio adaptation set. Encrypted renditions signal their DRM with a
+// content protection element.
+func (a *DASH) Assemble(api *bitmovin.BitmovinApi, cfg AssemblerCfg) error {
+	muxID, drmID, err := muxSegments(api, cfg, true)
+	if err != nil {
+		return fmt.Errorf("dash: %s: %w", cfg.Rendition(), err)
+	}
+	set := cfg.VidAdaptationSetID
+	if cfg.VidMuxingStream == (model.MuxingStream{}) {
+		set = cfg.AudAdaptationSetID
+	}
+	fmp4 := api.Encoding.Manifests.Dash.Periods.Adaptationsets.Representations.Fmp4
+	if drmID == "" {
+		_, err = fmp4.Create(cfg.ManifestID, cfg.PeriodID, set, model.DashFmp4Representation{
+			Type:        model.DashRepresentationType_TEMPLATE,
+			SegmentPath: cfg.Rendition(),
+			EncodingId:  cfg.EncID,
+			MuxingId:    muxID,
+		})
+	} else {
+		var rep *model.DashFmp4DrmRepresentation
+		rep, err = fmp4.Drm.Create(cfg.ManifestID, cfg.PeriodID, set, model.DashFmp4DrmRepresentation{
+			Type:        model.DashRepresentationType_TEMPLATE,
+			SegmentPath: cfg.Rendition(),
+			EncodingId:  cfg.EncID,
+			MuxingId:    muxID,
+			DrmId:       drmID,
+		})
+		if err == nil {
+			_, err = fmp4.Drm.Contentprotection.Create(cfg.ManifestID, cfg.PeriodID, set, rep.Id, model.ContentProtection{
+				EncodingId: cfg.EncID,
+				MuxingId:   muxID,
+				DrmId:      drmID,
+			})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("dash: %s: adding to manifest: %w", cfg.Rendition(), err)
+	}
+	return nil
+}
+
+// Enrich adds the master playlists and their renditions to the status
+func (e *HLS) Enrich(api *bitmovin.BitmovinApi, s job.Status) (job.Status, error) {
+	list, err := api.Encoding.Manifests.Hls.List(func(q *query.HlsManifestListQueryParams) {
+		q.EncodingId = s.ProviderJobID
+		q.Limit = 100
+	})
+	if err != nil || len(list.Items) == 0 {
+		return s, err
+	}
+	segs, err := listSegmented(api, s.ProviderJobID)
+	if err != nil {
+		return s, err
+	}
+	for _, m := range list.Items {
+		audio, err := api.Encoding.Manifests.Hls.Media.Audio.List(m.Id, func(q *query.AudioMediaInfoListQueryParams) {
+			q.Limit = 100
+		})
+		if err != nil {
+			return s, err
+		}
+		groups := map[string]string{}
+		for _, a := range audio.Items {
+			groups[a.MuxingId] = a.GroupId
+		}
+		s = addPackage(s, newPackage(job.ContainerHLS, m.Outputs, m.ManifestName, segs, groups))
+	}
+	return s, nil
+}
+
+// Enrich adds the MPDs and their renditions to the status
+func (e *DASH) Enrich(api *bitmovin.BitmovinApi, s job.Status) (job.Status, error) {
+	list, err := api.Encoding.Manifests.Dash.List(func(q *query.DashManifestListQueryParams) {
+		q.EncodingId = s.ProviderJobID
+		q.Limit = 100
+	})
+	if err != nil || len(list.Items) == 0 {
+		return s, err
+	}
+	segs, err := listSegmented(api, s.ProviderJobID)
+	if err != nil {
+		return s, err
+	}
+	for _, m := range list.Items {
+		s = addPackage(s, newPackage(job.ContainerDASH, m.Outputs, m.ManifestName, segs, nil))
+	}
+	return s, nil
+}
+
+// segmented is a muxing of a rendition's segments
+type segmented struct {
+	id, name, dir string
+	segments      int
+}
+
+// listSegmented returns the encoding's TS and fMP4 muxings, with the
+// directory their segments are in
+func listSegmented(api *bitmovin.BitmovinApi, encID string) ([]segmented, error) {
+	var all []segmented
+	add := func(id, name, description string, outputs []model.EncodingOutput, n *int32) {
+		s := segmented{id: id, name: name, dir: description}
+		if len(outputs) > 0 {
+			s.dir = outputs[0].OutputPath
+		}
+		if s.dir == "" {
+			return
+		}
+		if n != nil {
+			s.segments = int(*n)
+		}
+		all = append(all, s)
+	}
+
+	for n, total := 0, 1; n < total; {
+		resp, err := api.Encoding.Encodings.Muxings.Ts.List(encID, func(q *query.TsMuxingListQueryParams) {
+			q.Offset = int32(n)
+			q.Limit = 100
+		})
+		if err != nil {
+			return nil, err
+		}
+		if resp.TotalCount == nil || len(resp.Items) == 0 {
+			break
+		}
+		total, n = int(*resp.TotalCount), n+len(resp.Items)
+		for _, m := range resp.Items {
+			add(m.Id, m.Name, m.Description, m.Outputs, m.SegmentsMuxed)
+		}
+	}
+	for n, total := 0, 1; n < total; {
+		resp, err := api.Encoding.Encodings.Muxings.Fmp4.List(encID, func(q *query.Fmp4MuxingListQueryParams) {
+			q.Offset = int32(n)
+			q.Limit = 100
+		})
+		if err != nil {
+			return nil, err
+		}
+		if resp.TotalCount == nil || len(resp.Items) == 0 {
+			break
+		}
+		total, n = int(*resp.TotalCount), n+len(resp.Items)
+		for _, m := range resp.Items {
+			add(m.Id, m.Name, m.Description, m.Outputs, m.SegmentsMuxed)
+		}
+	}
+	return all, nil
+}
+
+// newPackage returns the package whose manifest is written to outputs,
+// with the renditions segmented next to it. HLS renditions have a media
+// playlist, and groups maps audio renditions' muxings to their group.
+func newPackage(protocol string, outputs []model.EncodingOutput, manifest string, segs []segmented, groups map[string]string) job.Package {
+	if len(outputs) == 0 {
+		return job.Package{Protocol: protocol}
+	}
+	dir := job.File{Name: outputs[0].OutputPath}
+	pkg := job.Package{Protocol: protocol, Manifest: dir.Join(manifest).Name}
+	for _, s := range segs {
+		if s.dir != dir.Join(s.name).Name {
+			continue
+		}
+		r := job.Rendition{Name: s.name, Group: groups[s.id], Segments: s.segments}
+		if protocol == job.ContainerHLS {
+			r.Playlist = dir.Join(s.name + ".m3u8").Name
+		}
+		pkg.Renditions = append(pkg.Renditions, r)
+	}
+	return pkg
+}
+
+// addPackage adds the package, and its manifest as an output
+func addPackage(s job.Status, pkg job.Package) job.Status {
+	if pkg.Manifest == "" {
+		return s
+	}
+	s.Output.Add(job.File{Name: pkg.Manifest, Container: pkg.Protocol})
+	s.Packages = append(s.Packages, pkg)
+	return s
+}
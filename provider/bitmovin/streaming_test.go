@@ -0,0 +1,91 @@
+package bitmovin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bitmovin/bitmovin-api-sdk-go/model"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidateStreaming(t *testing.T) {
+	var (
+		video = job.Video{Codec: "h264"}
+		audio = job.Audio{Codec: "aac"}
+		key   = "00112233445566778899aabbccddeeff"
+	)
+	for _, tt := range []struct {
+		name      string
+		streaming job.Streaming
+		file      job.File
+		err       error
+	}{
+		{name: "Progressive", file: job.File{Container: "mp4", Video: video, Audio: audio}},
+		{name: "MuxedTS", file: job.File{Container: "hls", Video: video, Audio: audio}},
+		{name: "VideoDASH", file: job.File{Container: "mpd", Video: video}},
+		{name: "MuxedDASH", file: job.File{Container: "dash", Video: video, Audio: audio}, err: ErrUnsupported},
+		{name: "MuxedFMP4", streaming: job.Streaming{Segments: "fmp4"}, file: job.File{Container: "hls", Video: video, Audio: audio}, err: ErrUnsupported},
+		{name: "BadSegments", streaming: job.Streaming{Segments: "webm"}, file: job.File{Container: "hls", Video: video}, err: ErrUnsupported},
+		{name: "CMAF", file: job.File{Container: "cmaf", Video: video}, err: ErrUnsupported},
+		{name: "EncryptedDASH", streaming: job.Streaming{Encryption: &job.Encryption{Method: "cenc", Key: key, KeyID: key}}, file: job.File{Container: "dash", Video: video}},
+		{name: "AES128DASH", streaming: job.Streaming{Encryption: &job.Encryption{Method: "aes-128", Key: key}}, file: job.File{Container: "dash", Video: video}, err: ErrUnsupported},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Job{Streaming: tt.streaming, Output: job.Dir{File: []job.File{tt.file}}}
+			if err := validateStreaming(j); !errors.Is(err, tt.err) {
+				t.Fatalf("have %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestStartRequest(t *testing.T) {
+	req := startRequest(map[string]manifest{"hls": {id: "m3u8"}, "dash": {id: "mpd"}})
+	want := model.StartEncodingRequest{
+		VodHlsManifests:  []model.ManifestResource{{ManifestId: "m3u8"}},
+		VodDashManifests: []model.ManifestResource{{ManifestId: "mpd"}},
+	}
+	if diff := cmp.Diff(want, req); diff != "" {
+		t.Fatalf("start request (-want +have):\n%s", diff)
+	}
+	if diff := cmp.Diff(model.StartEncodingRequest{}, startRequest(nil)); diff != "" {
+		t.Fatalf("start request without manifests (-want +have):\n%s", diff)
+	}
+}
+
+func TestPackages(t *testing.T) {
+	segs := []segmented{
+		{id: "1", name: "1080p", dir: "s3://out/abc/hls/1080p", segments: 12},
+		{id: "2", name: "en", dir: "s3://out/abc/hls/en", segments: 12},
+		{id: "3", name: "1080p", dir: "s3://out/abc/dash/1080p", segments: 11},
+	}
+	outputs := func(dir string) []model.EncodingOutput {
+		return []model.EncodingOutput{{OutputPath: dir}}
+	}
+
+	var s job.Status
+	s = addPackage(s, newPackage(job.ContainerHLS, outputs("s3://out/abc/hls"), "master.m3u8", segs, map[string]string{"2": "audio"}))
+	s = addPackage(s, newPackage(job.ContainerDASH, outputs("s3://out/abc/dash"), "master.mpd", segs, nil))
+	s = addPackage(s, newPackage(job.ContainerDASH, nil, "master.mpd", segs, nil))
+
+	want := []job.Package{
+		{Protocol: "hls", Manifest: "s3://out/abc/hls/master.m3u8", Renditions: []job.Rendition{
+			{Name: "1080p", Playlist: "s3://out/abc/hls/1080p.m3u8", Segments: 12},
+			{Name: "en", Playlist: "s3://out/abc/hls/en.m3u8", Group: "audio", Segments: 12},
+		}},
+		{Protocol: "dash", Manifest: "s3://out/abc/dash/master.mpd", Renditions: []job.Rendition{
+			{Name: "1080p", Segments: 11},
+		}},
+	}
+	if diff := cmp.Diff(want, s.Packages); diff != "" {
+		t.Fatalf("packages (-want +have):\n%s", diff)
+	}
+	files := []job.File{
+		{Name: "s3://out/abc/hls/master.m3u8", Container: "hls"},
+		{Name: "s3://out/abc/dash/master.mpd", Container: "dash"},
+	}
+	if diff := cmp.Diff(files, s.Output.File); diff != "" {
+		t.Fatalf("outputs (-want +have):\n%s", diff)
+	}
+}
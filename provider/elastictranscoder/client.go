@@ -0,0 +1,260 @@
+package elastictranscoder
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// apiVersion prefixes every path of the Elastic Transcoder REST API
+const apiVersion = "/2012-09-25"
+
+var ErrNotFound = errors.New("not found")
+
+// elastictranscoderClient is the subset of the Elastic Transcoder API
+// the driver uses. There's no SDK for the service vendored here, so api
+// implements it over signed http requests.
+type elastictranscoderClient interface {
+	CreateJob(context.Context, *CreateJobInput) (*Job, error)
+	ReadJob(ctx context.Context, id string) (*Job, error)
+	CancelJob(ctx context.Context, id string) error
+	ReadPipeline(ctx context.Context, id string) (*Pipeline, error)
+	ListPresets(context.Context) ([]Preset, error)
+	CreatePreset(context.Context, *Preset) (*Preset, error)
+}
+
+type CreateJobInput struct {
+	PipelineId      string
+	Inputs          []JobInput
+	Outputs         []CreateJobOutput
+	OutputKeyPrefix string            `json:",omitempty"`
+	UserMetadata    map[string]string `json:",omitempty"`
+}
+
+type JobInput struct {
+	Key      string
+	TimeSpan *TimeSpan `json:",omitempty"`
+}
+
+// TimeSpan is a clip of the input, in HH:mm:ss.SSS or seconds
+type TimeSpan struct {
+	StartTime string `json:",omitempty"`
+	Duration  string `json:",omitempty"`
+}
+
+type CreateJobOutput struct {
+	Key      string
+	PresetId string
+}
+
+type Job struct {
+	Id              string
+	PipelineId      string
+	Status          string
+	OutputKeyPrefix string
+	Outputs         []JobOutput
+	Timing          *Timing `json:",omitempty"`
+}
+
+type JobOutput struct {
+	Id             string
+	Key            string
+	PresetId       string
+	Status         string
+	StatusDetail   string
+	Duration       int64
+	DurationMillis int64
+	FileSize       int64
+	Width          int
+	Height         int
+}
+
+type Timing struct {
+	SubmitTimeMillis int64
+	StartTimeMillis  int64
+	FinishTimeMillis int64
+}
+
+type Pipeline struct {
+	Id           string
+	Name         string
+	Status       string
+	InputBucket  string
+	OutputBucket string
+}
+
+type Preset struct {
+	Id          string `json:",omitempty"`
+	Name        string
+	Description string `json:",omitempty"`
+	Container   string
+	Type        string        `json:",omitempty"`
+	Video       *PresetVideo  `json:",omitempty"`
+	Audio       *PresetAudio  `json:",omitempty"`
+	Thumbnails  *PresetThumbs `json:",omitempty"`
+}
+
+type PresetVideo struct {
+	Codec              string
+	CodecOptions       map[string]string `json:",omitempty"`
+	KeyframesMaxDist   string            `json:",omitempty"`
+	FixedGOP           string            `json:",omitempty"`
+	BitRate            string
+	FrameRate          string
+	MaxWidth           string
+	MaxHeight          string
+	DisplayAspectRatio string
+	SizingPolicy       string
+	PaddingPolicy      string
+}
+
+type PresetAudio struct {
+	Codec      string
+	SampleRate string
+	BitRate    string
+	Channels   string
+}
+
+type PresetThumbs struct {
+	Format        string
+	Interval      string
+	MaxWidth      string
+	MaxHeight     string
+	SizingPolicy  string
+	PaddingPolicy string
+}
+
+// api is an elastictranscoderClient for the service's REST API
+type api struct {
+	endpoint string
+	region   string
+	signer   *v4.Signer
+	client   *http.Client
+}
+
+func newAPI(creds aws.CredentialsProvider, region string) *api {
+	return &api{
+		endpoint: fmt.Sprintf("https://elastictranscoder.%s.amazonaws.com", region),
+		region:   region,
+		signer:   v4.NewSigner(creds),
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (a *api) CreateJob(ctx context.Context, in *CreateJobInput) (*Job, error) {
+	var out struct{ Job Job }
+	if err := a.do(ctx, http.MethodPost, "/jobs", in, &out); err != nil {
+		return nil, err
+	}
+	return &out.Job, nil
+}
+
+func (a *api) ReadJob(ctx context.Context, id string) (*Job, error) {
+	var out struct{ Job Job }
+	if err := a.do(ctx, http.MethodGet, "/jobs/"+url.PathEscape(id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out.Job, nil
+}
+
+func (a *api) CancelJob(ctx context.Context, id string) error {
+	return a.do(ctx, http.MethodDelete, "/jobs/"+url.PathEscape(id), nil, nil)
+}
+
+func (a *api) ReadPipeline(ctx context.Context, id string) (*Pipeline, error) {
+	var out struct{ Pipeline Pipeline }
+	if err := a.do(ctx, http.MethodGet, "/pipelines/"+url.PathEscape(id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out.Pipeline, nil
+}
+
+func (a *api) ListPresets(ctx context.Context) (list []Preset, err error) {
+	page := ""
+	for {
+		var out struct {
+			Presets       []Preset
+			NextPageToken string
+		}
+		path := "/presets"
+		if page != "" {
+			path += "?PageToken=" + url.QueryEscape(page)
+		}
+		if err := a.do(ctx, http.MethodGet, path, nil, &out); err != nil {
+			return nil, err
+		}
+		list = append(list, out.Presets...)
+		if page = out.NextPageToken; page == "" {
+			return list, nil
+		}
+	}
+}
+
+func (a *api) CreatePreset(ctx context.Context, p *Preset) (*Preset, error) {
+	var out struct{ Preset Preset }
+	if err := a.do(ctx, http.MethodPost, "/presets", p, &out); err != nil {
+		return nil, err
+	}
+	return &out.Preset, nil
+}
+
+// do signs and sends in, if any, as json and decodes the response into out, if any
+func (a *api) do(ctx context.Context, method, path string, in, out interface{}) error {
+	body := []byte{}
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("marshaling request: %w", err)
+		}
+		body = data
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.endpoint+apiVersion+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	sum := sha256.Sum256(body)
+	if err := a.signer.SignHTTP(ctx, req, hex.EncodeToString(sum[:]), "elastictranscoder", a.region, time.Now()); err != nil {
+		return fmt.Errorf("signing request: %w", err)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %w", err)
+	}
+	if c := resp.StatusCode; c/100 != 2 {
+		var e struct{ Message string }
+		json.Unmarshal(data, &e)
+		if e.Message == "" {
+			e.Message = string(data)
+		}
+		if c == http.StatusNotFound {
+			return fmt.Errorf("%w: %s", ErrNotFound, e.Message)
+		}
+		return fmt.Errorf("received non 2xx status code, got %d: %s", c, e.Message)
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("parsing elastic transcoder response: %w", err)
+	}
+	return nil
+}
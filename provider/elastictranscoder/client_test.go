@@ -0,0 +1,66 @@
+package elastictranscoder
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestAPI(t *testing.T) {
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=id/") || !strings.Contains(auth, "/us-east-1/elastictranscoder/aws4_request") {
+			t.Errorf("bad authorization: %q", auth)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		seen = append(seen, r.Method+" "+r.URL.RequestURI()+" "+string(body))
+		switch r.URL.Path {
+		case "/2012-09-25/jobs":
+			w.Write([]byte(`{"Job": {"Id": "1", "Status": "Submitted"}}`))
+		case "/2012-09-25/presets":
+			if r.URL.Query().Get("PageToken") == "" {
+				w.Write([]byte(`{"Presets": [{"Id": "a"}], "NextPageToken": "next"}`))
+				return
+			}
+			w.Write([]byte(`{"Presets": [{"Id": "b"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"Message": "The specified job was not found"}`))
+		}
+	}))
+	defer srv.Close()
+
+	a := newAPI(aws.StaticCredentialsProvider{Value: aws.Credentials{AccessKeyID: "id", SecretAccessKey: "secret"}}, "us-east-1")
+	a.endpoint, a.client = srv.URL, srv.Client()
+	ctx := context.Background()
+
+	j, err := a.CreateJob(ctx, &CreateJobInput{PipelineId: "pipe"})
+	if err != nil || j.Id != "1" {
+		t.Fatalf("create: %+v, %v", j, err)
+	}
+	list, err := a.ListPresets(ctx)
+	if err != nil || len(list) != 2 {
+		t.Fatalf("list: %+v, %v", list, err)
+	}
+	err = a.CancelJob(ctx, "2")
+	if !errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "was not found") {
+		t.Fatalf("cancel: have %v, want ErrNotFound", err)
+	}
+
+	want := []string{
+		`POST /2012-09-25/jobs {"PipelineId":"pipe","Inputs":null,"Outputs":null}`,
+		`GET /2012-09-25/presets `,
+		`GET /2012-09-25/presets?PageToken=next `,
+		`DELETE /2012-09-25/jobs/2 `,
+	}
+	if strings.Join(seen, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("requests:\n%s\nwant:\n%s", strings.Join(seen, "\n"), strings.Join(want, "\n"))
+	}
+}
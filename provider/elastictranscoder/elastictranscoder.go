@@ -0,0 +1,277 @@
+package elastictranscoder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/external"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/logging"
+	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/cbsinteractive/transcode-orchestrator/trace"
+	"github.com/zsiec/pkg/tracing"
+)
+
+// Name identifies the Elastic Transcoder provider by name
+const Name = "elastictranscoder"
+
+var ErrUnsupported = errors.New("unsupported")
+
+func init() {
+	err := provider.Register(Name, factory)
+	if err != nil {
+		logging.From(context.Background()).WithError(err).Error("registering elastictranscoder factory")
+	}
+}
+
+type driver struct {
+	client elastictranscoderClient
+	cfg    config.ElasticTranscoder
+	tracer tracing.Tracer
+}
+
+func (p *driver) trace(ctx context.Context, name string, err *error) func() {
+	_, x := trace.Begin(ctx, p.tracer, name)
+	return func() {
+		x.Close(*err)
+		logging.Done(ctx, name, *err)
+	}
+}
+
+func (p *driver) Create(ctx context.Context, j *job.Job) (st *job.Status, err error) {
+	defer p.trace(ctx, "elastictranscoder-create-job", &err)()
+
+	pipe, err := p.client.ReadPipeline(ctx, p.cfg.PipelineID)
+	if err != nil {
+		return nil, fmt.Errorf("reading pipeline %s: %w", p.cfg.PipelineID, err)
+	}
+	in, err := p.request(ctx, j, pipe)
+	if err != nil {
+		return nil, err
+	}
+	created, err := p.client.CreateJob(ctx, in)
+	if err != nil {
+		return nil, fmt.Errorf("submitting new job: %w", err)
+	}
+	return &job.Status{
+		Provider:      Name,
+		ProviderJobID: created.Id,
+		State:         job.StateQueued,
+	}, nil
+}
+
+func (p *driver) request(ctx context.Context, j *job.Job, pipe *Pipeline) (*CreateJobInput, error) {
+	src, err := key(j.Input.Name, pipe.InputBucket)
+	if err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	in := &CreateJobInput{
+		PipelineId: pipe.Id,
+		Inputs:     clips(src, j.Input),
+	}
+	if len(j.Labels) > 0 {
+		in.UserMetadata = map[string]string{}
+		for _, label := range j.Labels {
+			in.UserMetadata[label] = "true"
+		}
+	}
+
+	ids, err := p.presets(ctx, j.Output.File)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range j.Output.File {
+		dst, err := key(j.Location(f.Name), pipe.OutputBucket)
+		if err != nil {
+			return nil, fmt.Errorf("output %q: %w", f.Name, err)
+		}
+		in.Outputs = append(in.Outputs, CreateJobOutput{Key: dst, PresetId: ids[i]})
+	}
+	return in, nil
+}
+
+// clips turns the input's splice into one input per range, which
+// Elastic Transcoder stitches together in order
+func clips(key string, f job.File) []JobInput {
+	if len(f.Splice) == 0 {
+		return []JobInput{{Key: key}}
+	}
+	sec := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	in := make([]JobInput, 0, len(f.Splice))
+	for _, r := range f.Splice {
+		r = r.Canon()
+		in = append(in, JobInput{Key: key, TimeSpan: &TimeSpan{
+			StartTime: sec(r[0]),
+			Duration:  sec(r[1] - r[0]),
+		}})
+	}
+	return in
+}
+
+// key returns the object key of an s3 url, which must be in the
+// pipeline's bucket. A name without a scheme is a key already.
+func key(name, bucket string) (string, error) {
+	u, err := url.Parse(name)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" {
+		return strings.TrimPrefix(u.Path, "/"), nil
+	}
+	loc, err := storage.Check(name, storage.S3)
+	if err != nil {
+		return "", err
+	}
+	if loc.Bucket != bucket {
+		return "", fmt.Errorf("bucket %q is not the pipeline's bucket %q", loc.Bucket, bucket)
+	}
+	return loc.Key, nil
+}
+
+func (p *driver) Status(ctx context.Context, j *job.Job) (st *job.Status, err error) {
+	defer p.trace(ctx, "elastictranscoder-get-job", &err)()
+
+	et, err := p.client.ReadJob(ctx, j.ProviderJobID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, provider.JobNotFoundError{ID: j.ProviderJobID}
+	} else if err != nil {
+		return nil, fmt.Errorf("reading provider job %s: %w", j.ProviderJobID, err)
+	}
+	pipe, err := p.client.ReadPipeline(ctx, et.PipelineId)
+	if err != nil {
+		return nil, fmt.Errorf("reading pipeline %s: %w", et.PipelineId, err)
+	}
+
+	st = &job.Status{
+		Provider:      Name,
+		ProviderJobID: j.ProviderJobID,
+		State:         state(et.Status),
+		Labels:        j.Labels,
+		Output:        job.Dir{Path: dir(*j, pipe.OutputBucket)},
+		ProviderStatus: map[string]interface{}{
+			"status":   et.Status,
+			"pipeline": et.PipelineId,
+		},
+	}
+	if t := et.Timing; t != nil {
+		st.ProviderStatus["submitTimeMillis"] = t.SubmitTimeMillis
+		st.ProviderStatus["startTimeMillis"] = t.StartTimeMillis
+		st.ProviderStatus["finishTimeMillis"] = t.FinishTimeMillis
+	}
+
+	done := 0
+	for _, o := range et.Outputs {
+		if o.StatusDetail != "" && st.Msg == "" {
+			st.Msg = o.StatusDetail
+		}
+		if o.Status != "Complete" {
+			continue
+		}
+		done++
+		dur := time.Duration(o.DurationMillis) * time.Millisecond
+		if dur == 0 {
+			dur = time.Duration(o.Duration) * time.Second
+		}
+		st.Output.Add(job.File{
+			Name:     "s3://" + pipe.OutputBucket + "/" + et.OutputKeyPrefix + o.Key,
+			Size:     o.FileSize,
+			Duration: dur,
+			Video:    job.Video{Width: o.Width, Height: o.Height},
+		})
+	}
+	if n := len(et.Outputs); n > 0 {
+		st.Progress = 100 * float64(done) / float64(n)
+	}
+	return st, nil
+}
+
+func dir(j job.Job, bucket string) string {
+	if j.Output.Path == "" {
+		j.Output.Path = "s3://" + bucket
+	}
+	return j.Location("")
+}
+
+func state(s string) job.State {
+	switch s {
+	case "Submitted":
+		return job.StateQueued
+	case "Progressing":
+		return job.StateStarted
+	case "Complete":
+		return job.StateFinished
+	case "Canceled":
+		return job.StateCanceled
+	case "Error":
+		return job.StateFailed
+	}
+	return job.StateUnknown
+}
+
+// Cancel cancels a job. Elastic Transcoder only cancels jobs that
+// haven't started yet.
+func (p *driver) Cancel(ctx context.Context, id string) (err error) {
+	defer p.trace(ctx, "elastictranscoder-cancel-job", &err)()
+	err = p.client.CancelJob(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		err = provider.JobNotFoundError{ID: id}
+	}
+	return err
+}
+
+func (p *driver) Healthcheck() error {
+	pipe, err := p.client.ReadPipeline(context.Background(), p.cfg.PipelineID)
+	if err != nil {
+		return fmt.Errorf("reading pipeline: %w", err)
+	}
+	if pipe.Status != "Active" {
+		return fmt.Errorf("pipeline %s is %s", pipe.Id, pipe.Status)
+	}
+	return nil
+}
+
+func (*driver) Capabilities() provider.Capabilities {
+	return provider.Capabilities{
+		InputFormats:  []string{"h264", "mpeg2"},
+		OutputFormats: []string{"mp4", "ts", "webm", "mp3", "flac"},
+		Destinations:  []string{storage.S3},
+	}
+}
+
+func factory(cfg *config.Config) (provider.Provider, error) {
+	et := cfg.ElasticTranscoder
+	if et == nil || et.PipelineID == "" {
+		return nil, errors.New("incomplete Elastic Transcoder config")
+	}
+
+	awsCfg, err := external.LoadDefaultAWSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loading default aws config: %w", err)
+	}
+	if et.AccessKeyID+et.SecretAccessKey != "" {
+		awsCfg.Credentials = &aws.StaticCredentialsProvider{Value: aws.Credentials{
+			AccessKeyID:     et.AccessKeyID,
+			SecretAccessKey: et.SecretAccessKey,
+		}}
+	}
+	if et.Region != "" {
+		awsCfg.Region = et.Region
+	}
+	if awsCfg.Region == "" {
+		return nil, errors.New("no Elastic Transcoder region")
+	}
+
+	return &driver{
+		client: newAPI(awsCfg.Credentials, awsCfg.Region),
+		cfg:    *et,
+		tracer: cfg.Tracer,
+	}, nil
+}
@@ -0,0 +1,277 @@
+package elastictranscoder
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cbsinteractive/pkg/timecode"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakeClient struct {
+	pipeline Pipeline
+	presets  []Preset
+	job      *Job
+
+	created  []Preset
+	input    *CreateJobInput
+	canceled []string
+}
+
+func (c *fakeClient) CreateJob(_ context.Context, in *CreateJobInput) (*Job, error) {
+	c.input = in
+	return &Job{Id: "1600000000000-abcdef", PipelineId: in.PipelineId, Status: "Submitted"}, nil
+}
+
+func (c *fakeClient) ReadJob(_ context.Context, id string) (*Job, error) {
+	if c.job == nil || c.job.Id != id {
+		return nil, ErrNotFound
+	}
+	return c.job, nil
+}
+
+func (c *fakeClient) CancelJob(_ context.Context, id string) error {
+	if c.job == nil || c.job.Id != id {
+		return ErrNotFound
+	}
+	c.canceled = append(c.canceled, id)
+	return nil
+}
+
+func (c *fakeClient) ReadPipeline(_ context.Context, id string) (*Pipeline, error) {
+	if id != c.pipeline.Id {
+		return nil, ErrNotFound
+	}
+	return &c.pipeline, nil
+}
+
+func (c *fakeClient) ListPresets(context.Context) ([]Preset, error) {
+	return c.presets, nil
+}
+
+func (c *fakeClient) CreatePreset(_ context.Context, p *Preset) (*Preset, error) {
+	cp := *p
+	cp.Id, cp.Type = "custom-"+p.Name, "Custom"
+	c.created = append(c.created, cp)
+	c.presets = append(c.presets, cp)
+	return &cp, nil
+}
+
+func newDriver() (*driver, *fakeClient) {
+	c := &fakeClient{pipeline: Pipeline{Id: "pipe", Status: "Active", InputBucket: "in", OutputBucket: "out"}}
+	return &driver{client: c, cfg: config.ElasticTranscoder{PipelineID: "pipe"}}, c
+}
+
+var (
+	hd = job.File{Name: "hd.mp4", Video: job.Video{Codec: "h264", Height: 1080}}
+	sd = job.File{
+		Name: "sd.webm",
+		Video: job.Video{
+			Codec: "vp9", Width: 640, Height: 360, FPS: 30,
+			Bitrate: job.Bitrate{BPS: 1000000},
+			Gop:     job.Gop{Unit: "seconds", Size: 2},
+		},
+		Audio:   job.Audio{Codec: "vorbis", Bitrate: 96000},
+		Downmix: &job.Downmix{Dst: make([]job.AudioChannel, 2)},
+	}
+)
+
+func TestCreate(t *testing.T) {
+	p, c := newDriver()
+	j := &job.Job{
+		ID:     "abc",
+		Labels: []string{"news"},
+		Input: job.File{
+			Name:   "s3://in/src/movie.mov",
+			Splice: timecode.Splice{{0, 10}, {20.5, 30}},
+		},
+		Output: job.Dir{Path: "s3://out/dst", File: []job.File{hd, sd, sd}},
+	}
+	st, err := p.Create(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.ProviderJobID != "1600000000000-abcdef" || st.State != job.StateQueued {
+		t.Fatalf("bad status: %+v", st)
+	}
+
+	if len(c.created) != 1 {
+		t.Fatalf("created %d presets, want 1 shared by both sd outputs", len(c.created))
+	}
+	id := c.created[0].Id
+	want := &CreateJobInput{
+		PipelineId: "pipe",
+		Inputs: []JobInput{
+			{Key: "src/movie.mov", TimeSpan: &TimeSpan{StartTime: "0.000", Duration: "10.000"}},
+			{Key: "src/movie.mov", TimeSpan: &TimeSpan{StartTime: "20.500", Duration: "9.500"}},
+		},
+		Outputs: []CreateJobOutput{
+			{Key: "dst/abc/hd.mp4", PresetId: "1351620000001-000001"},
+			{Key: "dst/abc/sd.webm", PresetId: id},
+			{Key: "dst/abc/sd.webm", PresetId: id},
+		},
+		UserMetadata: map[string]string{"news": "true"},
+	}
+	if diff := cmp.Diff(want, c.input); diff != "" {
+		t.Fatalf("request mismatch (-want +have):\n%s", diff)
+	}
+
+	// the next job reuses the preset
+	if _, err := p.Create(context.Background(), j); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.created) != 1 {
+		t.Fatalf("created %d presets, want the existing one reused", len(c.created))
+	}
+}
+
+func TestCreateErrors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		j    job.Job
+		want error
+	}{
+		"InputBucket": {job.Job{Input: job.File{Name: "s3://other/a.mov"}}, nil},
+		"OutputBucket": {job.Job{Input: job.File{Name: "s3://in/a.mov"},
+			Output: job.Dir{Path: "s3://other", File: []job.File{hd}}}, nil},
+		"Scheme":    {job.Job{Input: job.File{Name: "gs://in/a.mov"}}, storage.ErrUnsupported},
+		"Codec":     {job.Job{Output: job.Dir{File: []job.File{{Name: "a.mp4", Video: job.Video{Codec: "h265"}}}}}, ErrUnsupported},
+		"Container": {job.Job{Output: job.Dir{File: []job.File{{Name: "a.mov", Video: job.Video{Codec: "h264"}}}}}, ErrUnsupported},
+		"Empty":     {job.Job{Output: job.Dir{File: []job.File{{Name: "a.mp4"}}}}, ErrUnsupported},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p, _ := newDriver()
+			_, err := p.Create(context.Background(), &tt.j)
+			if err == nil {
+				t.Fatal("no error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("have %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomPreset(t *testing.T) {
+	f := sd
+	f.Name = "sd.mp4"
+	f.Video.Codec = "h264"
+	f.Video.Profile = "High"
+	f.Video.Bitrate.Control = "CBR"
+	f.Audio.Codec = "aac"
+	have, err := custom(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &PresetVideo{
+		Codec: "H.264",
+		CodecOptions: map[string]string{
+			"Profile": "high", "Level": "4", "MaxReferenceFrames": "3",
+			"MaxBitRate": "1000", "BufferSize": "2000",
+		},
+		KeyframesMaxDist: "60", FixedGOP: "true",
+		BitRate: "1000", FrameRate: "30", MaxWidth: "640", MaxHeight: "360",
+		DisplayAspectRatio: "auto", SizingPolicy: "Fit", PaddingPolicy: "NoPad",
+	}
+	if diff := cmp.Diff(want, have.Video); diff != "" {
+		t.Fatalf("video mismatch (-want +have):\n%s", diff)
+	}
+	if a := have.Audio; a.Codec != "AAC" || a.BitRate != "96" || a.Channels != "2" {
+		t.Fatalf("bad audio: %+v", a)
+	}
+	if have.Container != "mp4" || have.Thumbnails == nil {
+		t.Fatalf("bad preset: %+v", have)
+	}
+}
+
+func TestSystemPreset(t *testing.T) {
+	for _, tt := range []struct {
+		f    job.File
+		want string
+	}{
+		{hd, "1351620000001-000001"},
+		{job.File{Name: "a.mp4", Video: job.Video{Width: 1280, Height: 720}, Audio: job.Audio{Codec: "aac"}}, "1351620000001-000010"},
+		{job.File{Name: "a.mp4", Video: job.Video{Width: 1000, Height: 720}}, ""},
+		{job.File{Name: "a.mp4", Video: job.Video{Height: 720, FPS: 25}}, ""},
+		{job.File{Name: "a.mp4", Video: job.Video{Height: 540}}, ""},
+		{job.File{Name: "a.webm", Video: job.Video{Height: 720}}, ""},
+	} {
+		if have, _ := system(tt.f); have != tt.want {
+			t.Errorf("%+v: have %q, want %q", tt.f, have, tt.want)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	p, c := newDriver()
+	c.job = &Job{
+		Id: "1", PipelineId: "pipe", Status: "Progressing", OutputKeyPrefix: "dst/",
+		Outputs: []JobOutput{
+			{Key: "abc/hd.mp4", Status: "Complete", Duration: 20, DurationMillis: 19500, FileSize: 1000, Width: 1920, Height: 1080},
+			{Key: "abc/sd.webm", Status: "Error", StatusDetail: "4000 bad preset"},
+		},
+	}
+	st, err := p.Status(context.Background(), &job.Job{ID: "abc", ProviderJobID: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &job.Status{
+		Provider:      Name,
+		ProviderJobID: "1",
+		State:         job.StateStarted,
+		Msg:           "4000 bad preset",
+		Progress:      50,
+		Output: job.Dir{Path: "s3://out/abc", File: []job.File{{
+			Name: "s3://out/dst/abc/hd.mp4", Size: 1000, Duration: 19500 * time.Millisecond,
+			Video: job.Video{Width: 1920, Height: 1080},
+		}}},
+		ProviderStatus: map[string]interface{}{"status": "Progressing", "pipeline": "pipe"},
+	}
+	if diff := cmp.Diff(want, st); diff != "" {
+		t.Fatalf("status mismatch (-want +have):\n%s", diff)
+	}
+
+	for s, want := range map[string]job.State{
+		"Submitted": job.StateQueued, "Complete": job.StateFinished,
+		"Canceled": job.StateCanceled, "Error": job.StateFailed, "Strange": job.StateUnknown,
+	} {
+		if have := state(s); have != want {
+			t.Errorf("state(%q): have %q, want %q", s, have, want)
+		}
+	}
+
+	_, err = p.Status(context.Background(), &job.Job{ProviderJobID: "2"})
+	if !errors.As(err, &provider.JobNotFoundError{}) {
+		t.Fatalf("have %v, want JobNotFoundError", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	p, c := newDriver()
+	c.job = &Job{Id: "1"}
+	if err := p.Cancel(context.Background(), "1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.canceled) != 1 {
+		t.Fatalf("canceled %v", c.canceled)
+	}
+	if err := p.Cancel(context.Background(), "2"); !errors.As(err, &provider.JobNotFoundError{}) {
+		t.Fatalf("have %v, want JobNotFoundError", err)
+	}
+}
+
+func TestHealthcheck(t *testing.T) {
+	p, c := newDriver()
+	if err := p.Healthcheck(); err != nil {
+		t.Fatal(err)
+	}
+	c.pipeline.Status = "Paused"
+	if err := p.Healthcheck(); err == nil {
+		t.Fatal("paused pipeline is healthy")
+	}
+}
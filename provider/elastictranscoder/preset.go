@@ -0,0 +1,227 @@
+package elastictranscoder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+)
+
+// presetPrefix names the custom presets the driver creates. A custom
+// preset is named for a hash of its settings, so jobs asking for the
+// same settings share a preset instead of using up the account's limit.
+const presetPrefix = "orchestrator-"
+
+// systemPresets are Elastic Transcoder's generic mp4 presets by height
+var systemPresets = map[int]struct {
+	id    string
+	width int
+}{
+	1080: {"1351620000001-000001", 1920},
+	720:  {"1351620000001-000010", 1280},
+	480:  {"1351620000001-000020", 854},
+	360:  {"1351620000001-000040", 640},
+}
+
+// system returns the system preset for a file that asks only for an
+// mp4 of a generic height, with default h264 video and aac audio
+func system(f job.File) (string, bool) {
+	if container(f) != "mp4" || f.Downmix != nil {
+		return "", false
+	}
+	sys, ok := systemPresets[f.Video.Height]
+	if !ok {
+		return "", false
+	}
+	v := f.Video
+	if strings.ToLower(v.Codec) != "h264" && v.Codec != "" {
+		return "", false
+	}
+	if v.Width != sys.width && v.Width != 0 {
+		return "", false
+	}
+	v.Codec, v.Width, v.Height = "", 0, 0
+	if !reflect.DeepEqual(v, job.Video{}) {
+		return "", false
+	}
+	if a := f.Audio; a != (job.Audio{}) && a != (job.Audio{Codec: "aac"}) {
+		return "", false
+	}
+	return sys.id, true
+}
+
+var (
+	containers = map[string]bool{
+		"mp4": true, "fmp4": true, "ts": true, "webm": true, "mpg": true, "flv": true, "mxf": true,
+		"mp3": true, "flac": true, "ogg": true, "wav": true, "gif": true,
+	}
+	videoCodecs = map[string]string{
+		"h264":  "H.264",
+		"avc":   "H.264",
+		"vp8":   "vp8",
+		"vp9":   "vp9",
+		"mpeg2": "mpeg2",
+	}
+	audioCodecs = map[string]string{
+		"aac":    "AAC",
+		"mp3":    "mp3",
+		"vorbis": "vorbis",
+		"flac":   "flac",
+		"pcm":    "pcm",
+	}
+)
+
+func container(f job.File) string {
+	if f.Container != "" {
+		return strings.ToLower(f.Container)
+	}
+	return f.Type()
+}
+
+// custom returns the custom preset for the file's settings
+func custom(f job.File) (*Preset, error) {
+	p := &Preset{Container: container(f)}
+	if !containers[p.Container] {
+		return nil, fmt.Errorf("%w: container %q", ErrUnsupported, p.Container)
+	}
+
+	if v := f.Video; v.On() {
+		codec, ok := videoCodecs[strings.ToLower(v.Codec)]
+		if !ok {
+			return nil, fmt.Errorf("%w: video codec %q", ErrUnsupported, v.Codec)
+		}
+		if !v.Crop.Empty() {
+			return nil, fmt.Errorf("%w: crop", ErrUnsupported)
+		}
+		p.Video = &PresetVideo{
+			Codec:              codec,
+			BitRate:            auto(v.Bitrate.Kbps()),
+			FrameRate:          "auto",
+			MaxWidth:           auto(v.Width),
+			MaxHeight:          auto(v.Height),
+			DisplayAspectRatio: "auto",
+			SizingPolicy:       "Fit",
+			PaddingPolicy:      "NoPad",
+		}
+		if v.FPS != 0 {
+			p.Video.FrameRate = strconv.FormatFloat(v.FPS, 'f', -1, 64)
+		}
+		if codec == "H.264" {
+			p.Video.CodecOptions = map[string]string{
+				"Profile":            strings.ToLower(v.Profile),
+				"Level":              v.Level,
+				"MaxReferenceFrames": "3",
+			}
+			if p.Video.CodecOptions["Profile"] == "" {
+				p.Video.CodecOptions["Profile"] = "main"
+			}
+			if v.Level == "" {
+				p.Video.CodecOptions["Level"] = "4"
+			}
+			if strings.EqualFold(v.Bitrate.Control, "CBR") && v.Bitrate.BPS != 0 {
+				p.Video.CodecOptions["MaxBitRate"] = p.Video.BitRate
+				p.Video.CodecOptions["BufferSize"] = strconv.Itoa(2 * v.Bitrate.Kbps())
+			}
+		}
+		if size := v.Gop.Size; size != 0 {
+			if v.Gop.Seconds() {
+				if v.FPS == 0 {
+					return nil, fmt.Errorf("%w: gop in seconds without a frame rate", ErrUnsupported)
+				}
+				size *= v.FPS
+			}
+			p.Video.KeyframesMaxDist = strconv.Itoa(int(size))
+			p.Video.FixedGOP = "true"
+		}
+		p.Thumbnails = &PresetThumbs{
+			Format:        "png",
+			Interval:      "60",
+			MaxWidth:      "auto",
+			MaxHeight:     "auto",
+			SizingPolicy:  "ShrinkToFit",
+			PaddingPolicy: "NoPad",
+		}
+	}
+
+	if a := f.Audio; a.On() {
+		codec, ok := audioCodecs[strings.ToLower(a.Codec)]
+		if !ok {
+			return nil, fmt.Errorf("%w: audio codec %q", ErrUnsupported, a.Codec)
+		}
+		p.Audio = &PresetAudio{
+			Codec:      codec,
+			SampleRate: "auto",
+			BitRate:    strconv.Itoa(a.Bitrate / 1000),
+			Channels:   "auto",
+		}
+		if a.Bitrate == 0 {
+			p.Audio.BitRate = "128"
+		}
+		if f.Downmix != nil {
+			p.Audio.Channels = strconv.Itoa(len(f.Downmix.Dst))
+		}
+	}
+
+	if p.Video == nil && p.Audio == nil {
+		return nil, fmt.Errorf("%w: no video or audio", ErrUnsupported)
+	}
+
+	data, _ := json.Marshal(p)
+	sum := sha256.Sum256(data)
+	p.Name = presetPrefix + hex.EncodeToString(sum[:8])
+	p.Description = "created by the transcode orchestrator"
+	return p, nil
+}
+
+func auto(n int) string {
+	if n == 0 {
+		return "auto"
+	}
+	return strconv.Itoa(n)
+}
+
+// presets returns the preset id for every output file, reusing system
+// presets and the driver's custom presets, and creating missing ones
+func (p *driver) presets(ctx context.Context, files []job.File) ([]string, error) {
+	ids := make([]string, len(files))
+	var existing map[string]string
+	for i, f := range files {
+		if id, ok := system(f); ok {
+			ids[i] = id
+			continue
+		}
+		want, err := custom(f)
+		if err != nil {
+			return nil, fmt.Errorf("output %q: %w", f.Name, err)
+		}
+		if existing == nil {
+			list, err := p.client.ListPresets(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("listing presets: %w", err)
+			}
+			existing = map[string]string{}
+			for _, ps := range list {
+				if ps.Type == "Custom" && strings.HasPrefix(ps.Name, presetPrefix) {
+					existing[ps.Name] = ps.Id
+				}
+			}
+		}
+		if id, ok := existing[want.Name]; ok {
+			ids[i] = id
+			continue
+		}
+		created, err := p.client.CreatePreset(ctx, want)
+		if err != nil {
+			return nil, fmt.Errorf("creating preset for output %q: %w", f.Name, err)
+		}
+		existing[want.Name] = created.Id
+		ids[i] = created.Id
+	}
+	return ids, nil
+}
@@ -0,0 +1,292 @@
+package elementalconductor
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/logging"
+	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/cbsinteractive/transcode-orchestrator/trace"
+	"github.com/zsiec/pkg/tracing"
+)
+
+// Name identifies the Elemental Conductor provider by name
+const Name = "elementalconductor"
+
+// defaultAuthExpires is how long, in seconds, a signed request is valid
+// when the config doesn't say
+const defaultAuthExpires = 120
+
+var (
+	ErrUnsupported = errors.New("unsupported")
+	errNotFound    = errors.New("not found")
+)
+
+// sources and destinations are the storage Conductor reads and writes.
+// Its nodes can read files mounted on them.
+var (
+	sources      = []string{storage.S3, storage.HTTP, storage.HTTPS, storage.File}
+	destinations = []string{storage.S3}
+)
+
+func init() {
+	err := provider.Register(Name, factory)
+	if err != nil {
+		logging.From(context.Background()).WithError(err).Error("registering elementalconductor factory")
+	}
+}
+
+type driver struct {
+	cfg    *config.ElementalConductor
+	client *http.Client
+	tracer tracing.Tracer
+	now    func() time.Time
+}
+
+func (p *driver) trace(ctx context.Context, name string, err *error) func() {
+	_, x := trace.Begin(ctx, p.tracer, name)
+	return func() {
+		x.Close(*err)
+		logging.Done(ctx, name, *err)
+	}
+}
+
+func (p *driver) Create(ctx context.Context, j *job.Job) (st *job.Status, err error) {
+	cj, err := p.create(j, Location{Username: p.cfg.AccessKeyID, Password: p.cfg.SecretAccessKey})
+	if err != nil {
+		return nil, err
+	}
+
+	defer p.trace(ctx, "elementalconductor-create-job", &err)()
+	var created Job
+	if err = p.do(ctx, http.MethodPost, "/jobs", cj, &created); err != nil {
+		return nil, fmt.Errorf("submitting new job: %w", err)
+	}
+	return &job.Status{
+		Provider:      Name,
+		ProviderJobID: created.ID(),
+		State:         job.StateQueued,
+	}, nil
+}
+
+// DryRun returns the job Create would submit, without the storage
+// credentials
+func (p *driver) DryRun(_ context.Context, j *job.Job) ([]byte, error) {
+	cj, err := p.create(j, Location{})
+	if err != nil {
+		return nil, err
+	}
+	return xml.MarshalIndent(cj, "", "\t")
+}
+
+// create returns the conductor job for j after checking its storage.
+// Conductor reads and writes s3 with the credentials in loc.
+func (p *driver) create(j *job.Job, loc Location) (*Job, error) {
+	cp := *j
+	if cp.Output.Path == "" {
+		cp.Output.Path = p.cfg.Destination
+	}
+	cj, err := newJob(&cp, loc)
+	if err != nil {
+		return nil, fmt.Errorf("generating conductor job: %w", err)
+	}
+	if _, err = storage.Check(cp.Input.Name, sources...); err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	if _, err = storage.Check(cp.Output.Path, destinations...); err != nil {
+		return nil, fmt.Errorf("output: %w", err)
+	}
+	return cj, nil
+}
+
+func (p *driver) Status(ctx context.Context, j *job.Job) (st *job.Status, err error) {
+	defer p.trace(ctx, "elementalconductor-get-job", &err)()
+
+	var cj Job
+	err = p.do(ctx, http.MethodGet, "/jobs/"+j.ProviderJobID, nil, &cj)
+	if errors.Is(err, errNotFound) {
+		return nil, provider.JobNotFoundError{ID: j.ProviderJobID}
+	} else if err != nil {
+		return nil, fmt.Errorf("querying for provider job %s: %w", j.ProviderJobID, err)
+	}
+
+	cp := *j
+	if cp.Output.Path == "" {
+		cp.Output.Path = p.cfg.Destination
+	}
+	st = &job.Status{
+		Provider:      Name,
+		ProviderJobID: j.ProviderJobID,
+		State:         state(cj.Status),
+		Progress:      cj.PctComplete,
+		Msg:           strings.Join(cj.Errors, "; "),
+		Labels:        j.Labels,
+		Output:        job.Dir{Path: cp.Location("")},
+		ProviderStatus: map[string]interface{}{
+			"status":      cj.Status,
+			"pctComplete": cj.PctComplete,
+		},
+	}
+	if st.State != job.StateFinished {
+		return st, nil
+	}
+	st.Progress = 100
+	for _, g := range cj.OutputGroups {
+		for _, o := range g.Outputs {
+			name := o.FullURI
+			if name == "" && g.FileGroup != nil {
+				name = g.FileGroup.Destination.URI + o.NameModifier + "." + o.Extension
+			}
+			st.Output.Add(job.File{Name: name, Container: o.Container})
+		}
+	}
+	return st, nil
+}
+
+func state(s string) job.State {
+	switch s {
+	case "pending":
+		return job.StateQueued
+	case "preprocessing", "running", "postprocessing":
+		return job.StateStarted
+	case "complete":
+		return job.StateFinished
+	case "cancelled":
+		return job.StateCanceled
+	case "error":
+		return job.StateFailed
+	}
+	return job.StateUnknown
+}
+
+func (p *driver) Cancel(ctx context.Context, id string) (err error) {
+	defer p.trace(ctx, "elementalconductor-cancel-job", &err)()
+	err = p.do(ctx, http.MethodPost, "/jobs/"+id+"/cancel", &struct {
+		XMLName xml.Name `xml:"cancel"`
+	}{}, nil)
+	if errors.Is(err, errNotFound) {
+		err = provider.JobNotFoundError{ID: id}
+	}
+	return err
+}
+
+func (p *driver) Healthcheck() error {
+	return p.do(context.Background(), http.MethodGet, "/nodes", nil, nil)
+}
+
+func (*driver) Capabilities() provider.Capabilities {
+	return provider.Capabilities{
+		InputFormats:  []string{"h264", "h265", "mpeg2", "prores"},
+		OutputFormats: []string{"mp4", "mov", "ts", "mxf"},
+		Destinations:  destinations,
+	}
+}
+
+// do sends in, if any, as xml to the api path and decodes the response into
+// out, if any. Requests are signed when the config has a login.
+func (p *driver) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := xml.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("marshaling request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.host()+"/api"+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/xml")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/xml")
+	}
+	p.sign(req.Header, path)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %w", err)
+	}
+	if c := resp.StatusCode; c == http.StatusNotFound {
+		return errNotFound
+	} else if c/100 != 2 {
+		return fmt.Errorf("received non 2xx status code, got %d with body: %s", c, string(data))
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := xml.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("parsing conductor response: %w", err)
+	}
+	return nil
+}
+
+// sign adds Conductor's auth headers for the api path (without the /api
+// prefix or query): the login, an expiry, and the key
+//
+//	md5(apikey + md5(path + login + apikey + expires))
+func (p *driver) sign(h http.Header, path string) {
+	if p.cfg.UserLogin == "" {
+		return
+	}
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	ttl := p.cfg.AuthExpires
+	if ttl <= 0 {
+		ttl = defaultAuthExpires
+	}
+	expires := strconv.FormatInt(p.now().Unix()+int64(ttl), 10)
+	h.Set("X-Auth-User", p.cfg.UserLogin)
+	h.Set("X-Auth-Expires", expires)
+	h.Set("X-Auth-Key", key(path, p.cfg.UserLogin, p.cfg.APIKey, expires))
+}
+
+func key(path, login, apikey, expires string) string {
+	sum := func(s string) string {
+		h := md5.Sum([]byte(s))
+		return hex.EncodeToString(h[:])
+	}
+	return sum(apikey + sum(path+login+apikey+expires))
+}
+
+func (p *driver) host() string {
+	h := strings.TrimSuffix(p.cfg.Host, "/")
+	if !strings.Contains(h, "://") {
+		h = "http://" + h
+	}
+	return h
+}
+
+func factory(cfg *config.Config) (provider.Provider, error) {
+	ec := cfg.ElementalConductor
+	if ec == nil || ec.Host == "" {
+		return nil, errors.New("incomplete Elemental Conductor config")
+	}
+	return &driver{
+		cfg:    ec,
+		client: &http.Client{Timeout: time.Second * 30},
+		tracer: cfg.Tracer,
+		now:    time.Now,
+	}, nil
+}
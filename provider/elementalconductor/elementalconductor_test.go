@@ -0,0 +1,311 @@
+package elementalconductor
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cbsinteractive/pkg/timecode"
+	"github.com/cbsinteractive/pkg/video"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/google/go-cmp/cmp"
+)
+
+var epoch = time.Unix(1600000000, 0)
+
+// server stands in for Conductor. It rejects requests with bad auth
+// headers, answers each "METHOD path" with its canned body and records
+// the last request body.
+type server struct {
+	*httptest.Server
+	body []byte
+}
+
+func newServer(t *testing.T, routes map[string]string) (*server, *driver) {
+	s := &server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := r.Header
+		want := key(strings.TrimPrefix(r.URL.Path, "/api"), "user", "apikey", "1600000030")
+		if h.Get("X-Auth-User") != "user" || h.Get("X-Auth-Expires") != "1600000030" || h.Get("X-Auth-Key") != want {
+			t.Errorf("%s %s: bad auth headers: %v", r.Method, r.URL.Path, h)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodPost && h.Get("Content-Type") != "application/xml" {
+			t.Errorf("content type: %q", h.Get("Content-Type"))
+		}
+		s.body, _ = ioutil.ReadAll(r.Body)
+		body, ok := routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(s.Close)
+	return s, &driver{
+		cfg: &config.ElementalConductor{
+			Host: s.URL, UserLogin: "user", APIKey: "apikey", AuthExpires: 30,
+			AccessKeyID: "id", SecretAccessKey: "secret", Destination: "s3://bucket/out",
+		},
+		client: s.Client(),
+		now:    func() time.Time { return epoch },
+	}
+}
+
+func TestKey(t *testing.T) {
+	// md5("apikey" + md5("/jobs" + "user" + "apikey" + "1600000030"))
+	if have, want := key("/jobs", "user", "apikey", "1600000030"), "0d892a92078754551a96cfe6ae55eb84"; have != want {
+		t.Fatalf("have %q, want %q", have, want)
+	}
+	if key("/jobs", "user", "apikey", "1") == key("/jobs/1", "user", "apikey", "1") {
+		t.Fatal("key doesn't depend on the path")
+	}
+}
+
+func TestCreate(t *testing.T) {
+	s, p := newServer(t, map[string]string{"POST /api/jobs": `<?xml version="1.0" encoding="UTF-8"?><job href="/jobs/42"><status>pending</status></job>`})
+	j := &job.Job{
+		ID:     "abc",
+		Labels: []string{"news", "late"},
+		Input: job.File{
+			Name:   "s3://bucket/in.mxf",
+			Splice: timecode.Splice{{10, 30.5}},
+			Video:  job.Video{FPS: 30},
+		},
+		Output: job.Dir{File: []job.File{{
+			Name: "hd.mp4",
+			Video: job.Video{
+				Codec: "h264", Profile: "High", Level: "4.1",
+				Width: 1920, Height: 1080, FPS: 29.97,
+				Bitrate:  job.Bitrate{BPS: 5000000, Control: "cbr", TwoPass: true},
+				Gop:      job.Gop{Unit: "seconds", Size: 2},
+				Scantype: job.ScanInterlaced,
+				Crop:     video.Crop{Top: 4},
+			},
+			Audio: job.Audio{Codec: "aac", Bitrate: 128000},
+		}}},
+	}
+	st, err := p.Create(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.ProviderJobID != "42" || st.State != job.StateQueued {
+		t.Fatalf("bad status: %+v", st)
+	}
+
+	var have Job
+	if err := xml.Unmarshal(s.body, &have); err != nil {
+		t.Fatalf("sent bad xml: %v\n%s", err, s.body)
+	}
+	creds := Location{Username: "id", Password: "secret"}
+	in, out := creds, creds
+	in.URI, out.URI = "s3://bucket/in.mxf", "s3://bucket/out/abc/hd"
+	want := Job{
+		XMLName:  xml.Name{Local: "job"},
+		Input:    Input{File: in, Clipping: []Clipping{{Start: "00:00:10:00", End: "00:00:30:15"}}},
+		UserData: "news,late",
+		Timecode: &TimecodeConfig{Source: "zerobased"},
+		OutputGroups: []OutputGroup{{
+			Order: 1, Type: "file_group_settings",
+			FileGroup: &FileGroup{Destination: out},
+			Outputs:   []GroupOutput{{Order: 1, Extension: "mp4", Container: "mp4", StreamAssembly: "stream_1"}},
+		}},
+		Streams: []StreamAssembly{{
+			Name: "stream_1",
+			Video: &VideoDescription{
+				Codec: "h.264", Width: 1920, Height: 1080,
+				H264: &CodecSettings{
+					Profile: "High", Level: "4.1", Bitrate: 5000000, RateControl: "CBR", Passes: 2,
+					GopSize: "2", GopUnits: "seconds", FramerateNum: 30000, FramerateDen: 1001,
+				},
+				Preprocessors: &Preprocessors{
+					Deinterlacer: &Deinterlacer{Mode: "Deinterlace"},
+					Crop:         &Crop{Top: 4},
+				},
+			},
+			Audio: &AudioDescription{Codec: "aac", AAC: &AACSettings{Bitrate: 128000, CodingMode: "2_0"}},
+		}},
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Fatalf("job mismatch (-want +have):\n%s", diff)
+	}
+}
+
+func TestSMPTE(t *testing.T) {
+	for _, tt := range []struct {
+		sec, fps float64
+		want     string
+	}{
+		{0, 30, "00:00:00:00"},
+		{30.5, 30, "00:00:30:15"},
+		{30.5, 29.97, "00:00:30:15"},
+		{10.04, 25, "00:00:10:01"},
+		{59.999, 30, "00:01:00:00"},
+		{3725.5, 24, "01:02:05:12"},
+		{1.5, 0, "00:00:01:12"},
+	} {
+		if have := smpte(tt.sec, tt.fps); have != tt.want {
+			t.Errorf("smpte(%v, %v): have %q, want %q", tt.sec, tt.fps, have, tt.want)
+		}
+	}
+}
+
+func TestCreateUnsupported(t *testing.T) {
+	_, p := newServer(t, nil)
+	for name, f := range map[string]job.File{
+		"Codec":     {Name: "a.mp4", Video: job.Video{Codec: "vp9"}},
+		"Container": {Name: "a.webm", Video: job.Video{Codec: "h264"}},
+		"Audio":     {Name: "a.mp4", Audio: job.Audio{Codec: "opus"}},
+		"Crop":      {Name: "a.mp4", Video: job.Video{Codec: "h264", Crop: video.Crop{Right: 2}}},
+		"Empty":     {Name: "a.mp4"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := p.Create(context.Background(), &job.Job{Output: job.Dir{File: []job.File{f}}})
+			if !errors.Is(err, ErrUnsupported) {
+				t.Fatalf("have %v, want %v", err, ErrUnsupported)
+			}
+		})
+	}
+}
+
+func TestCreateStorage(t *testing.T) {
+	_, p := newServer(t, nil)
+	f := job.File{Name: "a.mp4", Video: job.Video{Codec: "h264"}}
+	for name, j := range map[string]*job.Job{
+		"Input":  {Input: job.File{Name: "gs://bucket/in.mov"}, Output: job.Dir{File: []job.File{f}}},
+		"Output": {Input: job.File{Name: "/mnt/in.mov"}, Output: job.Dir{Path: "gs://bucket/out", File: []job.File{f}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := p.Create(context.Background(), j); !errors.Is(err, storage.ErrUnsupported) {
+				t.Fatalf("have %v, want %v", err, storage.ErrUnsupported)
+			}
+		})
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	s, p := newServer(t, nil)
+	j := &job.Job{ID: "abc", Input: job.File{Name: "s3://bucket/in.mxf"}, Output: job.Dir{File: []job.File{
+		{Name: "hd.mp4", Video: job.Video{Codec: "h264", Height: 1080}},
+	}}}
+	data, err := p.DryRun(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var have Job
+	if err := xml.Unmarshal(data, &have); err != nil {
+		t.Fatalf("bad xml: %v\n%s", err, data)
+	}
+	if have.Input.File.URI != j.Input.Name || len(have.OutputGroups) != 1 {
+		t.Fatalf("bad job: %s", data)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Fatalf("dry run has credentials: %s", data)
+	}
+	if s.body != nil {
+		t.Fatalf("dry run submitted the job: %s", s.body)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	const finished = `<job href="/jobs/42"><status>complete</status><pct_complete>99</pct_complete>
+		<output_group><order>1</order><type>file_group_settings</type>
+			<file_group_settings><destination><uri>s3://bucket/out/abc/hd</uri></destination></file_group_settings>
+			<output><order>1</order><extension>mp4</extension><container>mp4</container></output>
+			<output><order>2</order><extension>mp4</extension><container>mp4</container><full_uri>s3://bucket/out/abc/hd_2.mp4</full_uri></output>
+		</output_group></job>`
+	for _, tt := range []struct {
+		name string
+		body string
+		want *job.Status
+	}{
+		{
+			name: "Running",
+			body: `<job href="/jobs/42"><status>running</status><pct_complete>45</pct_complete></job>`,
+			want: &job.Status{State: job.StateStarted, Progress: 45},
+		},
+		{
+			name: "Error",
+			body: `<job href="/jobs/42"><status>error</status><error_messages><error><message>input not found</message></error><error><message>aborted</message></error></error_messages></job>`,
+			want: &job.Status{State: job.StateFailed, Msg: "input not found; aborted"},
+		},
+		{
+			name: "Complete",
+			body: finished,
+			want: &job.Status{State: job.StateFinished, Progress: 100, Output: job.Dir{File: []job.File{
+				{Name: "s3://bucket/out/abc/hd.mp4", Container: "mp4"},
+				{Name: "s3://bucket/out/abc/hd_2.mp4", Container: "mp4"},
+			}}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, p := newServer(t, map[string]string{"GET /api/jobs/42": tt.body})
+			st, err := p.Status(context.Background(), &job.Job{ID: "abc", ProviderJobID: "42"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want.State, st.State); diff != "" {
+				t.Fatalf("state: %s", diff)
+			}
+			if st.Progress != tt.want.Progress || st.Msg != tt.want.Msg {
+				t.Fatalf("have progress %v msg %q, want %v %q", st.Progress, st.Msg, tt.want.Progress, tt.want.Msg)
+			}
+			if diff := cmp.Diff(tt.want.Output.File, st.Output.File); diff != "" {
+				t.Fatalf("outputs (-want +have):\n%s", diff)
+			}
+			if st.Output.Path != "s3://bucket/out/abc" {
+				t.Fatalf("output path: %q", st.Output.Path)
+			}
+		})
+	}
+
+	_, p := newServer(t, nil)
+	if _, err := p.Status(context.Background(), &job.Job{ProviderJobID: "7"}); !errors.As(err, &provider.JobNotFoundError{}) {
+		t.Fatalf("have %v, want JobNotFoundError", err)
+	}
+}
+
+func TestState(t *testing.T) {
+	for s, want := range map[string]job.State{
+		"pending": job.StateQueued, "preprocessing": job.StateStarted, "postprocessing": job.StateStarted,
+		"cancelled": job.StateCanceled, "strange": job.StateUnknown,
+	} {
+		if have := state(s); have != want {
+			t.Errorf("state(%q): have %q, want %q", s, have, want)
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	s, p := newServer(t, map[string]string{"POST /api/jobs/42/cancel": `<job href="/jobs/42"><status>cancelled</status></job>`})
+	if err := p.Cancel(context.Background(), "42"); err != nil {
+		t.Fatal(err)
+	}
+	if string(s.body) != "<cancel></cancel>" {
+		t.Fatalf("cancel body: %q", s.body)
+	}
+	if err := p.Cancel(context.Background(), "7"); !errors.As(err, &provider.JobNotFoundError{}) {
+		t.Fatalf("have %v, want JobNotFoundError", err)
+	}
+}
+
+func TestHealthcheck(t *testing.T) {
+	s, p := newServer(t, map[string]string{"GET /api/nodes": `<node_list></node_list>`})
+	if err := p.Healthcheck(); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if err := p.Healthcheck(); err == nil {
+		t.Fatal("healthcheck passed with conductor down")
+	}
+}
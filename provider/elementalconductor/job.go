@@ -0,0 +1,300 @@
+package elementalconductor
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+)
+
+// Job is a Conductor job document, as sent and as returned
+type Job struct {
+	XMLName      xml.Name         `xml:"job"`
+	Href         string           `xml:"href,attr,omitempty"`
+	Input        Input            `xml:"input"`
+	Priority     int              `xml:"priority,omitempty"`
+	UserData     string           `xml:"user_data,omitempty"`
+	Timecode     *TimecodeConfig  `xml:"timecode_config,omitempty"`
+	OutputGroups []OutputGroup    `xml:"output_group"`
+	Streams      []StreamAssembly `xml:"stream_assembly"`
+
+	Status      string   `xml:"status,omitempty"`
+	PctComplete float64  `xml:"pct_complete,omitempty"`
+	Errors      []string `xml:"error_messages>error>message,omitempty"`
+}
+
+// ID is the job's id, the last element of its href
+func (j *Job) ID() string {
+	return path.Base(j.Href)
+}
+
+type Input struct {
+	File     Location   `xml:"file_input"`
+	Clipping []Clipping `xml:"input_clipping,omitempty"`
+}
+
+// Location is a uri with the credentials to access it
+type Location struct {
+	URI      string `xml:"uri"`
+	Username string `xml:"username,omitempty"`
+	Password string `xml:"password,omitempty"`
+}
+
+type Clipping struct {
+	Start string `xml:"start_timecode"`
+	End   string `xml:"end_timecode"`
+}
+
+type TimecodeConfig struct {
+	Source string `xml:"source"`
+}
+
+type OutputGroup struct {
+	Order     int           `xml:"order"`
+	Type      string        `xml:"type"`
+	FileGroup *FileGroup    `xml:"file_group_settings,omitempty"`
+	Outputs   []GroupOutput `xml:"output"`
+}
+
+type FileGroup struct {
+	Destination Location `xml:"destination"`
+}
+
+type GroupOutput struct {
+	Order          int    `xml:"order"`
+	Extension      string `xml:"extension"`
+	NameModifier   string `xml:"name_modifier,omitempty"`
+	Container      string `xml:"container"`
+	StreamAssembly string `xml:"stream_assembly_name"`
+	FullURI        string `xml:"full_uri,omitempty"`
+}
+
+type StreamAssembly struct {
+	Name  string            `xml:"name"`
+	Video *VideoDescription `xml:"video_description,omitempty"`
+	Audio *AudioDescription `xml:"audio_description,omitempty"`
+}
+
+type VideoDescription struct {
+	Codec         string         `xml:"codec"`
+	Width         int            `xml:"width,omitempty"`
+	Height        int            `xml:"height,omitempty"`
+	H264          *CodecSettings `xml:"h264_settings,omitempty"`
+	H265          *CodecSettings `xml:"h265_settings,omitempty"`
+	Preprocessors *Preprocessors `xml:"video_preprocessors,omitempty"`
+}
+
+type CodecSettings struct {
+	Profile      string `xml:"profile,omitempty"`
+	Level        string `xml:"level,omitempty"`
+	Bitrate      int    `xml:"bitrate,omitempty"`
+	RateControl  string `xml:"rate_control_mode,omitempty"`
+	Passes       int    `xml:"passes,omitempty"`
+	GopSize      string `xml:"gop_size,omitempty"`
+	GopUnits     string `xml:"gop_size_units,omitempty"`
+	FramerateNum int    `xml:"framerate_numerator,omitempty"`
+	FramerateDen int    `xml:"framerate_denominator,omitempty"`
+}
+
+type Preprocessors struct {
+	Deinterlacer *Deinterlacer `xml:"deinterlacer,omitempty"`
+	Crop         *Crop         `xml:"crop,omitempty"`
+}
+
+type Deinterlacer struct {
+	Mode string `xml:"deinterlace_mode"`
+}
+
+type Crop struct {
+	Top  int `xml:"top"`
+	Left int `xml:"left"`
+}
+
+type AudioDescription struct {
+	Codec string       `xml:"codec"`
+	AAC   *AACSettings `xml:"aac_settings,omitempty"`
+}
+
+type AACSettings struct {
+	Bitrate    int    `xml:"bitrate,omitempty"`
+	CodingMode string `xml:"coding_mode,omitempty"`
+}
+
+var (
+	containers = map[string]string{
+		"mp4":  "mp4",
+		"mov":  "mov",
+		"ts":   "m2ts",
+		"m2ts": "m2ts",
+		"mxf":  "mxf",
+	}
+	videoCodecs = map[string]string{
+		"h264": "h.264",
+		"avc":  "h.264",
+		"h265": "h.265",
+		"hevc": "h.265",
+	}
+	codingModes = map[int]string{
+		1: "1_0",
+		2: "2_0",
+		6: "5_1",
+	}
+)
+
+// defaultFPS is the frame rate of inputs that don't have one
+const defaultFPS = 23.976
+
+// smpte returns the HH:MM:SS:FF timecode of the frame at sec seconds.
+// The timecode package always writes frame 00, which would cut splices
+// to whole seconds.
+func smpte(sec, fps float64) string {
+	if fps <= 0 {
+		fps = defaultFPS
+	}
+	whole := math.Floor(sec)
+	f := int(math.Round((sec - whole) * fps))
+	s := int(whole)
+	if f >= int(math.Ceil(fps)) {
+		s, f = s+1, 0
+	}
+	return fmt.Sprintf("%02d:%02d:%02d:%02d", s/3600, s/60%60, s%60, f)
+}
+
+// newJob builds the Conductor job for j, reading and writing with the
+// given credentials
+func newJob(j *job.Job, creds Location) (*Job, error) {
+	cj := &Job{
+		Input: Input{File: Location{
+			URI:      j.Input.Name,
+			Username: creds.Username,
+			Password: creds.Password,
+		}},
+		UserData: strings.Join(j.Labels, ","),
+	}
+	if len(j.Input.Splice) > 0 {
+		cj.Timecode = &TimecodeConfig{Source: "zerobased"}
+		for _, r := range j.Input.Splice {
+			r = r.Canon()
+			cj.Input.Clipping = append(cj.Input.Clipping, Clipping{
+				Start: smpte(r[0], j.Input.Video.FPS),
+				End:   smpte(r[1], j.Input.Video.FPS),
+			})
+		}
+	}
+
+	for i, f := range j.Output.File {
+		name := strconv.Itoa(i + 1)
+		sa, err := stream("stream_"+name, f)
+		if err != nil {
+			return nil, fmt.Errorf("output %q: %w", f.Name, err)
+		}
+		ext := f.Type()
+		c := f.Container
+		if c == "" {
+			c = ext
+		}
+		container, ok := containers[strings.ToLower(c)]
+		if !ok {
+			return nil, fmt.Errorf("output %q: %w: container %q", f.Name, ErrUnsupported, c)
+		}
+
+		// conductor appends the extension to the destination
+		loc := creds
+		loc.URI = strings.TrimSuffix(j.Location(f.Name), "."+ext)
+		cj.Streams = append(cj.Streams, *sa)
+		cj.OutputGroups = append(cj.OutputGroups, OutputGroup{
+			Order:     i + 1,
+			Type:      "file_group_settings",
+			FileGroup: &FileGroup{Destination: loc},
+			Outputs: []GroupOutput{{
+				Order:          1,
+				Extension:      ext,
+				Container:      container,
+				StreamAssembly: sa.Name,
+			}},
+		})
+	}
+	return cj, nil
+}
+
+func stream(name string, f job.File) (*StreamAssembly, error) {
+	sa := &StreamAssembly{Name: name}
+	if v := f.Video; v.On() {
+		codec, ok := videoCodecs[strings.ToLower(v.Codec)]
+		if !ok {
+			return nil, fmt.Errorf("%w: video codec %q", ErrUnsupported, v.Codec)
+		}
+		cs := &CodecSettings{
+			Profile: v.Profile,
+			Level:   v.Level,
+			Bitrate: v.Bitrate.BPS,
+			Passes:  1,
+		}
+		if v.Bitrate.TwoPass {
+			cs.Passes = 2
+		}
+		if v.Bitrate.BPS != 0 {
+			cs.RateControl = strings.ToUpper(v.Bitrate.Control)
+			if cs.RateControl == "" {
+				cs.RateControl = "VBR"
+			}
+		}
+		if v.Gop.Size != 0 {
+			cs.GopSize = strconv.FormatFloat(v.Gop.Size, 'f', -1, 64)
+			cs.GopUnits = "frames"
+			if v.Gop.Seconds() {
+				cs.GopUnits = "seconds"
+			}
+		}
+		if v.FPS != 0 {
+			// fractional rates like 29.97 are sent as 30000/1001
+			cs.FramerateNum, cs.FramerateDen = int(v.FPS), 1
+			if v.FPS != float64(int(v.FPS)) {
+				cs.FramerateNum, cs.FramerateDen = int(v.FPS*1001+0.5), 1001
+			}
+		}
+		vd := &VideoDescription{Codec: codec, Width: v.Width, Height: v.Height}
+		if codec == "h.264" {
+			vd.H264 = cs
+		} else {
+			vd.H265 = cs
+		}
+		var pp Preprocessors
+		if v.Scantype == job.ScanInterlaced {
+			pp.Deinterlacer = &Deinterlacer{Mode: "Deinterlace"}
+		}
+		if c := v.Crop; !c.Empty() {
+			// conductor crops to a rectangle, which needs the input's size
+			// to crop from the right or bottom
+			if c.Right != 0 || c.Bottom != 0 {
+				return nil, fmt.Errorf("%w: crop from the right or bottom", ErrUnsupported)
+			}
+			pp.Crop = &Crop{Top: c.Top, Left: c.Left}
+		}
+		if pp != (Preprocessors{}) {
+			vd.Preprocessors = &pp
+		}
+		sa.Video = vd
+	}
+	if a := f.Audio; a.On() {
+		if !strings.EqualFold(a.Codec, "aac") {
+			return nil, fmt.Errorf("%w: audio codec %q", ErrUnsupported, a.Codec)
+		}
+		sa.Audio = &AudioDescription{Codec: "aac", AAC: &AACSettings{Bitrate: a.Bitrate, CodingMode: "2_0"}}
+		if f.Downmix != nil {
+			mode, ok := codingModes[len(f.Downmix.Dst)]
+			if !ok {
+				return nil, fmt.Errorf("%w: %d audio channels", ErrUnsupported, len(f.Downmix.Dst))
+			}
+			sa.Audio.AAC.CodingMode = mode
+		}
+	}
+	if sa.Video == nil && sa.Audio == nil {
+		return nil, fmt.Errorf("%w: no video or audio", ErrUnsupported)
+	}
+	return sa, nil
+}
@@ -0,0 +1,482 @@
+package encodingcom
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/logging"
+	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/cbsinteractive/transcode-orchestrator/trace"
+	"github.com/zsiec/pkg/tracing"
+)
+
+// Name identifies the Encoding.com provider by name
+const Name = "encodingcom"
+
+var ErrUnsupported = errors.New("unsupported")
+
+// sources and destinations are the storage Encoding.com reads and writes
+var (
+	sources      = []string{storage.S3, storage.GCS, storage.HTTP, storage.HTTPS}
+	destinations = []string{storage.S3, storage.GCS, storage.HTTP}
+)
+
+func init() {
+	err := provider.Register(Name, factory)
+	if err != nil {
+		logging.From(context.Background()).WithError(err).Error("registering encodingcom factory")
+	}
+}
+
+type driver struct {
+	cfg    *config.EncodingCom
+	client *http.Client
+	tracer tracing.Tracer
+}
+
+func (p *driver) trace(ctx context.Context, name string, err *error) func() {
+	_, x := trace.Begin(ctx, p.tracer, name)
+	return func() {
+		x.Close(*err)
+		logging.Done(ctx, name, *err)
+	}
+}
+
+// Query is an Encoding.com api request
+type Query struct {
+	UserID   string   `json:"userid"`
+	UserKey  string   `json:"userkey"`
+	Action   string   `json:"action"`
+	MediaID  string   `json:"mediaid,omitempty"`
+	Source   []string `json:"source,omitempty"`
+	Region   string   `json:"region,omitempty"`
+	Extended string   `json:"extended,omitempty"`
+	Format   []Format `json:"format,omitempty"`
+}
+
+// Format is a single output of a media. Encoding.com takes every
+// value as a string.
+type Format struct {
+	Output      string `json:"output"`
+	Destination string `json:"destination"`
+
+	VideoCodec    string `json:"video_codec,omitempty"`
+	Profile       string `json:"profile,omitempty"`
+	Level         string `json:"level,omitempty"`
+	Size          string `json:"size,omitempty"`
+	Bitrate       string `json:"bitrate,omitempty"`
+	CBR           string `json:"cbr,omitempty"`
+	TwoPass       string `json:"two_pass,omitempty"`
+	Framerate     string `json:"framerate,omitempty"`
+	Keyframe      string `json:"keyframe,omitempty"`
+	Deinterlacing string `json:"deinterlacing,omitempty"`
+	CropLeft      string `json:"crop_left,omitempty"`
+	CropTop       string `json:"crop_top,omitempty"`
+	CropRight     string `json:"crop_right,omitempty"`
+	CropBottom    string `json:"crop_bottom,omitempty"`
+
+	AudioCodec    string `json:"audio_codec,omitempty"`
+	AudioBitrate  string `json:"audio_bitrate,omitempty"`
+	AudioChannels string `json:"audio_channels_number,omitempty"`
+
+	Start    string `json:"start,omitempty"`
+	Duration string `json:"duration,omitempty"`
+}
+
+// Response is an Encoding.com api response. Only the fields of the
+// action's response are set.
+type Response struct {
+	Message string       `json:"message"`
+	MediaID string       `json:"MediaID"`
+	Errors  *Errors      `json:"errors"`
+	Job     *MediaStatus `json:"job"`
+}
+
+// Errors holds either a single error or a list of them
+type Errors struct {
+	Error many `json:"error"`
+}
+
+type MediaStatus struct {
+	ID       string      `json:"id"`
+	Status   string      `json:"status"`
+	Progress string      `json:"progress"`
+	Format   manyFormats `json:"format"`
+}
+
+type FormatStatus struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	Description   string `json:"description"`
+	Output        string `json:"output"`
+	Destination   many   `json:"destination"`
+	Size          string `json:"size"`
+	ConvertedSize string `json:"convertedsize"`
+}
+
+// many is a string or a list of strings
+type many []string
+
+func (m *many) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*m = many{s}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(m))
+}
+
+// manyFormats is a format or a list of them
+type manyFormats []FormatStatus
+
+func (m *manyFormats) UnmarshalJSON(data []byte) error {
+	var f FormatStatus
+	if json.Unmarshal(data, &f) == nil {
+		*m = manyFormats{f}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]FormatStatus)(m))
+}
+
+func (p *driver) Create(ctx context.Context, j *job.Job) (st *job.Status, err error) {
+	q, err := p.create(j)
+	if err != nil {
+		return nil, err
+	}
+
+	defer p.trace(ctx, "encodingcom-add-media", &err)()
+	r, err := p.do(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("submitting new job: %w", err)
+	}
+	return &job.Status{
+		Provider:      Name,
+		ProviderJobID: r.MediaID,
+		State:         job.StateQueued,
+	}, nil
+}
+
+// DryRun returns the query Create would submit, without the user id
+// and key
+func (p *driver) DryRun(_ context.Context, j *job.Job) ([]byte, error) {
+	q, err := p.create(j)
+	if err != nil {
+		return nil, err
+	}
+	q.UserID, q.UserKey = "", ""
+	return json.MarshalIndent(struct {
+		Query *Query `json:"query"`
+	}{q}, "", "\t")
+}
+
+// create returns the query that adds j's media after checking its storage
+func (p *driver) create(j *job.Job) (*Query, error) {
+	q, err := p.addMedia(j)
+	if err != nil {
+		return nil, fmt.Errorf("generating encoding.com request: %w", err)
+	}
+	if _, err = storage.Check(j.Input.Name, sources...); err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	if _, err = storage.Check(p.location(*j, ""), destinations...); err != nil {
+		return nil, fmt.Errorf("output: %w", err)
+	}
+	return q, nil
+}
+
+func (p *driver) addMedia(j *job.Job) (*Query, error) {
+	q := p.query("AddMedia")
+	q.Source = []string{j.Input.Name}
+	q.Region = p.cfg.Region
+
+	start, dur, err := clip(j.Input)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range j.Output.File {
+		ft, err := p.format(*j, f)
+		if err != nil {
+			return nil, fmt.Errorf("output %q: %w", f.Name, err)
+		}
+		ft.Start, ft.Duration = start, dur
+		q.Format = append(q.Format, ft)
+	}
+	return q, nil
+}
+
+var (
+	outputs     = map[string]bool{"mp4": true, "webm": true, "mov": true, "m4a": true, "mp3": true}
+	videoCodecs = map[string]string{
+		"h264": "libx264",
+		"avc":  "libx264",
+		"h265": "libx265",
+		"hevc": "libx265",
+		"vp8":  "libvpx",
+		"vp9":  "libvpx-vp9",
+	}
+	audioCodecs = map[string]string{
+		"aac":    "dolby_aac",
+		"mp3":    "libmp3lame",
+		"vorbis": "libvorbis",
+		"opus":   "libopus",
+	}
+)
+
+func (p *driver) format(j job.Job, f job.File) (Format, error) {
+	ft := Format{Output: strings.ToLower(f.Container), Destination: p.location(j, f.Name)}
+	if ft.Output == "" {
+		ft.Output = f.Type()
+	}
+	if !outputs[ft.Output] {
+		return ft, fmt.Errorf("%w: output %q", ErrUnsupported, ft.Output)
+	}
+
+	itoa := strconv.Itoa
+	ftoa := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	yes := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return ""
+	}
+
+	if v := f.Video; v.On() {
+		codec, ok := videoCodecs[strings.ToLower(v.Codec)]
+		if !ok {
+			return ft, fmt.Errorf("%w: video codec %q", ErrUnsupported, v.Codec)
+		}
+		ft.VideoCodec = codec
+		ft.Profile = strings.ToLower(v.Profile)
+		ft.Level = v.Level
+		if v.Width != 0 || v.Height != 0 {
+			// zero keeps the aspect ratio
+			ft.Size = itoa(v.Width) + "x" + itoa(v.Height)
+		}
+		if k := v.Bitrate.Kbps(); k != 0 {
+			ft.Bitrate = itoa(k) + "k"
+		}
+		ft.CBR = yes(strings.EqualFold(v.Bitrate.Control, "CBR"))
+		ft.TwoPass = yes(v.Bitrate.TwoPass)
+		if v.FPS != 0 {
+			ft.Framerate = ftoa(v.FPS)
+		}
+		if size := v.Gop.Size; size != 0 {
+			if v.Gop.Seconds() {
+				if v.FPS == 0 {
+					return ft, fmt.Errorf("%w: gop in seconds without a frame rate", ErrUnsupported)
+				}
+				size *= v.FPS
+			}
+			ft.Keyframe = itoa(int(size))
+		}
+		ft.Deinterlacing = yes(v.Scantype == job.ScanInterlaced)
+		if c := v.Crop; !c.Empty() {
+			ft.CropLeft, ft.CropTop = itoa(c.Left), itoa(c.Top)
+			ft.CropRight, ft.CropBottom = itoa(c.Right), itoa(c.Bottom)
+		}
+	}
+	if a := f.Audio; a.On() {
+		codec, ok := audioCodecs[strings.ToLower(a.Codec)]
+		if !ok {
+			return ft, fmt.Errorf("%w: audio codec %q", ErrUnsupported, a.Codec)
+		}
+		ft.AudioCodec = codec
+		if a.Bitrate != 0 {
+			ft.AudioBitrate = itoa(a.Bitrate/1000) + "k"
+		}
+		if f.Downmix != nil {
+			ft.AudioChannels = itoa(len(f.Downmix.Dst))
+		}
+	}
+	return ft, nil
+}
+
+// clip converts the input's splice to a start and duration in seconds.
+// Encoding.com clips each output to one range only.
+func clip(f job.File) (start, dur string, err error) {
+	switch len(f.Splice) {
+	case 0:
+		return "", "", nil
+	case 1:
+	default:
+		return "", "", fmt.Errorf("%w: splice with %d ranges", ErrUnsupported, len(f.Splice))
+	}
+	r := f.Splice[0].Canon()
+	sec := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	return sec(r[0]), sec(r[1] - r[0]), nil
+}
+
+func (p *driver) location(j job.Job, file string) string {
+	if j.Output.Path == "" {
+		j.Output.Path = p.cfg.Destination
+	}
+	return j.Location(file)
+}
+
+func (p *driver) Status(ctx context.Context, j *job.Job) (st *job.Status, err error) {
+	defer p.trace(ctx, "encodingcom-get-status", &err)()
+
+	q := p.query("GetStatus")
+	q.MediaID = j.ProviderJobID
+	q.Extended = "yes"
+	r, err := p.do(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("querying for provider job %s: %w", j.ProviderJobID, err)
+	}
+	m := r.Job
+	if m == nil {
+		return nil, provider.JobNotFoundError{ID: j.ProviderJobID}
+	}
+
+	st = &job.Status{
+		Provider:      Name,
+		ProviderJobID: j.ProviderJobID,
+		State:         state(m.Status),
+		Labels:        j.Labels,
+		Output:        job.Dir{Path: p.location(*j, "")},
+		ProviderStatus: map[string]interface{}{
+			"status":   m.Status,
+			"progress": m.Progress,
+		},
+	}
+	st.Progress, _ = strconv.ParseFloat(m.Progress, 64)
+	for _, f := range m.Format {
+		if f.Description != "" && st.Msg == "" {
+			st.Msg = f.Description
+		}
+		if st.State != job.StateFinished || f.Status != "Finished" {
+			continue
+		}
+		for _, dst := range f.Destination {
+			out := job.File{Name: dst, Container: f.Output}
+			out.Size, _ = strconv.ParseInt(f.ConvertedSize, 10, 64)
+			fmt.Sscanf(f.Size, "%dx%d", &out.Video.Width, &out.Video.Height)
+			st.Output.Add(out)
+		}
+	}
+	return st, nil
+}
+
+func state(s string) job.State {
+	switch s {
+	case "New", "Downloading", "Downloaded", "Ready to process", "Waiting for encoder":
+		return job.StateQueued
+	case "Processing", "Saving", "Saved":
+		return job.StateStarted
+	case "Finished":
+		return job.StateFinished
+	case "Error":
+		return job.StateFailed
+	case "Deleted", "Stopped":
+		return job.StateCanceled
+	}
+	return job.StateUnknown
+}
+
+func (p *driver) Cancel(ctx context.Context, id string) (err error) {
+	defer p.trace(ctx, "encodingcom-cancel-media", &err)()
+	q := p.query("CancelMedia")
+	q.MediaID = id
+	_, err = p.do(ctx, q)
+	return err
+}
+
+// Healthcheck reports an error unless Encoding.com's status page says
+// the service is ok
+func (p *driver) Healthcheck() error {
+	resp, err := p.client.Get(strings.TrimSuffix(p.cfg.StatusEndpoint, "/") + "/status.php?format=json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	var status struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return fmt.Errorf("parsing service status: %w", err)
+	}
+	if !strings.EqualFold(status.Status, "ok") {
+		return fmt.Errorf("service status is %q: %s", status.Status, status.Message)
+	}
+	return nil
+}
+
+func (*driver) Capabilities() provider.Capabilities {
+	return provider.Capabilities{
+		InputFormats:  []string{"h264", "h265", "prores", "mpeg2"},
+		OutputFormats: []string{"mp4", "webm", "mov"},
+		Destinations:  destinations,
+	}
+}
+
+func (p *driver) query(action string) *Query {
+	return &Query{UserID: p.cfg.UserID, UserKey: p.cfg.UserKey, Action: action}
+}
+
+// do posts the query as the json form value and returns the response,
+// or the errors it lists
+func (p *driver) do(ctx context.Context, q *Query) (*Response, error) {
+	data, err := json.Marshal(struct {
+		Query *Query `json:"query"`
+	}{q})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+	form := url.Values{"json": {string(data)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+	if c := resp.StatusCode; c/100 != 2 {
+		return nil, fmt.Errorf("received non 2xx status code, got %d with body: %s", c, string(body))
+	}
+	var r struct {
+		Response Response `json:"response"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("parsing encoding.com response: %w", err)
+	}
+	if e := r.Response.Errors; e != nil && len(e.Error) > 0 {
+		return nil, errors.New(strings.Join(e.Error, "; "))
+	}
+	return &r.Response, nil
+}
+
+func factory(cfg *config.Config) (provider.Provider, error) {
+	e := cfg.EncodingCom
+	if e == nil || e.UserID == "" || e.UserKey == "" {
+		return nil, errors.New("incomplete Encoding.com config")
+	}
+	return &driver{
+		cfg:    e,
+		client: &http.Client{Timeout: time.Second * 30},
+		tracer: cfg.Tracer,
+	}, nil
+}
@@ -0,0 +1,267 @@
+package encodingcom
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cbsinteractive/pkg/timecode"
+	"github.com/cbsinteractive/pkg/video"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/google/go-cmp/cmp"
+)
+
+// server stands in for the Encoding.com api, answering each action with
+// its canned response and recording the last query
+type server struct {
+	*httptest.Server
+	query Query
+}
+
+func newServer(t *testing.T, actions map[string]string) (*server, *driver) {
+	s := &server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rq struct{ Query Query }
+		if err := json.Unmarshal([]byte(r.FormValue("json")), &rq); err != nil {
+			t.Errorf("bad json form value: %v", err)
+		}
+		s.query = rq.Query
+		if q := rq.Query; q.UserID != "user" || q.UserKey != "key" {
+			w.Write([]byte(`{"response": {"errors": {"error": "Wrong user id or key!"}}}`))
+			return
+		}
+		body, ok := actions[rq.Query.Action]
+		if !ok {
+			w.Write([]byte(`{"response": {"errors": {"error": ["Unknown action", "Try again"]}}}`))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(s.Close)
+	return s, &driver{
+		cfg: &config.EncodingCom{
+			UserID: "user", UserKey: "key", Region: "us-east-1",
+			Destination: "s3://bucket/out", Endpoint: s.URL, StatusEndpoint: s.URL,
+		},
+		client: s.Client(),
+	}
+}
+
+func TestCreate(t *testing.T) {
+	s, p := newServer(t, map[string]string{"AddMedia": `{"response": {"message": "Added", "MediaID": "71891062"}}`})
+	j := &job.Job{
+		ID:    "abc",
+		Input: job.File{Name: "s3://bucket/in.mov", Splice: timecode.Splice{{30, 5}}},
+		Output: job.Dir{File: []job.File{
+			{
+				Name: "hd.mp4",
+				Video: job.Video{
+					Codec: "h264", Profile: "High", Level: "4.1", Height: 1080, FPS: 25,
+					Bitrate:  job.Bitrate{BPS: 5000000, Control: "CBR", TwoPass: true},
+					Gop:      job.Gop{Unit: "seconds", Size: 2},
+					Scantype: job.ScanInterlaced,
+					Crop:     video.Crop{Top: 2, Bottom: 2},
+				},
+				Audio:   job.Audio{Codec: "aac", Bitrate: 128000},
+				Downmix: &job.Downmix{Dst: make([]job.AudioChannel, 2)},
+			},
+			{Name: "audio.m4a", Audio: job.Audio{Codec: "aac"}},
+		}},
+	}
+	st, err := p.Create(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.ProviderJobID != "71891062" || st.State != job.StateQueued {
+		t.Fatalf("bad status: %+v", st)
+	}
+
+	want := Query{
+		UserID: "user", UserKey: "key", Action: "AddMedia", Region: "us-east-1",
+		Source: []string{"s3://bucket/in.mov"},
+		Format: []Format{
+			{
+				Output: "mp4", Destination: "s3://bucket/out/abc/hd.mp4",
+				VideoCodec: "libx264", Profile: "high", Level: "4.1", Size: "0x1080",
+				Bitrate: "5000k", CBR: "yes", TwoPass: "yes", Framerate: "25", Keyframe: "50",
+				Deinterlacing: "yes", CropLeft: "0", CropTop: "2", CropRight: "0", CropBottom: "2",
+				AudioCodec: "dolby_aac", AudioBitrate: "128k", AudioChannels: "2",
+				Start: "5", Duration: "25",
+			},
+			{
+				Output: "m4a", Destination: "s3://bucket/out/abc/audio.m4a",
+				AudioCodec: "dolby_aac", Start: "5", Duration: "25",
+			},
+		},
+	}
+	if diff := cmp.Diff(want, s.query); diff != "" {
+		t.Fatalf("query mismatch (-want +have):\n%s", diff)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	s, p := newServer(t, nil)
+	j := &job.Job{ID: "abc", Input: job.File{Name: "s3://bucket/in.mov"}, Output: job.Dir{File: []job.File{
+		{Name: "audio.m4a", Audio: job.Audio{Codec: "aac"}},
+	}}}
+	data, err := p.DryRun(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rq struct{ Query Query }
+	if err := json.Unmarshal(data, &rq); err != nil {
+		t.Fatalf("bad json: %v\n%s", err, data)
+	}
+	want := Query{
+		Action: "AddMedia", Region: "us-east-1", Source: []string{"s3://bucket/in.mov"},
+		Format: []Format{{Output: "m4a", Destination: "s3://bucket/out/abc/audio.m4a", AudioCodec: "dolby_aac"}},
+	}
+	if diff := cmp.Diff(want, rq.Query); diff != "" {
+		t.Fatalf("query mismatch (-want +have):\n%s", diff)
+	}
+	if s.query.Action != "" {
+		t.Fatalf("dry run called the api: %+v", s.query)
+	}
+}
+
+func TestCreateErrors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		j    job.Job
+		want error
+	}{
+		"Output": {job.Job{Output: job.Dir{File: []job.File{{Name: "a.mxf", Video: job.Video{Codec: "h264"}}}}}, ErrUnsupported},
+		"Codec":  {job.Job{Output: job.Dir{File: []job.File{{Name: "a.mp4", Video: job.Video{Codec: "prores"}}}}}, ErrUnsupported},
+		"Gop": {job.Job{Output: job.Dir{File: []job.File{{Name: "a.mp4",
+			Video: job.Video{Codec: "h264", Gop: job.Gop{Unit: "seconds", Size: 2}}}}}}, ErrUnsupported},
+		"Splice":  {job.Job{Input: job.File{Splice: timecode.Splice{{0, 1}, {2, 3}}}}, ErrUnsupported},
+		"Storage": {job.Job{Input: job.File{Name: "ftp://host/in.mov"}}, storage.ErrUnsupported},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, p := newServer(t, map[string]string{"AddMedia": `{"response": {"MediaID": "1"}}`})
+			if _, err := p.Create(context.Background(), &tt.j); !errors.Is(err, tt.want) {
+				t.Fatalf("have %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	_, p := newServer(t, nil)
+	p.cfg.UserKey = "wrong"
+	_, err := p.Create(context.Background(), &job.Job{Input: job.File{Name: "s3://bucket/in.mov"}})
+	if err == nil || err.Error() != "submitting new job: Wrong user id or key!" {
+		t.Fatalf("have %v", err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		body   string
+		state  job.State
+		pct    float64
+		msg    string
+		output []job.File
+	}{
+		{
+			name:  "Processing",
+			body:  `{"response": {"job": {"id": "1", "status": "Processing", "progress": "45.5", "format": {"status": "Processing", "output": "mp4"}}}}`,
+			state: job.StateStarted, pct: 45.5,
+		},
+		{
+			name:  "Waiting",
+			body:  `{"response": {"job": {"id": "1", "status": "Waiting for encoder", "progress": "0"}}}`,
+			state: job.StateQueued,
+		},
+		{
+			name: "Error",
+			body: `{"response": {"job": {"id": "1", "status": "Error", "progress": "10",
+				"format": [{"status": "Error", "description": "Source file is damaged"}]}}}`,
+			state: job.StateFailed, pct: 10, msg: "Source file is damaged",
+		},
+		{
+			name: "Finished",
+			body: `{"response": {"job": {"id": "1", "status": "Finished", "progress": "100",
+				"format": [
+					{"status": "Finished", "output": "mp4", "size": "1920x1080", "convertedsize": "12345", "destination": "s3://bucket/out/abc/hd.mp4"},
+					{"status": "Finished", "output": "m4a", "convertedsize": "99", "destination": ["s3://bucket/out/abc/audio.m4a", "s3://backup/audio.m4a"]}
+				]}}}`,
+			state: job.StateFinished, pct: 100,
+			output: []job.File{
+				{Name: "s3://bucket/out/abc/hd.mp4", Container: "mp4", Size: 12345, Video: job.Video{Width: 1920, Height: 1080}},
+				{Name: "s3://bucket/out/abc/audio.m4a", Container: "m4a", Size: 99},
+				{Name: "s3://backup/audio.m4a", Container: "m4a", Size: 99},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, p := newServer(t, map[string]string{"GetStatus": tt.body})
+			st, err := p.Status(context.Background(), &job.Job{ID: "abc", ProviderJobID: "1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.query.MediaID != "1" || s.query.Extended != "yes" {
+				t.Fatalf("bad query: %+v", s.query)
+			}
+			if st.State != tt.state || st.Progress != tt.pct || st.Msg != tt.msg {
+				t.Fatalf("have %q %v %q, want %q %v %q", st.State, st.Progress, st.Msg, tt.state, tt.pct, tt.msg)
+			}
+			if diff := cmp.Diff(tt.output, st.Output.File); diff != "" {
+				t.Fatalf("outputs (-want +have):\n%s", diff)
+			}
+		})
+	}
+
+	_, p := newServer(t, map[string]string{"GetStatus": `{"response": {}}`})
+	if _, err := p.Status(context.Background(), &job.Job{ProviderJobID: "2"}); !errors.As(err, &provider.JobNotFoundError{}) {
+		t.Fatalf("have %v, want JobNotFoundError", err)
+	}
+}
+
+func TestState(t *testing.T) {
+	for s, want := range map[string]job.State{
+		"New": job.StateQueued, "Downloading": job.StateQueued, "Saving": job.StateStarted,
+		"Deleted": job.StateCanceled, "Strange": job.StateUnknown,
+	} {
+		if have := state(s); have != want {
+			t.Errorf("state(%q): have %q, want %q", s, have, want)
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	s, p := newServer(t, map[string]string{"CancelMedia": `{"response": {"message": "Deleted"}}`})
+	if err := p.Cancel(context.Background(), "1"); err != nil {
+		t.Fatal(err)
+	}
+	if s.query.Action != "CancelMedia" || s.query.MediaID != "1" {
+		t.Fatalf("bad query: %+v", s.query)
+	}
+}
+
+func TestHealthcheck(t *testing.T) {
+	for _, tt := range []struct {
+		body string
+		ok   bool
+	}{
+		{`{"status": "Ok", "status_code": "ok"}`, true},
+		{`{"status": "API Outage", "message": "investigating"}`, false},
+		{`not json`, false},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/status.php" || r.URL.Query().Get("format") != "json" {
+				t.Errorf("bad status url: %s", r.URL)
+			}
+			w.Write([]byte(tt.body))
+		}))
+		p := &driver{cfg: &config.EncodingCom{StatusEndpoint: srv.URL + "/"}, client: srv.Client()}
+		if err := p.Healthcheck(); (err == nil) != tt.ok {
+			t.Errorf("%s: have %v", tt.body, err)
+		}
+		srv.Close()
+	}
+}
@@ -12,7 +12,11 @@ import (
 
 	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
 	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/logging"
 	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/cbsinteractive/transcode-orchestrator/trace"
+	"github.com/zsiec/pkg/tracing"
 )
 
 const (
@@ -20,16 +24,31 @@ const (
 	Name = "flock"
 )
 
+// sources and destinations are the storage Flock reads and writes
+var (
+	sources      = []string{storage.S3, storage.GCS, storage.HTTP, storage.HTTPS}
+	destinations = []string{storage.S3, storage.GCS}
+)
+
 func init() {
 	err := provider.Register(Name, flockFactory)
 	if err != nil {
-		fmt.Printf("registering flock factory: %v", err)
+		logging.From(context.Background()).WithError(err).Error("registering flock factory")
 	}
 }
 
 type flock struct {
 	cfg    *config.Flock
 	client *http.Client
+	tracer tracing.Tracer
+}
+
+func (p *flock) trace(ctx context.Context, name string, err *error) func() {
+	_, x := trace.Begin(ctx, p.tracer, name)
+	return func() {
+		x.Close(*err)
+		logging.Done(ctx, name, *err)
+	}
 }
 
 type JobRequest struct {
@@ -109,7 +128,9 @@ func (p *flock) Create(ctx context.Context, j *job.Job) (*job.Status, error) {
 	req.Header.Set("Authorization", p.cfg.Credential)
 	req.Header.Set("Content-Type", "application/json")
 
+	done := p.trace(ctx, "flock-create-job", &err)
 	resp, err := p.client.Do(req)
+	done()
 	if err != nil {
 		return nil, fmt.Errorf("submitting new job: %w", err)
 	}
@@ -133,6 +154,15 @@ func (p *flock) Create(ctx context.Context, j *job.Job) (*job.Status, error) {
 	}, nil
 }
 
+// DryRun returns the request Create would submit
+func (p *flock) DryRun(_ context.Context, j *job.Job) ([]byte, error) {
+	jr, err := p.flockJobRequestFrom(j)
+	if err != nil {
+		return nil, fmt.Errorf("generating flock job request: %w", err)
+	}
+	return json.MarshalIndent(jr, "", "\t")
+}
+
 func NewRequest(j *job.Job) (*JobRequest, error) {
 	fj := &JobRequest{}
 	fj.Job.Source = j.Input.Name
@@ -167,6 +197,14 @@ func NewRequest(j *job.Job) (*JobRequest, error) {
 }
 
 func (p *flock) flockJobRequestFrom(j *job.Job) (*JobRequest, error) {
+	if _, err := storage.Check(j.Input.Name, sources...); err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	if j.Output.Path != "" {
+		if _, err := storage.Check(j.Output.Path, destinations...); err != nil {
+			return nil, fmt.Errorf("output: %w", err)
+		}
+	}
 	return NewRequest(j)
 }
 
@@ -178,7 +216,9 @@ func (p *flock) Status(ctx context.Context, job *job.Job) (*job.Status, error) {
 	}
 	req.Header.Set("Authorization", p.cfg.Credential)
 
+	done := p.trace(ctx, "flock-get-job", &err)
 	resp, err := p.client.Do(req)
+	done()
 	if err != nil {
 		return nil, fmt.Errorf("querying for provider job %s: %w", job.ProviderJobID, err)
 	}
@@ -256,7 +296,8 @@ func state(j *JobResponse) job.State {
 	return job.StateUnknown
 }
 
-func (p *flock) Cancel(ctx context.Context, providerID string) error {
+func (p *flock) Cancel(ctx context.Context, providerID string) (err error) {
+	defer p.trace(ctx, "flock-cancel-job", &err)()
 	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, fmt.Sprintf("%s/api/v1/jobs/%s", p.cfg.Endpoint, providerID), nil)
 	if err != nil {
 		return err
@@ -298,7 +339,7 @@ func (*flock) Capabilities() provider.Capabilities {
 	return provider.Capabilities{
 		InputFormats:  []string{"h264", "h265"},
 		OutputFormats: []string{"mp4"},
-		Destinations:  []string{"s3", "gs"},
+		Destinations:  destinations,
 	}
 }
 
@@ -310,5 +351,6 @@ func flockFactory(cfg *config.Config) (provider.Provider, error) {
 	return &flock{
 		cfg:    cfg.Flock,
 		client: &http.Client{Timeout: time.Second * 30},
+		tracer: cfg.Tracer,
 	}, nil
 }
@@ -2,13 +2,16 @@ package flock
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"net/http"
 	"strings"
 	"testing"
 
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
 	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
 )
 
 func TestFlockCancel(t *testing.T) {
@@ -97,6 +100,40 @@ func TestFlockCancel(t *testing.T) {
 	}
 }
 
+func TestFlockStorage(t *testing.T) {
+	p := &flock{cfg: &config.Flock{}}
+	for name, j := range map[string]*job.Job{
+		"input":  {Input: job.File{Name: "ftp://host/in.mov"}},
+		"output": {Input: job.File{Name: "s3://bucket/in.mov"}, Output: job.Dir{Path: "file:///out"}},
+	} {
+		if _, err := p.flockJobRequestFrom(j); !errors.Is(err, storage.ErrUnsupported) {
+			t.Errorf("%s: have %v, want %v", name, err, storage.ErrUnsupported)
+		}
+	}
+}
+
+func TestFlockDryRun(t *testing.T) {
+	rt := &mockRoundTripper{}
+	p := &flock{cfg: &config.Flock{Credential: "secret"}, client: &http.Client{Transport: rt}}
+	j := &job.Job{ID: "abc", Input: job.File{Name: "s3://bucket/in.mov"}, Output: job.Dir{Path: "s3://bucket/out", File: []job.File{
+		{Name: "hd.mp4", Video: job.Video{Codec: "h264", Height: 1080}},
+	}}}
+	data, err := p.DryRun(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var have JobRequest
+	if err := json.Unmarshal(data, &have); err != nil {
+		t.Fatalf("bad json: %v\n%s", err, data)
+	}
+	if have.Job.Source != j.Input.Name || len(have.Job.Outputs) != 1 || have.Job.Outputs[0].Destination != "s3://bucket/out/abc/hd.mp4" {
+		t.Fatalf("bad request: %s", data)
+	}
+	if rt.calledWithReq != nil {
+		t.Fatalf("dry run called the api: %s %s", rt.calledWithReq.Method, rt.calledWithReq.URL)
+	}
+}
+
 type mockRoundTripper struct {
 	calledWithReq *http.Request
 	returnsResp   http.Response
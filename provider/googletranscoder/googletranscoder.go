@@ -0,0 +1,532 @@
+package googletranscoder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cbsinteractive/pkg/video"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/gcloud"
+	"github.com/cbsinteractive/transcode-orchestrator/logging"
+	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/cbsinteractive/transcode-orchestrator/trace"
+	"github.com/zsiec/pkg/tracing"
+)
+
+// Name identifies the Google Cloud Transcoder provider by name
+const Name = "googletranscoder"
+
+// inputKey names the job's only input in its config
+const inputKey = "input0"
+
+var (
+	ErrUnsupported = errors.New("unsupported")
+	errNotFound    = errors.New("not found")
+)
+
+func init() {
+	err := provider.Register(Name, factory)
+	if err != nil {
+		logging.From(context.Background()).WithError(err).Error("registering googletranscoder factory")
+	}
+}
+
+type driver struct {
+	cfg    *config.GoogleTranscoder
+	client *http.Client
+	tokens gcloud.TokenSource
+	tracer tracing.Tracer
+}
+
+func (p *driver) trace(ctx context.Context, name string, err *error) func() {
+	_, x := trace.Begin(ctx, p.tracer, name)
+	return func() {
+		x.Close(*err)
+		logging.Done(ctx, name, *err)
+	}
+}
+
+// Job is a Transcoder API job
+type Job struct {
+	Name      string            `json:"name,omitempty"`
+	InputURI  string            `json:"inputUri"`
+	OutputURI string            `json:"outputUri"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Config    *JobConfig        `json:"config,omitempty"`
+	State     string            `json:"state,omitempty"`
+	Error     *Error            `json:"error,omitempty"`
+}
+
+// Error is why a job failed
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JobConfig describes how the input is transcoded to the outputs
+type JobConfig struct {
+	Inputs            []Input            `json:"inputs"`
+	EditList          []EditAtom         `json:"editList,omitempty"`
+	ElementaryStreams []ElementaryStream `json:"elementaryStreams"`
+	MuxStreams        []MuxStream        `json:"muxStreams"`
+}
+
+// Input is an input of a job and how it's preprocessed
+type Input struct {
+	Key           string         `json:"key"`
+	URI           string         `json:"uri,omitempty"`
+	Preprocessing *Preprocessing `json:"preprocessingConfig,omitempty"`
+}
+
+// Preprocessing is applied to an input before it's encoded
+type Preprocessing struct {
+	Crop        *Crop        `json:"crop,omitempty"`
+	Deinterlace *Deinterlace `json:"deinterlace,omitempty"`
+}
+
+// Crop removes pixels from the edges of the input
+type Crop struct {
+	TopPixels    int `json:"topPixels,omitempty"`
+	BottomPixels int `json:"bottomPixels,omitempty"`
+	LeftPixels   int `json:"leftPixels,omitempty"`
+	RightPixels  int `json:"rightPixels,omitempty"`
+}
+
+// Deinterlace selects the yadif deinterlacer
+type Deinterlace struct {
+	Yadif *struct{} `json:"yadif,omitempty"`
+}
+
+// EditAtom is one range of the input placed in the outputs
+type EditAtom struct {
+	Key             string   `json:"key"`
+	Inputs          []string `json:"inputs"`
+	StartTimeOffset string   `json:"startTimeOffset,omitempty"`
+	EndTimeOffset   string   `json:"endTimeOffset,omitempty"`
+}
+
+// ElementaryStream is an encoded video or audio stream
+type ElementaryStream struct {
+	Key         string       `json:"key"`
+	VideoStream *VideoStream `json:"videoStream,omitempty"`
+	AudioStream *AudioStream `json:"audioStream,omitempty"`
+}
+
+// VideoStream holds the settings for one of its codecs
+type VideoStream struct {
+	H264 *VideoSettings `json:"h264,omitempty"`
+	H265 *VideoSettings `json:"h265,omitempty"`
+	VP9  *VideoSettings `json:"vp9,omitempty"`
+}
+
+// VideoSettings are the settings common to the video codecs. Vp9 doesn't
+// take the two pass and vbv settings.
+type VideoSettings struct {
+	WidthPixels   int     `json:"widthPixels,omitempty"`
+	HeightPixels  int     `json:"heightPixels,omitempty"`
+	FrameRate     float64 `json:"frameRate"`
+	BitrateBps    int     `json:"bitrateBps"`
+	Profile       string  `json:"profile,omitempty"`
+	GopFrameCount int     `json:"gopFrameCount,omitempty"`
+	GopDuration   string  `json:"gopDuration,omitempty"`
+	EnableTwoPass bool    `json:"enableTwoPass,omitempty"`
+	VbvSizeBits   int     `json:"vbvSizeBits,omitempty"`
+}
+
+// AudioStream is an encoded audio stream
+type AudioStream struct {
+	Codec        string `json:"codec"`
+	BitrateBps   int    `json:"bitrateBps"`
+	ChannelCount int    `json:"channelCount,omitempty"`
+}
+
+// MuxStream is an output file made of elementary streams
+type MuxStream struct {
+	Key               string   `json:"key"`
+	FileName          string   `json:"fileName"`
+	Container         string   `json:"container"`
+	ElementaryStreams []string `json:"elementaryStreams"`
+}
+
+func (p *driver) Create(ctx context.Context, j *job.Job) (*job.Status, error) {
+	gj, err := p.job(j)
+	if err != nil {
+		return nil, fmt.Errorf("generating transcoder job: %w", err)
+	}
+
+	var created Job
+	done := p.trace(ctx, "googletranscoder-create-job", &err)
+	err = p.do(ctx, http.MethodPost, p.jobs(), gj, &created)
+	done()
+	if err != nil {
+		return nil, fmt.Errorf("submitting new job: %w", err)
+	}
+
+	return &job.Status{
+		Provider:      Name,
+		ProviderJobID: path.Base(created.Name),
+		State:         job.StateQueued,
+	}, nil
+}
+
+// DryRun returns the job Create would submit
+func (p *driver) DryRun(_ context.Context, j *job.Job) ([]byte, error) {
+	gj, err := p.job(j)
+	if err != nil {
+		return nil, fmt.Errorf("generating transcoder job: %w", err)
+	}
+	return json.MarshalIndent(gj, "", "\t")
+}
+
+func (p *driver) job(j *job.Job) (*Job, error) {
+	src, err := storage.Check(j.Input.Name, storage.GCS)
+	if err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	dst, err := storage.Check(p.location(*j, ""), storage.GCS)
+	if err != nil {
+		return nil, fmt.Errorf("output: %w", err)
+	}
+	gj := &Job{
+		InputURI:  src.String(),
+		OutputURI: strings.TrimSuffix(dst.String(), "/") + "/",
+		Labels:    labels(j.Labels),
+		Config:    &JobConfig{},
+	}
+	in := Input{Key: inputKey, URI: src.String()}
+	for i, r := range j.Input.Splice {
+		r = r.Canon()
+		gj.Config.EditList = append(gj.Config.EditList, EditAtom{
+			Key:             "atom" + strconv.Itoa(i),
+			Inputs:          []string{inputKey},
+			StartTimeOffset: duration(r[0]),
+			EndTimeOffset:   duration(r[1]),
+		})
+	}
+
+	var pre *Preprocessing
+	for i, f := range j.Output.File {
+		mux, es, err := streams(i, f)
+		if err != nil {
+			return nil, fmt.Errorf("output %q: %w", f.Name, err)
+		}
+		if f.Video.On() {
+			fp := preprocessing(f.Video)
+			if pre != nil && !equal(pre, fp) {
+				return nil, fmt.Errorf("%w: outputs with different crop or scantype", ErrUnsupported)
+			}
+			pre = fp
+		}
+		gj.Config.ElementaryStreams = append(gj.Config.ElementaryStreams, es...)
+		gj.Config.MuxStreams = append(gj.Config.MuxStreams, mux)
+	}
+	if pre != nil && (pre.Crop != nil || pre.Deinterlace != nil) {
+		in.Preprocessing = pre
+	}
+	gj.Config.Inputs = []Input{in}
+	return gj, nil
+}
+
+// streams returns the ith output's mux stream and the elementary
+// streams in it
+func streams(i int, f job.File) (MuxStream, []ElementaryStream, error) {
+	n := strconv.Itoa(i)
+	mux := MuxStream{Key: "output" + n, FileName: f.Name, Container: f.Container}
+	if mux.Container == "" {
+		mux.Container = f.Type()
+	}
+	if mux.Container != "mp4" && mux.Container != "ts" {
+		return mux, nil, fmt.Errorf("%w: container %q", ErrUnsupported, mux.Container)
+	}
+
+	var es []ElementaryStream
+	if v := f.Video; v.On() {
+		vs, err := videoStream(v)
+		if err != nil {
+			return mux, nil, err
+		}
+		es = append(es, ElementaryStream{Key: "video" + n, VideoStream: vs})
+	}
+	if a := f.Audio; a.On() {
+		codec, ok := audioCodecs[strings.ToLower(a.Codec)]
+		if !ok {
+			return mux, nil, fmt.Errorf("%w: audio codec %q", ErrUnsupported, a.Codec)
+		}
+		as := &AudioStream{Codec: codec, BitrateBps: a.Bitrate, ChannelCount: 2}
+		if as.BitrateBps == 0 {
+			as.BitrateBps = 128000
+		}
+		if f.Downmix != nil && len(f.Downmix.Dst) > 0 {
+			as.ChannelCount = len(f.Downmix.Dst)
+		}
+		es = append(es, ElementaryStream{Key: "audio" + n, AudioStream: as})
+	}
+	if len(es) == 0 {
+		return mux, nil, fmt.Errorf("%w: output without audio or video", ErrUnsupported)
+	}
+	for _, e := range es {
+		mux.ElementaryStreams = append(mux.ElementaryStreams, e.Key)
+	}
+	return mux, es, nil
+}
+
+func videoStream(v job.Video) (*VideoStream, error) {
+	if v.FPS == 0 || v.Bitrate.BPS == 0 {
+		return nil, fmt.Errorf("%w: video without a frame rate and bitrate", ErrUnsupported)
+	}
+	s := &VideoSettings{
+		WidthPixels:  v.Width,
+		HeightPixels: v.Height,
+		FrameRate:    v.FPS,
+		BitrateBps:   v.Bitrate.BPS,
+		Profile:      strings.ToLower(v.Profile),
+	}
+	if v.Gop.Seconds() {
+		if v.Gop.Size > 0 {
+			s.GopDuration = duration(v.Gop.Size)
+		}
+	} else {
+		s.GopFrameCount = int(v.Gop.Size)
+	}
+
+	switch strings.ToLower(v.Codec) {
+	case "h264", "avc":
+		twopass(s, v.Bitrate)
+		return &VideoStream{H264: s}, nil
+	case "h265", "hevc":
+		twopass(s, v.Bitrate)
+		return &VideoStream{H265: s}, nil
+	case "vp9":
+		return &VideoStream{VP9: s}, nil
+	}
+	return nil, fmt.Errorf("%w: video codec %q", ErrUnsupported, v.Codec)
+}
+
+// twopass sets the rate control settings only h264 and h265 have. The api
+// has no cbr mode, so cbr limits the buffer to one second of video.
+func twopass(s *VideoSettings, b job.Bitrate) {
+	s.EnableTwoPass = b.TwoPass
+	if strings.EqualFold(b.Control, "CBR") {
+		s.VbvSizeBits = b.BPS
+	}
+}
+
+func preprocessing(v job.Video) *Preprocessing {
+	pre := &Preprocessing{}
+	if c := v.Crop; !c.Empty() {
+		pre.Crop = crop(c)
+	}
+	if v.Scantype == job.ScanInterlaced {
+		pre.Deinterlace = &Deinterlace{Yadif: &struct{}{}}
+	}
+	return pre
+}
+
+func crop(c video.Crop) *Crop {
+	return &Crop{TopPixels: c.Top, BottomPixels: c.Bottom, LeftPixels: c.Left, RightPixels: c.Right}
+}
+
+func equal(a, b *Preprocessing) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
+
+var audioCodecs = map[string]string{
+	"aac":  "aac",
+	"mp3":  "mp3",
+	"ac3":  "ac3",
+	"eac3": "eac3",
+}
+
+// labels converts the job's labels to label keys, which must be lowercase
+// letters, digits, underscores and dashes, starting with a letter
+func labels(l []string) map[string]string {
+	if len(l) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(l))
+	for _, s := range l {
+		k := []byte(strings.ToLower(s))
+		for i, c := range k {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				k[i] = '_'
+			}
+		}
+		if len(k) == 0 || k[0] < 'a' || k[0] > 'z' {
+			k = append([]byte("label_"), k...)
+		}
+		if len(k) > 63 {
+			k = k[:63]
+		}
+		m[string(k)] = "true"
+	}
+	return m
+}
+
+// duration formats seconds as a protobuf duration
+func duration(sec float64) string {
+	return strconv.FormatFloat(sec, 'f', -1, 64) + "s"
+}
+
+func (p *driver) location(j job.Job, file string) string {
+	if j.Output.Path == "" {
+		j.Output.Path = p.cfg.Destination
+	}
+	return j.Location(file)
+}
+
+func (p *driver) Status(ctx context.Context, j *job.Job) (st *job.Status, err error) {
+	defer p.trace(ctx, "googletranscoder-get-job", &err)()
+
+	var gj Job
+	err = p.do(ctx, http.MethodGet, p.jobs()+"/"+j.ProviderJobID, nil, &gj)
+	if errors.Is(err, errNotFound) {
+		return nil, provider.JobNotFoundError{ID: j.ProviderJobID}
+	} else if err != nil {
+		return nil, fmt.Errorf("querying for provider job %s: %w", j.ProviderJobID, err)
+	}
+
+	st = &job.Status{
+		Provider:      Name,
+		ProviderJobID: j.ProviderJobID,
+		State:         state(gj.State),
+		Labels:        j.Labels,
+		Output:        job.Dir{Path: strings.TrimSuffix(gj.OutputURI, "/")},
+		ProviderStatus: map[string]interface{}{
+			"name":  gj.Name,
+			"state": gj.State,
+		},
+	}
+	if gj.Error != nil {
+		st.Msg = gj.Error.Message
+	}
+	if st.State != job.StateFinished {
+		return st, nil
+	}
+	st.Progress = 100
+	if gj.Config != nil {
+		for _, m := range gj.Config.MuxStreams {
+			st.Output.Add(job.File{Name: gj.OutputURI + m.FileName, Container: m.Container})
+		}
+	}
+	return st, nil
+}
+
+func state(s string) job.State {
+	switch s {
+	case "PENDING":
+		return job.StateQueued
+	case "RUNNING":
+		return job.StateStarted
+	case "SUCCEEDED":
+		return job.StateFinished
+	case "FAILED":
+		return job.StateFailed
+	}
+	return job.StateUnknown
+}
+
+// Cancel deletes the job, which is the only way the api stops one
+func (p *driver) Cancel(ctx context.Context, id string) (err error) {
+	defer p.trace(ctx, "googletranscoder-cancel-job", &err)()
+	err = p.do(ctx, http.MethodDelete, p.jobs()+"/"+id, nil, nil)
+	if errors.Is(err, errNotFound) {
+		err = provider.JobNotFoundError{ID: id}
+	}
+	return err
+}
+
+func (p *driver) Healthcheck() error {
+	return p.do(context.Background(), http.MethodGet, p.jobs()+"?pageSize=1", nil, nil)
+}
+
+func (*driver) Capabilities() provider.Capabilities {
+	return provider.Capabilities{
+		InputFormats:  []string{"h264", "h265", "vp9", "mpeg2", "prores"},
+		OutputFormats: []string{"mp4", "ts"},
+		Destinations:  []string{storage.GCS},
+	}
+}
+
+func (p *driver) jobs() string {
+	return "/projects/" + p.cfg.ProjectID + "/locations/" + p.cfg.Location + "/jobs"
+}
+
+// do sends in, if any, as json and decodes the response into out, if any
+func (p *driver) do(ctx context.Context, method, path string, in, out interface{}) error {
+	tok, err := p.tokens.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("authenticating: %w", err)
+	}
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("marshaling request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(p.cfg.Endpoint, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %w", err)
+	}
+	if c := resp.StatusCode; c == http.StatusNotFound {
+		return errNotFound
+	} else if c/100 != 2 {
+		return fmt.Errorf("received non 2xx status code, got %d with body: %s", c, string(data))
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("parsing transcoder response: %w", err)
+	}
+	return nil
+}
+
+func factory(cfg *config.Config) (provider.Provider, error) {
+	g := cfg.GoogleTranscoder
+	if g == nil || g.ProjectID == "" || g.Location == "" {
+		return nil, errors.New("incomplete Google Transcoder config")
+	}
+	client := &http.Client{Timeout: time.Second * 30}
+	tokens, err := gcloud.Tokens(client, g.CredentialsKey)
+	if err != nil {
+		return nil, err
+	}
+	return &driver{
+		cfg:    g,
+		client: client,
+		tokens: tokens,
+		tracer: cfg.Tracer,
+	}, nil
+}
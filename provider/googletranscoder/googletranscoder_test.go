@@ -0,0 +1,294 @@
+package googletranscoder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cbsinteractive/pkg/timecode"
+	"github.com/cbsinteractive/pkg/video"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/google/go-cmp/cmp"
+)
+
+const jobs = "/v1/projects/proj/locations/us-central1/jobs"
+
+type static string
+
+func (s static) Token(context.Context) (string, error) { return string(s), nil }
+
+// server stands in for the Transcoder API. It rejects requests without
+// the bearer token, answers each "METHOD path" with its canned body and
+// records the last request body.
+type server struct {
+	*httptest.Server
+	body []byte
+}
+
+func newServer(t *testing.T, routes map[string]string) (*server, *driver) {
+	s := &server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.body, _ = ioutil.ReadAll(r.Body)
+		body, ok := routes[r.Method+" "+r.URL.RequestURI()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(s.Close)
+	return s, &driver{
+		cfg: &config.GoogleTranscoder{
+			ProjectID: "proj", Location: "us-central1",
+			Destination: "gs://bucket/out", Endpoint: s.URL + "/v1",
+		},
+		client: s.Client(),
+		tokens: static("tok"),
+	}
+}
+
+func TestCreate(t *testing.T) {
+	s, p := newServer(t, map[string]string{"POST " + jobs: `{"name": "projects/proj/locations/us-central1/jobs/j-1", "state": "PENDING"}`})
+	j := &job.Job{
+		ID:     "abc",
+		Labels: []string{"News", "late night"},
+		Input:  job.File{Name: "gs://bucket/in.mov", Splice: timecode.Splice{{30, 5}, {60, 90.5}}},
+		Output: job.Dir{File: []job.File{
+			{
+				Name: "hd.mp4",
+				Video: job.Video{
+					Codec: "h264", Profile: "High", Width: 1920, Height: 1080, FPS: 25,
+					Bitrate:  job.Bitrate{BPS: 5000000, Control: "CBR", TwoPass: true},
+					Gop:      job.Gop{Unit: "seconds", Size: 2},
+					Scantype: job.ScanInterlaced,
+					Crop:     video.Crop{Top: 2, Bottom: 2},
+				},
+				Audio:   job.Audio{Codec: "aac", Bitrate: 96000},
+				Downmix: &job.Downmix{Dst: make([]job.AudioChannel, 6)},
+			},
+			{
+				Name: "sd.ts",
+				Video: job.Video{
+					Codec: "vp9", Height: 480, FPS: 25, Bitrate: job.Bitrate{BPS: 1000000},
+					Gop: job.Gop{Size: 50}, Scantype: job.ScanInterlaced, Crop: video.Crop{Top: 2, Bottom: 2},
+				},
+			},
+			{Name: "audio.mp4", Audio: job.Audio{Codec: "aac"}},
+		}},
+	}
+	st, err := p.Create(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.ProviderJobID != "j-1" || st.State != job.StateQueued {
+		t.Fatalf("bad status: %+v", st)
+	}
+
+	var have Job
+	if err := json.Unmarshal(s.body, &have); err != nil {
+		t.Fatalf("sent bad json: %v\n%s", err, s.body)
+	}
+	want := Job{
+		InputURI:  "gs://bucket/in.mov",
+		OutputURI: "gs://bucket/out/abc/",
+		Labels:    map[string]string{"news": "true", "late_night": "true"},
+		Config: &JobConfig{
+			Inputs: []Input{{
+				Key: "input0", URI: "gs://bucket/in.mov",
+				Preprocessing: &Preprocessing{
+					Crop:        &Crop{TopPixels: 2, BottomPixels: 2},
+					Deinterlace: &Deinterlace{Yadif: &struct{}{}},
+				},
+			}},
+			EditList: []EditAtom{
+				{Key: "atom0", Inputs: []string{"input0"}, StartTimeOffset: "5s", EndTimeOffset: "30s"},
+				{Key: "atom1", Inputs: []string{"input0"}, StartTimeOffset: "60s", EndTimeOffset: "90.5s"},
+			},
+			ElementaryStreams: []ElementaryStream{
+				{Key: "video0", VideoStream: &VideoStream{H264: &VideoSettings{
+					WidthPixels: 1920, HeightPixels: 1080, FrameRate: 25, BitrateBps: 5000000,
+					Profile: "high", GopDuration: "2s", EnableTwoPass: true, VbvSizeBits: 5000000,
+				}}},
+				{Key: "audio0", AudioStream: &AudioStream{Codec: "aac", BitrateBps: 96000, ChannelCount: 6}},
+				{Key: "video1", VideoStream: &VideoStream{VP9: &VideoSettings{
+					HeightPixels: 480, FrameRate: 25, BitrateBps: 1000000, GopFrameCount: 50,
+				}}},
+				{Key: "audio2", AudioStream: &AudioStream{Codec: "aac", BitrateBps: 128000, ChannelCount: 2}},
+			},
+			MuxStreams: []MuxStream{
+				{Key: "output0", FileName: "hd.mp4", Container: "mp4", ElementaryStreams: []string{"video0", "audio0"}},
+				{Key: "output1", FileName: "sd.ts", Container: "ts", ElementaryStreams: []string{"video1"}},
+				{Key: "output2", FileName: "audio.mp4", Container: "mp4", ElementaryStreams: []string{"audio2"}},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Fatalf("job mismatch (-want +have):\n%s", diff)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	s, p := newServer(t, nil)
+	j := &job.Job{ID: "abc", Input: job.File{Name: "gs://bucket/in dir/100% a%2Fb.mov"}, Output: job.Dir{File: []job.File{
+		{Name: "hd.mp4", Video: job.Video{Codec: "h264", Height: 1080, FPS: 25, Bitrate: job.Bitrate{BPS: 5000000}}},
+	}}}
+	data, err := p.DryRun(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var have Job
+	if err := json.Unmarshal(data, &have); err != nil {
+		t.Fatalf("bad json: %v\n%s", err, data)
+	}
+	if have.InputURI != j.Input.Name || have.OutputURI != "gs://bucket/out/abc/" || len(have.Config.MuxStreams) != 1 {
+		t.Fatalf("bad job: %s", data)
+	}
+	if s.body != nil {
+		t.Fatalf("dry run submitted the job: %s", s.body)
+	}
+}
+
+func TestCreateUnsupported(t *testing.T) {
+	_, p := newServer(t, nil)
+	h264 := job.Video{Codec: "h264", FPS: 30, Bitrate: job.Bitrate{BPS: 1000}}
+	for name, j := range map[string]job.Job{
+		"Container": {Input: job.File{Name: "gs://b/in.mov"}, Output: job.Dir{File: []job.File{{Name: "a.mxf", Video: h264}}}},
+		"Codec":     {Input: job.File{Name: "gs://b/in.mov"}, Output: job.Dir{File: []job.File{{Name: "a.mp4", Video: job.Video{Codec: "prores", FPS: 30, Bitrate: job.Bitrate{BPS: 1000}}}}}},
+		"Bitrate":   {Input: job.File{Name: "gs://b/in.mov"}, Output: job.Dir{File: []job.File{{Name: "a.mp4", Video: job.Video{Codec: "h264", FPS: 30}}}}},
+		"Audio":     {Input: job.File{Name: "gs://b/in.mov"}, Output: job.Dir{File: []job.File{{Name: "a.mp4", Audio: job.Audio{Codec: "opus"}}}}},
+		"Empty":     {Input: job.File{Name: "gs://b/in.mov"}, Output: job.Dir{File: []job.File{{Name: "a.mp4"}}}},
+		"Crop": {Input: job.File{Name: "gs://b/in.mov"}, Output: job.Dir{File: []job.File{
+			{Name: "a.mp4", Video: h264},
+			{Name: "b.mp4", Video: job.Video{Codec: "h264", FPS: 30, Bitrate: job.Bitrate{BPS: 1000}, Crop: video.Crop{Left: 8}}},
+		}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := p.Create(context.Background(), &j); !errors.Is(err, ErrUnsupported) {
+				t.Fatalf("have %v, want %v", err, ErrUnsupported)
+			}
+		})
+	}
+}
+
+func TestCreateStorage(t *testing.T) {
+	_, p := newServer(t, nil)
+	for name, j := range map[string]job.Job{
+		"Input":  {Input: job.File{Name: "s3://bucket/in.mov"}},
+		"Output": {Input: job.File{Name: "gs://b/in.mov"}, Output: job.Dir{Path: "s3://bucket/out"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := p.Create(context.Background(), &j); !errors.Is(err, storage.ErrUnsupported) {
+				t.Fatalf("have %v, want %v", err, storage.ErrUnsupported)
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		body string
+		want *job.Status
+	}{
+		{
+			name: "Running",
+			body: `{"name": "projects/proj/locations/us-central1/jobs/j-1", "outputUri": "gs://bucket/out/abc/", "state": "RUNNING"}`,
+			want: &job.Status{State: job.StateStarted},
+		},
+		{
+			name: "Failed",
+			body: `{"name": "projects/proj/locations/us-central1/jobs/j-1", "outputUri": "gs://bucket/out/abc/", "state": "FAILED",
+				"error": {"code": 3, "message": "input is not a video"}}`,
+			want: &job.Status{State: job.StateFailed, Msg: "input is not a video"},
+		},
+		{
+			name: "Succeeded",
+			body: `{"name": "projects/proj/locations/us-central1/jobs/j-1", "outputUri": "gs://bucket/out/abc/", "state": "SUCCEEDED",
+				"config": {"muxStreams": [
+					{"key": "output0", "fileName": "hd.mp4", "container": "mp4"},
+					{"key": "output1", "fileName": "sd.ts", "container": "ts"}
+				]}}`,
+			want: &job.Status{State: job.StateFinished, Progress: 100, Output: job.Dir{File: []job.File{
+				{Name: "gs://bucket/out/abc/hd.mp4", Container: "mp4"},
+				{Name: "gs://bucket/out/abc/sd.ts", Container: "ts"},
+			}}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, p := newServer(t, map[string]string{"GET " + jobs + "/j-1": tt.body})
+			st, err := p.Status(context.Background(), &job.Job{ID: "abc", ProviderJobID: "j-1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if st.State != tt.want.State || st.Progress != tt.want.Progress || st.Msg != tt.want.Msg {
+				t.Fatalf("have %q %v %q, want %q %v %q", st.State, st.Progress, st.Msg, tt.want.State, tt.want.Progress, tt.want.Msg)
+			}
+			if diff := cmp.Diff(tt.want.Output.File, st.Output.File); diff != "" {
+				t.Fatalf("outputs (-want +have):\n%s", diff)
+			}
+			if st.Output.Path != "gs://bucket/out/abc" {
+				t.Fatalf("output path: %q", st.Output.Path)
+			}
+		})
+	}
+
+	_, p := newServer(t, nil)
+	if _, err := p.Status(context.Background(), &job.Job{ProviderJobID: "j-2"}); !errors.As(err, &provider.JobNotFoundError{}) {
+		t.Fatalf("have %v, want JobNotFoundError", err)
+	}
+}
+
+func TestState(t *testing.T) {
+	for s, want := range map[string]job.State{
+		"PENDING": job.StateQueued, "RUNNING": job.StateStarted, "SUCCEEDED": job.StateFinished,
+		"FAILED": job.StateFailed, "PROCESSING_STATE_UNSPECIFIED": job.StateUnknown,
+	} {
+		if have := state(s); have != want {
+			t.Errorf("state(%q): have %q, want %q", s, have, want)
+		}
+	}
+}
+
+func TestLabels(t *testing.T) {
+	have := labels([]string{"Prime Time", "4k", "a.b-c"})
+	want := map[string]string{"prime_time": "true", "label_4k": "true", "a_b-c": "true"}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Fatalf("labels (-want +have):\n%s", diff)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	_, p := newServer(t, map[string]string{"DELETE " + jobs + "/j-1": `{}`})
+	if err := p.Cancel(context.Background(), "j-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Cancel(context.Background(), "j-2"); !errors.As(err, &provider.JobNotFoundError{}) {
+		t.Fatalf("have %v, want JobNotFoundError", err)
+	}
+}
+
+func TestHealthcheck(t *testing.T) {
+	s, p := newServer(t, map[string]string{"GET " + jobs + "?pageSize=1": `{"jobs": []}`})
+	if err := p.Healthcheck(); err != nil {
+		t.Fatal(err)
+	}
+	p.tokens = static("expired")
+	if err := p.Healthcheck(); err == nil {
+		t.Fatal("healthcheck passed with a bad token")
+	}
+	s.Close()
+	if err := p.Healthcheck(); err == nil {
+		t.Fatal("healthcheck passed with the api down")
+	}
+}
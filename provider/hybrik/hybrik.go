@@ -9,8 +9,12 @@ import (
 	hy "github.com/cbsinteractive/hybrik-sdk-go"
 	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
 	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/logging"
 	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/cbsinteractive/transcode-orchestrator/trace"
 	"github.com/pkg/errors"
+	"github.com/zsiec/pkg/tracing"
 )
 
 type (
@@ -36,6 +40,7 @@ const (
 
 var (
 	ErrUnsupportedContainer = errors.New("container format unsupported. Hybrik provider capabilities may need to be updated")
+	ErrUnsupported          = errors.New("unsupported")
 )
 
 func init() {
@@ -45,6 +50,17 @@ func init() {
 type driver struct {
 	c      hy.ClientInterface
 	config *config.Hybrik
+	tracer tracing.Tracer
+
+	segmentDuration uint // seconds, when the job doesn't say
+}
+
+func (p *driver) trace(ctx context.Context, name string, err *error) func() {
+	_, x := trace.Begin(ctx, p.tracer, name)
+	return func() {
+		x.Close(*err)
+		logging.Done(ctx, name, *err)
+	}
 }
 
 func (p driver) String() string {
@@ -67,6 +83,9 @@ func hybrikTranscoderFactory(cfg *config.Config) (provider.Provider, error) {
 	return &driver{
 		c:      api,
 		config: cfg.Hybrik,
+		tracer: cfg.Tracer,
+
+		segmentDuration: cfg.DefaultSegmentDuration,
 	}, nil
 }
 
@@ -76,7 +95,9 @@ func (p *driver) Create(ctx context.Context, j *Job) (*Status, error) {
 		return nil, err
 	}
 
+	done := p.trace(ctx, "hybrik-queue-job", &err)
 	id, err := p.c.QueueJob(string(c))
+	done()
 	if err != nil {
 		return nil, err
 	}
@@ -88,6 +109,11 @@ func (p *driver) Create(ctx context.Context, j *Job) (*Status, error) {
 	}, nil
 }
 
+// DryRun returns the job Create would queue
+func (p *driver) DryRun(_ context.Context, j *Job) ([]byte, error) {
+	return p.create(j)
+}
+
 func (p *driver) create(j *Job) ([]byte, error) {
 	c, err := p.jobRequest(j)
 	if err != nil {
@@ -145,6 +171,9 @@ func (p *driver) jobRequest(j *Job) (*hy.CreateJob, error) {
 		})
 		prev = eg
 	}
+	pkgs, pconn := p.packageElems(j)
+	task = append(task, pkgs...)
+	conn = append(conn, pconn...)
 
 	return &hy.CreateJob{
 		Name: fmt.Sprintf("Job %s [%s]", j.ID, path.Base(j.Input.Name)),
@@ -159,8 +188,11 @@ func (p *driver) StorageFallback() (path string) {
 	return p.config.Destination
 }
 
-func (p *driver) Status(_ context.Context, j *Job) (*Status, error) {
+func (p *driver) Status(ctx context.Context, j *Job) (*Status, error) {
+	var err error
+	done := p.trace(ctx, "hybrik-get-job-info", &err)
 	ji, err := p.c.GetJobInfo(j.ProviderJobID)
+	done()
 	if err != nil {
 		return &Status{}, err
 	}
@@ -182,14 +214,26 @@ func (p *driver) Status(_ context.Context, j *Job) (*Status, error) {
 	}
 
 	var output job.Dir
+	var packages []job.Package
 	if status == job.StateFailed || status == job.StateFinished {
-		result, err := p.c.GetJobResult(j.ProviderJobID)
+		var result hy.JobResultResponse
+		done := p.trace(ctx, "hybrik-get-job-result", &err)
+		result, err = p.c.GetJobResult(j.ProviderJobID)
+		done()
 		if err != nil {
 			return &Status{}, err
 		}
 
 		output = job.Dir{}
 		for _, task := range result.Tasks {
+			if renditionTask(j, task) {
+				continue
+			}
+			if pkg, ok := packageFrom(j, task); ok {
+				output.File = append(output.File, job.File{Name: pkg.Manifest, Container: pkg.Protocol})
+				packages = append(packages, pkg)
+				continue
+			}
 			files, found, err := filesFrom(task)
 			if err != nil {
 				return &Status{}, err
@@ -206,6 +250,7 @@ func (p *driver) Status(_ context.Context, j *Job) (*Status, error) {
 		Progress:      float64(ji.Progress),
 		State:         status,
 		Output:        output,
+		Packages:      packages,
 	}, nil
 }
 
@@ -231,7 +276,7 @@ func features0(j *Job, dst interface{}) bool {
 	// it's always looking at the segmented rendering
 	// features even for the old dolby vision stuff
 	v, has := j.Features["segmentedRendering"]
-	if !has || j.Input.Provider() == "http" {
+	if !has || j.Input.Provider() == storage.HTTP {
 		// TODO(as): this check for http is a direct copy from the old
 		// version, but is http the only thing that doesn't support
 		// segmented rendering? what about https?
@@ -242,7 +287,8 @@ func features0(j *Job, dst interface{}) bool {
 	return err == nil
 }
 
-func (p *driver) Cancel(_ context.Context, id string) error {
+func (p *driver) Cancel(ctx context.Context, id string) (err error) {
+	defer p.trace(ctx, "hybrik-stop-job", &err)()
 	return p.c.StopJob(id)
 }
 
@@ -260,7 +306,7 @@ func (p *driver) Capabilities() provider.Capabilities {
 	// we can support quite a bit more format wise, but unsure of schema so limiting to known supported video-transcoding-api formats for now...
 	return provider.Capabilities{
 		InputFormats:  []string{"prores", "h264", "h265"},
-		OutputFormats: []string{"mp4", "hls", "webm", "mov"},
-		Destinations:  []string{storageProviderS3.string(), storageProviderGCS.string()},
+		OutputFormats: []string{"mp4", "hls", "dash", "cmaf", "webm", "mov"},
+		Destinations:  destinations,
 	}
 }
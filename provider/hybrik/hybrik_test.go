@@ -1,12 +1,17 @@
 package hybrik
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
 
 	hy "github.com/cbsinteractive/hybrik-sdk-go"
 	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
 	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -98,7 +103,7 @@ func TestPreset(t *testing.T) {
 				},
 				LocationTargetPayload: hy.LocationTargetPayload{
 					Location: hy.TranscodeLocation{
-						StorageProvider: storageProviderGCS.string(),
+						StorageProvider: storage.GCS,
 						Path:            "gs://some_bucket/encodes",
 						Access:          &hy.StorageAccess{CredentialsKey: "some_key", MaxCrossRegionMB: -1},
 					},
@@ -355,7 +360,7 @@ func TestPresetConversion(t *testing.T) {
 								LocationTargetPayload: hy.LocationTargetPayload{
 									Location: hy.TranscodeLocation{
 										Path:            "s3://some-dest/path/jobID",
-										StorageProvider: storageProviderS3.string(),
+										StorageProvider: storage.S3,
 									},
 									Targets: []hy.TranscodeTarget{{
 										Audio: []hy.AudioTarget{{
@@ -422,7 +427,7 @@ func TestPresetConversion(t *testing.T) {
 								LocationTargetPayload: hy.LocationTargetPayload{
 									Location: hy.TranscodeLocation{
 										Path:            "s3://some-dest/path/jobID",
-										StorageProvider: storageProviderS3.string(),
+										StorageProvider: storage.S3,
 									},
 									Targets: []hy.TranscodeTarget{{
 										Audio: []hy.AudioTarget{{
@@ -961,3 +966,169 @@ func TestTranscodeJobFields(t *testing.T) {
 func intToPtr(i int) *int {
 	return &i
 }
+
+func TestDryRun(t *testing.T) {
+	p := &driver{config: &config.Hybrik{Destination: "s3://some-dest/path", PresetPath: "some_preset_path"}}
+	j := testjob
+	j.Output.File = []job.File{defaultPreset}
+	data, err := p.DryRun(context.Background(), &j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var have hy.CreateJob
+	if err := json.Unmarshal(data, &have); err != nil {
+		t.Fatalf("bad json: %v\n%s", err, data)
+	}
+	if have.Name == "" || len(have.Payload.Elements) < 2 {
+		t.Fatalf("bad job: %s", data)
+	}
+}
+
+func TestPackage(t *testing.T) {
+	p := &driver{config: &config.Hybrik{}, segmentDuration: 6}
+	j := testjob
+	j.Output = job.Dir{Path: "s3://out", File: []job.File{
+		{Name: "1080p.m3u8", Container: "hls", Video: job.Video{Codec: "h264", Width: 1920, Height: 1080}},
+		{Name: "en", Container: "hls", Audio: job.Audio{Codec: "aac", Bitrate: 128000}},
+		{Name: "720p", Container: "cmaf", Video: job.Video{Codec: "h264", Width: 1280, Height: 720}},
+		{Name: "file.mp4", Container: "mp4", Video: job.Video{Codec: "h264"}},
+	}}
+	jr, err := p.jobRequest(&j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elems := jr.Payload.Elements
+	var uids []string
+	for _, e := range elems {
+		uids = append(uids, e.UID)
+	}
+	want := []string{SourceUID, "transcode_task_0", "transcode_task_1", "transcode_task_2", "transcode_task_3",
+		"hls_packager", "cmaf_hls_packager", "cmaf_dash_packager"}
+	if diff := cmp.Diff(want, uids); diff != "" {
+		t.Fatalf("elements (-want +have):\n%s", diff)
+	}
+	for _, e := range elems[5:] {
+		if !hasOutputs(hy.TaskResult{Kind: "Package", UID: e.UID}) {
+			t.Errorf("%s: outputs won't be found", e.UID)
+		}
+	}
+
+	hls := elems[5].Payload.(packagePayload).PackagePayload
+	wantHLS := hy.PackagePayload{
+		Location:           hy.TranscodeLocation{StorageProvider: "s3", Path: "s3://out/jobID/hls"},
+		FilePattern:        "master.m3u8",
+		Kind:               "hls",
+		SegmentationMode:   "segmented_ts",
+		SegmentDurationSec: 6,
+	}
+	if diff := cmp.Diff(wantHLS, hls); diff != "" {
+		t.Fatalf("hls packager (-want +have):\n%s", diff)
+	}
+	dash := elems[7].Payload.(packagePayload).PackagePayload
+	if dash.Kind != "dash" || dash.FilePattern != "master.mpd" || dash.Location.Path != "s3://out/jobID/cmaf" || dash.DASH.SegmentationMode != "fmp4" {
+		t.Fatalf("bad dash packager: %+v", dash)
+	}
+
+	wantConn := []hy.Connection{
+		{From: []hy.ConnectionFrom{{Element: "transcode_task_0"}, {Element: "transcode_task_1"}}, To: hy.ConnectionTo{Success: []hy.ToSuccess{{Element: "hls_packager"}}}},
+		{From: []hy.ConnectionFrom{{Element: "transcode_task_2"}}, To: hy.ConnectionTo{Success: []hy.ToSuccess{{Element: "cmaf_hls_packager"}}}},
+		{From: []hy.ConnectionFrom{{Element: "transcode_task_2"}}, To: hy.ConnectionTo{Success: []hy.ToSuccess{{Element: "cmaf_dash_packager"}}}},
+	}
+	if diff := cmp.Diff(wantConn, jr.Payload.Connections[1:]); diff != "" {
+		t.Fatalf("connections (-want +have):\n%s", diff)
+	}
+
+	targets := func(i int) []streamTarget {
+		return elems[i].Payload.(hy.TranscodePayload).Targets.([]streamTarget)
+	}
+	if v := targets(1)[0]; v.FilePattern != "1080p.ts" || v.Container.Kind != "mpegts" ||
+		v.Video.ExactKeyFrame != 6 || !reflect.DeepEqual(v.LayerAffinities, []string{"audio"}) {
+		t.Fatalf("bad video rendition: %+v", v)
+	}
+	if a := targets(2)[0]; a.FilePattern != "en.ts" || len(a.Audio) != 1 || a.Audio[0].TrackGroupID != "audio" {
+		t.Fatalf("bad audio rendition: %+v", a)
+	}
+	if c := targets(3)[0]; c.FilePattern != "720p.mp4" || c.Container.Kind != "mp4" || c.LayerAffinities != nil {
+		t.Fatalf("bad cmaf rendition: %+v", c)
+	}
+	if loc := elems[3].Payload.(hy.TranscodePayload).Location.Path; loc != "s3://out/jobID/cmaf" {
+		t.Fatalf("cmaf rendition location: %q", loc)
+	}
+	if _, ok := elems[4].Payload.(hy.TranscodePayload).Targets.([]hy.TranscodeTarget); !ok {
+		t.Fatalf("progressive output is a rendition")
+	}
+}
+
+func TestPackageSegmentedRendering(t *testing.T) {
+	p := &driver{config: &config.Hybrik{}}
+	j := testjob
+	j.Streaming = job.Streaming{SegmentDuration: 4}
+	j.Output = job.Dir{Path: "s3://out", File: []job.File{{Name: "1080p", Container: "dash", Video: job.Video{Codec: "h264"}}}}
+
+	j.Features = job.Features{"segmentedRendering": SegmentedRendering{Duration: 30}}
+	if _, err := p.jobRequest(&j); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("have %v, want %v", err, ErrUnsupported)
+	}
+
+	j.Features = job.Features{"segmentedRendering": SegmentedRendering{Duration: 40}}
+	jr, err := p.jobRequest(&j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := jr.Payload.Elements[1].Payload.(hy.TranscodePayload)
+	if tp.SourcePipeline.SegmentedRendering.Duration != 40 {
+		t.Fatalf("segmented rendering: %+v", tp.SourcePipeline.SegmentedRendering)
+	}
+	if kind := tp.Targets.([]streamTarget)[0].Container.Kind; kind != "mpegts" {
+		t.Fatalf("segmented rendition container: %q", kind)
+	}
+	if mode := lastPackage(t, *jr).SegmentationMode; mode != "fmp4" {
+		t.Fatalf("dash segmentation: %q", mode)
+	}
+}
+
+func lastPackage(t *testing.T, j hy.CreateJob) packagePayload {
+	t.Helper()
+	p := j.Payload.Elements
+	return p[len(p)-1].Payload.(packagePayload)
+}
+
+func TestPackageEncryption(t *testing.T) {
+	const key = "00112233445566778899aabbccddeeff"
+	p := &driver{config: &config.Hybrik{}}
+	j := testjob
+	j.Output = job.Dir{Path: "s3://out", File: []job.File{{Name: "1080p", Container: "hls", Video: job.Video{Codec: "h264"}}}}
+
+	j.Streaming.Encryption = &job.Encryption{Method: "aes-128", Key: key, IV: key, KeyURL: "https://keys/k"}
+	jr, err := p.jobRequest(&j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &packageEncryption{Enabled: true, Schema: "aes-128-cbc", Key: key, IV: key, KeyURI: "https://keys/k"}
+	if diff := cmp.Diff(want, lastPackage(t, *jr).Encryption); diff != "" {
+		t.Fatalf("encryption (-want +have):\n%s", diff)
+	}
+	data, _ := json.Marshal(lastPackage(t, *jr))
+	if !strings.Contains(string(data), `"encryption":{"enabled":true,"schema":"aes-128-cbc"`) || !strings.Contains(string(data), `"kind":"hls"`) {
+		t.Fatalf("bad payload: %s", data)
+	}
+
+	j.Output.File[0].Container = "dash"
+	j.Streaming.Encryption = &job.Encryption{Method: "cenc", Key: key, KeyID: key}
+	if jr, err = p.jobRequest(&j); err != nil {
+		t.Fatal(err)
+	}
+	if e := lastPackage(t, *jr).Encryption; e.Schema != "mpeg-cenc" || e.KeyID != key {
+		t.Fatalf("bad cenc encryption: %+v", e)
+	}
+
+	for name, e := range map[string]*job.Encryption{
+		"AES128DASH": {Method: "aes-128", Key: key},
+		"SPEKE":      {Method: "cenc", KeyProvider: &job.KeyProvider{URL: "https://keys/speke"}},
+	} {
+		j.Streaming.Encryption = e
+		if _, err := p.jobRequest(&j); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s: have %v, want %v", name, err, ErrUnsupported)
+		}
+	}
+}
@@ -135,3 +135,40 @@ func TestFiles(t *testing.T) {
 		})
 	}
 }
+
+func TestPackageFrom(t *testing.T) {
+	var task hy.TaskResult
+	data, err := ioutil.ReadFile("testdata/task_status_package.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &task); err != nil {
+		t.Fatal(err)
+	}
+	j := &job.Job{Output: job.Dir{File: []job.File{{Name: "blackmonday_360", Container: "hls"}}}}
+	have, ok := packageFrom(j, task)
+	if !ok {
+		t.Fatal("no package")
+	}
+	dir := "s3://vtg-tsymborski-test-bucket/encodes/blackmonday/hls/"
+	rendition := func(name string) job.Rendition {
+		return job.Rendition{Name: name, Playlist: dir + name + ".m3u8"}
+	}
+	want := job.Package{Protocol: "hls", Manifest: dir + "master.m3u8", Renditions: []job.Rendition{
+		rendition("blackmonday_360_audio"),
+		rendition("blackmonday_360_video"),
+		rendition("blackmonday_540_video"),
+		rendition("blackmonday_540_audio"),
+	}}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Fatalf("package (-want +have):\n%s", diff)
+	}
+
+	j.Streaming.Manifest = "index"
+	if _, ok := packageFrom(j, task); ok {
+		t.Fatal("found a package without its manifest")
+	}
+	if !renditionTask(j, hy.TaskResult{Kind: "Transcode", UID: "transcode_task_0"}) || renditionTask(j, task) {
+		t.Fatal("bad rendition tasks")
+	}
+}
@@ -0,0 +1,293 @@
+package hybrik
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	hy "github.com/cbsinteractive/hybrik-sdk-go"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+)
+
+const (
+	segmentedTS   = "segmented_ts"
+	segmentedFMP4 = "fmp4"
+)
+
+// streamTarget is the transcode target of a rendition in a streaming
+// package. The sdk's target can't put audio only renditions in a group,
+// or point video only renditions at it.
+type streamTarget struct {
+	hy.TranscodeTarget
+	LayerAffinities []string      `json:"layer_affinities,omitempty"`
+	Audio           []streamAudio `json:"audio,omitempty"`
+}
+
+type streamAudio struct {
+	hy.AudioTarget
+	TrackGroupID string `json:"track_group_id,omitempty"`
+}
+
+// packagePayload is a packager's payload, with the encryption the sdk's
+// payload lacks
+type packagePayload struct {
+	hy.PackagePayload
+	Encryption *packageEncryption `json:"encryption,omitempty"`
+}
+
+type packageEncryption struct {
+	Enabled bool   `json:"enabled"`
+	Schema  string `json:"schema"`
+	Key     string `json:"key,omitempty"`
+	KeyID   string `json:"key_id,omitempty"`
+	IV      string `json:"iv,omitempty"`
+	KeyURI  string `json:"key_uri,omitempty"`
+}
+
+// encryption schemas of the packager
+var schemas = map[string]string{
+	job.EncryptionAES128:    "aes-128-cbc",
+	job.EncryptionSampleAES: "sample-aes",
+	job.EncryptionCENC:      "mpeg-cenc",
+}
+
+func transcodeUID(i int) string {
+	return fmt.Sprintf("transcode_task_%d", i)
+}
+
+func (p *driver) segments(j *Job) int {
+	return int(j.Streaming.Duration(p.segmentDuration))
+}
+
+// validateStreaming rejects packages Hybrik can't make. Segmented
+// rendering splits the source on its own boundaries, so they have to
+// fall on segment boundaries for the packager to cut them cleanly.
+func (p *driver) validateStreaming(j *Job) error {
+	n := 0
+	for _, f := range j.Output.File {
+		if f.Streamed() {
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	if s := strings.ToLower(j.Streaming.Segments); s != "" && s != job.SegmentsTS && s != job.SegmentsFMP4 {
+		return fmt.Errorf("%w: hls segments: %q", ErrUnsupported, j.Streaming.Segments)
+	}
+	if countDolbyVision(&j.Output) > 0 {
+		return fmt.Errorf("%w: dolby vision packages", ErrUnsupported)
+	}
+	if err := validateEncryption(j); err != nil {
+		return err
+	}
+	if sr := features(j); sr != nil && sr.Duration%p.segments(j) != 0 {
+		return fmt.Errorf("%w: segmented rendering duration %ds is not a multiple of the %ds segments",
+			ErrUnsupported, sr.Duration, p.segments(j))
+	}
+	return nil
+}
+
+// validateEncryption rejects encryption the packager can't apply. It
+// only encrypts with static keys, AES-128 only in transport streams and
+// CENC only in fmp4.
+func validateEncryption(j *Job) error {
+	e := j.Streaming.Encryption
+	if e == nil {
+		return nil
+	}
+	if err := e.Check(); err != nil {
+		return err
+	}
+	if !e.Static() {
+		return fmt.Errorf("%w: speke key providers", ErrUnsupported)
+	}
+	for _, f := range j.Output.File {
+		ts := f.Protocol() == job.ContainerHLS && !j.Streaming.FMP4()
+		switch {
+		case !f.Streamed():
+		case ts && e.Is(job.EncryptionCENC):
+			return fmt.Errorf("%w: cenc of transport streams", ErrUnsupported)
+		case !ts && e.Is(job.EncryptionAES128):
+			return fmt.Errorf("%s: %w: aes-128 of fragmented mp4", f.Protocol(), ErrUnsupported)
+		}
+	}
+	return nil
+}
+
+// rendition returns the intermediate file the packager segments f from,
+// in the directory of its package. Segmented rendering only makes
+// transport streams, which the packager remuxes when it needs fmp4.
+func rendition(j *Job, f job.File) (job.File, string) {
+	kind := "mp4"
+	if features(j) != nil || f.Protocol() == job.ContainerHLS && !j.Streaming.FMP4() {
+		kind = "mpegts"
+	}
+	ext := map[string]string{"mp4": ".mp4", "mpegts": ".ts"}[kind]
+	name := strings.TrimSuffix(path.Base(f.Name), path.Ext(f.Name))
+	f.Name = path.Join(f.Protocol(), name+ext)
+	return f, kind
+}
+
+// audioGroup returns the rendition group of audio only HLS renditions in
+// protocol's package, or nothing if it has none
+func audioGroup(j *Job, protocol string) string {
+	if protocol == job.ContainerDASH {
+		return ""
+	}
+	for _, f := range j.Output.File {
+		if f.Protocol() == protocol && !f.Video.On() && f.Audio.Codec != "" {
+			return j.Streaming.AudioGroupName()
+		}
+	}
+	return ""
+}
+
+// streamTargetFrom groups target, the rendition for f, with the other
+// renditions in its package and aligns its keyframes on the segments
+func (p *driver) streamTargetFrom(j *Job, f job.File, target hy.TranscodeTarget) streamTarget {
+	if target.Video != nil && f.Video.Gop.Size == 0 {
+		target.Video.ExactKeyFrame = p.segments(j)
+	}
+	t := streamTarget{TranscodeTarget: target}
+	group := audioGroup(j, f.Protocol())
+	for _, a := range target.Audio {
+		t.Audio = append(t.Audio, streamAudio{AudioTarget: a})
+	}
+	switch {
+	case group == "":
+	case target.Video == nil:
+		for i := range t.Audio {
+			t.Audio[i].TrackGroupID = group
+		}
+	case len(target.Audio) == 0:
+		t.LayerAffinities = []string{group}
+	}
+	return t
+}
+
+// packageElems returns the packagers of the job's streaming packages and
+// their connections from the transcodes of the renditions they package.
+// CMAF packages are an HLS and a DASH manifest over fmp4 segments.
+func (p *driver) packageElems(j *Job) (e []hy.Element, conn []hy.Connection) {
+	for _, protocol := range []string{job.ContainerHLS, job.ContainerDASH, job.ContainerCMAF} {
+		src := []hy.ConnectionFrom{}
+		for i, f := range j.Output.File {
+			if f.Protocol() == protocol {
+				src = append(src, hy.ConnectionFrom{Element: transcodeUID(i)})
+			}
+		}
+		if len(src) == 0 {
+			continue
+		}
+		kinds := []string{protocol}
+		if protocol == job.ContainerCMAF {
+			kinds = []string{job.ContainerHLS, job.ContainerDASH}
+		}
+		for _, kind := range kinds {
+			pkg := p.packager(j, protocol, kind)
+			e = append(e, pkg)
+			conn = append(conn, hy.Connection{
+				From: src,
+				To:   hy.ConnectionTo{Success: []hy.ToSuccess{{Element: pkg.UID}}},
+			})
+		}
+	}
+	return e, conn
+}
+
+func (p *driver) packager(j *Job, protocol, kind string) hy.Element {
+	ext := map[string]string{job.ContainerHLS: ".m3u8", job.ContainerDASH: ".mpd"}[kind]
+	m := j.Abs(job.File{Name: path.Join(protocol, j.Streaming.ManifestName()+ext)})
+
+	uid := kind + "_packager"
+	if kind != protocol {
+		uid = protocol + "_" + uid
+	}
+	seg := p.segments(j)
+	payload := hy.PackagePayload{
+		Location:           p.location(m, p.auth(j).Write),
+		FilePattern:        m.Base(),
+		Kind:               kind,
+		SegmentationMode:   segmentedFMP4,
+		SegmentDurationSec: seg,
+	}
+	switch kind {
+	case job.ContainerHLS:
+		if protocol == job.ContainerHLS && !j.Streaming.FMP4() {
+			payload.SegmentationMode = segmentedTS
+		}
+	case job.ContainerDASH:
+		payload.DASH = &hy.DASHPackagingSettings{
+			SegmentationMode:   segmentedFMP4,
+			SegmentDurationSec: strconv.Itoa(seg),
+		}
+	}
+	return hy.Element{
+		UID:  uid,
+		Kind: "package",
+		Task: &hy.ElementTaskOptions{
+			Name: fmt.Sprintf("Package - %s", path.Join(protocol, m.Base())),
+			Tags: tag(j, job.TagTranscodeDefault),
+		},
+		Payload: packagePayload{PackagePayload: payload, Encryption: encryption(j)},
+	}
+}
+
+func encryption(j *Job) *packageEncryption {
+	e := j.Streaming.Encryption
+	if e == nil {
+		return nil
+	}
+	return &packageEncryption{
+		Enabled: true,
+		Schema:  schemas[strings.ToLower(e.Method)],
+		Key:     e.Key,
+		KeyID:   e.KeyID,
+		IV:      e.IV,
+		KeyURI:  e.KeyURL,
+	}
+}
+
+// renditionTask reports whether the task transcodes a rendition the
+// packagers consume, whose files aren't outputs of the job
+func renditionTask(j *Job, task hy.TaskResult) bool {
+	for i, f := range j.Output.File {
+		if f.Streamed() && strings.HasSuffix(task.UID, transcodeUID(i)) {
+			return true
+		}
+	}
+	return false
+}
+
+// packageFrom returns the package a packager task wrote. Its manifest is
+// the only output file; the other playlists are its renditions', split
+// into video and audio, except for the I-frame playlists.
+func packageFrom(j *Job, task hy.TaskResult) (pkg job.Package, ok bool) {
+	if task.Kind != "Package" || !hasOutputs(task) {
+		return pkg, false
+	}
+	manifest := j.Streaming.ManifestName()
+	for _, d := range task.Documents {
+		for _, a := range d.ResultPayload.Payload.AssetVersions {
+			dir := job.File{Name: a.Location.Path}
+			for _, c := range a.AssetComponents {
+				ext := path.Ext(c.Name)
+				name := strings.TrimSuffix(path.Base(c.Name), ext)
+				protocol := map[string]string{".m3u8": job.ContainerHLS, ".mpd": job.ContainerDASH}[ext]
+				switch {
+				case protocol == "":
+				case name == manifest:
+					pkg.Protocol, pkg.Manifest = protocol, dir.Join(c.Name).Name
+				case protocol == job.ContainerHLS && !strings.HasSuffix(name, "-iframes"):
+					pkg.Renditions = append(pkg.Renditions, job.Rendition{
+						Name:     name,
+						Playlist: dir.Join(c.Name).Name,
+					})
+				}
+			}
+		}
+	}
+	return pkg, pkg.Manifest != ""
+}
@@ -3,34 +3,17 @@ package hybrik
 import (
 	hy "github.com/cbsinteractive/hybrik-sdk-go"
 	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
 )
 
-type storageProvider string
-
-func (p storageProvider) supportsSegmentedRendering() bool { return p != storageProviderHTTP }
-func (p storageProvider) string() string                   { return string(p) }
-
-const (
-	storageProviderUnrecognized storageProvider = "unrecognized"
-	storageProviderS3           storageProvider = "s3"
-	storageProviderGCS          storageProvider = "gs"
-	storageProviderHTTP         storageProvider = "http"
+// sources and destinations are the storage Hybrik reads and writes
+var (
+	sources      = []string{storage.S3, storage.GCS, storage.HTTP, storage.HTTPS}
+	destinations = []string{storage.S3, storage.GCS}
 )
 
-var StorageProviders = []string{"s3", "gcs", "http", "https"}
-
-func Supported(f job.File) bool {
-	p := f.Provider()
-	for _, sp := range StorageProviders {
-		if p == sp {
-			return true
-		}
-	}
-	return false
-}
-
 func storageBugfix(provider string, sa *hy.StorageAccess) *hy.StorageAccess {
-	if provider == "gs" {
+	if provider == storage.GCS {
 		// Hybrik has a bug where they identify multi-region GCS -> region GCP
 		// transfers as triggering egress costs, so we remove their validation for
 		// GCS sources
@@ -41,7 +24,7 @@ func storageBugfix(provider string, sa *hy.StorageAccess) *hy.StorageAccess {
 
 func (p *driver) access(f *job.File, creds string) *hy.StorageAccess {
 	if creds == "" {
-		if f.Provider() != "gs" {
+		if f.Provider() != storage.GCS {
 			return nil
 		}
 		creds = p.config.GCPCredentialsKey
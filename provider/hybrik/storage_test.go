@@ -1,9 +1,11 @@
 package hybrik
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
 )
 
 func TestStorage(t *testing.T) {
@@ -12,9 +14,10 @@ func TestStorage(t *testing.T) {
 	}{
 		{"s3", "s3://some-bucket/some-path", "s3"},
 		{"gs", "gs://some-bucket/some-path", "gs"},
+		{"gcs", "gcs://some-bucket/some-path", "gs"},
 		{"http", "http://some-domain.com/some-path", "http"},
 		{"https", "https://some-domain.com/some-path", "https"},
-		// {"unsupported", "fakescheme://some-bucket/some-path", "", `the scheme "fakescheme" is unsupported`},
+		{"unsupported", "fakescheme://some-bucket/some-path", ""},
 		{"bad", "%fsdf://some-bucket/some-path", ""},
 	}
 
@@ -28,6 +31,18 @@ func TestStorage(t *testing.T) {
 	}
 }
 
+func TestStorageUnsupported(t *testing.T) {
+	p := &driver{}
+	for name, j := range map[string]*job.Job{
+		"input":  {Input: job.File{Name: "ftp://host/in.mov"}},
+		"output": {Input: job.File{Name: "s3://bucket/in.mov"}, Output: job.Dir{Path: "https://host/out"}},
+	} {
+		if _, err := p.create(j); !errors.Is(err, storage.ErrUnsupported) {
+			t.Errorf("%s: have %v, want %v", name, err, storage.ErrUnsupported)
+		}
+	}
+}
+
 func assertWantErr(err error, wantErr, caller string, t *testing.T) bool {
 	if err != nil {
 		if wantErr != err.Error() {
@@ -5,6 +5,7 @@ import (
 
 	hy "github.com/cbsinteractive/hybrik-sdk-go"
 	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
 )
 
 const SourceUID = "source_file"
@@ -16,11 +17,19 @@ func (p *driver) auth(j *Job) (a struct{ Read, Write string }) {
 }
 
 func (p *driver) validate(j *Job) error {
+	if _, err := storage.Check(j.Input.Name, sources...); err != nil {
+		return fmt.Errorf("input: %w", err)
+	}
+	if j.Output.Path != "" {
+		if _, err := storage.Check(j.Output.Path, destinations...); err != nil {
+			return fmt.Errorf("output: %w", err)
+		}
+	}
 	n := countDolbyVision(&j.Output)
 	if n > 0 && n != j.Output.Len() {
 		return ErrMixedPresets
 	}
-	return nil
+	return p.validateStreaming(j)
 }
 
 const LegacyDolbyVision = true
@@ -62,11 +71,17 @@ func (p *driver) srcFrom(j *Job) hy.Element {
 
 func (p *driver) transcodeElems(j *Job) (e []hy.Element) {
 	for i, f := range j.Output.File {
+		kind := ""
+		if f.Streamed() {
+			f, kind = rendition(j, f)
+		} else {
+			kind = p.container(f) //TODO(as): validation
+		}
 		f = j.Abs(f)
 		target := hy.TranscodeTarget{
 			FilePattern:   f.Base(),
 			ExistingFiles: "replace",
-			Container:     hy.TranscodeContainer{Kind: p.container(f)}, //TODO(as): validation
+			Container:     hy.TranscodeContainer{Kind: kind},
 			NumPasses:     passes(f),
 			Video:         videoTarget(f.Video),
 			Audio:         audioTarget(f.Audio),
@@ -78,9 +93,13 @@ func (p *driver) transcodeElems(j *Job) (e []hy.Element) {
 		if j.Input.Type() == "mxf" {
 			applyMXF(&target, f)
 		}
+		var targets interface{} = []hy.TranscodeTarget{target}
+		if f.Streamed() {
+			targets = []streamTarget{p.streamTargetFrom(j, f, target)}
+		}
 		e = append(e, hy.Element{
 			Kind: "transcode",
-			UID:  fmt.Sprintf("transcode_task_%d", i),
+			UID:  transcodeUID(i),
 			Task: &hy.ElementTaskOptions{
 				Name: fmt.Sprintf("Transcode - %s", f.Base()),
 				Tags: tag(j, job.TagTranscodeDefault),
@@ -90,7 +109,7 @@ func (p *driver) transcodeElems(j *Job) (e []hy.Element) {
 				SourcePipeline: hy.TranscodeSourcePipeline{SegmentedRendering: features(j)},
 				LocationTargetPayload: hy.LocationTargetPayload{
 					Location: p.location(f, p.auth(j).Write),
-					Targets:  []hy.TranscodeTarget{target},
+					Targets:  targets,
 				},
 			},
 		})
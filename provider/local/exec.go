@@ -0,0 +1,37 @@
+package local
+
+import (
+	"io"
+	"os/exec"
+)
+
+// executor starts commands. The driver runs ffmpeg through it so tests
+// can check the arguments without an ffmpeg binary.
+type executor interface {
+	Start(name string, args []string, stdout, stderr io.Writer) (process, error)
+}
+
+// process is a started command
+type process interface {
+	Wait() error
+
+	// Kill stops the command and every process it started
+	Kill() error
+}
+
+// osExec runs commands as subprocesses, each in its own process group
+type osExec struct{}
+
+func (osExec) Start(name string, args []string, stdout, stderr io.Writer) (process, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	group(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return subprocess{cmd}, nil
+}
+
+type subprocess struct{ *exec.Cmd }
+
+func (s subprocess) Kill() error { return kill(s.Cmd) }
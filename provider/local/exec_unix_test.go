@@ -0,0 +1,40 @@
+//go:build !windows
+// +build !windows
+
+package local
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestKillGroup(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip(err)
+	}
+	// the child sleep keeps stdout open unless it's killed too
+	var out bytes.Buffer
+	p, err := osExec{}.Start("sh", []string{"-c", "sleep 30 & echo started; wait"}, &out, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := p.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- p.Wait() }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("killed process exited cleanly")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("process group survived the kill")
+	}
+	if err := p.Kill(); err != nil {
+		t.Fatalf("killing an exited group: %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+package local
+
+import "os/exec"
+
+func group(*exec.Cmd) {}
+
+// kill stops only the command itself, windows has no process groups
+func kill(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
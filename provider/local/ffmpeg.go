@@ -0,0 +1,233 @@
+package local
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cbsinteractive/pkg/timecode"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+)
+
+// step is one ffmpeg run. Two pass outputs take two steps, and only the
+// second writes dst.
+type step struct {
+	args []string
+
+	// total is the duration of the output when it's known beforehand,
+	// otherwise it's read from ffmpeg as it runs
+	total time.Duration
+}
+
+var videoCodecs = map[string]string{
+	"h264":   "libx264",
+	"avc":    "libx264",
+	"h265":   "libx265",
+	"hevc":   "libx265",
+	"vp8":    "libvpx",
+	"vp9":    "libvpx-vp9",
+	"prores": "prores_ks",
+}
+
+var audioCodecs = map[string]string{
+	"aac":    "aac",
+	"mp3":    "libmp3lame",
+	"opus":   "libopus",
+	"vorbis": "libvorbis",
+	"ac3":    "ac3",
+	"eac3":   "eac3",
+	"flac":   "flac",
+}
+
+// formats maps containers to ffmpeg's muxer names where they differ
+var formats = map[string]string{
+	"ts":   "mpegts",
+	"m2ts": "mpegts",
+	"mkv":  "matroska",
+	"m4a":  "mp4",
+}
+
+// ffmpeg returns the steps transcoding the input file in to dst as
+// described by f. Two pass logs are written under passlog.
+func ffmpeg(in, dst string, splice timecode.Splice, f job.File, passlog string) ([]step, error) {
+	v, a := f.Video, f.Audio
+	if !v.On() && !a.On() {
+		return nil, fmt.Errorf("%w: output without audio or video", ErrUnsupported)
+	}
+	args := []string{"-hide_banner", "-nostdin", "-y", "-progress", "pipe:1", "-nostats"}
+
+	var total time.Duration
+	for _, r := range splice {
+		r = r.Canon()
+		total += r.Size()
+	}
+	if len(splice) == 1 {
+		r := splice[0].Canon()
+		args = append(args, "-ss", seconds(r[0]), "-to", seconds(r[1]))
+	}
+	args = append(args, "-i", in)
+
+	vf := filters(v)
+	if len(splice) > 1 {
+		args = append(args, graph(splice, v.On(), a.On(), vf)...)
+	} else if len(vf) > 0 && v.On() {
+		args = append(args, "-vf", strings.Join(vf, ","))
+	}
+
+	var pass func(n int) []string
+	if !v.On() {
+		args = append(args, "-vn")
+	} else {
+		codec, ok := videoCodecs[strings.ToLower(v.Codec)]
+		if !ok {
+			return nil, fmt.Errorf("%w: video codec %q", ErrUnsupported, v.Codec)
+		}
+		args = append(args, "-c:v", codec)
+		if v.Profile != "" {
+			args = append(args, "-profile:v", strings.ToLower(v.Profile))
+		}
+		if v.Level != "" {
+			args = append(args, "-level:v", v.Level)
+		}
+		if v.FPS > 0 {
+			args = append(args, "-r", strconv.FormatFloat(v.FPS, 'f', -1, 64))
+		}
+		if b := v.Bitrate; b.BPS > 0 {
+			bps := strconv.Itoa(b.BPS)
+			args = append(args, "-b:v", bps)
+			if strings.EqualFold(b.Control, "CBR") {
+				args = append(args, "-minrate", bps, "-maxrate", bps, "-bufsize", bps)
+			}
+		}
+		if g := v.Gop; g.Size > 0 {
+			if g.Seconds() {
+				args = append(args, "-force_key_frames", "expr:gte(t,n_forced*"+seconds(g.Size)+")")
+			} else {
+				args = append(args, "-g", strconv.Itoa(int(g.Size)))
+			}
+		}
+		if v.Bitrate.TwoPass {
+			pass = func(n int) []string {
+				if codec == "libx265" {
+					return []string{"-x265-params", "pass=" + strconv.Itoa(n) + ":stats=" + passlog + ".log"}
+				}
+				return []string{"-pass", strconv.Itoa(n), "-passlogfile", passlog}
+			}
+		}
+	}
+
+	if !a.On() {
+		args = append(args, "-an")
+	} else {
+		codec, ok := audioCodecs[strings.ToLower(a.Codec)]
+		if !ok {
+			return nil, fmt.Errorf("%w: audio codec %q", ErrUnsupported, a.Codec)
+		}
+		args = append(args, "-c:a", codec)
+		if a.Bitrate > 0 {
+			args = append(args, "-b:a", strconv.Itoa(a.Bitrate))
+		}
+		if f.Downmix != nil && len(f.Downmix.Dst) > 0 {
+			args = append(args, "-ac", strconv.Itoa(len(f.Downmix.Dst)))
+		}
+	}
+
+	tail := []string{dst}
+	if format, ok := formats[f.Container]; ok {
+		tail = []string{"-f", format, dst}
+	} else if f.Container != "" {
+		tail = []string{"-f", f.Container, dst}
+	}
+	if pass == nil {
+		return []step{{args: cat(args, tail), total: total}}, nil
+	}
+	return []step{
+		{args: cat(args, pass(1), []string{"-f", "null", os.DevNull}), total: total},
+		{args: cat(args, pass(2), tail), total: total},
+	}, nil
+}
+
+func cat(args ...[]string) (all []string) {
+	for _, a := range args {
+		all = append(all, a...)
+	}
+	return all
+}
+
+// filters returns the video filters for the scantype, crop and size
+func filters(v job.Video) (vf []string) {
+	if v.Scantype == job.ScanInterlaced {
+		vf = append(vf, "yadif")
+	}
+	if c := v.Crop; !c.Empty() {
+		vf = append(vf, fmt.Sprintf("crop=in_w-%d:in_h-%d:%d:%d", c.Left+c.Right, c.Top+c.Bottom, c.Left, c.Top))
+	}
+	if v.Width > 0 || v.Height > 0 {
+		vf = append(vf, fmt.Sprintf("scale=%d:%d", even(v.Width), even(v.Height)))
+	}
+	return vf
+}
+
+// even keeps the aspect ratio for an unset dimension, rounded to an even
+// number of pixels
+func even(n int) int {
+	if n == 0 {
+		return -2
+	}
+	return n
+}
+
+// graph trims each range of the splice from the input and concatenates
+// them, then applies the video filters
+func graph(splice timecode.Splice, video, audio bool, vf []string) []string {
+	var g, concat []string
+	for i, r := range splice {
+		r = r.Canon()
+		trim := "start=" + seconds(r[0]) + ":end=" + seconds(r[1])
+		if video {
+			g = append(g, fmt.Sprintf("[0:v]trim=%s,setpts=PTS-STARTPTS[v%d]", trim, i))
+			concat = append(concat, fmt.Sprintf("[v%d]", i))
+		}
+		if audio {
+			g = append(g, fmt.Sprintf("[0:a]atrim=%s,asetpts=PTS-STARTPTS[a%d]", trim, i))
+			concat = append(concat, fmt.Sprintf("[a%d]", i))
+		}
+	}
+	v, a := 0, 0
+	var outs, maps []string
+	if video {
+		v = 1
+		label := "[v]"
+		if len(vf) > 0 {
+			label = "[vc]"
+		}
+		outs = append(outs, label)
+		maps = append(maps, "-map", "[v]")
+	}
+	if audio {
+		a = 1
+		outs = append(outs, "[a]")
+		maps = append(maps, "-map", "[a]")
+	}
+	g = append(g, fmt.Sprintf("%sconcat=n=%d:v=%d:a=%d%s", strings.Join(concat, ""), len(splice), v, a, strings.Join(outs, "")))
+	if video && len(vf) > 0 {
+		g = append(g, "[vc]"+strings.Join(vf, ",")+"[v]")
+	}
+	return append([]string{"-filter_complex", strings.Join(g, ";")}, maps...)
+}
+
+func seconds(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// path returns the local path of a file:// url, or of an absolute path
+func path(name string) (string, error) {
+	loc, err := storage.Check(name, storage.File)
+	if err != nil {
+		return "", err
+	}
+	return loc.Path(), nil
+}
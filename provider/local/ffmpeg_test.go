@@ -0,0 +1,134 @@
+package local
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cbsinteractive/pkg/timecode"
+	"github.com/cbsinteractive/pkg/video"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/google/go-cmp/cmp"
+)
+
+const head = "-hide_banner -nostdin -y -progress pipe:1 -nostats "
+
+func TestFFmpeg(t *testing.T) {
+	h264 := job.Video{Codec: "h264", Profile: "High", Level: "4.1", Width: 1280, FPS: 29.97, Bitrate: job.Bitrate{BPS: 3000000}}
+	for _, tt := range []struct {
+		name   string
+		splice timecode.Splice
+		f      job.File
+		want   []string
+		total  time.Duration
+	}{
+		{
+			name: "Video",
+			f: job.File{
+				Video: job.Video{
+					Codec: "h264", Profile: "High", Level: "4.1", Width: 1280, FPS: 29.97,
+					Bitrate: job.Bitrate{BPS: 3000000, Control: "CBR"}, Gop: job.Gop{Size: 60},
+					Scantype: job.ScanInterlaced, Crop: video.Crop{Left: 8, Right: 8, Top: 4},
+				},
+				Audio:   job.Audio{Codec: "aac", Bitrate: 128000},
+				Downmix: &job.Downmix{Dst: make([]job.AudioChannel, 2)},
+			},
+			want: []string{head + "-i in.mov -vf yadif,crop=in_w-16:in_h-4:8:4,scale=1280:-2 " +
+				"-c:v libx264 -profile:v high -level:v 4.1 -r 29.97 -b:v 3000000 -minrate 3000000 -maxrate 3000000 -bufsize 3000000 -g 60 " +
+				"-c:a aac -b:a 128000 -ac 2 out.mp4"},
+		},
+		{
+			name:   "Clip",
+			splice: timecode.Splice{{20, 10}},
+			f:      job.File{Container: "ts", Video: job.Video{Codec: "vp9", Height: 720, Gop: job.Gop{Unit: "seconds", Size: 2}}},
+			want:   []string{head + "-ss 10 -to 20 -i in.mov -vf scale=-2:720 -c:v libvpx-vp9 -force_key_frames expr:gte(t,n_forced*2) -an -f mpegts out.mp4"},
+			total:  10 * time.Second,
+		},
+		{
+			name:   "Splice",
+			splice: timecode.Splice{{0, 1.5}, {3, 4}},
+			f:      job.File{Video: job.Video{Codec: "h264", Height: 480}, Audio: job.Audio{Codec: "opus"}},
+			want: []string{head + "-i in.mov -filter_complex " +
+				"[0:v]trim=start=0:end=1.5,setpts=PTS-STARTPTS[v0];[0:a]atrim=start=0:end=1.5,asetpts=PTS-STARTPTS[a0];" +
+				"[0:v]trim=start=3:end=4,setpts=PTS-STARTPTS[v1];[0:a]atrim=start=3:end=4,asetpts=PTS-STARTPTS[a1];" +
+				"[v0][a0][v1][a1]concat=n=2:v=1:a=1[vc][a];[vc]scale=-2:480[v] " +
+				"-map [v] -map [a] -c:v libx264 -c:a libopus out.mp4"},
+			total: 2500 * time.Millisecond,
+		},
+		{
+			name:   "SpliceAudio",
+			splice: timecode.Splice{{0, 1}, {2, 3}},
+			f:      job.File{Audio: job.Audio{Codec: "mp3"}},
+			want: []string{head + "-i in.mov -filter_complex " +
+				"[0:a]atrim=start=0:end=1,asetpts=PTS-STARTPTS[a0];[0:a]atrim=start=2:end=3,asetpts=PTS-STARTPTS[a1];" +
+				"[a0][a1]concat=n=2:v=0:a=1[a] -map [a] -vn -c:a libmp3lame out.mp4"},
+			total: 2 * time.Second,
+		},
+		{
+			name: "TwoPass",
+			f:    job.File{Video: job.Video{Codec: "h264", Bitrate: job.Bitrate{BPS: 1000, TwoPass: true}}, Audio: job.Audio{Codec: "aac"}},
+			want: []string{
+				head + "-i in.mov -c:v libx264 -b:v 1000 -c:a aac -pass 1 -passlogfile /tmp/0 -f null " + os.DevNull,
+				head + "-i in.mov -c:v libx264 -b:v 1000 -c:a aac -pass 2 -passlogfile /tmp/0 out.mp4",
+			},
+		},
+		{
+			name: "TwoPassHEVC",
+			f:    job.File{Video: job.Video{Codec: "hevc", Bitrate: job.Bitrate{BPS: 1000, TwoPass: true}}},
+			want: []string{
+				head + "-i in.mov -c:v libx265 -b:v 1000 -an -x265-params pass=1:stats=/tmp/0.log -f null " + os.DevNull,
+				head + "-i in.mov -c:v libx265 -b:v 1000 -an -x265-params pass=2:stats=/tmp/0.log out.mp4",
+			},
+		},
+		{
+			name: "Profile",
+			f:    job.File{Container: "mp4", Video: h264},
+			want: []string{head + "-i in.mov -vf scale=1280:-2 -c:v libx264 -profile:v high -level:v 4.1 -r 29.97 -b:v 3000000 -an -f mp4 out.mp4"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := ffmpeg("in.mov", "out.mp4", tt.splice, tt.f, "/tmp/0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var have []string
+			for _, s := range steps {
+				have = append(have, strings.Join(s.args, " "))
+				if s.total != tt.total {
+					t.Errorf("total: have %v, want %v", s.total, tt.total)
+				}
+			}
+			if diff := cmp.Diff(tt.want, have); diff != "" {
+				t.Fatalf("args (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFFmpegUnsupported(t *testing.T) {
+	for name, f := range map[string]job.File{
+		"Empty": {},
+		"Video": {Video: job.Video{Codec: "mpeg1"}},
+		"Audio": {Audio: job.Audio{Codec: "dts"}},
+	} {
+		if _, err := ffmpeg("in", "out", nil, f, ""); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s: have %v, want %v", name, err, ErrUnsupported)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	for name, want := range map[string]string{
+		"file:///var/media/in.mov": "/var/media/in.mov",
+		"/var/media/in.mov":        "/var/media/in.mov",
+		"s3://bucket/in.mov":       "",
+	} {
+		have, err := path(name)
+		if have != want || (want == "") != errors.Is(err, storage.ErrUnsupported) {
+			t.Errorf("path(%q): have %q, %v, want %q", name, have, err, want)
+		}
+	}
+}
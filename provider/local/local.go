@@ -0,0 +1,344 @@
+package local
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/logging"
+	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/cbsinteractive/transcode-orchestrator/trace"
+	"github.com/zsiec/pkg/tracing"
+)
+
+// Name identifies the local provider by name
+const Name = "local"
+
+// retention is how long a finished job's status is kept
+const retention = 24 * time.Hour
+
+var ErrUnsupported = errors.New("unsupported")
+
+func init() {
+	err := provider.Register(Name, factory)
+	if err != nil {
+		logging.From(context.Background()).WithError(err).Error("registering local factory")
+	}
+}
+
+// jobs holds every job of the process. Drivers are built for each
+// request, so they share it. Nothing else knows about the jobs, so the
+// provider needs a single orchestrator instance, and a job asked about
+// after a restart is reported failed.
+var jobs = &registry{run: map[string]*run{}}
+
+type driver struct {
+	cfg    *config.Local
+	exec   executor
+	jobs   *registry
+	tmp    string
+	tracer tracing.Tracer
+}
+
+func (p *driver) trace(ctx context.Context, name string, err *error) func() {
+	_, x := trace.Begin(ctx, p.tracer, name)
+	return func() {
+		x.Close(*err)
+		logging.Done(ctx, name, *err)
+	}
+}
+
+// registry is the set of jobs run by the provider, by id
+type registry struct {
+	sync.Mutex
+	run map[string]*run
+}
+
+func (r *registry) add(id string, j *run) {
+	r.Lock()
+	defer r.Unlock()
+	for id, j := range r.run {
+		if j.expired() {
+			delete(r.run, id)
+		}
+	}
+	r.run[id] = j
+}
+
+func (r *registry) get(id string) *run {
+	r.Lock()
+	defer r.Unlock()
+	return r.run[id]
+}
+
+// run is a job's steps and how far along they are
+type run struct {
+	sync.Mutex
+	state    job.State
+	msg      string
+	step     int
+	steps    int
+	cur      *progress
+	proc     process
+	output   job.Dir
+	finished time.Time
+}
+
+func (r *run) expired() bool {
+	r.Lock()
+	defer r.Unlock()
+	return !r.finished.IsZero() && time.Since(r.finished) > retention
+}
+
+// end sets the terminal state of the run, unless it has one, and
+// returns the process running, if any
+func (r *run) end(s job.State, msg string) process {
+	r.Lock()
+	defer r.Unlock()
+	if !r.state.Terminal() {
+		r.state, r.msg, r.finished = s, msg, time.Now()
+	}
+	return r.proc
+}
+
+func (p *driver) Create(ctx context.Context, j *job.Job) (st *job.Status, err error) {
+	defer p.trace(ctx, "local-create-job", &err)()
+
+	id, err := newID()
+	if err != nil {
+		return nil, fmt.Errorf("generating job id: %w", err)
+	}
+	tmp := filepath.Join(p.tmp, "local-"+id)
+	steps, files, err := p.steps(j, tmp)
+	if err != nil {
+		return nil, fmt.Errorf("generating ffmpeg commands: %w", err)
+	}
+
+	r := &run{state: job.StateQueued, steps: len(steps), output: job.Dir{Path: p.location(*j, "")}}
+	p.jobs.add(id, r)
+	go p.execute(r, steps, files, tmp)
+
+	return &job.Status{
+		Provider:      Name,
+		ProviderJobID: id,
+		State:         job.StateQueued,
+	}, nil
+}
+
+// DryRun returns the ffmpeg commands Create would run, one per line.
+// The pass logs go under a placeholder directory.
+func (p *driver) DryRun(_ context.Context, j *job.Job) ([]byte, error) {
+	steps, _, err := p.steps(j, filepath.Join(p.tmp, "local-dryrun"))
+	if err != nil {
+		return nil, fmt.Errorf("generating ffmpeg commands: %w", err)
+	}
+	var b strings.Builder
+	for _, s := range steps {
+		b.WriteString(quote(p.cfg.FFmpegPath))
+		for _, a := range s.args {
+			b.WriteString(" " + quote(a))
+		}
+		b.WriteString("\n")
+	}
+	return []byte(b.String()), nil
+}
+
+// quote returns s single quoted for a posix shell, unless it's safe as is
+func quote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=+,") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// steps returns the ffmpeg runs for every output of j, and the outputs
+func (p *driver) steps(j *job.Job, tmp string) (steps []step, files []job.File, err error) {
+	in, err := path(j.Input.Name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("input: %w", err)
+	}
+	for i, f := range j.Output.File {
+		f.Name = p.location(*j, f.Name)
+		dst, err := path(f.Name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("output: %w", err)
+		}
+		s, err := ffmpeg(in, dst, j.Input.Splice, f, filepath.Join(tmp, strconv.Itoa(i)))
+		if err != nil {
+			return nil, nil, fmt.Errorf("output %q: %w", f.Name, err)
+		}
+		steps = append(steps, s...)
+		if f.Container == "" {
+			f.Container = f.Type()
+		}
+		files = append(files, job.File{Name: f.Name, Container: f.Container})
+	}
+	return steps, files, nil
+}
+
+// execute runs the steps one after another until one fails or the job
+// is canceled, then describes the outputs
+func (p *driver) execute(r *run, steps []step, files []job.File, tmp string) {
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		r.end(job.StateFailed, err.Error())
+		return
+	}
+	defer os.RemoveAll(tmp)
+	for _, f := range files {
+		dst, _ := path(f.Name)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			r.end(job.StateFailed, err.Error())
+			return
+		}
+	}
+
+	for i, s := range steps {
+		pr := &progress{total: s.total}
+		r.Lock()
+		if r.state.Terminal() {
+			r.Unlock()
+			return
+		}
+		proc, err := p.exec.Start(p.cfg.FFmpegPath, s.args, &lines{fn: pr.stdout}, &lines{fn: pr.stderr})
+		if err != nil {
+			r.Unlock()
+			r.end(job.StateFailed, fmt.Sprintf("starting ffmpeg: %v", err))
+			return
+		}
+		r.state, r.step, r.cur, r.proc = job.StateStarted, i, pr, proc
+		r.Unlock()
+
+		err = proc.Wait()
+		r.Lock()
+		r.proc = nil
+		r.Unlock()
+		if err != nil {
+			r.end(job.StateFailed, fmt.Sprintf("ffmpeg: %v: %s", err, pr.Tail()))
+			return
+		}
+	}
+
+	out := job.Dir{Path: r.output.Path}
+	for _, f := range files {
+		dst, _ := path(f.Name)
+		if fi, err := os.Stat(dst); err == nil {
+			f.Size = fi.Size()
+		}
+		out.Add(f)
+	}
+	r.Lock()
+	r.output = out
+	r.Unlock()
+	r.end(job.StateFinished, "")
+}
+
+func (p *driver) location(j job.Job, file string) string {
+	if j.Output.Path == "" {
+		j.Output.Path = p.cfg.Destination
+	}
+	return j.Location(file)
+}
+
+func (p *driver) Status(ctx context.Context, j *job.Job) (st *job.Status, err error) {
+	defer p.trace(ctx, "local-get-job", &err)()
+
+	r := p.jobs.get(j.ProviderJobID)
+	if r == nil {
+		// the process that ran the job is gone, as after a restart, so
+		// it will never finish. A job that had already ended keeps its
+		// stored state.
+		return &job.Status{
+			Provider:      Name,
+			ProviderJobID: j.ProviderJobID,
+			State:         job.StateFailed,
+			Msg:           "job was lost: the orchestrator restarted or another instance ran it",
+			Labels:        j.Labels,
+		}, nil
+	}
+	r.Lock()
+	defer r.Unlock()
+	st = &job.Status{
+		Provider:      Name,
+		ProviderJobID: j.ProviderJobID,
+		State:         r.state,
+		Msg:           r.msg,
+		Labels:        j.Labels,
+		Output:        r.output,
+		ProviderStatus: map[string]interface{}{
+			"step":  r.step + 1,
+			"steps": r.steps,
+		},
+	}
+	switch {
+	case r.state == job.StateFinished:
+		st.Progress = 100
+	case r.cur != nil && r.steps > 0:
+		st.Progress = 100 * (float64(r.step) + r.cur.Fraction()) / float64(r.steps)
+	}
+	return st, nil
+}
+
+// Cancel kills the running ffmpeg, and every process it started, and
+// skips the job's remaining steps
+func (p *driver) Cancel(ctx context.Context, id string) (err error) {
+	defer p.trace(ctx, "local-cancel-job", &err)()
+
+	r := p.jobs.get(id)
+	if r == nil {
+		return provider.JobNotFoundError{ID: id}
+	}
+	if proc := r.end(job.StateCanceled, ""); proc != nil {
+		return proc.Kill()
+	}
+	return nil
+}
+
+func (p *driver) Healthcheck() error {
+	if _, err := exec.LookPath(p.cfg.FFmpegPath); err != nil {
+		return fmt.Errorf("finding ffmpeg: %w", err)
+	}
+	return nil
+}
+
+func (*driver) Capabilities() provider.Capabilities {
+	return provider.Capabilities{
+		InputFormats:  []string{"h264", "h265", "vp8", "vp9", "mpeg2", "prores"},
+		OutputFormats: []string{"mp4", "mov", "webm", "ts", "mkv"},
+		Destinations:  []string{storage.File},
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// factory builds a driver on the process's jobs, so every job must be
+// created and polled through the same orchestrator instance
+func factory(cfg *config.Config) (provider.Provider, error) {
+	if cfg.Local == nil || cfg.Local.FFmpegPath == "" {
+		return nil, errors.New("incomplete Local config")
+	}
+	return &driver{
+		cfg:    cfg.Local,
+		exec:   osExec{},
+		jobs:   jobs,
+		tmp:    os.TempDir(),
+		tracer: cfg.Tracer,
+	}, nil
+}
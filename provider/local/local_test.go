@@ -0,0 +1,321 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/google/go-cmp/cmp"
+)
+
+// fake stands in for ffmpeg. Each process writes the canned output, and
+// the file named by its last argument, then exits with err. When block
+// is set, processes run until killed.
+type fake struct {
+	stdout, stderr string
+	err            error
+	block          bool
+
+	sync.Mutex
+	args [][]string
+}
+
+func (f *fake) Start(name string, args []string, stdout, stderr io.Writer) (process, error) {
+	f.Lock()
+	f.args = append(f.args, args)
+	f.Unlock()
+	io.WriteString(stdout, f.stdout)
+	io.WriteString(stderr, f.stderr)
+	if dst := args[len(args)-1]; dst != os.DevNull {
+		ioutil.WriteFile(dst, []byte("media"), 0644)
+	}
+	p := &fakeProcess{err: f.err, killed: make(chan struct{})}
+	if !f.block {
+		close(p.killed)
+	}
+	return p, nil
+}
+
+type fakeProcess struct {
+	err    error
+	killed chan struct{}
+	once   sync.Once
+}
+
+func (p *fakeProcess) Wait() error {
+	<-p.killed
+	return p.err
+}
+
+func (p *fakeProcess) Kill() error {
+	p.once.Do(func() {
+		p.err = errors.New("signal: killed")
+		close(p.killed)
+	})
+	return nil
+}
+
+// tempDir returns a directory that's removed when the test ends
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func newDriver(t *testing.T, x *fake) (*driver, string) {
+	dir := tempDir(t)
+	return &driver{
+		cfg:  &config.Local{FFmpegPath: "ffmpeg", Destination: "file://" + dir},
+		exec: x,
+		jobs: &registry{run: map[string]*run{}},
+		tmp:  dir,
+	}, dir
+}
+
+// wait polls the job until its state is s
+func wait(t *testing.T, p *driver, id string, s job.State) *job.Status {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		st, err := p.Status(context.Background(), &job.Job{ProviderJobID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.State == s {
+			return st
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job never reached %q", s)
+	return nil
+}
+
+func TestCreate(t *testing.T) {
+	x := &fake{stdout: "out_time_us=1000000\nprogress=end\n"}
+	p, dir := newDriver(t, x)
+	j := &job.Job{
+		ID:    "abc",
+		Input: job.File{Name: "file:///media/in.mov"},
+		Output: job.Dir{File: []job.File{
+			{Name: "hd.mp4", Video: job.Video{Codec: "h264", Bitrate: job.Bitrate{BPS: 1000, TwoPass: true}}},
+			{Name: "audio/en.m4a", Audio: job.Audio{Codec: "aac"}},
+		}},
+	}
+	st, err := p.Create(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.State != job.StateQueued || st.ProviderJobID == "" {
+		t.Fatalf("bad status: %+v", st)
+	}
+
+	st = wait(t, p, st.ProviderJobID, job.StateFinished)
+	if st.Progress != 100 {
+		t.Fatalf("progress: %v", st.Progress)
+	}
+	want := job.Dir{Path: "file://" + dir + "/abc", File: []job.File{
+		{Name: "file://" + dir + "/abc/hd.mp4", Container: "mp4", Size: 5},
+		{Name: "file://" + dir + "/abc/audio/en.m4a", Container: "m4a", Size: 5},
+	}}
+	if diff := cmp.Diff(want, st.Output); diff != "" {
+		t.Fatalf("output (-want +have):\n%s", diff)
+	}
+
+	if len(x.args) != 3 {
+		t.Fatalf("have %d ffmpeg runs, want 3: %q", len(x.args), x.args)
+	}
+	for i, dst := range []string{os.DevNull, dir + "/abc/hd.mp4", dir + "/abc/audio/en.m4a"} {
+		if args := x.args[i]; args[len(args)-1] != dst {
+			t.Errorf("run %d: writes %q, want %q", i, args[len(args)-1], dst)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "local-"+st.ProviderJobID)); !os.IsNotExist(err) {
+		t.Errorf("pass logs weren't removed: %v", err)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	x := &fake{}
+	p, dir := newDriver(t, x)
+	j := &job.Job{
+		ID:    "abc",
+		Input: job.File{Name: "file:///media/my in.mov"},
+		Output: job.Dir{File: []job.File{
+			{Name: "hd.mp4", Video: job.Video{Codec: "h264", Bitrate: job.Bitrate{BPS: 1000, TwoPass: true}}},
+		}},
+	}
+	data, err := p.DryRun(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("have %d commands, want 2:\n%s", len(lines), data)
+	}
+	for _, l := range lines {
+		if !strings.HasPrefix(l, "ffmpeg ") || !strings.Contains(l, "'/media/my in.mov'") {
+			t.Errorf("bad command: %s", l)
+		}
+	}
+	if !strings.HasSuffix(lines[1], " "+dir+"/abc/hd.mp4") {
+		t.Errorf("bad destination: %s", lines[1])
+	}
+	if len(x.args) != 0 {
+		t.Fatalf("dry run ran ffmpeg: %q", x.args)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	for in, want := range map[string]string{
+		"-c:v":         "-c:v",
+		"/tmp/out.mp4": "/tmp/out.mp4",
+		"":             "''",
+		"a b":          "'a b'",
+		"it's":         `'it'\''s'`,
+		"[0:v]scale":   "'[0:v]scale'",
+	} {
+		if have := quote(in); have != want {
+			t.Errorf("quote(%q): have %s, want %s", in, have, want)
+		}
+	}
+}
+
+func TestCreateUnsupported(t *testing.T) {
+	p, _ := newDriver(t, &fake{})
+	for name, tt := range map[string]struct {
+		j    job.Job
+		want error
+	}{
+		"Input":  {job.Job{Input: job.File{Name: "s3://bucket/in.mov"}}, storage.ErrUnsupported},
+		"Output": {job.Job{Input: job.File{Name: "/in.mov"}, Output: job.Dir{Path: "gs://bucket", File: []job.File{{Name: "a.mp4"}}}}, storage.ErrUnsupported},
+		"Codec":  {job.Job{Input: job.File{Name: "/in.mov"}, Output: job.Dir{File: []job.File{{Name: "a.mp4", Video: job.Video{Codec: "theora"}}}}}, ErrUnsupported},
+	} {
+		if _, err := p.Create(context.Background(), &tt.j); !errors.Is(err, tt.want) {
+			t.Errorf("%s: have %v, want %v", name, err, tt.want)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	x := &fake{
+		block:  true,
+		stderr: "Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'in.mov':\n  Duration: 00:00:10.00, start: 0.000000, bitrate: 1205 kb/s\n",
+		stdout: "frame=120\nout_time_us=2500000\nprogress=continue\n",
+	}
+	p, _ := newDriver(t, x)
+	st, err := p.Create(context.Background(), &job.Job{
+		Input:  job.File{Name: "/media/in.mov"},
+		Output: job.Dir{File: []job.File{{Name: "a.mp4", Audio: job.Audio{Codec: "aac"}}, {Name: "b.mp4", Audio: job.Audio{Codec: "aac"}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st = wait(t, p, st.ProviderJobID, job.StateStarted)
+	if st.Progress != 12.5 {
+		t.Fatalf("progress: have %v, want 12.5", st.Progress)
+	}
+	p.Cancel(context.Background(), st.ProviderJobID)
+
+	if st, err := p.Status(context.Background(), &job.Job{ProviderJobID: "nope"}); err != nil || st.State != job.StateFailed {
+		t.Fatalf("lost job: have %+v, %v, want it failed", st, err)
+	}
+}
+
+func TestFailed(t *testing.T) {
+	x := &fake{err: errors.New("exit status 1"), stderr: "in.mov: No such file or directory\n"}
+	p, _ := newDriver(t, x)
+	st, err := p.Create(context.Background(), &job.Job{
+		Input:  job.File{Name: "/media/in.mov"},
+		Output: job.Dir{File: []job.File{{Name: "a.mp4", Audio: job.Audio{Codec: "aac"}}, {Name: "b.mp4", Audio: job.Audio{Codec: "aac"}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st = wait(t, p, st.ProviderJobID, job.StateFailed)
+	if want := "ffmpeg: exit status 1: in.mov: No such file or directory"; st.Msg != want {
+		t.Fatalf("msg: have %q, want %q", st.Msg, want)
+	}
+	if len(x.args) != 1 {
+		t.Fatalf("ran %d steps after a failure, want 1", len(x.args))
+	}
+}
+
+func TestCancel(t *testing.T) {
+	x := &fake{block: true}
+	p, _ := newDriver(t, x)
+	st, err := p.Create(context.Background(), &job.Job{
+		Input:  job.File{Name: "/media/in.mov"},
+		Output: job.Dir{File: []job.File{{Name: "a.mp4", Audio: job.Audio{Codec: "aac"}}, {Name: "b.mp4", Audio: job.Audio{Codec: "aac"}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := st.ProviderJobID
+	wait(t, p, id, job.StateStarted)
+	if err := p.Cancel(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	wait(t, p, id, job.StateCanceled)
+
+	// the killed step must not fail the job, or start the next one
+	time.Sleep(20 * time.Millisecond)
+	st = wait(t, p, id, job.StateCanceled)
+	x.Lock()
+	defer x.Unlock()
+	if len(x.args) != 1 || st.Msg != "" {
+		t.Fatalf("have %d runs and msg %q after cancel", len(x.args), st.Msg)
+	}
+
+	if err := p.Cancel(context.Background(), "nope"); !errors.As(err, &provider.JobNotFoundError{}) {
+		t.Fatalf("have %v, want JobNotFoundError", err)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	pr := &progress{}
+	out, diag := &lines{fn: pr.stdout}, &lines{fn: pr.stderr}
+	io.WriteString(diag, "  Duration: 01:00:")
+	io.WriteString(diag, "00.00, start: 0.0\r")
+	io.WriteString(out, "out_time_us=N/A\nout_time_ms=900000000\n")
+	if have := pr.Fraction(); have != 0.25 {
+		t.Fatalf("have %v, want 0.25", have)
+	}
+	io.WriteString(out, "progress=end\n")
+	if have := pr.Fraction(); have != 1 {
+		t.Fatalf("have %v at the end, want 1", have)
+	}
+	for i := 0; i < 10; i++ {
+		io.WriteString(diag, "line "+strings.Repeat("x", i)+"\n")
+	}
+	if tail := strings.Split(pr.Tail(), "\n"); len(tail) != tailLines || tail[tailLines-1] != "line xxxxxxxxx" {
+		t.Fatalf("tail: %q", tail)
+	}
+}
+
+func TestHealthcheck(t *testing.T) {
+	self, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+	p := &driver{cfg: &config.Local{FFmpegPath: self}}
+	if err := p.Healthcheck(); err != nil {
+		t.Fatal(err)
+	}
+	p.cfg.FFmpegPath = filepath.Join(tempDir(t), "ffmpeg")
+	if err := p.Healthcheck(); err == nil {
+		t.Fatal("healthcheck passed without ffmpeg")
+	}
+}
@@ -0,0 +1,122 @@
+package local
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tailLines is how many lines of ffmpeg's diagnostics are kept for the
+// message of a failed job
+const tailLines = 5
+
+// progress follows one ffmpeg run: the time written so far from the
+// key=value lines of -progress on stdout, and the input's duration and
+// the last lines of diagnostics from stderr
+type progress struct {
+	sync.Mutex
+	total time.Duration
+	done  time.Duration
+	tail  []string
+}
+
+// Fraction is how much of the output is written, from 0 to 1
+func (p *progress) Fraction() float64 {
+	p.Lock()
+	defer p.Unlock()
+	if p.total <= 0 {
+		return 0
+	}
+	if f := float64(p.done) / float64(p.total); f < 1 {
+		return f
+	}
+	return 1
+}
+
+// Tail is the last lines ffmpeg wrote to stderr
+func (p *progress) Tail() string {
+	p.Lock()
+	defer p.Unlock()
+	return strings.Join(p.tail, "\n")
+}
+
+func (p *progress) stdout(line string) {
+	kv := strings.SplitN(line, "=", 2)
+	if len(kv) != 2 {
+		return
+	}
+	switch kv[0] {
+	case "out_time_us", "out_time_ms":
+		// both are in microseconds
+		us, err := strconv.ParseInt(kv[1], 10, 64)
+		if err != nil || us < 0 {
+			return
+		}
+		p.Lock()
+		p.done = time.Duration(us) * time.Microsecond
+		p.Unlock()
+	case "progress":
+		if kv[1] == "end" {
+			p.Lock()
+			p.done = p.total
+			p.Unlock()
+		}
+	}
+}
+
+func (p *progress) stderr(line string) {
+	p.Lock()
+	defer p.Unlock()
+	if p.total == 0 {
+		p.total = duration(line)
+	}
+	p.tail = append(p.tail, line)
+	if len(p.tail) > tailLines {
+		p.tail = p.tail[1:]
+	}
+}
+
+// duration parses the input duration ffmpeg reports, like
+//
+//	Duration: 00:01:02.50, start: 0.000000, bitrate: 1205 kb/s
+func duration(line string) time.Duration {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "Duration: ") {
+		return 0
+	}
+	hms := strings.SplitN(strings.TrimPrefix(line, "Duration: "), ",", 2)[0]
+	part := strings.Split(hms, ":")
+	if len(part) != 3 {
+		return 0
+	}
+	h, err1 := strconv.Atoi(part[0])
+	m, err2 := strconv.Atoi(part[1])
+	s, err3 := strconv.ParseFloat(part[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s*float64(time.Second))
+}
+
+// lines calls fn with every complete line written to it. Ffmpeg ends its
+// status lines with carriage returns, so they end lines too.
+type lines struct {
+	fn  func(string)
+	buf []byte
+}
+
+func (l *lines) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexAny(l.buf, "\r\n")
+		if i < 0 {
+			return len(p), nil
+		}
+		if line := string(l.buf[:i]); strings.TrimSpace(line) != "" {
+			l.fn(line)
+		}
+		l.buf = l.buf[i+1:]
+	}
+}
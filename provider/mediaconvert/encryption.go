@@ -0,0 +1,117 @@
+package mediaconvert
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	mc "github.com/aws/aws-sdk-go-v2/service/mediaconvert"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+)
+
+// encrypt sets the encryption settings of a streaming group. MediaConvert
+// only gets DASH keys from a SPEKE server, only encrypts transport stream
+// segments with AES-128, and needs the url players get a static key from.
+func encrypt(g *mc.OutputGroup, e *job.Encryption) error {
+	if e == nil {
+		return nil
+	}
+	if err := e.Check(); err != nil {
+		return err
+	}
+	if e.Static() && e.KeyURL == "" {
+		return fmt.Errorf("%w: static key without a key url", ErrUnsupported)
+	}
+	s := g.OutputGroupSettings
+	switch s.Type {
+	case mc.OutputGroupTypeHlsGroupSettings:
+		method := mc.HlsEncryptionTypeSampleAes
+		switch {
+		case e.Is(job.EncryptionAES128):
+			method = mc.HlsEncryptionTypeAes128
+		case e.Is(job.EncryptionCENC):
+			return fmt.Errorf("hls: %w: cenc of transport streams", ErrUnsupported)
+		}
+		es := &mc.HlsEncryptionSettings{
+			EncryptionMethod:  method,
+			Type:              mc.HlsKeyProviderTypeStaticKey,
+			StaticKeyProvider: staticKey(e),
+		}
+		if !e.Static() {
+			es.Type, es.StaticKeyProvider, es.SpekeKeyProvider = mc.HlsKeyProviderTypeSpeke, nil, speke(e)
+		}
+		if e.IV != "" {
+			es.ConstantInitializationVector = aws.String(e.IV)
+			es.InitializationVectorInManifest = mc.HlsInitializationVectorInManifestInclude
+		}
+		s.HlsGroupSettings.Encryption = es
+	case mc.OutputGroupTypeDashIsoGroupSettings:
+		if !e.Is(job.EncryptionCENC) || e.Static() {
+			return fmt.Errorf("dash: %w: encryption other than cenc with speke keys", ErrUnsupported)
+		}
+		s.DashIsoGroupSettings.Encryption = &mc.DashIsoEncryptionSettings{
+			PlaybackDeviceCompatibility: mc.DashIsoPlaybackDeviceCompatibilityCencV1,
+			SpekeKeyProvider:            speke(e),
+		}
+	case mc.OutputGroupTypeCmafGroupSettings:
+		c := s.CmafGroupSettings
+		method := mc.CmafEncryptionTypeAesCtr
+		switch {
+		case e.Is(job.EncryptionSampleAES):
+			method = mc.CmafEncryptionTypeSampleAes
+		case e.Is(job.EncryptionAES128):
+			return fmt.Errorf("cmaf: %w: aes-128 of fragmented mp4", ErrUnsupported)
+		}
+		es := &mc.CmafEncryptionSettings{
+			EncryptionMethod:  method,
+			Type:              mc.CmafKeyProviderTypeStaticKey,
+			StaticKeyProvider: staticKey(e),
+		}
+		if !e.Static() {
+			p := speke(e)
+			es.Type, es.StaticKeyProvider = mc.CmafKeyProviderTypeSpeke, nil
+			es.SpekeKeyProvider = &mc.SpekeKeyProviderCmaf{
+				Url:            p.Url,
+				ResourceId:     p.ResourceId,
+				CertificateArn: p.CertificateArn,
+			}
+			if c.WriteDashManifest != mc.CmafWriteDASHManifestDisabled {
+				es.SpekeKeyProvider.DashSignaledSystemIds = p.SystemIds
+			} else {
+				es.SpekeKeyProvider.HlsSignaledSystemIds = p.SystemIds
+			}
+		}
+		if e.IV != "" {
+			es.ConstantInitializationVector = aws.String(e.IV)
+			es.InitializationVectorInManifest = mc.CmafInitializationVectorInManifestInclude
+		}
+		c.Encryption = es
+	}
+	return nil
+}
+
+func staticKey(e *job.Encryption) *mc.StaticKeyProvider {
+	if !e.Static() {
+		return nil
+	}
+	return &mc.StaticKeyProvider{
+		StaticKeyValue:    aws.String(e.Key),
+		Url:               aws.String(e.KeyURL),
+		KeyFormat:         aws.String("identity"),
+		KeyFormatVersions: aws.String("1"),
+	}
+}
+
+func speke(e *job.Encryption) *mc.SpekeKeyProvider {
+	p := e.KeyProvider
+	s := &mc.SpekeKeyProvider{
+		Url:       aws.String(p.URL),
+		SystemIds: p.SystemIDs,
+	}
+	if p.ResourceID != "" {
+		s.ResourceId = aws.String(p.ResourceID)
+	}
+	if p.CertificateARN != "" {
+		s.CertificateArn = aws.String(p.CertificateARN)
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package mediaconvert
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	mc "github.com/aws/aws-sdk-go-v2/service/mediaconvert"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/google/go-cmp/cmp"
+)
+
+const testKey = "00112233445566778899aabbccddeeff"
+
+var testProvider = &job.KeyProvider{
+	URL:            "https://keys.example.com/speke",
+	ResourceID:     "abc",
+	SystemIDs:      []string{"edef8ba9-79d6-4ace-a3c8-27dcd51d21ed"},
+	CertificateARN: "arn:aws:acm:us-east-1:1:certificate/x",
+}
+
+func TestEncryption(t *testing.T) {
+	d := &driver{cfg: config.MediaConvert{Destination: "s3://out"}}
+	groups := func(t *testing.T, container string, e *job.Encryption) []mc.OutputGroup {
+		t.Helper()
+		j := streamingJob(container, job.File{Name: "1080p", Video: hd})
+		j.Streaming.Encryption = e
+		g, err := d.outputGroupsFrom(j)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return g
+	}
+
+	t.Run("HLSStatic", func(t *testing.T) {
+		e := &job.Encryption{Method: "aes-128", Key: testKey, IV: testKey, KeyURL: "https://keys.example.com/k"}
+		have := groups(t, "hls", e)[0].OutputGroupSettings.HlsGroupSettings.Encryption
+		want := &mc.HlsEncryptionSettings{
+			EncryptionMethod:               mc.HlsEncryptionTypeAes128,
+			Type:                           mc.HlsKeyProviderTypeStaticKey,
+			ConstantInitializationVector:   aws.String(testKey),
+			InitializationVectorInManifest: mc.HlsInitializationVectorInManifestInclude,
+			StaticKeyProvider: &mc.StaticKeyProvider{
+				StaticKeyValue:    aws.String(testKey),
+				Url:               aws.String("https://keys.example.com/k"),
+				KeyFormat:         aws.String("identity"),
+				KeyFormatVersions: aws.String("1"),
+			},
+		}
+		if diff := cmp.Diff(want, have); diff != "" {
+			t.Fatalf("hls encryption (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("DASHSpeke", func(t *testing.T) {
+		have := groups(t, "dash", &job.Encryption{Method: "cenc", KeyProvider: testProvider})[0].OutputGroupSettings.DashIsoGroupSettings.Encryption
+		want := &mc.DashIsoEncryptionSettings{
+			PlaybackDeviceCompatibility: mc.DashIsoPlaybackDeviceCompatibilityCencV1,
+			SpekeKeyProvider: &mc.SpekeKeyProvider{
+				Url:            aws.String(testProvider.URL),
+				ResourceId:     aws.String("abc"),
+				SystemIds:      testProvider.SystemIDs,
+				CertificateArn: aws.String(testProvider.CertificateARN),
+			},
+		}
+		if diff := cmp.Diff(want, have); diff != "" {
+			t.Fatalf("dash encryption (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("CMAFSpeke", func(t *testing.T) {
+		have := groups(t, "cmaf", &job.Encryption{Method: "cenc", KeyProvider: testProvider})[0].OutputGroupSettings.CmafGroupSettings.Encryption
+		want := &mc.CmafEncryptionSettings{
+			EncryptionMethod: mc.CmafEncryptionTypeAesCtr,
+			Type:             mc.CmafKeyProviderTypeSpeke,
+			SpekeKeyProvider: &mc.SpekeKeyProviderCmaf{
+				Url:                   aws.String(testProvider.URL),
+				ResourceId:            aws.String("abc"),
+				CertificateArn:        aws.String(testProvider.CertificateARN),
+				DashSignaledSystemIds: testProvider.SystemIDs,
+			},
+		}
+		if diff := cmp.Diff(want, have); diff != "" {
+			t.Fatalf("cmaf encryption (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("FMP4SampleAES", func(t *testing.T) {
+		j := streamingJob("hls", job.File{Name: "1080p", Video: hd})
+		j.Streaming = job.Streaming{Segments: "fmp4", Encryption: &job.Encryption{Method: "sample-aes", KeyProvider: testProvider}}
+		g, err := d.outputGroupsFrom(j)
+		if err != nil {
+			t.Fatal(err)
+		}
+		have := g[0].OutputGroupSettings.CmafGroupSettings.Encryption
+		if have.EncryptionMethod != mc.CmafEncryptionTypeSampleAes || have.SpekeKeyProvider.HlsSignaledSystemIds == nil {
+			t.Fatalf("bad encryption: %+v", have)
+		}
+	})
+
+	for name, tt := range map[string]struct {
+		container string
+		e         *job.Encryption
+	}{
+		"DASHStatic":   {"dash", &job.Encryption{Method: "cenc", Key: testKey, KeyID: testKey, KeyURL: "https://keys.example.com/k"}},
+		"HLSCENC":      {"hls", &job.Encryption{Method: "cenc", Key: testKey, KeyID: testKey, KeyURL: "https://keys.example.com/k"}},
+		"CMAFAES128":   {"cmaf", &job.Encryption{Method: "aes-128", Key: testKey, KeyURL: "https://keys.example.com/k"}},
+		"HLSNoKeyURL":  {"hls", &job.Encryption{Method: "aes-128", Key: testKey}},
+		"CMAFNoKeyURL": {"cmaf", &job.Encryption{Method: "cenc", Key: testKey, KeyID: testKey}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			j := streamingJob(tt.container, job.File{Name: "1080p", Video: hd})
+			j.Streaming.Encryption = tt.e
+			if _, err := d.outputGroupsFrom(j); !errors.Is(err, ErrUnsupported) {
+				t.Fatalf("have %v, want %v", err, ErrUnsupported)
+			}
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package mediaconvert
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"path"
 	"strings"
 
@@ -10,11 +13,17 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/external"
+	"github.com/aws/aws-sdk-go-v2/private/protocol"
+	awsjson "github.com/aws/aws-sdk-go-v2/private/protocol/json"
 	"github.com/cbsinteractive/pkg/timecode"
 	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
 	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/logging"
 	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/cbsinteractive/transcode-orchestrator/trace"
 	"github.com/pkg/errors"
+	"github.com/zsiec/pkg/tracing"
 )
 
 const (
@@ -29,10 +38,16 @@ type (
 	Job    = job.Job
 )
 
+// sources and destinations are the storage MediaConvert reads and writes
+var (
+	sources      = []string{storage.S3, storage.HTTP, storage.HTTPS}
+	destinations = []string{storage.S3}
+)
+
 func init() {
 	err := provider.Register(Name, mediaconvertFactory)
 	if err != nil {
-		fmt.Printf("registering mediaconvert factory: %v", err)
+		logging.From(context.Background()).WithError(err).Error("registering mediaconvert factory")
 	}
 }
 
@@ -49,6 +64,17 @@ type mediaconvertClient interface {
 type driver struct {
 	client mediaconvertClient
 	cfg    config.MediaConvert
+	tracer tracing.Tracer
+
+	segmentDuration uint // seconds, when the job doesn't say
+}
+
+func (p *driver) trace(ctx context.Context, name string, err *error) func() {
+	_, x := trace.Begin(ctx, p.tracer, name)
+	return func() {
+		x.Close(*err)
+		logging.Done(ctx, name, *err)
+	}
 }
 
 type outputCfg struct {
@@ -129,12 +155,25 @@ func (p *driver) createRequest(ctx context.Context, j *Job) (*mc.CreateJobInput,
 	}, nil
 }
 
+// create checks the job's storage and returns the request that submits it
+func (p *driver) create(ctx context.Context, j *Job) (*mc.CreateJobInput, error) {
+	if _, err := storage.Check(j.Input.Name, sources...); err != nil {
+		return nil, fmt.Errorf("mediaconvert: input: %w", err)
+	}
+	if _, err := storage.Check(p.location(*j, ""), destinations...); err != nil {
+		return nil, fmt.Errorf("mediaconvert: output: %w", err)
+	}
+	return p.createRequest(ctx, j)
+}
+
 func (p *driver) Create(ctx context.Context, j *Job) (*Status, error) {
-	input, err := p.createRequest(ctx, j)
+	input, err := p.create(ctx, j)
 	if err != nil {
 		return nil, err
 	}
+	done := p.trace(ctx, "mediaconvert-create-job", &err)
 	resp, err := p.client.CreateJobRequest(input).Send(ctx)
+	done()
 	if err != nil {
 		return nil, err
 	}
@@ -145,10 +184,48 @@ func (p *driver) Create(ctx context.Context, j *Job) (*Status, error) {
 	}, nil
 }
 
+// DryRun returns the body of the create job request, as the api
+// encodes it
+func (p *driver) DryRun(ctx context.Context, j *Job) ([]byte, error) {
+	input, err := p.create(ctx, j)
+	if err != nil {
+		return nil, err
+	}
+	e := awsjson.NewEncoder()
+	if err := input.MarshalFields(bodyEncoder{e}); err != nil {
+		return nil, err
+	}
+	r, err := e.Encode()
+	if err != nil || r == nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := json.Indent(buf, body, "", "\t"); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// bodyEncoder leaves out the fields that go in the request's headers
+type bodyEncoder struct{ *awsjson.Encoder }
+
+func (e bodyEncoder) SetValue(t protocol.Target, k string, v protocol.ValueMarshaler, meta protocol.Metadata) {
+	if t == protocol.BodyTarget {
+		e.Encoder.SetValue(t, k, v, meta)
+	}
+}
+
 func (p *driver) Status(ctx context.Context, job *Job) (*Status, error) {
+	var err error
+	done := p.trace(ctx, "mediaconvert-get-job", &err)
 	jobResp, err := p.client.GetJobRequest(&mc.GetJobInput{
 		Id: aws.String(job.ProviderJobID),
 	}).Send(ctx)
+	done()
 	if err != nil {
 		return &Status{}, errors.Wrap(err, "fetching job info with the mediaconvert API")
 	}
@@ -156,8 +233,9 @@ func (p *driver) Status(ctx context.Context, job *Job) (*Status, error) {
 	return p.status(job, jobResp.Job), nil
 }
 
-func (p *driver) Cancel(ctx context.Context, id string) error {
-	_, err := p.client.CancelJobRequest(&mc.CancelJobInput{
+func (p *driver) Cancel(ctx context.Context, id string) (err error) {
+	defer p.trace(ctx, "mediaconvert-cancel-job", &err)()
+	_, err = p.client.CancelJobRequest(&mc.CancelJobInput{
 		Id: aws.String(id),
 	}).Send(ctx)
 
@@ -175,13 +253,14 @@ func (p *driver) Healthcheck() error {
 func (p *driver) Capabilities() provider.Capabilities {
 	return provider.Capabilities{
 		InputFormats:  []string{"h264", "h265", "hdr10"},
-		OutputFormats: []string{"mp4", "hls", "hdr10", "cmaf", "mov"},
-		Destinations:  []string{"s3"},
+		OutputFormats: []string{"mp4", "hls", "dash", "hdr10", "cmaf", "mov"},
+		Destinations:  destinations,
 	}
 }
 
 func (p *driver) outputGroupsFrom(j *Job) ([]mc.OutputGroup, error) {
 	cfg := map[mc.ContainerType][]outputCfg{}
+	renditions := map[string][]outputCfg{}
 	for _, f := range j.Output.File {
 		mc, err := outputFrom(f, j.Input)
 		if err != nil {
@@ -193,13 +272,18 @@ func (p *driver) outputGroupsFrom(j *Job) ([]mc.OutputGroup, error) {
 			return nil, fmt.Errorf("no container was found on outout settings %+v", mc)
 		}
 
-		cfg[cs.Container] = append(cfg[cs.Container], outputCfg{
-			output:   mc,
-			filename: f.Name,
-		})
+		o := outputCfg{output: mc, filename: f.Name}
+		if protocol := f.Protocol(); protocol != "" {
+			renditions[protocol] = append(renditions[protocol], o)
+			continue
+		}
+		cfg[cs.Container] = append(cfg[cs.Container], o)
 	}
 
-	mcOutputGroups := []mc.OutputGroup{}
+	mcOutputGroups, err := p.streamingGroups(j, renditions)
+	if err != nil {
+		return nil, err
+	}
 	for container, outputs := range cfg {
 		mcOutputGroup := mc.OutputGroup{}
 
@@ -219,7 +303,7 @@ func (p *driver) outputGroupsFrom(j *Job) ([]mc.OutputGroup, error) {
 		}
 		mcOutputGroup.Outputs = mcOutputs
 
-		destination := p.destinationPath(*j, "m")
+		destination := p.location(*j, "m")
 
 		switch container {
 		case mc.ContainerTypeMp4, mc.ContainerTypeMov, mc.ContainerTypeWebm, mc.ContainerTypeMxf:
@@ -239,7 +323,7 @@ func (p *driver) outputGroupsFrom(j *Job) ([]mc.OutputGroup, error) {
 	return mcOutputGroups, nil
 }
 
-func (p *driver) destinationPath(j Job, file string) string {
+func (p *driver) location(j Job, file string) string {
 	if j.Output.Path == "" {
 		j.Output.Path = p.cfg.Destination
 	}
@@ -253,7 +337,7 @@ func (p *driver) status(j *Job, mcJob *mc.Job) *Status {
 		State:         state(mcJob.Status),
 		Msg:           message(mcJob),
 		Output: job.Dir{
-			Path: p.destinationPath(*j, ""),
+			Path: p.location(*j, ""),
 		},
 	}
 
@@ -266,6 +350,13 @@ func (p *driver) status(j *Job, mcJob *mc.Job) *Status {
 	var files []job.File
 	if settings := mcJob.Settings; settings != nil {
 		for _, group := range settings.OutputGroups {
+			if pkgs := packages(group); pkgs != nil {
+				for _, pkg := range pkgs {
+					files = append(files, job.File{Name: pkg.Manifest, Container: pkg.Protocol})
+				}
+				status.Packages = append(status.Packages, pkgs...)
+				continue
+			}
 			groupDestination, err := outputGroupDestinationFrom(group)
 			if err != nil {
 				continue
@@ -409,7 +500,9 @@ func mediaconvertFactory(cfg *config.Config) (provider.Provider, error) {
 	}
 
 	return &driver{
-		client: mc.New(mcCfg),
-		cfg:    *cfg.MediaConvert,
+		client:          mc.New(mcCfg),
+		cfg:             *cfg.MediaConvert,
+		tracer:          cfg.Tracer,
+		segmentDuration: cfg.DefaultSegmentDuration,
 	}, nil
 }
@@ -14,6 +14,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
 	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -1122,6 +1123,18 @@ func TestDriverCreate(t *testing.T) {
 	}
 }
 
+func TestDriverCreateStorage(t *testing.T) {
+	d := &driver{cfg: config.MediaConvert{Destination: "s3://some_dest"}}
+	for name, j := range map[string]*job.Job{
+		"input":  {Input: job.File{Name: "gs://some/path.mp4"}},
+		"output": {Input: job.File{Name: "s3://some/path.mp4"}, Output: job.Dir{Path: "gs://some/destination"}},
+	} {
+		if _, err := d.Create(context.Background(), j); !errors.Is(err, storage.ErrUnsupported) {
+			t.Errorf("%s: have %v, want %v", name, err, storage.ErrUnsupported)
+		}
+	}
+}
+
 func TestAudioMixTimecodeBurnin(t *testing.T) {
 
 	input := &job.Job{
@@ -1370,3 +1383,34 @@ func filterjunk(v interface{}) {
 		}
 	}
 }
+
+func TestDriverDryRun(t *testing.T) {
+	d := &driver{cfg: config.MediaConvert{Destination: "s3://some_dest", Role: "some-role"}}
+	j := &job.Job{
+		ID:    "jobID",
+		Input: job.File{Name: "s3://some/path.mov"},
+		Output: job.Dir{File: []job.File{{
+			Name:      "file1.mp4",
+			Container: "mp4",
+			Video:     job.Video{Codec: "h264", Width: 1280, Bitrate: job.Bitrate{BPS: 3000000, Control: "CBR"}},
+		}}},
+	}
+	data, err := d.DryRun(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req struct {
+		Role     string `json:"role"`
+		Settings struct {
+			Inputs []struct {
+				FileInput string `json:"fileInput"`
+			} `json:"inputs"`
+		} `json:"settings"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatalf("%v: %s", err, data)
+	}
+	if req.Role != "some-role" || len(req.Settings.Inputs) != 1 || req.Settings.Inputs[0].FileInput != j.Input.Name {
+		t.Fatalf("bad request: %s", data)
+	}
+}
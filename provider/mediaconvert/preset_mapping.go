@@ -87,6 +87,10 @@ func containerFrom(v string) (mc.ContainerType, error) {
 		return mc.ContainerTypeWebm, nil
 	case "cmaf":
 		return mc.ContainerTypeCmfc, nil
+	case "hls", "m3u8":
+		return mc.ContainerTypeM3u8, nil
+	case "dash", "mpd":
+		return mc.ContainerTypeMpd, nil
 	default:
 		return "", fmt.Errorf("%w: %q", ErrUnsupported, v)
 	}
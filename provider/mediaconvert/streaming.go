@@ -0,0 +1,228 @@
+package mediaconvert
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	mc "github.com/aws/aws-sdk-go-v2/service/mediaconvert"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+)
+
+// streamingGroups returns an output group for each streaming protocol
+// the job's renditions use. HLS with fmp4 segments is a cmaf group that
+// only writes HLS manifests.
+func (p *driver) streamingGroups(j *Job, renditions map[string][]outputCfg) ([]mc.OutputGroup, error) {
+	if len(renditions) == 0 {
+		return nil, nil
+	}
+	seg := int64(j.Streaming.Duration(p.segmentDuration))
+	if s := strings.ToLower(j.Streaming.Segments); s != "" && s != job.SegmentsTS && s != job.SegmentsFMP4 {
+		return nil, fmt.Errorf("%w: hls segments: %q", ErrUnsupported, j.Streaming.Segments)
+	}
+	group := j.Streaming.AudioGroupName()
+
+	var groups []mc.OutputGroup
+	for _, protocol := range []string{job.ContainerHLS, job.ContainerDASH, job.ContainerCMAF} {
+		outputs := renditions[protocol]
+		if len(outputs) == 0 {
+			continue
+		}
+		dest := aws.String(p.location(*j, path.Join(protocol, j.Streaming.ManifestName())))
+		g := mc.OutputGroup{Name: aws.String(strings.ToUpper(protocol))}
+
+		switch {
+		case protocol == job.ContainerHLS && !j.Streaming.FMP4():
+			g.OutputGroupSettings = &mc.OutputGroupSettings{
+				Type: mc.OutputGroupTypeHlsGroupSettings,
+				HlsGroupSettings: &mc.HlsGroupSettings{
+					Destination:            dest,
+					SegmentLength:          aws.Int64(seg),
+					MinSegmentLength:       aws.Int64(0),
+					SegmentControl:         mc.HlsSegmentControlSegmentedFiles,
+					DirectoryStructure:     mc.HlsDirectoryStructureSingleDirectory,
+					ManifestDurationFormat: mc.HlsManifestDurationFormatInteger,
+					OutputSelection:        mc.HlsOutputSelectionManifestsAndSegments,
+					StreamInfResolution:    mc.HlsStreamInfResolutionInclude,
+					CodecSpecification:     mc.HlsCodecSpecificationRfc4281,
+					ClientCache:            mc.HlsClientCacheEnabled,
+				},
+			}
+			g.Outputs = hlsOutputs(outputs, group)
+		case protocol == job.ContainerDASH:
+			g.OutputGroupSettings = &mc.OutputGroupSettings{
+				Type: mc.OutputGroupTypeDashIsoGroupSettings,
+				DashIsoGroupSettings: &mc.DashIsoGroupSettings{
+					Destination:    dest,
+					SegmentLength:  aws.Int64(seg),
+					FragmentLength: aws.Int64(seg),
+					SegmentControl: mc.DashIsoSegmentControlSegmentedFiles,
+					MpdProfile:     mc.DashIsoMpdProfileMainProfile,
+				},
+			}
+			g.Outputs = fragmentedOutputs(outputs, mc.ContainerTypeMpd)
+		default:
+			dash := mc.CmafWriteDASHManifestEnabled
+			if protocol == job.ContainerHLS {
+				dash = mc.CmafWriteDASHManifestDisabled
+			}
+			g.OutputGroupSettings = &mc.OutputGroupSettings{
+				Type: mc.OutputGroupTypeCmafGroupSettings,
+				CmafGroupSettings: &mc.CmafGroupSettings{
+					Destination:            dest,
+					SegmentLength:          aws.Int64(seg),
+					FragmentLength:         aws.Int64(seg),
+					SegmentControl:         mc.CmafSegmentControlSegmentedFiles,
+					WriteHlsManifest:       mc.CmafWriteHLSManifestEnabled,
+					WriteDashManifest:      dash,
+					ManifestDurationFormat: mc.CmafManifestDurationFormatInteger,
+					StreamInfResolution:    mc.CmafStreamInfResolutionInclude,
+					CodecSpecification:     mc.CmafCodecSpecificationRfc4281,
+					MpdProfile:             mc.CmafMpdProfileMainProfile,
+					ClientCache:            mc.CmafClientCacheEnabled,
+				},
+			}
+			g.Outputs = fragmentedOutputs(outputs, mc.ContainerTypeCmfc)
+		}
+		if g.Outputs == nil {
+			return nil, fmt.Errorf("%s: %w: fragmented mp4 outputs carry either video or a single audio track", protocol, ErrUnsupported)
+		}
+		if err := encrypt(&g, j.Streaming.Encryption); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// hlsOutputs returns the outputs of an HLS group. Audio only outputs are
+// alternate renditions in the audio group, the first one the default,
+// and video only outputs play with that group.
+func hlsOutputs(outputs []outputCfg, group string) []mc.Output {
+	audio := 0
+	for _, o := range outputs {
+		if o.output.VideoDescription == nil {
+			audio++
+		}
+	}
+	var all []mc.Output
+	n := 0
+	for _, o := range outputs {
+		out := rendition(o, mc.ContainerTypeM3u8)
+		out.ContainerSettings.M3u8Settings = &mc.M3u8Settings{}
+		switch {
+		case o.output.VideoDescription == nil:
+			track := mc.HlsAudioTrackTypeAlternateAudioAutoSelect
+			if n++; n == 1 {
+				track = mc.HlsAudioTrackTypeAlternateAudioAutoSelectDefault
+			}
+			out.OutputSettings = &mc.OutputSettings{HlsSettings: &mc.HlsSettings{
+				AudioGroupId:       aws.String(group),
+				AudioTrackType:     track,
+				AudioOnlyContainer: mc.HlsAudioOnlyContainerAutomatic,
+			}}
+		case len(o.output.AudioDescriptions) == 0 && audio > 0:
+			out.OutputSettings = &mc.OutputSettings{HlsSettings: &mc.HlsSettings{
+				AudioRenditionSets: aws.String(group),
+			}}
+		}
+		all = append(all, out)
+	}
+	return all
+}
+
+// fragmentedOutputs returns the outputs of a DASH or CMAF group, or nil
+// if any output has both video and audio, or more than one audio track
+func fragmentedOutputs(outputs []outputCfg, container mc.ContainerType) []mc.Output {
+	var all []mc.Output
+	for _, o := range outputs {
+		a := len(o.output.AudioDescriptions)
+		if a > 1 || (a == 1 && o.output.VideoDescription != nil) {
+			return nil
+		}
+		all = append(all, rendition(o, container))
+	}
+	return all
+}
+
+// rendition returns the output for a rendition, named after its file
+func rendition(o outputCfg, container mc.ContainerType) mc.Output {
+	name := path.Base(o.filename)
+	name = strings.TrimSuffix(name, path.Ext(name))
+	return mc.Output{
+		NameModifier:      aws.String("_" + name),
+		ContainerSettings: &mc.ContainerSettings{Container: container},
+		AudioDescriptions: o.output.AudioDescriptions,
+		VideoDescription:  o.output.VideoDescription,
+	}
+}
+
+// packages returns the streaming packages that a group writes
+func packages(g mc.OutputGroup) (pkgs []job.Package) {
+	s := g.OutputGroupSettings
+	if s == nil {
+		return nil
+	}
+	var (
+		dest       *string
+		hls, dash  bool
+		mediaLists bool
+	)
+	switch s.Type {
+	case mc.OutputGroupTypeHlsGroupSettings:
+		if s.HlsGroupSettings != nil {
+			dest, hls, mediaLists = s.HlsGroupSettings.Destination, true, true
+		}
+	case mc.OutputGroupTypeDashIsoGroupSettings:
+		if s.DashIsoGroupSettings != nil {
+			dest, dash = s.DashIsoGroupSettings.Destination, true
+		}
+	case mc.OutputGroupTypeCmafGroupSettings:
+		if c := s.CmafGroupSettings; c != nil {
+			dest = c.Destination
+			hls = c.WriteHlsManifest != mc.CmafWriteHLSManifestDisabled
+			dash = c.WriteDashManifest != mc.CmafWriteDASHManifestDisabled
+			mediaLists = hls
+		}
+	}
+	if dest == nil {
+		return nil
+	}
+
+	var renditions []job.Rendition
+	for _, o := range g.Outputs {
+		if o.NameModifier == nil {
+			continue
+		}
+		r := job.Rendition{Name: strings.TrimPrefix(*o.NameModifier, "_")}
+		if v := o.VideoDescription; v != nil {
+			r.Video.Width, r.Video.Height = int(aws.Int64Value(v.Width)), int(aws.Int64Value(v.Height))
+		}
+		if len(o.AudioDescriptions) > 0 {
+			if c := o.AudioDescriptions[0].CodecSettings; c != nil {
+				r.Audio.Codec = strings.ToLower(string(c.Codec))
+			}
+		}
+		if hs := o.OutputSettings; hs != nil && hs.HlsSettings != nil {
+			r.Group = aws.StringValue(hs.HlsSettings.AudioGroupId)
+		}
+		if mediaLists {
+			r.Playlist = *dest + *o.NameModifier + ".m3u8"
+		}
+		renditions = append(renditions, r)
+	}
+
+	if hls {
+		pkgs = append(pkgs, job.Package{Protocol: job.ContainerHLS, Manifest: *dest + ".m3u8", Renditions: renditions})
+	}
+	if dash {
+		var rs []job.Rendition
+		for _, r := range renditions {
+			r.Playlist = ""
+			rs = append(rs, r)
+		}
+		pkgs = append(pkgs, job.Package{Protocol: job.ContainerDASH, Manifest: *dest + ".mpd", Renditions: rs})
+	}
+	return pkgs
+}
@@ -0,0 +1,168 @@
+package mediaconvert
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	mc "github.com/aws/aws-sdk-go-v2/service/mediaconvert"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/google/go-cmp/cmp"
+)
+
+var (
+	hd    = job.Video{Codec: "h264", Width: 1920, Height: 1080, Bitrate: job.Bitrate{BPS: 5000000, Control: "CBR"}}
+	sd    = job.Video{Codec: "h264", Width: 640, Height: 360, Bitrate: job.Bitrate{BPS: 800000, Control: "CBR"}}
+	audio = job.Audio{Codec: "aac", Bitrate: 128000}
+)
+
+func streamingJob(container string, files ...job.File) *job.Job {
+	for i := range files {
+		files[i].Container = container
+	}
+	return &job.Job{ID: "abc", Input: job.File{Name: "s3://in/a.mov"}, Output: job.Dir{File: files}}
+}
+
+func TestStreamingGroups(t *testing.T) {
+	d := &driver{cfg: config.MediaConvert{Destination: "s3://out"}, segmentDuration: 6}
+
+	t.Run("HLS", func(t *testing.T) {
+		j := streamingJob("hls",
+			job.File{Name: "1080p.m3u8", Video: hd},
+			job.File{Name: "360p.m3u8", Video: sd},
+			job.File{Name: "en.m3u8", Audio: audio},
+			job.File{Name: "es.m3u8", Audio: audio},
+		)
+		j.Streaming = job.Streaming{Manifest: "index", AudioGroup: "aac"}
+		groups, err := d.outputGroupsFrom(j)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(groups) != 1 || groups[0].OutputGroupSettings.Type != mc.OutputGroupTypeHlsGroupSettings {
+			t.Fatalf("bad groups: %+v", groups)
+		}
+		s := groups[0].OutputGroupSettings.HlsGroupSettings
+		if *s.Destination != "s3://out/abc/hls/index" || *s.SegmentLength != 6 {
+			t.Fatalf("destination %q, segment length %d", *s.Destination, *s.SegmentLength)
+		}
+		var have []mc.HlsSettings
+		for _, o := range groups[0].Outputs {
+			if o.ContainerSettings.Container != mc.ContainerTypeM3u8 {
+				t.Errorf("%s: container %q", *o.NameModifier, o.ContainerSettings.Container)
+			}
+			have = append(have, *o.OutputSettings.HlsSettings)
+		}
+		want := []mc.HlsSettings{
+			{AudioRenditionSets: aws.String("aac")},
+			{AudioRenditionSets: aws.String("aac")},
+			{AudioGroupId: aws.String("aac"), AudioTrackType: mc.HlsAudioTrackTypeAlternateAudioAutoSelectDefault, AudioOnlyContainer: mc.HlsAudioOnlyContainerAutomatic},
+			{AudioGroupId: aws.String("aac"), AudioTrackType: mc.HlsAudioTrackTypeAlternateAudioAutoSelect, AudioOnlyContainer: mc.HlsAudioOnlyContainerAutomatic},
+		}
+		if diff := cmp.Diff(want, have); diff != "" {
+			t.Fatalf("hls settings (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("Muxed", func(t *testing.T) {
+		groups, err := d.outputGroupsFrom(streamingJob("hls", job.File{Name: "720p", Video: hd, Audio: audio}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o := groups[0].Outputs[0]; o.OutputSettings != nil || *o.NameModifier != "_720p" {
+			t.Fatalf("muxed output: %+v", o)
+		}
+	})
+
+	t.Run("FMP4", func(t *testing.T) {
+		j := streamingJob("hls", job.File{Name: "1080p", Video: hd}, job.File{Name: "en", Audio: audio})
+		j.Streaming = job.Streaming{Segments: "fmp4", SegmentDuration: 4}
+		groups, err := d.outputGroupsFrom(j)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := groups[0].OutputGroupSettings.CmafGroupSettings
+		if s == nil || s.WriteHlsManifest != mc.CmafWriteHLSManifestEnabled || s.WriteDashManifest != mc.CmafWriteDASHManifestDisabled {
+			t.Fatalf("bad settings: %+v", groups[0].OutputGroupSettings)
+		}
+		if *s.Destination != "s3://out/abc/hls/master" || *s.SegmentLength != 4 || *s.FragmentLength != 4 {
+			t.Fatalf("destination %q, segment length %d", *s.Destination, *s.SegmentLength)
+		}
+	})
+
+	t.Run("DASHAndCMAF", func(t *testing.T) {
+		j := streamingJob("dash", job.File{Name: "1080p", Video: hd}, job.File{Name: "en", Audio: audio})
+		j.Output.File = append(j.Output.File, job.File{Name: "sd", Container: "cmaf", Video: sd})
+		j.Output.File = append(j.Output.File, job.File{Name: "file.mp4", Container: "mp4", Video: sd})
+		groups, err := d.outputGroupsFrom(j)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var types []mc.OutputGroupType
+		for _, g := range groups {
+			types = append(types, g.OutputGroupSettings.Type)
+		}
+		want := []mc.OutputGroupType{mc.OutputGroupTypeDashIsoGroupSettings, mc.OutputGroupTypeCmafGroupSettings, mc.OutputGroupTypeFileGroupSettings}
+		if diff := cmp.Diff(want, types); diff != "" {
+			t.Fatalf("group types (-want +have):\n%s", diff)
+		}
+		if *groups[0].OutputGroupSettings.DashIsoGroupSettings.Destination != "s3://out/abc/dash/master" {
+			t.Fatalf("dash destination: %q", *groups[0].OutputGroupSettings.DashIsoGroupSettings.Destination)
+		}
+		if c := groups[1].OutputGroupSettings.CmafGroupSettings; c.WriteDashManifest != mc.CmafWriteDASHManifestEnabled || *c.SegmentLength != 6 {
+			t.Fatalf("bad cmaf settings: %+v", c)
+		}
+	})
+
+	for name, j := range map[string]*job.Job{
+		"MuxedDASH": streamingJob("dash", job.File{Name: "720p", Video: hd, Audio: audio}),
+		"MuxedCMAF": streamingJob("cmaf", job.File{Name: "720p", Video: sd, Audio: audio}),
+		"BadSegments": func() *job.Job {
+			j := streamingJob("hls", job.File{Name: "720p", Video: hd})
+			j.Streaming.Segments = "webm"
+			return j
+		}(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := d.outputGroupsFrom(j); !errors.Is(err, ErrUnsupported) {
+				t.Fatalf("have %v, want %v", err, ErrUnsupported)
+			}
+		})
+	}
+}
+
+func TestStreamingStatus(t *testing.T) {
+	d := &driver{cfg: config.MediaConvert{Destination: "s3://out"}}
+	j := streamingJob("cmaf", job.File{Name: "1080p", Video: hd}, job.File{Name: "en", Audio: audio})
+	j.Output.File = append(j.Output.File, job.File{Name: "720p", Container: "hls", Video: hd, Audio: audio})
+	groups, err := d.outputGroupsFrom(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat := d.status(j, &mc.Job{Status: mc.JobStatusComplete, Settings: &mc.JobSettings{OutputGroups: groups}})
+
+	manifests := []job.File{
+		{Name: "s3://out/abc/hls/master.m3u8", Container: "hls"},
+		{Name: "s3://out/abc/cmaf/master.m3u8", Container: "hls"},
+		{Name: "s3://out/abc/cmaf/master.mpd", Container: "dash"},
+	}
+	if diff := cmp.Diff(manifests, stat.Output.File); diff != "" {
+		t.Fatalf("outputs (-want +have):\n%s", diff)
+	}
+	want := []job.Package{
+		{Protocol: "hls", Manifest: "s3://out/abc/hls/master.m3u8", Renditions: []job.Rendition{
+			{Name: "720p", Playlist: "s3://out/abc/hls/master_720p.m3u8", Video: job.Video{Width: 1920, Height: 1080}, Audio: job.Audio{Codec: "aac"}},
+		}},
+		{Protocol: "hls", Manifest: "s3://out/abc/cmaf/master.m3u8", Renditions: []job.Rendition{
+			{Name: "1080p", Playlist: "s3://out/abc/cmaf/master_1080p.m3u8", Video: job.Video{Width: 1920, Height: 1080}},
+			{Name: "en", Playlist: "s3://out/abc/cmaf/master_en.m3u8", Audio: job.Audio{Codec: "aac"}},
+		}},
+		{Protocol: "dash", Manifest: "s3://out/abc/cmaf/master.mpd", Renditions: []job.Rendition{
+			{Name: "1080p", Video: job.Video{Width: 1920, Height: 1080}},
+			{Name: "en", Audio: job.Audio{Codec: "aac"}},
+		}},
+	}
+	if diff := cmp.Diff(want, stat.Packages); diff != "" {
+		t.Fatalf("packages (-want +have):\n%s", diff)
+	}
+}
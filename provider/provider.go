@@ -5,12 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"sync"
 
 	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
 	"github.com/cbsinteractive/transcode-orchestrator/config"
 )
 
-var providers = map[string]Factory{}
+var (
+	mu        sync.RWMutex
+	providers = map[string]Factory{}
+	instances = map[string]string{} // instance name to driver
+)
 
 var (
 	ErrRegistered = errors.New("provider is already registered")
@@ -28,6 +33,19 @@ type Provider interface {
 	Capabilities() Capabilities
 }
 
+// DryRunner is a Provider that can build the request it would submit
+// for a job, in the provider's own format, without submitting it.
+// Credentials are left out of the request.
+type DryRunner interface {
+	DryRun(context.Context, *job.Job) ([]byte, error)
+}
+
+// Simulator is a Provider that only pretends to transcode, so the
+// outputs it reports are never written and aren't verified
+type Simulator interface {
+	Simulated()
+}
+
 // Factory is the function responsible for creating the instance of a
 // provider.
 type Factory func(cfg *config.Config) (Provider, error)
@@ -50,6 +68,8 @@ func (err JobNotFoundError) Error() string {
 
 // Register register a new provider in the internal list of providers.
 func Register(name string, provider Factory) error {
+	mu.Lock()
+	defer mu.Unlock()
 	if _, ok := providers[name]; ok {
 		return ErrRegistered
 	}
@@ -57,10 +77,53 @@ func Register(name string, provider Factory) error {
 	return nil
 }
 
+// RegisterInstances registers every instance in the config under its
+// own name. Each is built by its driver's factory, from the instance's
+// section of the config passed to the factory at the time. It's called
+// again after each reload: instances it registered before are kept, or
+// replaced if their driver changed, and those no longer in the config
+// stay registered but can't be built, so List leaves them out.
+func RegisterInstances(c *config.Config) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if instances == nil {
+		instances = map[string]string{}
+	}
+	for _, name := range c.InstanceNames() {
+		d := c.Instances[name].Driver
+		driver, ok := providers[d]
+		if !ok {
+			return fmt.Errorf("instance %q: driver %q: %w", name, d, ErrNotFound)
+		}
+		prev, seen := instances[name]
+		if seen && prev == d {
+			continue
+		}
+		if _, taken := providers[name]; taken && !seen {
+			return fmt.Errorf("instance %q: %w", name, ErrRegistered)
+		}
+		providers[name] = instance(name, driver)
+		instances[name] = d
+	}
+	return nil
+}
+
+func instance(name string, driver Factory) Factory {
+	return func(c *config.Config) (Provider, error) {
+		ic, err := c.Instance(name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrConfig, err)
+		}
+		return driver(ic)
+	}
+}
+
 // GetProviderFactory looks up the list of registered providers and returns the
 // factory function for the given provider name, if it's available.
 func GetFactory(name string) (Factory, error) {
+	mu.RLock()
 	factory, ok := providers[name]
+	mu.RUnlock()
 	if !ok {
 		return nil, ErrNotFound
 	}
@@ -70,8 +133,14 @@ func GetFactory(name string) (Factory, error) {
 // List returns the list of currently registered providers,
 // alphabetically ordered.
 func List(c *config.Config) []string {
-	providerNames := make([]string, 0, len(providers))
+	mu.RLock()
+	factories := make(map[string]Factory, len(providers))
 	for name, factory := range providers {
+		factories[name] = factory
+	}
+	mu.RUnlock()
+	providerNames := make([]string, 0, len(factories))
+	for name, factory := range factories {
 		if _, err := factory(c); err == nil {
 			providerNames = append(providerNames, name)
 		}
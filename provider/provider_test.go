@@ -90,3 +90,62 @@ func TestDescribe(t *testing.T) {
 		t.Errorf("Unexpected non-nil description: %#v", description)
 	}
 }
+
+func TestRegisterInstances(t *testing.T) {
+	var got []string
+	providers = map[string]Factory{
+		"flock": func(c *config.Config) (Provider, error) {
+			got = append(got, c.Flock.Endpoint)
+			return &fake{}, nil
+		},
+	}
+	instances = nil
+	c := &config.Config{
+		Flock: &config.Flock{Endpoint: "https://base", Credential: "c"},
+		Instances: map[string]*config.Instance{
+			"flock-2": {Driver: "flock", Flock: &config.Flock{Endpoint: "https://two"}},
+		},
+	}
+	if err := RegisterInstances(c); err != nil {
+		t.Fatal(err)
+	}
+	if have := List(c); !reflect.DeepEqual(have, []string{"flock", "flock-2"}) {
+		t.Fatalf("list: have %v", have)
+	}
+	f, err := GetFactory("flock-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	f(c)
+	if !reflect.DeepEqual(got, []string{"https://two"}) {
+		t.Fatalf("instance built with wrong section: %v", got)
+	}
+
+	// a reload registers the config's instances again, and any new ones
+	if err := RegisterInstances(c); err != nil {
+		t.Fatalf("register twice: %v", err)
+	}
+	c.Instances["flock-3"] = &config.Instance{Driver: "flock", Flock: &config.Flock{Endpoint: "https://three"}}
+	if err := RegisterInstances(c); err != nil {
+		t.Fatalf("register new instance: %v", err)
+	}
+	if have := List(c); !reflect.DeepEqual(have, []string{"flock", "flock-2", "flock-3"}) {
+		t.Fatalf("list after reload: have %v", have)
+	}
+	delete(c.Instances, "flock-3")
+	if have := List(c); !reflect.DeepEqual(have, []string{"flock", "flock-2"}) {
+		t.Fatalf("list after removal: have %v", have)
+	}
+
+	c.Instances["flock"] = &config.Instance{Driver: "flock"}
+	if err := RegisterInstances(c); !errors.Is(err, ErrRegistered) {
+		t.Fatalf("instance named after a driver: have %v, want %v", err, ErrRegistered)
+	}
+	delete(c.Instances, "flock")
+	c.Instances["x"] = &config.Instance{Driver: "missing"}
+	delete(c.Instances, "flock-2")
+	if err := RegisterInstances(c); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unknown driver: have %v, want %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,362 @@
+package simulator
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/logging"
+	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/cbsinteractive/transcode-orchestrator/trace"
+	"github.com/zsiec/pkg/tracing"
+)
+
+// Name identifies the simulator provider by name
+const Name = "simulator"
+
+// Labels on a job decide what happens to it, regardless of the rates
+// in the config
+const (
+	LabelFail   = "simulator-fail"   // fails while running
+	LabelCancel = "simulator-cancel" // cancels itself while running
+	LabelSlow   = "simulator-slow"   // every request takes the latency
+	LabelReject = "simulator-reject" // creating it fails
+)
+
+// duration is how long the simulated input is, unless it's spliced
+const duration = time.Minute
+
+var ErrRejected = errors.New("simulated rejection")
+
+func init() {
+	err := provider.Register(Name, factory)
+	if err != nil {
+		logging.From(context.Background()).WithError(err).Error("registering simulator factory")
+	}
+}
+
+// canceled holds the time each job was canceled at. Everything else about
+// a job is in its id, so any instance of the orchestrator can answer for
+// it, but a cancel is only known to the instance that received it, until
+// it restarts.
+var canceled = &cancels{at: map[string]time.Time{}}
+
+type cancels struct {
+	sync.Mutex
+	at map[string]time.Time
+}
+
+func (c *cancels) set(id string, t time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	for id, at := range c.at {
+		if t.Sub(at) > 24*time.Hour {
+			delete(c.at, id)
+		}
+	}
+	if _, ok := c.at[id]; !ok {
+		c.at[id] = t
+	}
+}
+
+func (c *cancels) get(id string) (time.Time, bool) {
+	c.Lock()
+	defer c.Unlock()
+	t, ok := c.at[id]
+	return t, ok
+}
+
+// float is a seeded source of the fates, safe for concurrent use
+var float = func() func() float64 {
+	var mu sync.Mutex
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return func() float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return r.Float64()
+	}
+}()
+
+type driver struct {
+	cfg      *config.Simulator
+	canceled *cancels
+	now      func() time.Time
+	rand     func() float64
+	tracer   tracing.Tracer
+}
+
+func (p *driver) trace(ctx context.Context, name string, err *error) func() {
+	_, x := trace.Begin(ctx, p.tracer, name)
+	return func() {
+		x.Close(*err)
+		logging.Done(ctx, name, *err)
+	}
+}
+
+// fate is what happens to a simulated job
+type fate struct {
+	created time.Time
+	end     job.State // finished, failed or canceled
+	at      int       // percent of progress where a failure or cancel happens
+	slow    bool
+	nonce   string
+}
+
+// id encodes the fate as the provider's job id
+func (f fate) id() string {
+	slow := "fast"
+	if f.slow {
+		slow = "slow"
+	}
+	return strings.Join([]string{
+		strconv.FormatInt(f.created.UnixNano()/int64(time.Millisecond), 10),
+		string(f.end), strconv.Itoa(f.at), slow, f.nonce,
+	}, "-")
+}
+
+func parse(id string) (f fate, ok bool) {
+	part := strings.Split(id, "-")
+	if len(part) != 5 {
+		return f, false
+	}
+	ms, err1 := strconv.ParseInt(part[0], 10, 64)
+	at, err2 := strconv.Atoi(part[2])
+	if err1 != nil || err2 != nil {
+		return f, false
+	}
+	f = fate{
+		created: time.Unix(0, ms*int64(time.Millisecond)),
+		end:     job.State(part[1]),
+		at:      at,
+		slow:    part[3] == "slow",
+		nonce:   part[4],
+	}
+	switch f.end {
+	case job.StateFinished, job.StateFailed, job.StateCanceled:
+		return f, true
+	}
+	return f, false
+}
+
+func (p *driver) Create(ctx context.Context, j *job.Job) (st *job.Status, err error) {
+	defer p.trace(ctx, "simulator-create-job", &err)()
+
+	// the simulator reads and writes anything, but a job elsewhere
+	// wouldn't get past storage it doesn't know
+	if j.Input.Name != "" {
+		if _, err = storage.Parse(j.Input.Name); err != nil {
+			return nil, fmt.Errorf("input: %w", err)
+		}
+	}
+	if _, err = storage.Parse(p.location(*j, "")); err != nil {
+		return nil, fmt.Errorf("output: %w", err)
+	}
+
+	f := fate{
+		created: p.now(),
+		end:     job.StateFinished,
+		at:      10 + int(p.rand()*80),
+		slow:    labeled(j, LabelSlow) || p.rand() < p.cfg.LatencyRate,
+	}
+	if f.nonce, err = nonce(); err != nil {
+		return nil, fmt.Errorf("generating job id: %w", err)
+	}
+	switch {
+	case labeled(j, LabelFail) || p.rand() < p.cfg.FailRate:
+		f.end = job.StateFailed
+	case labeled(j, LabelCancel) || p.rand() < p.cfg.CancelRate:
+		f.end = job.StateCanceled
+	}
+	if err = p.wait(ctx, f); err != nil {
+		return nil, err
+	}
+	if labeled(j, LabelReject) {
+		return nil, ErrRejected
+	}
+	return &job.Status{
+		Provider:      Name,
+		ProviderJobID: f.id(),
+		State:         job.StateQueued,
+	}, nil
+}
+
+func nonce() (string, error) {
+	b := make([]byte, 4)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func labeled(j *job.Job, label string) bool {
+	for _, l := range j.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// wait takes the latency for slow jobs
+func (p *driver) wait(ctx context.Context, f fate) error {
+	if !f.slow || p.cfg.Latency <= 0 {
+		return nil
+	}
+	t := time.NewTimer(time.Duration(p.cfg.Latency) * time.Millisecond)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *driver) Status(ctx context.Context, j *job.Job) (st *job.Status, err error) {
+	defer p.trace(ctx, "simulator-get-job", &err)()
+
+	f, ok := parse(j.ProviderJobID)
+	if !ok {
+		return nil, provider.JobNotFoundError{ID: j.ProviderJobID}
+	}
+	if err = p.wait(ctx, f); err != nil {
+		return nil, err
+	}
+
+	st = &job.Status{
+		Provider:      Name,
+		ProviderJobID: j.ProviderJobID,
+		Labels:        j.Labels,
+		Output:        job.Dir{Path: p.location(*j, "")},
+		ProviderStatus: map[string]interface{}{
+			"fate": string(f.end),
+			"at":   f.at,
+			"slow": f.slow,
+		},
+	}
+	st.State, st.Progress = p.progress(f, p.now())
+	if t, ok := p.canceled.get(j.ProviderJobID); ok {
+		// canceled at its progress then, unless it had ended
+		if s, pct := p.progress(f, t); !s.Terminal() {
+			st.State, st.Progress = job.StateCanceled, pct
+		}
+	}
+	switch st.State {
+	case job.StateFailed:
+		st.Msg = fmt.Sprintf("simulated failure at %d%%", f.at)
+	case job.StateFinished:
+		st.Output.File = p.outputs(j)
+	}
+	return st, nil
+}
+
+// progress is the state and progress of the job at t
+func (p *driver) progress(f fate, t time.Time) (job.State, float64) {
+	queue := time.Duration(p.cfg.QueueTime) * time.Millisecond
+	run := time.Duration(p.cfg.RunTime) * time.Millisecond
+	elapsed := t.Sub(f.created)
+	if elapsed < queue {
+		return job.StateQueued, 0
+	}
+	pct := 100.0
+	if run > 0 {
+		pct = 100 * float64(elapsed-queue) / float64(run)
+	}
+	if f.end != job.StateFinished && pct >= float64(f.at) {
+		return f.end, float64(f.at)
+	}
+	if pct >= 100 {
+		return job.StateFinished, 100
+	}
+	return job.StateStarted, pct
+}
+
+// outputs describes the files the job would have made, sized by their
+// bitrates
+func (p *driver) outputs(j *job.Job) (files []job.File) {
+	dur := duration
+	if len(j.Input.Splice) > 0 {
+		dur = 0
+		for _, r := range j.Input.Splice {
+			dur += r.Canon().Size()
+		}
+	}
+	for _, f := range j.Output.File {
+		out := job.File{
+			Name:      p.location(*j, f.Name),
+			Container: f.Container,
+			Duration:  dur,
+			Size:      int64(float64(f.Video.Bitrate.BPS+f.Audio.Bitrate) * dur.Seconds() / 8),
+		}
+		if out.Container == "" {
+			out.Container = f.Type()
+		}
+		if f.Video.On() {
+			out.Video = job.Video{Codec: f.Video.Codec, Width: f.Video.Width, Height: f.Video.Height, FPS: f.Video.FPS}
+		}
+		if f.Audio.On() {
+			out.Audio = job.Audio{Codec: f.Audio.Codec}
+		}
+		files = append(files, out)
+	}
+	return files
+}
+
+func (p *driver) location(j job.Job, file string) string {
+	if j.Output.Path == "" {
+		j.Output.Path = p.cfg.Destination
+	}
+	return j.Location(file)
+}
+
+func (p *driver) Cancel(ctx context.Context, id string) (err error) {
+	defer p.trace(ctx, "simulator-cancel-job", &err)()
+
+	f, ok := parse(id)
+	if !ok {
+		return provider.JobNotFoundError{ID: id}
+	}
+	if err = p.wait(ctx, f); err != nil {
+		return err
+	}
+	p.canceled.set(id, p.now())
+	return nil
+}
+
+func (p *driver) Healthcheck() error {
+	return nil
+}
+
+// Simulated marks the driver as a provider.Simulator
+func (*driver) Simulated() {}
+
+func (*driver) Capabilities() provider.Capabilities {
+	return provider.Capabilities{
+		InputFormats:  []string{"h264", "h265", "vp8", "vp9", "mpeg2", "prores"},
+		OutputFormats: []string{"mp4", "mov", "webm", "ts", "mxf", "hls", "dash", "cmaf"},
+		Destinations:  storage.Schemes,
+	}
+}
+
+func factory(cfg *config.Config) (provider.Provider, error) {
+	if cfg.Simulator == nil || !cfg.Simulator.Enabled {
+		return nil, errors.New("simulator is disabled")
+	}
+	return &driver{
+		cfg:      cfg.Simulator,
+		canceled: canceled,
+		now:      time.Now,
+		rand:     float,
+		tracer:   cfg.Tracer,
+	}, nil
+}
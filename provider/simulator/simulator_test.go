@@ -0,0 +1,218 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cbsinteractive/pkg/timecode"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/google/go-cmp/cmp"
+)
+
+var epoch = time.Unix(1600000000, 0)
+
+// newDriver returns a simulator where jobs queue for a second and run
+// for ten, and the clock only moves when told to. Every random number
+// is r.
+func newDriver(r float64) (*driver, *time.Time) {
+	now := epoch
+	return &driver{
+		cfg: &config.Simulator{
+			Enabled: true, QueueTime: 1000, RunTime: 10000, Latency: 50,
+			FailRate: 0.1, CancelRate: 0.1, LatencyRate: 0.1, Destination: "s3://sim",
+		},
+		canceled: &cancels{at: map[string]time.Time{}},
+		now:      func() time.Time { return now },
+		rand:     func() float64 { return r },
+	}, &now
+}
+
+func status(t *testing.T, p *driver, j *job.Job) *job.Status {
+	t.Helper()
+	st, err := p.Status(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func TestLifecycle(t *testing.T) {
+	p, now := newDriver(0.5)
+	j := &job.Job{
+		ID:    "abc",
+		Input: job.File{Name: "s3://in/a.mov", Splice: timecode.Splice{{0, 30}}},
+		Output: job.Dir{File: []job.File{
+			{Name: "hd.mp4", Video: job.Video{Codec: "h264", Width: 1920, Height: 1080, Bitrate: job.Bitrate{BPS: 4000000}}, Audio: job.Audio{Codec: "aac", Bitrate: 128000}},
+			{Name: "audio.m4a", Audio: job.Audio{Codec: "aac", Bitrate: 64000}},
+		}},
+	}
+	st, err := p.Create(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.State != job.StateQueued {
+		t.Fatalf("created job is %q", st.State)
+	}
+	j.ProviderJobID = st.ProviderJobID
+
+	for _, tt := range []struct {
+		at    time.Duration
+		state job.State
+		pct   float64
+	}{
+		{500 * time.Millisecond, job.StateQueued, 0},
+		{1 * time.Second, job.StateStarted, 0},
+		{3500 * time.Millisecond, job.StateStarted, 25},
+		{11 * time.Second, job.StateFinished, 100},
+	} {
+		*now = epoch.Add(tt.at)
+		st := status(t, p, j)
+		if st.State != tt.state || st.Progress != tt.pct {
+			t.Fatalf("at %v: have %q %v, want %q %v", tt.at, st.State, st.Progress, tt.state, tt.pct)
+		}
+	}
+
+	want := job.Dir{Path: "s3://sim/abc", File: []job.File{
+		{
+			Name: "s3://sim/abc/hd.mp4", Container: "mp4", Duration: 30 * time.Second, Size: 15480000,
+			Video: job.Video{Codec: "h264", Width: 1920, Height: 1080}, Audio: job.Audio{Codec: "aac"},
+		},
+		{Name: "s3://sim/abc/audio.m4a", Container: "m4a", Duration: 30 * time.Second, Size: 240000, Audio: job.Audio{Codec: "aac"}},
+	}}
+	if diff := cmp.Diff(want, status(t, p, j).Output); diff != "" {
+		t.Fatalf("output (-want +have):\n%s", diff)
+	}
+}
+
+func TestFates(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		rand   float64
+		labels []string
+		state  job.State
+		msg    string
+	}{
+		{name: "Finish", rand: 0.5, state: job.StateFinished},
+		{name: "FailRate", rand: 0.05, state: job.StateFailed, msg: "simulated failure at 14%"},
+		{name: "FailLabel", rand: 0.5, labels: []string{LabelFail}, state: job.StateFailed, msg: "simulated failure at 50%"},
+		{name: "CancelLabel", rand: 0.5, labels: []string{LabelCancel}, state: job.StateCanceled},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p, now := newDriver(tt.rand)
+			p.cfg.Latency = 0
+			j := &job.Job{Labels: tt.labels}
+			st, err := p.Create(context.Background(), j)
+			if err != nil {
+				t.Fatal(err)
+			}
+			j.ProviderJobID = st.ProviderJobID
+			*now = epoch.Add(time.Hour)
+			if st = status(t, p, j); st.State != tt.state || st.Msg != tt.msg {
+				t.Fatalf("have %q %q, want %q %q", st.State, st.Msg, tt.state, tt.msg)
+			}
+			if st.State != job.StateFinished && st.Progress != float64(10+int(tt.rand*80)) {
+				t.Fatalf("ended at %v%%", st.Progress)
+			}
+		})
+	}
+}
+
+func TestReject(t *testing.T) {
+	p, _ := newDriver(0.5)
+	if _, err := p.Create(context.Background(), &job.Job{Labels: []string{LabelReject}}); !errors.Is(err, ErrRejected) {
+		t.Fatalf("have %v, want %v", err, ErrRejected)
+	}
+}
+
+func TestStorage(t *testing.T) {
+	p, _ := newDriver(0.5)
+	for name, j := range map[string]*job.Job{
+		"input":  {Input: job.File{Name: "ftp://host/in.mov"}},
+		"output": {Output: job.Dir{Path: "ftp://host/out"}},
+	} {
+		if _, err := p.Create(context.Background(), j); !errors.Is(err, storage.ErrUnsupported) {
+			t.Errorf("%s: have %v, want %v", name, err, storage.ErrUnsupported)
+		}
+	}
+}
+
+func TestLatency(t *testing.T) {
+	p, _ := newDriver(0.5)
+	start := time.Now()
+	st, err := p.Create(context.Background(), &job.Job{Labels: []string{LabelSlow}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Fatal("slow job answered without latency")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Status(ctx, &job.Job{ProviderJobID: st.ProviderJobID}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("have %v, want %v", err, context.Canceled)
+	}
+
+	start = time.Now()
+	st, _ = p.Create(context.Background(), &job.Job{})
+	status(t, p, &job.Job{ProviderJobID: st.ProviderJobID})
+	if time.Since(start) >= 50*time.Millisecond {
+		t.Fatal("fast job took the latency")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	p, now := newDriver(0.5)
+	j := &job.Job{}
+	st, _ := p.Create(context.Background(), j)
+	j.ProviderJobID = st.ProviderJobID
+
+	*now = epoch.Add(6 * time.Second)
+	if err := p.Cancel(context.Background(), j.ProviderJobID); err != nil {
+		t.Fatal(err)
+	}
+	*now = epoch.Add(time.Hour)
+	if st := status(t, p, j); st.State != job.StateCanceled || st.Progress != 50 {
+		t.Fatalf("have %q %v, want canceled at 50", st.State, st.Progress)
+	}
+
+	// canceling a finished job changes nothing
+	done := &job.Job{}
+	st, _ = p.Create(context.Background(), done)
+	done.ProviderJobID = st.ProviderJobID
+	*now = now.Add(time.Hour)
+	p.Cancel(context.Background(), done.ProviderJobID)
+	if st := status(t, p, done); st.State != job.StateFinished {
+		t.Fatalf("have %q, want finished", st.State)
+	}
+
+	for _, id := range []string{"", "42", "1-bogus-1-fast-x"} {
+		if err := p.Cancel(context.Background(), id); !errors.As(err, &provider.JobNotFoundError{}) {
+			t.Errorf("cancel %q: have %v, want JobNotFoundError", id, err)
+		}
+		if _, err := p.Status(context.Background(), &job.Job{ProviderJobID: id}); !errors.As(err, &provider.JobNotFoundError{}) {
+			t.Errorf("status %q: have %v, want JobNotFoundError", id, err)
+		}
+	}
+}
+
+func TestFactory(t *testing.T) {
+	if _, err := factory(&config.Config{Simulator: &config.Simulator{}}); err == nil {
+		t.Fatal("disabled simulator was built")
+	}
+	p, err := factory(&config.Config{Simulator: &config.Simulator{Enabled: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Healthcheck(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.(provider.Simulator); !ok {
+		t.Fatal("simulator isn't marked simulated, so its outputs would be verified")
+	}
+}
@@ -0,0 +1,444 @@
+package zencoder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/logging"
+	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/cbsinteractive/transcode-orchestrator/trace"
+	"github.com/zsiec/pkg/tracing"
+)
+
+// Name identifies the Zencoder provider by name
+const Name = "zencoder"
+
+var (
+	ErrUnsupported = errors.New("unsupported")
+	errNotFound    = errors.New("not found")
+)
+
+// sources and destinations are the storage Zencoder reads and writes
+var (
+	sources      = []string{storage.S3, storage.GCS, storage.HTTP, storage.HTTPS}
+	destinations = []string{storage.S3, storage.GCS, storage.HTTP}
+)
+
+func init() {
+	err := provider.Register(Name, factory)
+	if err != nil {
+		logging.From(context.Background()).WithError(err).Error("registering zencoder factory")
+	}
+}
+
+type driver struct {
+	cfg    *config.Zencoder
+	client *http.Client
+	tracer tracing.Tracer
+}
+
+func (p *driver) trace(ctx context.Context, name string, err *error) func() {
+	_, x := trace.Begin(ctx, p.tracer, name)
+	return func() {
+		x.Close(*err)
+		logging.Done(ctx, name, *err)
+	}
+}
+
+// Request is a Zencoder job
+type Request struct {
+	Input       string   `json:"input"`
+	PassThrough string   `json:"pass_through,omitempty"`
+	Outputs     []Output `json:"outputs"`
+}
+
+// Output is a single output of a Zencoder job
+type Output struct {
+	Label  string `json:"label,omitempty"`
+	URL    string `json:"url"`
+	Format string `json:"format,omitempty"`
+
+	SkipVideo        bool    `json:"skip_video,omitempty"`
+	VideoCodec       string  `json:"video_codec,omitempty"`
+	H264Profile      string  `json:"h264_profile,omitempty"`
+	H264Level        string  `json:"h264_level,omitempty"`
+	Width            int     `json:"width,omitempty"`
+	Height           int     `json:"height,omitempty"`
+	FrameRate        float64 `json:"frame_rate,omitempty"`
+	VideoBitrate     int     `json:"video_bitrate,omitempty"`
+	ConstantBitrate  bool    `json:"constant_bitrate,omitempty"`
+	OnePass          bool    `json:"one_pass,omitempty"`
+	KeyframeInterval int     `json:"keyframe_interval,omitempty"`
+	KeyframeRate     float64 `json:"keyframe_rate,omitempty"`
+	Deinterlace      string  `json:"deinterlace,omitempty"`
+	CropLeft         int     `json:"crop_left,omitempty"`
+	CropTop          int     `json:"crop_top,omitempty"`
+	CropRight        int     `json:"crop_right,omitempty"`
+	CropBottom       int     `json:"crop_bottom,omitempty"`
+
+	SkipAudio     bool   `json:"skip_audio,omitempty"`
+	AudioCodec    string `json:"audio_codec,omitempty"`
+	AudioBitrate  int    `json:"audio_bitrate,omitempty"`
+	AudioChannels int    `json:"audio_channels,omitempty"`
+
+	StartClip  string `json:"start_clip,omitempty"`
+	ClipLength string `json:"clip_length,omitempty"`
+}
+
+// Created is Zencoder's response to a new job
+type Created struct {
+	ID int64 `json:"id"`
+}
+
+// Progress is the state of a job while it runs
+type Progress struct {
+	State        string  `json:"state"`
+	Progress     float64 `json:"progress"`
+	CurrentEvent string  `json:"current_event"`
+}
+
+// Details is the full description of a job
+type Details struct {
+	Job struct {
+		State   string      `json:"state"`
+		Input   MediaFile   `json:"input_media_file"`
+		Outputs []MediaFile `json:"output_media_files"`
+	} `json:"job"`
+}
+
+// MediaFile is an input or output file of a job
+type MediaFile struct {
+	Label        string `json:"label"`
+	URL          string `json:"url"`
+	State        string `json:"state"`
+	ErrorMessage string `json:"error_message"`
+	Size         int64  `json:"file_size_bytes"`
+	DurationMS   int64  `json:"duration_in_ms"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	VideoCodec   string `json:"video_codec"`
+}
+
+func (p *driver) Create(ctx context.Context, j *job.Job) (*job.Status, error) {
+	rq, err := p.create(j)
+	if err != nil {
+		return nil, err
+	}
+
+	var created Created
+	done := p.trace(ctx, "zencoder-create-job", &err)
+	err = p.do(ctx, http.MethodPost, "/jobs", rq, &created)
+	done()
+	if err != nil {
+		return nil, fmt.Errorf("submitting new job: %w", err)
+	}
+
+	return &job.Status{
+		Provider:      Name,
+		ProviderJobID: strconv.FormatInt(created.ID, 10),
+		State:         job.StateQueued,
+	}, nil
+}
+
+// DryRun returns the request Create would submit
+func (p *driver) DryRun(_ context.Context, j *job.Job) ([]byte, error) {
+	rq, err := p.create(j)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(rq, "", "\t")
+}
+
+// create returns the request for j after checking its storage
+func (p *driver) create(j *job.Job) (*Request, error) {
+	rq, err := p.request(j)
+	if err != nil {
+		return nil, fmt.Errorf("generating zencoder job request: %w", err)
+	}
+	if _, err = storage.Check(j.Input.Name, sources...); err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	if _, err = storage.Check(p.location(*j, ""), destinations...); err != nil {
+		return nil, fmt.Errorf("output: %w", err)
+	}
+	return rq, nil
+}
+
+func (p *driver) request(j *job.Job) (*Request, error) {
+	rq := &Request{
+		Input:       j.Input.Name,
+		PassThrough: strings.Join(j.Labels, ","),
+	}
+	start, length, err := clip(j.Input)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range j.Output.File {
+		o, err := p.output(*j, f)
+		if err != nil {
+			return nil, fmt.Errorf("output %q: %w", f.Name, err)
+		}
+		o.StartClip, o.ClipLength = start, length
+		rq.Outputs = append(rq.Outputs, o)
+	}
+	return rq, nil
+}
+
+// label names the output in Zencoder's notifications and dashboard,
+// prefixed with the job's labels, as in "a,b:hd.mp4". Zencoder only
+// keeps one label per output, and pass_through only on the job.
+func label(j job.Job, f job.File) string {
+	if len(j.Labels) == 0 {
+		return f.Name
+	}
+	return strings.Join(j.Labels, ",") + ":" + f.Name
+}
+
+func (p *driver) output(j job.Job, f job.File) (Output, error) {
+	o := Output{
+		Label:  label(j, f),
+		URL:    p.location(j, f.Name),
+		Format: f.Container,
+	}
+	if o.Format == "" {
+		o.Format = f.Type()
+	}
+
+	v := f.Video
+	if !v.On() {
+		o.SkipVideo = true
+	} else {
+		codec, ok := videoCodecs[strings.ToLower(v.Codec)]
+		if !ok {
+			return o, fmt.Errorf("%w: video codec %q", ErrUnsupported, v.Codec)
+		}
+		o.VideoCodec = codec
+		if codec == "h264" {
+			o.H264Profile = strings.ToLower(v.Profile)
+			o.H264Level = v.Level
+		}
+		o.Width, o.Height = v.Width, v.Height
+		o.FrameRate = v.FPS
+		o.VideoBitrate = v.Bitrate.Kbps()
+		o.ConstantBitrate = strings.EqualFold(v.Bitrate.Control, "CBR")
+		o.OnePass = o.VideoBitrate != 0 && !v.Bitrate.TwoPass
+		if v.Gop.Seconds() {
+			if v.Gop.Size > 0 {
+				o.KeyframeRate = 1 / v.Gop.Size
+			}
+		} else {
+			o.KeyframeInterval = int(v.Gop.Size)
+		}
+		if v.Scantype == job.ScanInterlaced {
+			o.Deinterlace = "on"
+		}
+		o.CropLeft, o.CropTop = v.Crop.Left, v.Crop.Top
+		o.CropRight, o.CropBottom = v.Crop.Right, v.Crop.Bottom
+	}
+
+	if a := f.Audio; !a.On() {
+		o.SkipAudio = true
+	} else {
+		o.AudioCodec = strings.ToLower(a.Codec)
+		o.AudioBitrate = a.Bitrate / 1000
+		if f.Downmix != nil {
+			o.AudioChannels = len(f.Downmix.Dst)
+		}
+	}
+	return o, nil
+}
+
+var videoCodecs = map[string]string{
+	"h264": "h264",
+	"avc":  "h264",
+	"h265": "hevc",
+	"hevc": "hevc",
+	"vp8":  "vp8",
+	"vp9":  "vp9",
+}
+
+// clip converts the input's splice to a start and length in seconds.
+// Zencoder clips each output to one range only.
+func clip(f job.File) (start, length string, err error) {
+	switch len(f.Splice) {
+	case 0:
+		return "", "", nil
+	case 1:
+	default:
+		return "", "", fmt.Errorf("%w: splice with %d ranges", ErrUnsupported, len(f.Splice))
+	}
+	r := f.Splice[0].Canon()
+	sec := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	return sec(r[0]), sec(r[1] - r[0]), nil
+}
+
+func (p *driver) location(j job.Job, file string) string {
+	if j.Output.Path == "" {
+		j.Output.Path = p.cfg.Destination
+	}
+	return j.Location(file)
+}
+
+func (p *driver) Status(ctx context.Context, j *job.Job) (st *job.Status, err error) {
+	defer p.trace(ctx, "zencoder-get-job", &err)()
+
+	var pr Progress
+	err = p.do(ctx, http.MethodGet, "/jobs/"+j.ProviderJobID+"/progress.json", nil, &pr)
+	if errors.Is(err, errNotFound) {
+		return nil, provider.JobNotFoundError{ID: j.ProviderJobID}
+	} else if err != nil {
+		return nil, fmt.Errorf("querying for provider job %s: %w", j.ProviderJobID, err)
+	}
+
+	st = &job.Status{
+		Provider:      Name,
+		ProviderJobID: j.ProviderJobID,
+		State:         state(pr.State),
+		Progress:      pr.Progress,
+		Labels:        j.Labels,
+		Output:        job.Dir{Path: p.location(*j, "")},
+		ProviderStatus: map[string]interface{}{
+			"state":         pr.State,
+			"progress":      pr.Progress,
+			"current_event": pr.CurrentEvent,
+		},
+	}
+	if !st.State.Terminal() || st.State == job.StateCanceled {
+		return st, nil
+	}
+
+	var d Details
+	err = p.do(ctx, http.MethodGet, "/jobs/"+j.ProviderJobID+".json", nil, &d)
+	if err != nil {
+		return nil, fmt.Errorf("describing provider job %s: %w", j.ProviderJobID, err)
+	}
+	if st.State == job.StateFinished {
+		st.Progress = 100
+	}
+	st.Input = file(d.Job.Input)
+	for _, o := range d.Job.Outputs {
+		if o.ErrorMessage != "" && st.Msg == "" {
+			st.Msg = o.ErrorMessage
+		}
+		if o.State == "finished" {
+			st.Output.Add(file(o))
+		}
+	}
+	if st.Msg == "" {
+		st.Msg = d.Job.Input.ErrorMessage
+	}
+	return st, nil
+}
+
+func file(m MediaFile) job.File {
+	return job.File{
+		Name:     m.URL,
+		Size:     m.Size,
+		Duration: time.Duration(m.DurationMS) * time.Millisecond,
+		Video:    job.Video{Width: m.Width, Height: m.Height, Codec: m.VideoCodec},
+	}
+}
+
+func state(s string) job.State {
+	switch s {
+	case "pending", "waiting":
+		return job.StateQueued
+	case "processing":
+		return job.StateStarted
+	case "finished":
+		return job.StateFinished
+	case "failed":
+		return job.StateFailed
+	case "cancelled":
+		return job.StateCanceled
+	}
+	return job.StateUnknown
+}
+
+func (p *driver) Cancel(ctx context.Context, id string) (err error) {
+	defer p.trace(ctx, "zencoder-cancel-job", &err)()
+	err = p.do(ctx, http.MethodPut, "/jobs/"+id+"/cancel.json", nil, nil)
+	if errors.Is(err, errNotFound) {
+		err = provider.JobNotFoundError{ID: id}
+	}
+	return err
+}
+
+func (p *driver) Healthcheck() error {
+	return p.do(context.Background(), http.MethodGet, "/account", nil, nil)
+}
+
+func (*driver) Capabilities() provider.Capabilities {
+	return provider.Capabilities{
+		InputFormats:  []string{"h264", "h265", "vp8", "vp9"},
+		OutputFormats: []string{"mp4", "webm", "mov", "ts"},
+		Destinations:  destinations,
+	}
+}
+
+// do sends in, if any, as json and decodes the response into out, if any
+func (p *driver) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("marshaling request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.cfg.Endpoint+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Zencoder-Api-Key", p.cfg.APIKey)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %w", err)
+	}
+	if c := resp.StatusCode; c == http.StatusNotFound {
+		return errNotFound
+	} else if c/100 != 2 {
+		return fmt.Errorf("received non 2xx status code, got %d with body: %s", c, string(data))
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("parsing zencoder response: %w", err)
+	}
+	return nil
+}
+
+func factory(cfg *config.Config) (provider.Provider, error) {
+	if cfg.Zencoder == nil || cfg.Zencoder.APIKey == "" {
+		return nil, errors.New("incomplete Zencoder config")
+	}
+	return &driver{
+		cfg:    cfg.Zencoder,
+		client: &http.Client{Timeout: time.Second * 30},
+		tracer: cfg.Tracer,
+	}, nil
+}
@@ -0,0 +1,293 @@
+package zencoder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cbsinteractive/pkg/timecode"
+	"github.com/cbsinteractive/pkg/video"
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/google/go-cmp/cmp"
+)
+
+// server stands in for the Zencoder API, answering each "METHOD path"
+// with its canned body and recording the last request it saw
+type server struct {
+	*httptest.Server
+	routes map[string]string
+	method string
+	path   string
+	key    string
+	body   []byte
+}
+
+func newServer(t *testing.T, routes map[string]string) (*server, *driver) {
+	s := &server{routes: routes}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.method, s.path, s.key = r.Method, r.URL.Path, r.Header.Get("Zencoder-Api-Key")
+		s.body, _ = ioutil.ReadAll(r.Body)
+		body, ok := routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(s.Close)
+	return s, &driver{
+		cfg:    &config.Zencoder{APIKey: "key", Endpoint: s.URL, Destination: "s3://bucket/out/"},
+		client: s.Client(),
+	}
+}
+
+func TestCreate(t *testing.T) {
+	s, p := newServer(t, map[string]string{"POST /jobs": `{"id": 1234}`})
+	j := &job.Job{
+		ID:     "abc",
+		Labels: []string{"a", "b"},
+		Input: job.File{
+			Name:   "s3://bucket/in.mov",
+			Splice: timecode.Splice{{10, 25.5}},
+		},
+		Output: job.Dir{File: []job.File{
+			{
+				Name: "hd.mp4",
+				Video: job.Video{
+					Codec: "h264", Profile: "High", Level: "4.1",
+					Width: 1920, Height: 1080, FPS: 30,
+					Bitrate:  job.Bitrate{BPS: 5000000, Control: "CBR"},
+					Gop:      job.Gop{Unit: "seconds", Size: 2},
+					Scantype: job.ScanInterlaced,
+					Crop:     video.Crop{Left: 8, Right: 8},
+				},
+				Audio:   job.Audio{Codec: "AAC", Bitrate: 128000},
+				Downmix: &job.Downmix{Dst: make([]job.AudioChannel, 2)},
+			},
+			{
+				Name:  "sd.webm",
+				Video: job.Video{Codec: "vp9", Height: 480, Bitrate: job.Bitrate{BPS: 1000000, TwoPass: true}, Gop: job.Gop{Size: 60}},
+			},
+		}},
+	}
+
+	st, err := p.Create(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.ProviderJobID != "1234" || st.State != job.StateQueued {
+		t.Fatalf("bad status: %+v", st)
+	}
+	if s.key != "key" {
+		t.Fatalf("api key: have %q", s.key)
+	}
+
+	var have Request
+	if err := json.Unmarshal(s.body, &have); err != nil {
+		t.Fatal(err)
+	}
+	want := Request{
+		Input:       "s3://bucket/in.mov",
+		PassThrough: "a,b",
+		Outputs: []Output{
+			{
+				Label: "a,b:hd.mp4", URL: "s3://bucket/out/abc/hd.mp4", Format: "mp4",
+				VideoCodec: "h264", H264Profile: "high", H264Level: "4.1",
+				Width: 1920, Height: 1080, FrameRate: 30,
+				VideoBitrate: 5000, ConstantBitrate: true, OnePass: true,
+				KeyframeRate: 0.5, Deinterlace: "on", CropLeft: 8, CropRight: 8,
+				AudioCodec: "aac", AudioBitrate: 128, AudioChannels: 2,
+				StartClip: "10", ClipLength: "15.5",
+			},
+			{
+				Label: "a,b:sd.webm", URL: "s3://bucket/out/abc/sd.webm", Format: "webm",
+				VideoCodec: "vp9", Height: 480, VideoBitrate: 1000, KeyframeInterval: 60,
+				SkipAudio: true, StartClip: "10", ClipLength: "15.5",
+			},
+		},
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Fatalf("request mismatch (-want +have):\n%s", diff)
+	}
+}
+
+func TestLabel(t *testing.T) {
+	for _, tt := range []struct {
+		labels []string
+		want   string
+	}{
+		{nil, "hd.mp4"},
+		{[]string{"a"}, "a:hd.mp4"},
+		{[]string{"a", "b"}, "a,b:hd.mp4"},
+	} {
+		if have := label(job.Job{Labels: tt.labels}, job.File{Name: "hd.mp4"}); have != tt.want {
+			t.Errorf("label(%q): have %q, want %q", tt.labels, have, tt.want)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	s, p := newServer(t, nil)
+	j := &job.Job{ID: "abc", Input: job.File{Name: "s3://bucket/in.mov"}, Output: job.Dir{File: []job.File{
+		{Name: "hd.mp4", Video: job.Video{Codec: "h264", Height: 1080}},
+	}}}
+	data, err := p.DryRun(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var have Request
+	if err := json.Unmarshal(data, &have); err != nil {
+		t.Fatalf("bad json: %v\n%s", err, data)
+	}
+	if have.Input != j.Input.Name || len(have.Outputs) != 1 || have.Outputs[0].URL != "s3://bucket/out/abc/hd.mp4" {
+		t.Fatalf("bad request: %s", data)
+	}
+	if s.method != "" {
+		t.Fatalf("dry run called the api: %s %s", s.method, s.path)
+	}
+	j.Input.Name = "ftp://host/in.mov"
+	if _, err := p.DryRun(context.Background(), j); !errors.Is(err, storage.ErrUnsupported) {
+		t.Fatalf("have %v, want %v", err, storage.ErrUnsupported)
+	}
+}
+
+func TestCreateUnsupported(t *testing.T) {
+	_, p := newServer(t, map[string]string{"POST /jobs": `{"id": 1}`})
+	for name, j := range map[string]*job.Job{
+		"codec": {Output: job.Dir{File: []job.File{{Name: "a.mp4", Video: job.Video{Codec: "prores"}}}}},
+		"splice": {Input: job.File{Splice: timecode.Splice{{0, 1}, {2, 3}}},
+			Output: job.Dir{File: []job.File{{Name: "a.mp4", Video: job.Video{Codec: "h264"}}}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := p.Create(context.Background(), j); !errors.Is(err, ErrUnsupported) {
+				t.Fatalf("have %v, want %v", err, ErrUnsupported)
+			}
+		})
+	}
+}
+
+func TestCreateStorage(t *testing.T) {
+	_, p := newServer(t, map[string]string{"POST /jobs": `{"id": 1}`})
+	out := job.Dir{File: []job.File{{Name: "a.mp4", Video: job.Video{Codec: "h264"}}}}
+	for name, j := range map[string]*job.Job{
+		"input":  {Input: job.File{Name: "ftp://host/in.mov"}, Output: out},
+		"output": {Input: job.File{Name: "s3://bucket/in.mov"}, Output: job.Dir{Path: "file:///out", File: out.File}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := p.Create(context.Background(), j); !errors.Is(err, storage.ErrUnsupported) {
+				t.Fatalf("have %v, want %v", err, storage.ErrUnsupported)
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	details := `{"job": {"state": "finished",
+		"input_media_file": {"url": "s3://bucket/in.mov", "duration_in_ms": 60000, "width": 1920, "height": 1080},
+		"output_media_files": [
+			{"url": "s3://bucket/out/abc/hd.mp4", "state": "finished", "file_size_bytes": 1000, "duration_in_ms": 15500, "width": 1920, "height": 1080, "video_codec": "h264"},
+			{"url": "s3://bucket/out/abc/sd.webm", "state": "failed", "error_message": "bad settings"}
+		]}}`
+	for _, tt := range []struct {
+		state    string
+		want     job.State
+		progress float64
+		outputs  int
+	}{
+		{"pending", job.StateQueued, 42, 0},
+		{"waiting", job.StateQueued, 42, 0},
+		{"processing", job.StateStarted, 42, 0},
+		{"finished", job.StateFinished, 100, 1},
+		{"failed", job.StateFailed, 42, 1},
+		{"cancelled", job.StateCanceled, 42, 0},
+		{"strange", job.StateUnknown, 42, 0},
+	} {
+		t.Run(tt.state, func(t *testing.T) {
+			_, p := newServer(t, map[string]string{
+				"GET /jobs/1234/progress.json": `{"state": "` + tt.state + `", "progress": 42}`,
+				"GET /jobs/1234.json":          details,
+			})
+			st, err := p.Status(context.Background(), &job.Job{ID: "abc", ProviderJobID: "1234"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if st.State != tt.want {
+				t.Fatalf("state: have %q, want %q", st.State, tt.want)
+			}
+			if st.Progress != tt.progress {
+				t.Fatalf("progress: have %v, want %v", st.Progress, tt.progress)
+			}
+			if n := st.Output.Len(); n != tt.outputs {
+				t.Fatalf("outputs: have %d, want %d", n, tt.outputs)
+			}
+			if st.Output.Path != "s3://bucket/out/abc" {
+				t.Fatalf("output path: have %q", st.Output.Path)
+			}
+			if tt.outputs == 0 {
+				return
+			}
+			want := job.File{
+				Name: "s3://bucket/out/abc/hd.mp4", Size: 1000, Duration: 15500 * time.Millisecond,
+				Video: job.Video{Width: 1920, Height: 1080, Codec: "h264"},
+			}
+			if diff := cmp.Diff(want, st.Output.File[0]); diff != "" {
+				t.Fatalf("output mismatch (-want +have):\n%s", diff)
+			}
+			if st.Input.Duration != time.Minute {
+				t.Fatalf("input duration: have %v", st.Input.Duration)
+			}
+			if st.Msg != "bad settings" {
+				t.Fatalf("msg: have %q", st.Msg)
+			}
+		})
+	}
+}
+
+func TestStatusNotFound(t *testing.T) {
+	_, p := newServer(t, nil)
+	_, err := p.Status(context.Background(), &job.Job{ProviderJobID: "404"})
+	if !errors.As(err, &provider.JobNotFoundError{}) {
+		t.Fatalf("have %v, want JobNotFoundError", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	s, p := newServer(t, map[string]string{"PUT /jobs/1234/cancel.json": ""})
+	if err := p.Cancel(context.Background(), "1234"); err != nil {
+		t.Fatal(err)
+	}
+	if s.method != http.MethodPut || s.path != "/jobs/1234/cancel.json" {
+		t.Fatalf("have %s %s", s.method, s.path)
+	}
+	if err := p.Cancel(context.Background(), "5678"); !errors.As(err, &provider.JobNotFoundError{}) {
+		t.Fatalf("have %v, want JobNotFoundError", err)
+	}
+}
+
+func TestHealthcheck(t *testing.T) {
+	s, p := newServer(t, map[string]string{"GET /account": `{"account_state": "active"}`})
+	if err := p.Healthcheck(); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if err := p.Healthcheck(); err == nil {
+		t.Fatal("healthcheck passed with the api down")
+	}
+}
+
+func TestFactory(t *testing.T) {
+	if _, err := factory(&config.Config{Zencoder: &config.Zencoder{}}); err == nil {
+		t.Fatal("factory accepted a config without an api key")
+	}
+	if _, err := factory(&config.Config{Zencoder: &config.Zencoder{APIKey: "key"}}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,79 @@
+package secrets
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+)
+
+// Cache keeps secrets from Source for TTL, after which they're fetched
+// again. If a refresh fails the expired value keeps being used, so a
+// secret store outage doesn't fail jobs that were working.
+type Cache struct {
+	Source SecretSource
+	TTL    time.Duration
+
+	mu sync.Mutex
+	m  map[string]entry
+}
+
+type entry struct {
+	v   string
+	exp time.Time
+}
+
+// NewCache returns a cache of src
+func NewCache(src SecretSource, ttl time.Duration) *Cache {
+	return &Cache{Source: src, TTL: ttl, m: map[string]entry{}}
+}
+
+func (c *Cache) Secret(ctx context.Context, path string) (string, error) {
+	c.mu.Lock()
+	e, ok := c.m[path]
+	c.mu.Unlock()
+	if ok && time.Now().Before(e.exp) {
+		return e.v, nil
+	}
+	v, err := c.Source.Secret(ctx, path)
+	if err != nil {
+		if ok {
+			return e.v, nil
+		}
+		return "", err
+	}
+	c.mu.Lock()
+	c.m[path] = entry{v: v, exp: time.Now().Add(c.TTL)}
+	c.mu.Unlock()
+	return v, nil
+}
+
+// Resolver keeps the resolved copy of a config, see Config, for TTL or
+// until it's asked for another config, as after a reload. Resolution
+// failures are passed to Log, which may be nil.
+type Resolver struct {
+	Source SecretSource
+	TTL    time.Duration
+	Log    func(error)
+
+	mu  sync.Mutex
+	in  *config.Config
+	out *config.Config
+	exp time.Time
+}
+
+// Config returns c with its secret references resolved
+func (r *Resolver) Config(ctx context.Context, c *config.Config) *config.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c == r.in && time.Now().Before(r.exp) {
+		return r.out
+	}
+	out, err := Config(ctx, r.Source, c)
+	if err != nil && r.Log != nil {
+		r.Log(err)
+	}
+	r.in, r.out, r.exp = c, out, time.Now().Add(r.TTL)
+	return out
+}
@@ -0,0 +1,172 @@
+// Package secrets resolves secret references, like secret://hybrik/oapi,
+// found in the config and in job storage aliases. Resolved values are only
+// ever handed to providers, so they must not be logged or stored.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+)
+
+// Scheme prefixes a secret reference
+const Scheme = "secret://"
+
+var (
+	ErrNotFound = errors.New("secret not found")
+	ErrPath     = errors.New("bad secret path")
+	ErrDenied   = errors.New("secret not allowed")
+)
+
+// SecretSource looks up secrets by path, like "hybrik/oapi"
+type SecretSource interface {
+	Secret(ctx context.Context, path string) (string, error)
+}
+
+// Ref returns the path in the secret reference s, and false if s
+// isn't a reference
+func Ref(s string) (path string, ok bool) {
+	if !strings.HasPrefix(s, Scheme) {
+		return "", false
+	}
+	return strings.TrimPrefix(s, Scheme), true
+}
+
+// Resolve returns the secret s references, or s if it isn't a reference
+func Resolve(ctx context.Context, src SecretSource, s string) (string, error) {
+	path, ok := Ref(s)
+	if !ok {
+		return s, nil
+	}
+	if !valid(path) {
+		return "", fmt.Errorf("%w: %q", ErrPath, path)
+	}
+	v, err := src.Secret(ctx, path)
+	if err != nil {
+		return "", fmt.Errorf("secret %q: %w", path, err)
+	}
+	return v, nil
+}
+
+// ResolveUnder is Resolve, but only for references to paths under prefix.
+// An empty prefix allows no references.
+func ResolveUnder(ctx context.Context, src SecretSource, prefix, s string) (string, error) {
+	if path, ok := Ref(s); ok && (prefix == "" || !strings.HasPrefix(path, prefix)) {
+		return "", fmt.Errorf("%w: %q", ErrDenied, path)
+	}
+	return Resolve(ctx, src, s)
+}
+
+// Config returns a copy of c with every secret reference in it resolved.
+// The copy is deep, so c is never modified. A section of c, or an entry
+// of a map in it, that fails to resolve is left out of the copy, so the
+// provider it configures is disabled rather than every provider, and the
+// failures are returned together with the copy.
+func Config(ctx context.Context, src SecretSource, c *config.Config) (*config.Config, error) {
+	v := reflect.ValueOf(c).Elem()
+	r := reflect.New(v.Type()).Elem()
+	r.Set(v)
+	var failed []string
+	for i := 0; i < r.NumField(); i++ {
+		name, f := v.Type().Field(i).Name, v.Field(i)
+		if !r.Field(i).CanSet() {
+			continue
+		}
+		if f.Kind() != reflect.Map || f.IsNil() {
+			e, err := expand(ctx, src, f)
+			if err != nil {
+				e = reflect.Zero(f.Type())
+				failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			}
+			r.Field(i).Set(e)
+			continue
+		}
+		m := reflect.MakeMapWithSize(f.Type(), f.Len())
+		for it := f.MapRange(); it.Next(); {
+			e, err := expand(ctx, src, it.Value())
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("%s[%v]: %v", name, it.Key(), err))
+				continue
+			}
+			m.SetMapIndex(it.Key(), e)
+		}
+		r.Field(i).Set(m)
+	}
+	var err error
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		err = fmt.Errorf("secrets: %s", strings.Join(failed, "; "))
+	}
+	return r.Addr().Interface().(*config.Config), err
+}
+
+// expand copies pointers, structs and maps, resolving the strings in them.
+// Everything else is shared with the original.
+func expand(ctx context.Context, src SecretSource, v reflect.Value) (reflect.Value, error) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v, nil
+		}
+		e, err := expand(ctx, src, v.Elem())
+		if err != nil {
+			return v, err
+		}
+		p := reflect.New(e.Type())
+		p.Elem().Set(e)
+		return p, nil
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < c.NumField(); i++ {
+			if !c.Field(i).CanSet() {
+				continue
+			}
+			f, err := expand(ctx, src, v.Field(i))
+			if err != nil {
+				return v, err
+			}
+			c.Field(i).Set(f)
+		}
+		return c, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return v, nil
+		}
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for it := v.MapRange(); it.Next(); {
+			e, err := expand(ctx, src, it.Value())
+			if err != nil {
+				return v, err
+			}
+			m.SetMapIndex(it.Key(), e)
+		}
+		return m, nil
+	case reflect.String:
+		s, err := Resolve(ctx, src, v.String())
+		if err != nil {
+			return v, err
+		}
+		return reflect.ValueOf(s).Convert(v.Type()), nil
+	}
+	return v, nil
+}
+
+// valid reports whether path is a relative, slash-separated path
+// without empty, "." or ".." elements
+func valid(path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, e := range strings.Split(path, "/") {
+		if e == "" || e == "." || e == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,225 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+)
+
+type static map[string]string
+
+func (s static) Secret(_ context.Context, path string) (string, error) {
+	v, ok := s[path]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+func TestResolve(t *testing.T) {
+	src := static{"hybrik/oapi": "s3cr3t"}
+	ctx := context.Background()
+	for _, tt := range []struct {
+		in, want string
+		err      error
+	}{
+		{"plain", "plain", nil},
+		{"", "", nil},
+		{"secret://hybrik/oapi", "s3cr3t", nil},
+		{"secret://hybrik/missing", "", ErrNotFound},
+		{"secret://../etc/passwd", "", ErrPath},
+		{"secret://", "", ErrPath},
+	} {
+		have, err := Resolve(ctx, src, tt.in)
+		if have != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("Resolve(%q): have %q, %v, want %q, %v", tt.in, have, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	c := &config.Config{
+		Hybrik: &config.Hybrik{URL: "https://hybrik", OAPIKey: "secret://hybrik/oapi"},
+		Instances: map[string]*config.Instance{
+			"hybrik-2": {Driver: "hybrik", Hybrik: &config.Hybrik{OAPIKey: "secret://hybrik2/oapi"}},
+		},
+	}
+	r, err := Config(context.Background(), static{"hybrik/oapi": "one", "hybrik2/oapi": "two"}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Hybrik.OAPIKey != "one" || r.Hybrik.URL != "https://hybrik" || r.Instances["hybrik-2"].Hybrik.OAPIKey != "two" {
+		t.Fatalf("not resolved: %+v %+v", r.Hybrik, r.Instances["hybrik-2"].Hybrik)
+	}
+	if c.Hybrik.OAPIKey != "secret://hybrik/oapi" || c.Instances["hybrik-2"].Hybrik.OAPIKey != "secret://hybrik2/oapi" {
+		t.Fatal("original config was modified")
+	}
+
+	// a bad reference only disables the section it's in
+	r, err = Config(context.Background(), static{"hybrik2/oapi": "two"}, c)
+	if err == nil || !strings.Contains(err.Error(), "Hybrik") {
+		t.Fatalf("missing secret: have %v", err)
+	}
+	if r.Hybrik != nil || r.Instances["hybrik-2"].Hybrik.OAPIKey != "two" {
+		t.Fatalf("missing secret: have %+v %+v", r.Hybrik, r.Instances["hybrik-2"])
+	}
+	r, _ = Config(context.Background(), static{"hybrik/oapi": "one"}, c)
+	if r.Hybrik.OAPIKey != "one" || r.Instances["hybrik-2"] != nil {
+		t.Fatalf("missing instance secret: have %+v %+v", r.Hybrik, r.Instances)
+	}
+}
+
+func TestResolveUnder(t *testing.T) {
+	src := static{"storage/in": "in", "hybrik/oapi": "key"}
+	ctx := context.Background()
+	for _, tt := range []struct {
+		prefix, in, want string
+		err              error
+	}{
+		{"storage/", "secret://storage/in", "in", nil},
+		{"storage/", "plain", "plain", nil},
+		{"storage/", "secret://hybrik/oapi", "", ErrDenied},
+		{"storage/", "secret://storage/../hybrik/oapi", "", ErrPath},
+		{"", "secret://storage/in", "", ErrDenied},
+		{"", "plain", "plain", nil},
+	} {
+		have, err := ResolveUnder(ctx, src, tt.prefix, tt.in)
+		if have != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("ResolveUnder(%q, %q): have %q, %v, want %q, %v", tt.prefix, tt.in, have, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestResolver(t *testing.T) {
+	src := &flaky{v: "one"}
+	var logged []error
+	r := &Resolver{Source: src, TTL: time.Hour, Log: func(err error) { logged = append(logged, err) }}
+	c := &config.Config{Hybrik: &config.Hybrik{OAPIKey: "secret://hybrik/oapi"}}
+	for i := 0; i < 3; i++ {
+		if have := r.Config(context.Background(), c); have.Hybrik.OAPIKey != "one" {
+			t.Fatalf("have %q, want one", have.Hybrik.OAPIKey)
+		}
+	}
+	if src.calls != 1 {
+		t.Fatalf("resolved %d times, want once", src.calls)
+	}
+
+	// a reloaded config is resolved again
+	src.err = ErrNotFound
+	if have := r.Config(context.Background(), &config.Config{Hybrik: c.Hybrik}); have.Hybrik != nil {
+		t.Fatalf("failed section kept: %+v", have.Hybrik)
+	}
+	if src.calls != 2 || len(logged) != 1 {
+		t.Fatalf("have %d calls and %d logged errors, want 2 and 1", src.calls, len(logged))
+	}
+}
+
+func TestNewNil(t *testing.T) {
+	os.Setenv("SECRET_NIL_TEST", "v")
+	defer os.Unsetenv("SECRET_NIL_TEST")
+	src, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := src.Secret(context.Background(), "nil/test"); v != "v" || err != nil {
+		t.Fatalf("have %q, %v, want the env source", v, err)
+	}
+}
+
+func TestSources(t *testing.T) {
+	ctx := context.Background()
+
+	os.Setenv("SECRET_HYBRIK_OAPI_KEY", "from-env")
+	defer os.Unsetenv("SECRET_HYBRIK_OAPI_KEY")
+
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "hybrik"), 0700)
+	ioutil.WriteFile(filepath.Join(dir, "hybrik", "oapi-key"), []byte("from-file\n"), 0600)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/hybrik/oapi-key" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("from-http"))
+	}))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		name string
+		src  SecretSource
+		want string
+	}{
+		{"env", Env{Prefix: "SECRET_"}, "from-env"},
+		{"file", File{Dir: dir}, "from-file"},
+		{"http", HTTP{URL: srv.URL + "/v1/", Token: "tok"}, "from-http"},
+	} {
+		if have, err := tt.src.Secret(ctx, "hybrik/oapi-key"); have != tt.want || err != nil {
+			t.Errorf("%s: have %q, %v, want %q", tt.name, have, err, tt.want)
+		}
+		if _, err := tt.src.Secret(ctx, "hybrik/missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: missing: have %v, want %v", tt.name, err, ErrNotFound)
+		}
+	}
+	if _, err := (HTTP{URL: srv.URL}).Secret(ctx, "hybrik/oapi-key"); err == nil {
+		t.Error("http: no token: want error")
+	}
+}
+
+type flaky struct {
+	v     string
+	err   error
+	calls int
+}
+
+func (f *flaky) Secret(context.Context, string) (string, error) {
+	f.calls++
+	return f.v, f.err
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	src := &flaky{v: "one"}
+	c := NewCache(src, 20*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		if v, _ := c.Secret(ctx, "a"); v != "one" {
+			t.Fatalf("have %q, want one", v)
+		}
+	}
+	if src.calls != 1 {
+		t.Fatalf("source called %d times, want 1", src.calls)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	src.v = "two"
+	if v, _ := c.Secret(ctx, "a"); v != "two" {
+		t.Fatalf("after ttl: have %q, want two", v)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	src.err = errors.New("down")
+	if v, err := c.Secret(ctx, "a"); v != "two" || err != nil {
+		t.Fatalf("refresh failure: have %q, %v, want stale value", v, err)
+	}
+	if _, err := c.Secret(ctx, "b"); err == nil {
+		t.Fatal("uncached failure: want error")
+	}
+}
@@ -0,0 +1,112 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+)
+
+// Env reads secrets from environment variables. The path is upper-cased,
+// slashes and dashes become underscores and Prefix is prepended, so
+// "hybrik/oapi" is read from SECRET_HYBRIK_OAPI.
+type Env struct {
+	Prefix string
+}
+
+func (e Env) Secret(_ context.Context, path string) (string, error) {
+	name := e.Prefix + strings.ToUpper(strings.NewReplacer("/", "_", "-", "_", ".", "_").Replace(path))
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+// File reads each secret from a file under Dir named by its path,
+// like a mounted kubernetes secret. A trailing newline is removed.
+type File struct {
+	Dir string
+}
+
+func (f File) Secret(_ context.Context, path string) (string, error) {
+	if !valid(path) {
+		return "", ErrPath
+	}
+	data, err := ioutil.ReadFile(filepath.Join(f.Dir, filepath.FromSlash(path)))
+	if os.IsNotExist(err) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// HTTP gets each secret from URL/path, the response body is the secret.
+// If Token is set, it's sent as a bearer token.
+type HTTP struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+func (h HTTP) Secret(ctx context.Context, path string) (string, error) {
+	if !valid(path) {
+		return "", ErrPath
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(h.URL, "/")+"/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	if h.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.Token)
+	}
+	c := h.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", ErrNotFound
+	default:
+		return "", fmt.Errorf("secret server: status %d", resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// New returns the source named in the config, behind a Cache.
+// A nil config is the env source, without caching.
+func New(c *config.Secrets) (SecretSource, error) {
+	if c == nil {
+		c = &config.Secrets{}
+	}
+	var src SecretSource
+	switch c.Source {
+	case "", "env":
+		src = Env{Prefix: "SECRET_"}
+	case "file":
+		src = File{Dir: c.Dir}
+	case "http":
+		src = HTTP{URL: c.URL, Token: c.Token, Client: &http.Client{Timeout: 10 * time.Second}}
+	default:
+		return nil, fmt.Errorf("secrets: unknown source %q", c.Source)
+	}
+	return NewCache(src, time.Duration(c.TTL)*time.Second), nil
+}
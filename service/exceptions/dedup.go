@@ -0,0 +1,77 @@
+package exceptions
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dedup wraps a Reporter and drops reports that repeat one sent within
+// the window. Reports are the same if they share an op, a provider and
+// an error message, ignoring the ids in it, so a provider that fails
+// every job is reported once per window. The number of dropped reports
+// is passed on with the next one that gets through.
+type Dedup struct {
+	Reporter
+	Window time.Duration
+
+	mu   sync.Mutex
+	seen map[string]*seen
+}
+
+type seen struct {
+	at      time.Time
+	repeats int
+}
+
+// NewDedup returns a Dedup reporting to r
+func NewDedup(r Reporter, window time.Duration) *Dedup {
+	return &Dedup{Reporter: r, Window: window, seen: map[string]*seen{}}
+}
+
+// ReportException reports err without context
+func (d *Dedup) ReportException(err error) {
+	d.Report(err, Context{})
+}
+
+// Report passes err to the underlying reporter, unless it's a duplicate
+func (d *Dedup) Report(err error, c Context) {
+	if err == nil {
+		return
+	}
+	k := key(err, c)
+	now := time.Now()
+
+	d.mu.Lock()
+	for k, s := range d.seen {
+		if age := now.Sub(s.at); age >= 2*d.Window || age >= d.Window && s.repeats == 0 {
+			delete(d.seen, k)
+		}
+	}
+	s := d.seen[k]
+	if s != nil && now.Sub(s.at) < d.Window {
+		s.repeats++
+		d.mu.Unlock()
+		return
+	}
+	if s != nil {
+		c.Repeats = s.repeats
+	}
+	d.seen[k] = &seen{at: now}
+	d.mu.Unlock()
+
+	d.Reporter.Report(err, c)
+}
+
+var digits = regexp.MustCompile(`\b[0-9a-fA-F-]*[0-9][0-9a-fA-F-]*\b`)
+
+func key(err error, c Context) string {
+	msg := err.Error()
+	for _, id := range []string{c.JobID, c.ProviderJobID} {
+		if id != "" {
+			msg = strings.ReplaceAll(msg, id, "#")
+		}
+	}
+	return c.Op + "\x00" + c.Provider + "\x00" + digits.ReplaceAllString(msg, "#")
+}
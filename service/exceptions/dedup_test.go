@@ -0,0 +1,43 @@
+package exceptions
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type sink struct {
+	errs []error
+	ctx  []Context
+}
+
+func (s *sink) ReportException(err error) { s.Report(err, Context{}) }
+func (s *sink) Flush(time.Duration) bool  { return true }
+func (s *sink) Report(err error, c Context) {
+	s.errs = append(s.errs, err)
+	s.ctx = append(s.ctx, c)
+}
+
+func TestDedup(t *testing.T) {
+	s := &sink{}
+	d := NewDedup(s, 50*time.Millisecond)
+
+	d.Report(errors.New("provider error: job 1234 failed"), Context{Op: "create", Provider: "a", JobID: "j1"})
+	d.Report(errors.New("provider error: job 5678 failed"), Context{Op: "create", Provider: "a", JobID: "j2"})
+	d.Report(errors.New("provider error: job j3 failed"), Context{Op: "create", Provider: "a", JobID: "j3"})
+	d.Report(errors.New("provider error: job 1234 failed"), Context{Op: "create", Provider: "b", JobID: "j1"})
+	d.Report(errors.New("provider error: job 1234 failed"), Context{Op: "status", Provider: "a", JobID: "j1"})
+	d.Report(nil, Context{})
+	if len(s.errs) != 3 {
+		t.Fatalf("have %d reports, want 3: %v", len(s.errs), s.errs)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	d.Report(errors.New("provider error: job 9 failed"), Context{Op: "create", Provider: "a", JobID: "j4"})
+	if len(s.errs) != 4 {
+		t.Fatalf("have %d reports after window, want 4", len(s.errs))
+	}
+	if have := s.ctx[3].Repeats; have != 2 {
+		t.Fatalf("repeats: have %d, want 2", have)
+	}
+}
@@ -1,16 +1,44 @@
 package exceptions
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/getsentry/sentry-go"
 )
 
-const defaultFlushTimeout = time.Second * 5
+// Context describes the job and request an error happened in
+type Context struct {
+	Op            string
+	JobID         string
+	Provider      string
+	ProviderJobID string
+	Rid           uint64
 
-// Reporter sends exceptions to an external source
+	// Job is the job spec, callers must redact it
+	Job interface{}
+
+	// Repeats counts the reports suppressed since the last one
+	Repeats int
+}
+
+// Reporter sends exceptions to an external source. Reports may be sent
+// in the background, so call Flush before exiting.
 type Reporter interface {
 	ReportException(err error)
+	Report(err error, c Context)
+
+	// Flush waits up to timeout for the reports to be sent and
+	// reports whether they were
+	Flush(timeout time.Duration) bool
+}
+
+// New returns a SentryReporter if dsn is set, otherwise a NoopReporter
+func New(dsn, env string) (Reporter, error) {
+	if dsn == "" {
+		return &NoopReporter{}, nil
+	}
+	return NewSentryReporter(dsn, env)
 }
 
 // NoopReporter is a no-op exception reporter
@@ -19,6 +47,12 @@ type NoopReporter struct{}
 // ReportException does nothing
 func (r *NoopReporter) ReportException(_ error) {}
 
+// Report does nothing
+func (r *NoopReporter) Report(_ error, _ Context) {}
+
+// Flush does nothing
+func (r *NoopReporter) Flush(_ time.Duration) bool { return true }
+
 // SentryReporter is an ErrorReporter that sends error information to Sentry
 type SentryReporter struct{}
 
@@ -32,8 +66,33 @@ func NewSentryReporter(dsn, env string) (*SentryReporter, error) {
 	return &SentryReporter{}, nil
 }
 
-// ReportException will send errors to Sentry
+// ReportException queues the error to be sent to Sentry
 func (r *SentryReporter) ReportException(err error) {
 	sentry.CaptureException(err)
-	sentry.Flush(defaultFlushTimeout)
+}
+
+// Report queues the error to be sent to Sentry, the context is attached
+// as tags and the job spec as extra data
+func (r *SentryReporter) Report(err error, c Context) {
+	sentry.WithScope(func(scope *sentry.Scope) {
+		scope.SetTags(map[string]string{
+			"op":          c.Op,
+			"job":         c.JobID,
+			"provider":    c.Provider,
+			"providerJob": c.ProviderJobID,
+			"rid":         fmt.Sprint(c.Rid),
+		})
+		if c.Job != nil {
+			scope.SetExtra("job", c.Job)
+		}
+		if c.Repeats > 0 {
+			scope.SetExtra("repeats", c.Repeats)
+		}
+		sentry.CaptureException(err)
+	})
+}
+
+// Flush waits for the queued errors to be sent to Sentry
+func (r *SentryReporter) Flush(timeout time.Duration) bool {
+	return sentry.Flush(timeout)
 }
@@ -12,6 +12,10 @@ import (
 	"path"
 	"strings"
 	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/logging"
+	"github.com/sirupsen/logrus"
 )
 
 const defaultMaxBodyLen = 1024 * 1024
@@ -36,16 +40,16 @@ type request struct {
 
 // newRequest initializes request scoped structures, context and counters, returning
 // a function that can be deferred to log request details, newrelic, etc
-func newRequest(w http.ResponseWriter, rq *http.Request) request {
+func newRequest(w http.ResponseWriter, rq *http.Request, l *logrus.Entry) request {
 	r := request{
 		path:  rq.URL.Path,
-		ctx:   rq.Context(),
 		r:     rq,
 		w:     w,
 		start: time.Now(),
 		rid:   rand.Uint64(),
 	}
 	r.rid |= 1 << 63 // sacrifice one bit of entropy so they always have the same # digits
+	r.ctx = logging.With(rq.Context(), l.WithField("rid", r.rid))
 	r.ip = r.r.Header.Get("X-Forwarded-For")
 	r.port = r.r.Header.Get("X-Forwarded-Port")
 	if r.ip == "" {
@@ -57,7 +61,7 @@ func newRequest(w http.ResponseWriter, rq *http.Request) request {
 		"raddr", r.r.RemoteAddr,
 		"method", r.r.Method,
 		"path", r.r.URL.Path,
-		"ref", r.r.Referer(),
+		"ref", job.RedactURL(r.r.Referer()),
 		"ua", r.r.UserAgent(),
 	)
 	return r
@@ -93,7 +97,14 @@ func (s *request) Body() []byte {
 }
 
 func (s *request) writeerror(msg string, code int, err error) bool {
-	s.log(
+	if s.logerr == nil {
+		s.logerr = err
+	}
+	lv := logrus.WarnLevel
+	if code >= 500 {
+		lv = logrus.ErrorLevel
+	}
+	s.logat(lv,
 		"msg", msg,
 		"code", code,
 		"err", err,
@@ -110,10 +121,26 @@ func (s *request) writeerror(msg string, code int, err error) bool {
 }
 
 func (s *request) log(kv ...interface{}) {
-	logkv(append([]interface{}{
-		"t", time.Now().UnixNano(),
-		"rid", s.rid,
-	}, kv...)...)
+	s.logat(logrus.InfoLevel, kv...)
+}
+
+// logat logs the key-value pairs at level lv with the fields
+// in the request context. The "msg" key is the message.
+func (s *request) logat(lv logrus.Level, kv ...interface{}) {
+	f := logrus.Fields{}
+	msg := ""
+	for i := 0; i+1 < len(kv); i += 2 {
+		k, v := fmt.Sprint(kv[i]), kv[i+1]
+		if k == "msg" {
+			msg = fmt.Sprint(v)
+			continue
+		}
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		f[k] = v
+	}
+	logging.From(s.ctx).WithFields(f).Log(lv, msg)
 }
 
 func (s *request) writebody(data interface{}, mimeType ...string) bool {
@@ -1,37 +1,71 @@
 package service
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
 	"github.com/cbsinteractive/transcode-orchestrator/config"
 	"github.com/cbsinteractive/transcode-orchestrator/db"
+	"github.com/cbsinteractive/transcode-orchestrator/logging"
 	transcoding "github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/secrets"
 	"github.com/cbsinteractive/transcode-orchestrator/service/exceptions"
+	"github.com/cbsinteractive/transcode-orchestrator/storage/store"
+	"github.com/cbsinteractive/transcode-orchestrator/trace"
 	"github.com/sirupsen/logrus"
 	"github.com/zsiec/pkg/tracing"
 )
 
+const (
+	defaultPageLen = 100
+	maxPageLen     = 1000
+)
+
 var ErrProvider = errors.New("provider error")
 var ErrStorage = errors.New("storage error")
 
 type Server struct {
-	Config      *config.Config
-	DB          *db.Client
-	logger      *logrus.Logger
-	errReporter exceptions.Reporter
-	tracer      tracing.Tracer
+	Config   *config.Config
+	Live     *config.Live // if set, replaces Config on each request
+	DB       db.Store
+	Audit    db.Audit
+	Archive  db.Archive
+	Reporter exceptions.Reporter
+	Secrets  secrets.SecretSource // resolves job storage aliases
+	Resolver *secrets.Resolver    // if set, resolves secrets in the config
+	Logger   *logrus.Logger
+	Objects  store.Store // if set, checks the outputs of finished jobs
+	tracer   tracing.Tracer
 
 	request
 }
 
 func (s Server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
-	s.request = newRequest(rw, r)
+	l := logging.From(r.Context())
+	if s.Logger != nil {
+		l = logrus.NewEntry(s.Logger)
+	}
+	s.request = newRequest(rw, r, l)
+	if s.Live != nil {
+		s.Config = s.Live.Config()
+	}
+	if s.tracer == nil {
+		s.tracer = s.Config.Tracer
+	}
+	var span interface{ Close(error) }
+	s.request.ctx, span = trace.Begin(s.request.ctx, s.tracer, "request",
+		"rid", s.rid,
+		"method", r.Method,
+		"path", r.URL.Path,
+	)
+	defer func() { span.Close(s.logerr) }()
 	s.serve()
 	defer s.request.finalize()
 }
@@ -40,78 +74,400 @@ func (s *Server) serve() bool {
 	switch s.chop() {
 	case "jobs":
 		job := &job.Job{ID: s.chop()}
+		if s.chop() == "audit" {
+			if s.method() != "GET" {
+				return s.writeerror("bad request method", 405, nil)
+			}
+			if s.Audit == nil {
+				return s.writeerror("audit log disabled", 404, nil)
+			}
+			events, err := s.Audit.Events(job.ID)
+			if err != nil {
+				return s.writeerror("get audit failed", 400, err)
+			}
+			return s.writebody(events)
+		}
 		switch s.method() {
 		case "POST":
 			if !s.request.UnmarshalJSON(job) {
+				s.record("create", job.ID, nil, s.err)
 				return false
 			}
-			log.Printf("job: %#v", job)
 			stat, err := s.putJob0(job)
+			s.record("create", job.ID, job, err)
 			if err != nil {
 				return s.writeerror("put job failed", 400, err)
 			}
 			return s.writebody(stat)
 		case "GET":
+			if job.ID == "" {
+				return s.list()
+			}
 			stat, err := s.getJob0(job, false)
 			if err != nil {
 				return s.writeerror("get job failed", 400, err)
 			}
 			return s.writebody(stat)
 		case "DELETE":
-			stat, err := s.getJob0(job, false)
+			stat, err := s.getJob0(job, true)
+			s.record("cancel", job.ID, nil, err)
 			if err != nil {
 				return s.writeerror("del job failed", 400, err)
 			}
 			return s.writebody(stat)
 		}
 	case "providers":
+		if s.method() != "GET" {
+			return s.writeerror("bad request method", 405, nil)
+		}
+		cfg := s.config()
+		name := s.chop()
+		if name == "" {
+			return s.writebody(transcoding.List(cfg))
+		}
+		desc, err := transcoding.Describe(name, cfg)
+		if err == transcoding.ErrNotFound {
+			return s.writeerror("provider not found", 404, err)
+		}
+		if err != nil {
+			return s.writeerror("describe provider failed", 400, err)
+		}
+		return s.writebody(desc)
+	case "dry-run":
+		if s.method() != "POST" {
+			return s.writeerror("bad request method", 405, nil)
+		}
+		return s.dryRun()
 	default:
 		s.writeerror("bad request path", 400, nil)
 	}
 	return false
 }
 
-func (s *Server) provider0(job *job.Job) (transcoding.Provider, error) {
-	fn, err := transcoding.GetFactory(job.Provider)
+// record appends the state-changing request to the job's audit log.
+// The payload is the redacted spec, if any, never the request body,
+// which may hold keys and signed urls. The user is whatever the client,
+// or a proxy in front of the API, put in X-Forwarded-User.
+func (s *Server) record(action, id string, spec *job.Job, err error) {
+	if s.Audit == nil {
+		return
+	}
+	e := db.Event{
+		JobID:  id,
+		Time:   time.Now(),
+		Action: action,
+		Rid:    s.rid,
+		IP:     s.ip,
+		Port:   s.port,
+		UA:     s.r.UserAgent(),
+		User:   s.r.Header.Get("X-Forwarded-User"),
+	}
+	if spec != nil {
+		if data, err := json.Marshal(spec.Redacted()); err == nil {
+			e.Payload = data
+		}
+	}
 	if err != nil {
-		return nil, err
+		e.Err = err.Error()
+	}
+	if err := s.Audit.Append(e); err != nil {
+		s.logat(logrus.ErrorLevel, "msg", "audit failed", "job", id, "err", err)
+	}
+}
+
+// list writes a page of jobs, newest first, with the offset of the
+// next page if there's one. Job specs are redacted.
+func (s *Server) list() bool {
+	q := s.r.URL.Query()
+	offset, limit := 0, defaultPageLen
+	var err error
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return s.writeerror("bad offset", 400, err)
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > maxPageLen {
+			return s.writeerror("bad limit", 400, err)
+		}
+	}
+	_, done := s.trace("db-list", &err, "offset", offset, "limit", limit)
+	list, err := s.DB.List(offset, limit+1)
+	done()
+	if err != nil {
+		return s.writeerror("list jobs failed", 500, fmt.Errorf("%w: %v", ErrStorage, err))
+	}
+	page := job.Page{Jobs: []job.Job{}}
+	if len(list) > limit {
+		list, page.Next = list[:limit], offset+limit
 	}
-	return fn(s.Config)
+	for _, j := range list {
+		page.Jobs = append(page.Jobs, j.Redacted())
+	}
+	return s.writebody(page)
+}
+
+// dryRun writes the request the job's provider would be sent to create
+// it, in the provider's own format. Nothing is sent or stored. Storage
+// aliases aren't resolved, so no secret ends up in the response.
+func (s *Server) dryRun() bool {
+	j := &job.Job{}
+	if !s.request.UnmarshalJSON(j) {
+		return s.writeerror("bad job", 400, s.err)
+	}
+	if j.ID == "" {
+		j.ID = genID()
+	}
+	fn, err := transcoding.GetFactory(j.Provider)
+	if err == transcoding.ErrNotFound {
+		return s.writeerror("provider not found", 404, err)
+	}
+	p, err := fn(s.config())
+	if err != nil {
+		return s.writeerror("dry run failed", 400, err)
+	}
+	dr, ok := p.(transcoding.DryRunner)
+	if !ok {
+		return s.writeerror("provider doesn't support dry runs", 501, nil)
+	}
+	ctx, done := s.trace("provider-dry-run", &err)
+	data, err := dr.DryRun(ctx, j)
+	done()
+	if err != nil {
+		return s.writeerror("dry run failed", 400, err)
+	}
+	mime := "text/plain; charset=utf-8"
+	if json.Valid(data) {
+		mime = "application/json"
+	} else if bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+		mime = "application/xml"
+	}
+	return s.writebody(data, mime)
+}
+
+// provider0 returns the job's provider and a copy of the job for it, with
+// secret references in the storage aliases resolved. The copy must not be
+// stored or logged.
+func (s *Server) provider0(j *job.Job) (transcoding.Provider, *job.Job, error) {
+	fn, err := transcoding.GetFactory(j.Provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := fn(s.config())
+	if err != nil || s.Secrets == nil {
+		return p, j, err
+	}
+	prefix := ""
+	if c := s.Config.Secrets; c != nil {
+		prefix = c.AliasPrefix
+	}
+	pj := *j
+	for _, alias := range []*string{&pj.Env.InputAlias, &pj.Env.OutputAlias} {
+		if *alias, err = secrets.ResolveUnder(s.ctx, s.Secrets, prefix, *alias); err != nil {
+			return nil, nil, err
+		}
+	}
+	return p, &pj, nil
+}
+
+// config returns the config with secret references resolved
+func (s *Server) config() *config.Config {
+	if s.Resolver == nil {
+		return s.Config
+	}
+	return s.Resolver.Config(s.ctx, s.Config)
 }
 
 func (s *Server) putJob0(job *job.Job) (*job.Status, error) {
-	p, err := s.provider0(job)
+	if job.ID == "" {
+		job.ID = genID()
+	}
+	trace.Set(s.ctx, "job", job.ID, "provider", job.Provider)
+	s.ctx = logging.Set(s.ctx, "job", job.ID, "provider", job.Provider)
+	logging.From(s.ctx).WithField("spec", job.Redacted()).Debug("create job")
+	p, pj, err := s.provider0(job)
 	if err != nil {
 		return nil, err
 	}
-	stat, err := p.Create(s.request.ctx, job)
+	ctx, done := s.trace("provider-create", &err)
+	stat, err := p.Create(ctx, pj)
+	if err == nil {
+		trace.Set(ctx, "providerJob", stat.ProviderJobID)
+	}
+	done()
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrProvider, err)
+		err = fmt.Errorf("%w: %v", ErrProvider, err)
+		s.report("provider-create", job, err)
+		return nil, err
 	}
-	stat.ID = job.ID
+	stat.ID, stat.Provider = job.ID, job.Provider
 	job.ProviderJobID = stat.ProviderJobID
+	s.ctx = logging.Set(s.ctx, "providerJob", job.ProviderJobID)
+	job.State = stat.State
 	job.CreatedAt = time.Now()
-	if err = s.DB.Put(job.ID, &job); err != nil {
-		return stat, fmt.Errorf("%w: %v", ErrStorage, err)
+	// the provider has the encryption keys now; the store never needs them
+	stored := *job
+	stored.Streaming.Encryption = job.Streaming.Encryption.Redacted()
+	_, done = s.trace("db-put", &err, "providerJob", job.ProviderJobID)
+	err = s.DB.Put(&stored)
+	done()
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrStorage, err)
+		s.report("db-put", job, err)
+		return stat, err
+	}
+	if stat.State.Terminal() {
+		s.retire(&stored, stat)
 	}
 	return stat, nil
 }
 
 func (s *Server) getJob0(job *job.Job, del bool) (*job.Status, error) {
-	if err := s.DB.Get(job.ID, &job); err != nil {
+	in, id := job, job.ID
+	trace.Set(s.ctx, "job", id)
+	s.ctx = logging.Set(s.ctx, "job", id)
+	var err error
+	_, done := s.trace("db-get", &err)
+	job, err = s.DB.Get(id)
+	done()
+	if err == db.ErrJobNotFound && s.Archive != nil && !del {
+		if stat, err := s.archived(id); err == nil {
+			return stat, nil
+		}
+	}
+	if err == db.ErrJobNotFound {
 		return nil, err
 	}
-	p, err := s.provider0(job)
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrStorage, err)
+		s.report("db-get", in, err)
+		return nil, err
+	}
+	trace.Set(s.ctx, "provider", job.Provider, "providerJob", job.ProviderJobID)
+	s.ctx = logging.Set(s.ctx, "provider", job.Provider, "providerJob", job.ProviderJobID)
+	p, pj, err := s.provider0(job)
 	if err != nil {
 		return nil, err
 	}
 	if del {
-		if err = p.Cancel(s.request.ctx, job.ProviderJobID); err != nil {
+		ctx, done := s.trace("provider-cancel", &err)
+		err = p.Cancel(ctx, job.ProviderJobID)
+		done()
+		if err != nil {
+			err = fmt.Errorf("%w: %v", ErrProvider, err)
+			s.report("provider-cancel", job, err)
 			return nil, err
 		}
 	}
-	//TODO(as): provider name
-	return p.Status(s.request.ctx, job)
+	ctx, done := s.trace("provider-status", &err)
+	stat, err := p.Status(ctx, pj)
+	done()
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrProvider, err)
+		s.report("provider-status", job, err)
+		return nil, err
+	}
+	stat.Provider = job.Provider
+	if job.State.Terminal() {
+		// the stored state is final, so a job failed by verification, or
+		// finished and since cleaned up, stays as it was retired
+		stat.State = job.State
+		if job.Msg != "" {
+			stat.Msg = job.Msg
+		}
+		return stat, nil
+	}
+	if _, ok := p.(transcoding.Simulator); !ok {
+		s.verify(job, stat)
+	}
+	if stat.State != "" && stat.State != job.State {
+		// a job that ends is stored whole, with the message it ended
+		// with, which later polls repeat
+		op, set := "db-setstate", func() error { return s.DB.SetState(job.ID, stat.State) }
+		if stat.State.Terminal() {
+			job.State, job.Msg = stat.State, stat.Msg
+			op, set = "db-put", func() error { return s.DB.Put(job) }
+		}
+		_, done := s.trace(op, &err, "state", stat.State)
+		err = set()
+		done()
+		if err != nil {
+			err = fmt.Errorf("%w: %v", ErrStorage, err)
+			s.report(op, job, err)
+			return stat, err
+		}
+		if stat.State.Terminal() {
+			s.retire(job, stat)
+		}
+	}
+	return stat, nil
+}
+
+func (s *Server) archived(id string) (stat *job.Status, err error) {
+	_, done := s.trace("archive-get", &err)
+	defer done()
+	r, err := s.Archive.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.Status, nil
+}
+
+// retire archives the job and its final status, then lets
+// it expire from the store. If archival fails the job stays
+// in the store so nothing is lost. It is called whenever a
+// job becomes terminal: when it's created or polled, by a
+// request or by Sweep.
+func (s *Server) retire(j *job.Job, stat *job.Status) {
+	ttl := 0
+	if r := s.Config.Retention; r != nil {
+		ttl = r.TTL
+	}
+	if ttl <= 0 {
+		return
+	}
+	var err error
+	if s.Archive != nil {
+		_, done := s.trace("archive-put", &err)
+		err = s.Archive.Put(db.Record{Job: j, Status: stat})
+		done()
+		if err != nil {
+			s.logat(logrus.ErrorLevel, "msg", "archive failed", "err", err)
+			s.report("archive-put", j, fmt.Errorf("%w: %v", ErrStorage, err))
+			return
+		}
+	}
+	_, done := s.trace("db-expire", &err)
+	err = s.DB.Expire(j.ID, time.Duration(ttl)*time.Hour)
+	done()
+	if err != nil {
+		s.logat(logrus.ErrorLevel, "msg", "expire failed", "err", err)
+		s.report("db-expire", j, fmt.Errorf("%w: %v", ErrStorage, err))
+	}
+}
+
+// report sends a provider or storage failure to the exception
+// reporter, along with the job and request it happened in
+func (s *Server) report(op string, j *job.Job, err error) {
+	if s.Reporter == nil {
+		return
+	}
+	spec := j.Redacted()
+	s.Reporter.Report(err, exceptions.Context{
+		Op:            op,
+		JobID:         j.ID,
+		Provider:      j.Provider,
+		ProviderJobID: j.ProviderJobID,
+		Rid:           s.rid,
+		Job:           &spec,
+	})
+}
+
+// trace starts a child of the request span, the returned function
+// ends it with the error that err points to
+func (s *Server) trace(name string, err *error, kv ...interface{}) (context.Context, func()) {
+	ctx, span := trace.Begin(s.ctx, s.tracer, name, kv...)
+	return ctx, func() { span.Close(*err) }
 }
 
 func (s *Server) method() string {
@@ -145,27 +501,3 @@ func (e StatusError) NotFound() bool {
 func (e StatusError) Error() string {
 	return fmt.Sprintf("http status: %d: %q", e.Code, e.body)
 }
-
-func logkv(kv ...interface{}) bool {
-	msg := `{`
-	sep := " "
-	for i := 0; i+1 < len(kv); i += 2 {
-		v := kv[i+1]
-		if v == nil {
-			v = ""
-		} else {
-			switch v.(type) {
-			case fmt.Stringer:
-				v = fmt.Sprint(v)
-			case error:
-				v = fmt.Sprint(v)
-			}
-		}
-		value, _ := json.Marshal(v)
-		msg += fmt.Sprintf(`%s%q:%s`, sep, kv[i], string(value))
-		sep = ", "
-	}
-	msg += `}`
-	log.Println(msg)
-	return true
-}
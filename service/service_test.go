@@ -0,0 +1,615 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/db"
+	"github.com/cbsinteractive/transcode-orchestrator/provider"
+	"github.com/cbsinteractive/transcode-orchestrator/service/exceptions"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/cbsinteractive/transcode-orchestrator/storage/store"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	testProvider     = "service-test"
+	brokenProvider   = "service-test-broken"
+	finishedProvider = "service-test-finished"
+	dryRunProvider   = "service-test-dry-run"
+	simProvider      = "service-test-simulator"
+)
+
+type fake struct {
+	canceled []string
+	created  []job.Job
+	err      error
+	status   job.Status // if set, the status of every job
+}
+
+func (f *fake) Create(_ context.Context, j *job.Job) (*job.Status, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.created = append(f.created, *j)
+	state := job.StateQueued
+	if f.status.State != "" {
+		state = f.status.State
+	}
+	return &job.Status{ProviderJobID: "p1", State: state}, nil
+}
+func (f *fake) Status(_ context.Context, j *job.Job) (*job.Status, error) {
+	if f.status.State != "" {
+		stat := f.status
+		return &stat, nil
+	}
+	return &job.Status{ID: j.ID, ProviderJobID: j.ProviderJobID, State: job.StateStarted}, nil
+}
+func (f *fake) Cancel(_ context.Context, id string) error {
+	f.canceled = append(f.canceled, id)
+	return nil
+}
+func (f *fake) Healthcheck() error                  { return nil }
+func (f *fake) Capabilities() provider.Capabilities { return provider.Capabilities{} }
+
+var testFake = &fake{}
+
+// dryRunner builds a request naming the job's id and input
+type dryRunner struct{ fake }
+
+func (*dryRunner) DryRun(_ context.Context, j *job.Job) ([]byte, error) {
+	if j.Input.Name == "" {
+		return nil, errors.New("no input")
+	}
+	return []byte(`<job id="` + j.ID + `"><input>` + j.Input.Name + `</input></job>`), nil
+}
+
+// finishedFake reports every job finished with the outputs, which
+// are checked against the sizes in the store
+var finishedFake = &fake{status: job.Status{State: job.StateFinished, Output: job.Dir{
+	Path: "s3://out/job",
+	File: []job.File{
+		{Name: "s3://out/job/hd.mp4", Size: 100},
+		{Name: "sd.mp4", Size: 60},
+		{Name: "https://host/job/audio.mp4?sig=secret"},
+		{Name: "azure://account/container/job/hd.mp4"},
+	},
+}}}
+
+// simulated reports the outputs of finishedFake without writing them
+type simulated struct{ *fake }
+
+func (simulated) Simulated() {}
+
+func init() {
+	provider.Register(testProvider, func(*config.Config) (provider.Provider, error) {
+		return testFake, nil
+	})
+	provider.Register(brokenProvider, func(*config.Config) (provider.Provider, error) {
+		return &fake{err: errors.New("api down")}, nil
+	})
+	provider.Register(finishedProvider, func(*config.Config) (provider.Provider, error) {
+		return finishedFake, nil
+	})
+	provider.Register(dryRunProvider, func(*config.Config) (provider.Provider, error) {
+		return &dryRunner{}, nil
+	})
+	provider.Register(simProvider, func(*config.Config) (provider.Provider, error) {
+		return simulated{finishedFake}, nil
+	})
+}
+
+func testServer() (Server, *db.Memory) {
+	store := db.NewMemory()
+	return Server{Config: &config.Config{}, DB: store, Audit: store}, store
+}
+
+func do(t *testing.T, srv http.Handler, method, path, body string, hdr ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for i := 0; i+1 < len(hdr); i += 2 {
+		r.Header.Set(hdr[i], hdr[i+1])
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, r)
+	return w
+}
+
+func TestAudit(t *testing.T) {
+	srv, store := testServer()
+	body := `{"id":"j1","provider":"` + testProvider + `","input":{"name":"https://host/in.mp4?sig=secret"}}`
+
+	if w := do(t, srv, "POST", "/jobs/j1", body, "X-Forwarded-User", "ops@example.com"); w.Code != 200 {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body)
+	}
+	if w := do(t, srv, "GET", "/jobs/j1", ""); w.Code != 200 {
+		t.Fatalf("get: status %d: %s", w.Code, w.Body)
+	}
+	if w := do(t, srv, "DELETE", "/jobs/j1", ""); w.Code != 200 {
+		t.Fatalf("cancel: status %d: %s", w.Code, w.Body)
+	}
+	if len(testFake.canceled) != 1 || testFake.canceled[0] != "p1" {
+		t.Fatalf("cancel: provider not called: %v", testFake.canceled)
+	}
+	if j, _ := store.Get("j1"); j == nil || j.State != job.StateStarted {
+		t.Fatalf("stored job state was not updated: %+v", j)
+	}
+
+	w := do(t, srv, "GET", "/jobs/j1/audit", "")
+	if w.Code != 200 {
+		t.Fatalf("audit: status %d: %s", w.Code, w.Body)
+	}
+	var events []db.Event
+	if err := json.Unmarshal(w.Body.Bytes(), &events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("audit: want 2 events, have %d: %+v", len(events), events)
+	}
+	create, cancel := events[0], events[1]
+	if create.Action != "create" || create.User != "ops@example.com" || create.IP == "" || create.Rid == 0 {
+		t.Errorf("bad create event: %+v", create)
+	}
+	var spec job.Job
+	if err := json.Unmarshal(create.Payload, &spec); err != nil || spec.ID != "j1" || spec.Provider != testProvider {
+		t.Errorf("create payload: have %s, want the job: %v", create.Payload, err)
+	}
+	if bytes.Contains(create.Payload, []byte("secret")) || spec.Input.Name != "https://host/in.mp4?redacted" {
+		t.Errorf("create payload is not redacted: %s", create.Payload)
+	}
+	if cancel.Action != "cancel" || cancel.Err != "" || cancel.Payload != nil {
+		t.Errorf("bad cancel event: %+v", cancel)
+	}
+
+	if w := do(t, srv, "POST", "/jobs/j1/audit", ""); w.Code != 405 {
+		t.Errorf("audit post: status %d, want 405", w.Code)
+	}
+}
+
+type reports struct {
+	ctx []exceptions.Context
+}
+
+func (r *reports) ReportException(err error) { r.Report(err, exceptions.Context{}) }
+func (r *reports) Flush(time.Duration) bool  { return true }
+func (r *reports) Report(err error, c exceptions.Context) {
+	if !errors.Is(err, ErrProvider) {
+		panic(err)
+	}
+	r.ctx = append(r.ctx, c)
+}
+
+func TestReport(t *testing.T) {
+	srv, _ := testServer()
+	r := &reports{}
+	srv.Reporter = exceptions.NewDedup(r, time.Minute)
+
+	for _, id := range []string{"j1", "j2"} {
+		body := `{"id":"` + id + `","provider":"` + brokenProvider + `","input":{"name":"https://host/in.mov?sig=secret"}}`
+		if w := do(t, srv, "POST", "/jobs/"+id, body); w.Code != 400 {
+			t.Fatalf("create: status %d, want 400", w.Code)
+		}
+	}
+	if w := do(t, srv, "POST", "/jobs/j3", `{"provider":"missing"}`); w.Code != 400 {
+		t.Fatalf("create: status %d, want 400", w.Code)
+	}
+	if len(r.ctx) != 1 {
+		t.Fatalf("have %d reports, want 1: %+v", len(r.ctx), r.ctx)
+	}
+	c := r.ctx[0]
+	if c.Op != "provider-create" || c.JobID != "j1" || c.Provider != brokenProvider || c.Rid == 0 {
+		t.Fatalf("bad context: %+v", c)
+	}
+	if spec := c.Job.(*job.Job); spec.Input.Name != "https://host/in.mov?redacted" {
+		t.Fatalf("job spec not redacted: %q", spec.Input.Name)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	srv, _ := testServer()
+	buf := &bytes.Buffer{}
+	srv.Logger = logrus.New()
+	srv.Logger.SetOutput(buf)
+	srv.Logger.SetFormatter(&logrus.JSONFormatter{})
+	srv.Logger.SetLevel(logrus.DebugLevel)
+
+	body := `{"id":"j1","provider":"` + testProvider + `","input":{"name":"https://host/in.mov?sig=secret"}}`
+	if w := do(t, srv, "POST", "/jobs/j1", body, "Referer", "https://host/app?token=secret"); w.Code != 200 {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body)
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Fatalf("log leaks signature: %s", buf)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) < 3 {
+		t.Fatalf("want at least 3 lines, have %q", lines)
+	}
+	for i, l := range lines {
+		var kv map[string]interface{}
+		if err := json.Unmarshal([]byte(l), &kv); err != nil {
+			t.Fatalf("line %d: %v: %s", i, err, l)
+		}
+		if kv["rid"] == nil {
+			t.Errorf("line %d: no rid: %s", i, l)
+		}
+		if i > 0 && (kv["job"] != "j1" || kv["provider"] != testProvider) {
+			t.Errorf("line %d: no job or provider: %s", i, l)
+		}
+	}
+}
+
+func TestProviders(t *testing.T) {
+	srv, _ := testServer()
+
+	w := do(t, srv, "GET", "/providers", "")
+	var names []string
+	if err := json.Unmarshal(w.Body.Bytes(), &names); err != nil || w.Code != 200 {
+		t.Fatalf("list: status %d: %s", w.Code, w.Body)
+	}
+	found := false
+	for _, n := range names {
+		found = found || n == testProvider
+	}
+	if !found {
+		t.Fatalf("list: %q not in %v", testProvider, names)
+	}
+
+	w = do(t, srv, "GET", "/providers/"+testProvider, "")
+	var desc provider.Description
+	if err := json.Unmarshal(w.Body.Bytes(), &desc); err != nil || w.Code != 200 {
+		t.Fatalf("describe: status %d: %s", w.Code, w.Body)
+	}
+	if desc.Name != testProvider || !desc.Enabled || !desc.Health.OK {
+		t.Fatalf("describe: bad description: %+v", desc)
+	}
+
+	if w := do(t, srv, "GET", "/providers/missing", ""); w.Code != 404 {
+		t.Errorf("describe missing: status %d, want 404", w.Code)
+	}
+	if w := do(t, srv, "POST", "/providers", ""); w.Code != 405 {
+		t.Errorf("post: status %d, want 405", w.Code)
+	}
+}
+
+type secretMap map[string]string
+
+func (m secretMap) Secret(_ context.Context, path string) (string, error) {
+	return m[path], nil
+}
+
+func TestSecrets(t *testing.T) {
+	srv, store := testServer()
+	srv.Secrets = secretMap{"storage/in": "in-credential", "hybrik/oapi": "provider-credential"}
+	srv.Config.Secrets = &config.Secrets{AliasPrefix: "storage/"}
+	buf := &bytes.Buffer{}
+	srv.Logger = logrus.New()
+	srv.Logger.SetOutput(buf)
+	srv.Logger.SetLevel(logrus.DebugLevel)
+
+	body := `{"id":"s1","provider":"` + testProvider + `","env":{"inputAlias":"secret://storage/in","outputAlias":"plain"}}`
+	if w := do(t, srv, "POST", "/jobs/s1", body); w.Code != 200 {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body)
+	}
+	got := testFake.created[len(testFake.created)-1]
+	if got.Env.InputAlias != "in-credential" || got.Env.OutputAlias != "plain" {
+		t.Fatalf("provider got unresolved aliases: %+v", got.Env)
+	}
+	stored, _ := store.Get("s1")
+	if stored.Env.InputAlias != "secret://storage/in" {
+		t.Fatalf("stored job has resolved alias: %+v", stored.Env)
+	}
+	if strings.Contains(buf.String(), "in-credential") {
+		t.Fatalf("log leaks secret: %s", buf)
+	}
+
+	body = `{"id":"s2","provider":"` + testProvider + `","env":{"inputAlias":"secret://hybrik/oapi"}}`
+	if w := do(t, srv, "POST", "/jobs/s2", body); w.Code != 400 || strings.Contains(w.Body.String(), "provider-credential") {
+		t.Fatalf("alias outside the prefix: status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestStoredKeys(t *testing.T) {
+	srv, store := testServer()
+	key := "00112233445566778899aabbccddeeff"
+	body := `{"id":"k1","provider":"` + testProvider + `","streaming":{"encryption":{"method":"aes-128","key":"` + key + `"}}}`
+	if w := do(t, srv, "POST", "/jobs/k1", body); w.Code != 200 {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body)
+	}
+	if got := testFake.created[len(testFake.created)-1]; got.Streaming.Encryption.Key != key {
+		t.Fatalf("provider got no key: %+v", got.Streaming.Encryption)
+	}
+	stored, _ := store.Get("k1")
+	if stored.Streaming.Encryption.Key != "redacted" || stored.ProviderJobID != "p1" {
+		t.Fatalf("stored job has key material: %+v", stored.Streaming.Encryption)
+	}
+	w := do(t, srv, "GET", "/jobs/k1/audit", "")
+	if w.Code != 200 || !strings.Contains(w.Body.String(), `"create"`) {
+		t.Fatalf("audit: status %d: %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), key) {
+		t.Fatalf("audit has the key: %s", w.Body)
+	}
+}
+
+// archive is an in-memory db.Archive
+type archive map[string]db.Record
+
+func (a archive) Put(r db.Record) error { a[r.Job.ID] = r; return nil }
+func (a archive) Get(id string) (*db.Record, error) {
+	r, ok := a[id]
+	if !ok {
+		return nil, db.ErrJobNotFound
+	}
+	return &r, nil
+}
+
+func TestRetention(t *testing.T) {
+	srv, store := testServer()
+	srv.Config.Retention = &config.Retention{TTL: 1}
+	a := archive{}
+	srv.Archive = a
+
+	// finished when created
+	if w := do(t, srv, "POST", "/jobs/r1", `{"id":"r1","provider":"`+finishedProvider+`"}`); w.Code != 200 {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body)
+	}
+	if r, ok := a["r1"]; !ok || r.Status.State != job.StateFinished {
+		t.Fatalf("job finished at create was not archived: %+v", r)
+	}
+
+	// finished without being polled
+	store.Put(&job.Job{ID: "r2", Provider: finishedProvider, ProviderJobID: "p1", State: job.StateStarted})
+	store.Put(&job.Job{ID: "r3", Provider: testProvider, ProviderJobID: "p1", State: job.StateStarted})
+	srv.sweep(context.Background())
+	if r, ok := a["r2"]; !ok || r.Status.State != job.StateFinished {
+		t.Fatalf("job finished between polls was not archived: %+v", r)
+	}
+	if j, _ := store.Get("r2"); j.State != job.StateFinished {
+		t.Fatalf("stored state: have %q, want %q", j.State, job.StateFinished)
+	}
+	if _, ok := a["r3"]; ok {
+		t.Fatal("running job was archived")
+	}
+	if j, _ := store.Get("r3"); j.State != job.StateStarted {
+		t.Fatalf("stored state: have %q, want %q", j.State, job.StateStarted)
+	}
+
+	// young jobs are left to their clients, and polls are paced
+	store.Put(&job.Job{ID: "r4", Provider: finishedProvider, ProviderJobID: "p1", State: job.StateStarted, CreatedAt: time.Now()})
+	store.Put(&job.Job{ID: "r5", Provider: testProvider, ProviderJobID: "p1", State: job.StateStarted})
+	srv.Config.Retention.SweepAge = 60
+	srv.Config.Retention.SweepRate = 50
+	start := time.Now()
+	srv.sweep(context.Background())
+	if _, ok := a["r4"]; ok {
+		t.Fatal("job younger than the sweep age was polled")
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Fatalf("two polls at 50 a second took %v", d)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	store.Put(&job.Job{ID: "r6", Provider: finishedProvider, ProviderJobID: "p1", State: job.StateStarted})
+	srv.sweep(ctx)
+	if _, ok := a["r6"]; ok {
+		t.Fatal("canceled sweep polled a job")
+	}
+}
+
+type brokenStore struct{}
+
+func (brokenStore) Stat(context.Context, storage.Location) (store.Object, error) {
+	return store.Object{}, errors.New("access denied")
+}
+
+func TestVerify(t *testing.T) {
+	ok := store.Fake{
+		"s3://out/job/hd.mp4":                   100,
+		"s3://out/job/sd.mp4":                   60,
+		"https://host/job/audio.mp4?sig=secret": 10,
+	}
+	for _, tt := range []struct {
+		name     string
+		provider string
+		store    store.Store
+		state    job.State
+		msg      string
+	}{
+		{name: "OK", store: store.Mux{storage.S3: ok, storage.HTTPS: ok}, state: job.StateFinished},
+		{name: "Simulated", provider: simProvider, store: store.Mux{storage.S3: store.Fake{}}, state: job.StateFinished},
+		{name: "Disabled", state: job.StateFinished},
+		{name: "Broken", store: brokenStore{}, state: job.StateFinished},
+		{
+			name: "Bad",
+			store: store.Mux{
+				storage.S3:    store.Fake{"s3://out/job/hd.mp4": 100, "s3://out/job/sd.mp4": 59},
+				storage.HTTPS: store.Fake{"https://host/job/audio.mp4?sig=secret": 0},
+			},
+			state: job.StateFailed,
+			msg: "output verification failed: s3://out/job/sd.mp4 is 59 bytes, the provider reported 60; " +
+				"https://host/job/audio.mp4 is empty",
+		},
+		{
+			name:  "Missing",
+			store: store.Mux{storage.S3: store.Fake{"s3://out/job/sd.mp4": 60}},
+			state: job.StateFailed,
+			msg:   "output verification failed: s3://out/job/hd.mp4 is missing",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, db := testServer()
+			srv.Objects = tt.store
+			if tt.provider == "" {
+				tt.provider = finishedProvider
+			}
+			db.Put(&job.Job{ID: "v1", Provider: tt.provider, ProviderJobID: "p1", State: job.StateStarted})
+
+			w := do(t, srv, "GET", "/jobs/v1", "")
+			var stat job.Status
+			if err := json.Unmarshal(w.Body.Bytes(), &stat); err != nil || w.Code != 200 {
+				t.Fatalf("get: status %d: %s", w.Code, w.Body)
+			}
+			if stat.State != tt.state || stat.Msg != tt.msg {
+				t.Fatalf("have %q %q, want %q %q", stat.State, stat.Msg, tt.state, tt.msg)
+			}
+			if j, _ := db.Get("v1"); j.State != tt.state {
+				t.Fatalf("stored state: have %q, want %q", j.State, tt.state)
+			}
+		})
+	}
+}
+
+// setStates counts the state changes of the store, made by setting
+// the state or storing the job again
+type setStates struct {
+	*db.Memory
+	n int
+}
+
+func (s *setStates) SetState(id string, state job.State) error {
+	s.n++
+	return s.Memory.SetState(id, state)
+}
+
+func (s *setStates) Put(j *job.Job) error {
+	s.n++
+	return s.Memory.Put(j)
+}
+
+func TestVerifyOnce(t *testing.T) {
+	ok := store.Mux{storage.S3: store.Fake{"s3://out/job/hd.mp4": 100, "s3://out/job/sd.mp4": 60}}
+	missing := store.Mux{storage.S3: store.Fake{}}
+	for _, tt := range []struct {
+		name        string
+		first, then store.Store
+		state       job.State
+	}{
+		{"FailedStaysFailed", missing, ok, job.StateFailed},
+		{"FinishedStaysFinished", ok, missing, job.StateFinished},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := testServer()
+			mem := &setStates{Memory: db.NewMemory()}
+			a := archive{}
+			srv.DB, srv.Archive = mem, a
+			srv.Config.Retention = &config.Retention{TTL: 1}
+			mem.Put(&job.Job{ID: "v1", Provider: finishedProvider, ProviderJobID: "p1", State: job.StateStarted})
+			mem.n = 0
+
+			msg := ""
+			for i, objects := range []store.Store{tt.first, tt.then, tt.then} {
+				srv.Objects = objects
+				w := do(t, srv, "GET", "/jobs/v1", "")
+				var stat job.Status
+				if err := json.Unmarshal(w.Body.Bytes(), &stat); err != nil || w.Code != 200 {
+					t.Fatalf("get %d: status %d: %s", i, w.Code, w.Body)
+				}
+				if stat.State != tt.state {
+					t.Fatalf("get %d: have %q, want %q", i, stat.State, tt.state)
+				}
+				if i == 0 {
+					msg = stat.Msg
+				}
+				if stat.Msg != msg {
+					t.Fatalf("get %d: have message %q, want %q as retired", i, stat.Msg, msg)
+				}
+			}
+			if tt.state == job.StateFailed && !strings.HasPrefix(msg, "output verification failed") {
+				t.Fatalf("have message %q, want the verification failure", msg)
+			}
+			if j, _ := mem.Get("v1"); j.State != tt.state {
+				t.Fatalf("stored state: have %q, want %q", j.State, tt.state)
+			}
+			if mem.n != 1 {
+				t.Fatalf("state was set %d times, want once", mem.n)
+			}
+			if r := a["v1"]; r.Status == nil || r.Status.State != tt.state {
+				t.Fatalf("archived %+v, want %q", r.Status, tt.state)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	srv, store := testServer()
+	now := time.Now()
+	for i, id := range []string{"j1", "j2", "j3"} {
+		store.Put(&job.Job{
+			ID:        id,
+			Provider:  testProvider,
+			CreatedAt: now.Add(time.Duration(i) * time.Second),
+			Input:     job.File{Name: "https://host/in.mov?sig=secret"},
+		})
+	}
+	for _, tt := range []struct {
+		query string
+		ids   []string
+		next  int
+	}{
+		{query: "", ids: []string{"j3", "j2", "j1"}},
+		{query: "?limit=2", ids: []string{"j3", "j2"}, next: 2},
+		{query: "?offset=2&limit=2", ids: []string{"j1"}},
+		{query: "?offset=5", ids: []string{}},
+	} {
+		w := do(t, srv, "GET", "/jobs"+tt.query, "")
+		var page job.Page
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil || w.Code != 200 {
+			t.Fatalf("%s: status %d: %s", tt.query, w.Code, w.Body)
+		}
+		ids := []string{}
+		for _, j := range page.Jobs {
+			ids = append(ids, j.ID)
+			if j.Input.Name != "https://host/in.mov?redacted" {
+				t.Errorf("%s: job spec not redacted: %q", tt.query, j.Input.Name)
+			}
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.ids, ",") || page.Next != tt.next {
+			t.Errorf("%s: have %v next %d, want %v next %d", tt.query, ids, page.Next, tt.ids, tt.next)
+		}
+	}
+	for _, q := range []string{"?limit=0", "?limit=5000", "?offset=-1", "?limit=x"} {
+		if w := do(t, srv, "GET", "/jobs"+q, ""); w.Code != 400 {
+			t.Errorf("%s: status %d, want 400", q, w.Code)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	srv, store := testServer()
+	w := do(t, srv, "POST", "/dry-run", `{"id":"j1","provider":"`+dryRunProvider+`","input":{"name":"s3://in/a.mov"}}`)
+	if w.Code != 200 || w.Body.String() != `<job id="j1"><input>s3://in/a.mov</input></job>` {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/xml" {
+		t.Fatalf("content type: %q", ct)
+	}
+	if j, _ := store.Get("j1"); j != nil {
+		t.Fatalf("dry run stored the job: %+v", j)
+	}
+
+	for _, tt := range []struct {
+		name, method, body string
+		code               int
+	}{
+		{"NoInput", "POST", `{"provider":"` + dryRunProvider + `"}`, 400},
+		{"BadJSON", "POST", `{`, 400},
+		{"Unsupported", "POST", `{"provider":"` + testProvider + `"}`, 501},
+		{"NoProvider", "POST", `{"provider":"missing"}`, 404},
+		{"Method", "GET", "", 405},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := do(t, srv, tt.method, "/dry-run", tt.body); w.Code != tt.code {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.code, w.Body)
+			}
+		})
+	}
+}
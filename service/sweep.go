@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/logging"
+	"github.com/sirupsen/logrus"
+)
+
+const sweepPageLen = 100
+
+// Sweep polls the provider of every unfinished job each interval, as a GET
+// would, so jobs that finish without anyone asking are still retired. Jobs
+// younger than the retention's sweep age are skipped, and providers are
+// polled at most the sweep rate a second. It returns when ctx is done.
+func (s Server) Sweep(ctx context.Context, interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			s.sweep(ctx)
+		}
+	}
+}
+
+// sweep polls the unfinished jobs once. Each poll gets its own request
+// id, and failures are logged and reported as they would be for a GET.
+func (s Server) sweep(ctx context.Context) {
+	if s.Live != nil {
+		s.Config = s.Live.Config()
+	}
+	if s.tracer == nil {
+		s.tracer = s.Config.Tracer
+	}
+	l := logging.From(ctx)
+	if s.Logger != nil {
+		l = logrus.NewEntry(s.Logger)
+	}
+	var (
+		age  time.Duration
+		pace <-chan time.Time
+	)
+	if r := s.Config.Retention; r != nil {
+		age = time.Duration(r.SweepAge) * time.Minute
+		if r.SweepRate > 0 {
+			t := time.NewTicker(time.Second / time.Duration(r.SweepRate))
+			defer t.Stop()
+			pace = t.C
+		}
+	}
+	for offset := 0; ; offset += sweepPageLen {
+		list, err := s.DB.List(offset, sweepPageLen)
+		if err != nil {
+			l.WithError(err).Error("sweep: list jobs failed")
+			return
+		}
+		if len(list) == 0 {
+			return
+		}
+		for _, j := range list {
+			if j.State.Terminal() || time.Since(j.CreatedAt) < age {
+				continue
+			}
+			if pace != nil {
+				select {
+				case <-ctx.Done():
+					return
+				case <-pace:
+				}
+			}
+			s.request = request{rid: rand.Uint64() | 1<<63}
+			s.ctx = logging.With(ctx, l.WithField("rid", s.rid))
+			if _, err := s.getJob0(&job.Job{ID: j.ID}, false); err != nil {
+				s.logat(logrus.WarnLevel, "msg", "sweep: get job failed", "job", j.ID, "err", err)
+			}
+		}
+	}
+}
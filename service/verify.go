@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cbsinteractive/transcode-orchestrator/client/transcoding/job"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+	"github.com/cbsinteractive/transcode-orchestrator/storage/store"
+	"github.com/sirupsen/logrus"
+)
+
+// verify stats the outputs of a job that just finished and fails it if
+// any are missing, empty, or not the size the provider reported. Outputs
+// in storage without a store are skipped. If the store can't be read, the
+// job is left finished. Jobs stored as terminal aren't verified again.
+func (s *Server) verify(j *job.Job, stat *job.Status) {
+	if s.Objects == nil || stat.State != job.StateFinished {
+		return
+	}
+	var err error
+	ctx, done := s.trace("verify-outputs", &err, "outputs", len(stat.Output.File))
+	defer done()
+
+	var bad []string
+	for _, f := range stat.Output.File {
+		l, perr := output(stat.Output.Path, f.Name)
+		if perr != nil {
+			continue
+		}
+		o, serr := s.Objects.Stat(ctx, l)
+		l.Query = "" // it may hold a signature
+		switch {
+		case errors.Is(serr, store.ErrNotExist):
+			bad = append(bad, fmt.Sprintf("%s is missing", l))
+		case errors.Is(serr, storage.ErrUnsupported):
+		case serr != nil:
+			err = fmt.Errorf("%w: verifying outputs: %v", ErrStorage, serr)
+			s.logat(logrus.ErrorLevel, "msg", "verifying outputs failed", "err", serr)
+			s.report("verify-outputs", j, err)
+			return
+		case o.Size == 0:
+			bad = append(bad, fmt.Sprintf("%s is empty", l))
+		case f.Size > 0 && o.Size > 0 && o.Size != f.Size:
+			bad = append(bad, fmt.Sprintf("%s is %d bytes, the provider reported %d", l, o.Size, f.Size))
+		}
+	}
+	if len(bad) > 0 {
+		stat.State = job.StateFailed
+		stat.Msg = "output verification failed: " + strings.Join(bad, "; ")
+		s.logat(logrus.WarnLevel, "msg", "output verification failed", "outputs", bad)
+	}
+}
+
+// output returns the location of a status output, which is either a
+// url or a name relative to the output path
+func output(dir, name string) (storage.Location, error) {
+	l, err := storage.Parse(name)
+	if err == nil || dir == "" || !errors.Is(err, storage.ErrInvalid) {
+		return l, err
+	}
+	d, derr := storage.Parse(dir)
+	if derr != nil {
+		return l, err
+	}
+	return d.Join(name), nil
+}
@@ -0,0 +1,247 @@
+// Package storage parses the urls of media inputs and outputs. Every
+// driver checks its job's locations with it, so a scheme a provider
+// can't read or write is rejected the same way before submission.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Schemes of the storage the orchestrator knows about
+const (
+	S3    = "s3"
+	GCS   = "gs"
+	HTTP  = "http"
+	HTTPS = "https"
+	File  = "file"
+	Azure = "azure"
+)
+
+// Schemes is every scheme Parse accepts
+var Schemes = []string{S3, GCS, HTTP, HTTPS, File, Azure}
+
+// aliases are the other spellings of a scheme
+var aliases = map[string]string{
+	"gcs": GCS,
+	"az":  Azure,
+}
+
+var (
+	ErrInvalid     = errors.New("invalid storage location")
+	ErrUnsupported = errors.New("unsupported storage")
+)
+
+// Location is a parsed storage url
+//
+//	s3://bucket/key
+//	gs://bucket/key
+//	azure://account/container/key
+//	https://host/key?query
+//	file:///key
+type Location struct {
+	Scheme string
+	Host   string // the http host, or the azure account
+	Bucket string // the bucket, or the azure container
+	Key    string // the object key or file path, without a leading slash
+	Region string // the aws region, if the url names one
+	Query  string
+}
+
+// Parse parses and normalizes a storage url. An absolute path is a
+// file url. On ErrUnsupported, the location's scheme is set.
+//
+// Bucket urls name their objects literally, as the aws and gsutil tools
+// do, so gs://bucket/a%20b.mp4 is the object "a%20b.mp4". Only http and
+// file urls are unescaped.
+func Parse(s string) (Location, error) {
+	if s == "" {
+		return Location{}, fmt.Errorf("%w: empty url", ErrInvalid)
+	}
+	if l, ok, err := parseBucket(s); ok {
+		return l, err
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return Location{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	l := Location{Scheme: strings.ToLower(u.Scheme), Key: strings.TrimPrefix(u.Path, "/")}
+	if a, ok := aliases[l.Scheme]; ok {
+		l.Scheme = a
+	}
+
+	switch l.Scheme {
+	case "":
+		if !path.IsAbs(u.Path) {
+			return Location{}, fmt.Errorf("%w: %q has no scheme", ErrInvalid, s)
+		}
+		l.Scheme = File
+	case HTTP, HTTPS:
+		l.Host, l.Query = u.Host, u.RawQuery
+		if bucket, region, ok := s3host(u.Hostname()); ok {
+			if bucket == "" {
+				bucket, l.Key = split(l.Key)
+			}
+			l.Bucket, l.Region = bucket, region
+		} else if azurehost(u.Hostname()) {
+			l.Bucket, l.Key = split(l.Key)
+		}
+	case File:
+		if u.Host != "" && u.Host != "localhost" {
+			return Location{}, fmt.Errorf("%w: %q is on host %q", ErrInvalid, s, u.Host)
+		}
+	default:
+		return l, unsupported(s, l.Scheme, Schemes)
+	}
+
+	if l.Scheme != File && l.Host == "" && l.Bucket == "" {
+		return Location{}, fmt.Errorf("%w: %q has no bucket or host", ErrInvalid, s)
+	}
+	return l, nil
+}
+
+// parseBucket parses s3, gs and azure urls, and reports whether s is one.
+// Their keys are literal, but the bucket or account has to be a host.
+func parseBucket(s string) (l Location, ok bool, err error) {
+	i := strings.Index(s, "://")
+	if i < 0 {
+		return l, false, nil
+	}
+	l.Scheme = strings.ToLower(s[:i])
+	if a, ok := aliases[l.Scheme]; ok {
+		l.Scheme = a
+	}
+	host, key := split(s[i+3:])
+	switch l.Scheme {
+	case S3, GCS:
+		l.Bucket, l.Key = host, key
+	case Azure:
+		l.Host = host
+		l.Bucket, l.Key = split(key)
+	default:
+		return Location{}, false, nil
+	}
+	if _, err := url.Parse(s[:i+3] + host); err != nil {
+		return Location{}, true, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if l.Host == "" && l.Bucket == "" {
+		return Location{}, true, fmt.Errorf("%w: %q has no bucket or host", ErrInvalid, s)
+	}
+	return l, true, nil
+}
+
+// Check parses s and returns ErrUnsupported unless its scheme is one
+// of schemes
+func Check(s string, schemes ...string) (Location, error) {
+	l, err := Parse(s)
+	if err != nil && !errors.Is(err, ErrUnsupported) {
+		return l, err
+	}
+	for _, want := range schemes {
+		if l.Scheme == want {
+			return l, nil
+		}
+	}
+	return l, unsupported(s, l.Scheme, schemes)
+}
+
+func unsupported(s, scheme string, want []string) error {
+	return fmt.Errorf("%w: scheme %q in %q, want one of %s", ErrUnsupported, scheme, s, strings.Join(want, ", "))
+}
+
+// String returns the url of the location, which Parse returns the
+// location from
+func (l Location) String() string {
+	switch l.Scheme {
+	case S3, GCS, Azure:
+		s := l.Scheme + "://" + l.Bucket
+		if l.Scheme == Azure {
+			s = l.Scheme + "://" + l.Host + "/" + l.Bucket
+		}
+		if l.Key != "" {
+			s += "/" + l.Key
+		}
+		return s
+	}
+	u := url.URL{Scheme: l.Scheme, Host: l.Host, RawQuery: l.Query}
+	key := l.Key
+	switch l.Scheme {
+	case HTTP, HTTPS:
+		// the bucket is in the path, unless it's in the host
+		if b, _, ok := s3host(u.Hostname()); l.Bucket != "" && (!ok || b == "") {
+			key = path.Join(l.Bucket, l.Key) + trailing(l.Key)
+		}
+	}
+	if key != "" || l.Scheme == File {
+		u.Path = "/" + key
+	}
+	return u.String()
+}
+
+// Path returns the key as an absolute path
+func (l Location) Path() string {
+	return "/" + l.Key
+}
+
+// Join returns the location with elem joined to its key
+func (l Location) Join(elem ...string) Location {
+	l.Key = strings.TrimPrefix(path.Join(append([]string{l.Key}, elem...)...), "/")
+	return l
+}
+
+// Dir returns the location of the key's directory
+func (l Location) Dir() Location {
+	if l.Key = path.Dir(l.Key); l.Key == "." || l.Key == "/" {
+		l.Key = ""
+	}
+	return l
+}
+
+// split separates the first element of a key from the rest
+func split(key string) (string, string) {
+	if i := strings.Index(key, "/"); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return key, ""
+}
+
+// s3host returns the bucket and region named by an s3 endpoint, like
+// bucket.s3.us-west-2.amazonaws.com or s3-us-west-2.amazonaws.com.
+// The bucket is empty for path style hosts.
+func s3host(host string) (bucket, region string, ok bool) {
+	if !strings.HasSuffix(host, ".amazonaws.com") {
+		return "", "", false
+	}
+	label := strings.Split(strings.TrimSuffix(host, ".amazonaws.com"), ".")
+	for i := len(label) - 1; i >= 0; i-- {
+		switch {
+		case label[i] == "s3" && i == len(label)-1:
+			region = "us-east-1"
+		case label[i] == "s3" && i == len(label)-2:
+			region = label[i+1]
+		case strings.HasPrefix(label[i], "s3-") && i == len(label)-1:
+			region = strings.TrimPrefix(label[i], "s3-")
+		default:
+			continue
+		}
+		return strings.Join(label[:i], "."), region, true
+	}
+	return "", "", false
+}
+
+// azurehost reports whether host is an azure blob endpoint, which has
+// the container in the path
+func azurehost(host string) bool {
+	return strings.HasSuffix(host, ".blob.core.windows.net")
+}
+
+// trailing returns the slash ending a key that names a directory
+func trailing(key string) string {
+	if key != "" && strings.HasSuffix(key, "/") {
+		return "/"
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		url  string
+		want Location
+		str  string // the normalized url, if it differs
+	}{
+		{url: "s3://bucket/dir/in.mov", want: Location{Scheme: S3, Bucket: "bucket", Key: "dir/in.mov"}},
+		{url: "s3://bucket", want: Location{Scheme: S3, Bucket: "bucket"}},
+		{url: "s3://bucket/dir/", want: Location{Scheme: S3, Bucket: "bucket", Key: "dir/"}},
+		{url: "gs://bucket/in.mov", want: Location{Scheme: GCS, Bucket: "bucket", Key: "in.mov"}},
+		{url: "gcs://bucket/in.mov", want: Location{Scheme: GCS, Bucket: "bucket", Key: "in.mov"}, str: "gs://bucket/in.mov"},
+		{url: "GS://bucket/in.mov", want: Location{Scheme: GCS, Bucket: "bucket", Key: "in.mov"}, str: "gs://bucket/in.mov"},
+		{url: "azure://account/container/dir/in.mov", want: Location{Scheme: Azure, Host: "account", Bucket: "container", Key: "dir/in.mov"}},
+		{url: "az://account/container", want: Location{Scheme: Azure, Host: "account", Bucket: "container"}, str: "azure://account/container"},
+		{url: "http://example.com/in.mov", want: Location{Scheme: HTTP, Host: "example.com", Key: "in.mov"}},
+		{url: "https://example.com:8443/a/in.mov?sig=x", want: Location{Scheme: HTTPS, Host: "example.com:8443", Key: "a/in.mov", Query: "sig=x"}},
+		{
+			url:  "https://bucket.s3.amazonaws.com/in.mov",
+			want: Location{Scheme: HTTPS, Host: "bucket.s3.amazonaws.com", Bucket: "bucket", Key: "in.mov", Region: "us-east-1"},
+		},
+		{
+			url:  "https://my.bucket.s3.us-west-2.amazonaws.com/a/in.mov",
+			want: Location{Scheme: HTTPS, Host: "my.bucket.s3.us-west-2.amazonaws.com", Bucket: "my.bucket", Key: "a/in.mov", Region: "us-west-2"},
+		},
+		{
+			url:  "https://s3-eu-west-1.amazonaws.com/bucket/in.mov",
+			want: Location{Scheme: HTTPS, Host: "s3-eu-west-1.amazonaws.com", Bucket: "bucket", Key: "in.mov", Region: "eu-west-1"},
+		},
+		{
+			url:  "https://account.blob.core.windows.net/container/in.mov",
+			want: Location{Scheme: HTTPS, Host: "account.blob.core.windows.net", Bucket: "container", Key: "in.mov"},
+		},
+		{url: "gs://bucket/a b.mp4", want: Location{Scheme: GCS, Bucket: "bucket", Key: "a b.mp4"}},
+		{url: "s3://bucket/x%2Fy.mp4", want: Location{Scheme: S3, Bucket: "bucket", Key: "x%2Fy.mp4"}},
+		{url: "s3://bucket/100%.mp4", want: Location{Scheme: S3, Bucket: "bucket", Key: "100%.mp4"}},
+		{url: "s3://bucket/a?b#c.mp4", want: Location{Scheme: S3, Bucket: "bucket", Key: "a?b#c.mp4"}},
+		{url: "azure://account/container/a b%20c.mp4", want: Location{Scheme: Azure, Host: "account", Bucket: "container", Key: "a b%20c.mp4"}},
+		{url: "https://example.com/a%20b%25.mp4", want: Location{Scheme: HTTPS, Host: "example.com", Key: "a b%.mp4"}},
+		{url: "file:///media/in.mov", want: Location{Scheme: File, Key: "media/in.mov"}},
+		{url: "file:///media/a%20b.mov", want: Location{Scheme: File, Key: "media/a b.mov"}},
+		{url: "/media/in.mov", want: Location{Scheme: File, Key: "media/in.mov"}, str: "file:///media/in.mov"},
+	} {
+		t.Run(tt.url, func(t *testing.T) {
+			have, err := Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, have); diff != "" {
+				t.Fatalf("location (-want +have):\n%s", diff)
+			}
+			if tt.str == "" {
+				tt.str = tt.url
+			}
+			if s := have.String(); s != tt.str {
+				t.Fatalf("string: have %q, want %q", s, tt.str)
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for url, want := range map[string]error{
+		"":                     ErrInvalid,
+		"in.mov":               ErrInvalid,
+		"s3:///in.mov":         ErrInvalid,
+		"%fsdf://bucket/a":     ErrInvalid,
+		"file://host/a":        ErrInvalid,
+		"ftp://host/in.mov":    ErrUnsupported,
+		"cbscloud://bucket/in": ErrUnsupported,
+	} {
+		if _, err := Parse(url); !errors.Is(err, want) {
+			t.Errorf("Parse(%q): have %v, want %v", url, err, want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	if _, err := Check("gcs://bucket/in.mov", S3, GCS); err != nil {
+		t.Fatal(err)
+	}
+	for url, want := range map[string]string{
+		"https://example.com/in.mov": `unsupported storage: scheme "https" in "https://example.com/in.mov", want one of s3, gs`,
+		"ftp://example.com/in.mov":   `unsupported storage: scheme "ftp" in "ftp://example.com/in.mov", want one of s3, gs`,
+		"in.mov":                     `invalid storage location: "in.mov" has no scheme`,
+	} {
+		if _, err := Check(url, S3, GCS); err == nil || err.Error() != want {
+			t.Errorf("Check(%q): have %v, want %q", url, err, want)
+		}
+	}
+}
+
+func TestJoin(t *testing.T) {
+	l, _ := Parse("s3://bucket/out")
+	if have, want := l.Join("job", "hd.mp4").String(), "s3://bucket/out/job/hd.mp4"; have != want {
+		t.Fatalf("join: have %q, want %q", have, want)
+	}
+	if have, want := l.Join("hd.mp4").Dir().String(), "s3://bucket/out"; have != want {
+		t.Fatalf("dir: have %q, want %q", have, want)
+	}
+	if have, want := l.Dir().String(), "s3://bucket"; have != want {
+		t.Fatalf("dir of the bucket: have %q, want %q", have, want)
+	}
+	if have, want := l.Join("a b%.mp4").String(), "s3://bucket/out/a b%.mp4"; have != want {
+		t.Fatalf("join literal: have %q, want %q", have, want)
+	}
+	l, _ = Parse("https://s3.amazonaws.com/bucket")
+	if have, want := l.Join("a.mp4").String(), "https://s3.amazonaws.com/bucket/a.mp4"; have != want {
+		t.Fatalf("path style: have %q, want %q", have, want)
+	}
+	if have, want := l.Join("a.mp4").Path(), "/a.mp4"; have != want {
+		t.Fatalf("path: have %q, want %q", have, want)
+	}
+}
@@ -0,0 +1,26 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+)
+
+// File stats files on the orchestrator's own host
+type File struct{}
+
+func (File) Stat(_ context.Context, l storage.Location) (Object, error) {
+	fi, err := os.Stat(l.Path())
+	if os.IsNotExist(err) {
+		return Object{}, fmt.Errorf("%w: %s", ErrNotExist, l)
+	}
+	if err != nil {
+		return Object{}, err
+	}
+	if fi.IsDir() {
+		return Object{}, fmt.Errorf("stat %s: is a directory", l)
+	}
+	return Object{Size: fi.Size()}, nil
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/cbsinteractive/transcode-orchestrator/gcloud"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+)
+
+// GCS stats objects with the Cloud Storage json api
+type GCS struct {
+	Client   *http.Client
+	Tokens   gcloud.TokenSource
+	Endpoint string // defaults to https://storage.googleapis.com
+}
+
+func (g *GCS) Stat(ctx context.Context, l storage.Location) (Object, error) {
+	endpoint := g.Endpoint
+	if endpoint == "" {
+		endpoint = "https://storage.googleapis.com"
+	}
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?fields=size", endpoint, url.PathEscape(l.Bucket), url.PathEscape(l.Key))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return Object{}, err
+	}
+	tok, err := g.Tokens.Token(ctx)
+	if err != nil {
+		return Object{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok)
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return Object{}, fmt.Errorf("stat %s: %w", l, err)
+	}
+	defer resp.Body.Close()
+	if err := status(resp, l); err != nil {
+		return Object{}, err
+	}
+
+	// the api encodes the 64 bit size as a string
+	var o struct {
+		Size string `json:"size"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&o); err != nil {
+		return Object{}, fmt.Errorf("stat %s: decoding response: %w", l, err)
+	}
+	size, err := strconv.ParseInt(o.Size, 10, 64)
+	if err != nil {
+		return Object{}, fmt.Errorf("stat %s: bad size %q", l, o.Size)
+	}
+	return Object{Size: size}, nil
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+)
+
+// HTTP stats objects with a HEAD request. If the server doesn't allow
+// HEAD, it asks for the first byte and reads the size from the range.
+type HTTP struct {
+	Client *http.Client
+}
+
+func (h *HTTP) Stat(ctx context.Context, l storage.Location) (Object, error) {
+	resp, err := h.do(ctx, http.MethodHead, l)
+	if err != nil {
+		return Object{}, err
+	}
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		if err := status(resp, l); err != nil {
+			return Object{}, err
+		}
+		return Object{Size: resp.ContentLength}, nil
+	}
+
+	resp, err = h.do(ctx, http.MethodGet, l)
+	if err != nil {
+		return Object{}, err
+	}
+	if err := status(resp, l); err != nil {
+		return Object{}, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return Object{Size: resp.ContentLength}, nil
+	}
+	// Content-Range: bytes 0-0/1234
+	r := resp.Header.Get("Content-Range")
+	size, err := strconv.ParseInt(r[strings.LastIndex(r, "/")+1:], 10, 64)
+	if err != nil {
+		size = -1
+	}
+	return Object{Size: size}, nil
+}
+
+func (h *HTTP) do(ctx context.Context, method string, l storage.Location) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, l.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		// the url.Error would repeat the url with its query
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err
+		}
+		l.Query = ""
+		return nil, fmt.Errorf("stat %s: %w", l, err)
+	}
+	resp.Body.Close()
+	return resp, nil
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+)
+
+// emptyHash is the payload hash of a request without a body
+var emptyHash = func() string {
+	sum := sha256.Sum256(nil)
+	return hex.EncodeToString(sum[:])
+}()
+
+// S3 stats objects with a signed HEAD request. A bucket outside the
+// region is found from the redirect s3 answers with, and remembered.
+type S3 struct {
+	Client      *http.Client
+	Credentials aws.CredentialsProvider
+	Region      string
+	Endpoint    string // if set, used instead of aws, with the bucket in the path
+
+	once    sync.Once
+	signer  *v4.Signer
+	regions sync.Map // bucket -> region
+}
+
+func (s *S3) Stat(ctx context.Context, l storage.Location) (Object, error) {
+	s.once.Do(func() {
+		s.signer = v4.NewSigner(s.Credentials, func(v *v4.Signer) {
+			v.DisableURIPathEscaping = true
+		})
+	})
+	region := s.Region
+	if r, ok := s.regions.Load(l.Bucket); ok {
+		region = r.(string)
+	}
+	resp, err := s.head(ctx, l, region)
+	if err != nil {
+		return Object{}, err
+	}
+	if r := resp.Header.Get("X-Amz-Bucket-Region"); r != "" && r != region && resp.StatusCode != http.StatusOK {
+		s.regions.Store(l.Bucket, r)
+		if resp, err = s.head(ctx, l, r); err != nil {
+			return Object{}, err
+		}
+	}
+	if err := status(resp, l); err != nil {
+		return Object{}, err
+	}
+	return Object{Size: resp.ContentLength}, nil
+}
+
+func (s *S3) head(ctx context.Context, l storage.Location, region string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.url(l, region), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Amz-Content-Sha256", emptyHash)
+	if err := s.signer.SignHTTP(ctx, req, emptyHash, "s3", region, time.Now()); err != nil {
+		return nil, fmt.Errorf("signing request: %w", err)
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("stat %s: %w", l, err)
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// url returns the virtual hosted url of the object, or the path style
+// url for buckets with dots, which don't match the endpoint's certificate
+func (s *S3) url(l storage.Location, region string) string {
+	key := escape(l.Key)
+	switch {
+	case s.Endpoint != "":
+		return strings.TrimSuffix(s.Endpoint, "/") + "/" + l.Bucket + "/" + key
+	case strings.Contains(l.Bucket, "."):
+		return fmt.Sprintf("https://s3.%s.amazonaws.com/%s/%s", region, l.Bucket, key)
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", l.Bucket, region, key)
+}
+
+// escape encodes a key the way s3 does when it checks the signature:
+// everything but unreserved characters and slashes
+func escape(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,97 @@
+// Package store reads the metadata of objects in the storage jobs write
+// to, so the orchestrator can check a provider's outputs for itself.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/external"
+	"github.com/cbsinteractive/transcode-orchestrator/config"
+	"github.com/cbsinteractive/transcode-orchestrator/gcloud"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+)
+
+var ErrNotExist = errors.New("object does not exist")
+
+// Object is the metadata of a stored object
+type Object struct {
+	Size int64 // -1 if the store doesn't know
+}
+
+// Store stats objects. It returns ErrNotExist for a missing object.
+type Store interface {
+	Stat(ctx context.Context, l storage.Location) (Object, error)
+}
+
+// Mux is a Store that passes each location to the store for its scheme
+type Mux map[string]Store
+
+func (m Mux) Stat(ctx context.Context, l storage.Location) (Object, error) {
+	s, ok := m[l.Scheme]
+	if !ok {
+		return Object{}, fmt.Errorf("%w: no store for scheme %q", storage.ErrUnsupported, l.Scheme)
+	}
+	return s.Stat(ctx, l)
+}
+
+// New returns a Mux with a store for s3, gs, http, https and file
+// locations
+func New(cfg *config.Verify) (Store, error) {
+	if cfg == nil {
+		cfg = &config.Verify{}
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	awsCfg, err := external.LoadDefaultAWSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loading default aws config: %w", err)
+	}
+	if cfg.S3Region != "" {
+		awsCfg.Region = cfg.S3Region
+	}
+	if awsCfg.Region == "" {
+		awsCfg.Region = "us-east-1"
+	}
+	tokens, err := gcloud.Tokens(client, cfg.GCSCredentialsKey)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &HTTP{Client: client}
+	return Mux{
+		storage.S3:    &S3{Client: client, Credentials: awsCfg.Credentials, Region: awsCfg.Region},
+		storage.GCS:   &GCS{Client: client, Tokens: tokens},
+		storage.HTTP:  h,
+		storage.HTTPS: h,
+		storage.File:  File{},
+	}, nil
+}
+
+// Fake is a Store of object sizes by url, for tests
+type Fake map[string]int64
+
+func (f Fake) Stat(_ context.Context, l storage.Location) (Object, error) {
+	size, ok := f[l.String()]
+	if !ok {
+		return Object{}, fmt.Errorf("%w: %s", ErrNotExist, l)
+	}
+	return Object{Size: size}, nil
+}
+
+// status returns ErrNotExist for a missing object's http status, or
+// an error for any other unexpected status. The query is left out of
+// the error, since it may hold a signature.
+func status(resp *http.Response, l storage.Location) error {
+	l.Query = ""
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+		return nil
+	case http.StatusNotFound, http.StatusGone:
+		return fmt.Errorf("%w: %s", ErrNotExist, l)
+	}
+	return fmt.Errorf("stat %s: status %d", l, resp.StatusCode)
+}
@@ -0,0 +1,205 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/cbsinteractive/transcode-orchestrator/storage"
+)
+
+// stat parses url and stats it, failing the test on a bad url
+func stat(t *testing.T, s Store, url string) (Object, error) {
+	t.Helper()
+	l, err := storage.Parse(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.Stat(context.Background(), l)
+}
+
+// check compares the result of a stat with want, or with ErrNotExist
+// if want is negative
+func check(t *testing.T, url string, o Object, err error, want int64) {
+	t.Helper()
+	if want < 0 {
+		if !errors.Is(err, ErrNotExist) {
+			t.Errorf("%s: have %v, want %v", url, err, ErrNotExist)
+		}
+		return
+	}
+	if err != nil || o.Size != want {
+		t.Errorf("%s: have %d, %v, want %d", url, o.Size, err, want)
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err := ioutil.WriteFile(filepath.Join(dir, "out.mp4"), []byte("12345"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for url, want := range map[string]int64{
+		"file://" + dir + "/out.mp4": 5,
+		dir + "/missing.mp4":         -1,
+	} {
+		o, err := stat(t, File{}, url)
+		check(t, url, o, err, want)
+	}
+	if _, err := stat(t, File{}, dir); err == nil {
+		t.Error("stat of a directory succeeded")
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/out.mp4" && r.Method == http.MethodHead:
+			w.Header().Set("Content-Length", "5")
+		case r.URL.Path == "/nohead.mp4" && r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path == "/nohead.mp4" && r.Header.Get("Range") == "bytes=0-0":
+			w.Header().Set("Content-Range", "bytes 0-0/1234")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte("0"))
+		case r.URL.Path == "/denied.mp4":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	h := &HTTP{Client: srv.Client()}
+	for name, want := range map[string]int64{
+		"out.mp4":     5,
+		"nohead.mp4":  1234,
+		"missing.mp4": -1,
+	} {
+		o, err := stat(t, h, srv.URL+"/"+name)
+		check(t, name, o, err, want)
+	}
+	_, err := stat(t, h, srv.URL+"/denied.mp4?sig=secret")
+	if err == nil || errors.Is(err, ErrNotExist) || strings.Contains(err.Error(), "secret") {
+		t.Errorf("denied: have %v, want a status error without the query", err)
+	}
+}
+
+func TestS3(t *testing.T) {
+	var auth []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a := r.Header.Get("Authorization")
+		auth = append(auth, a)
+		if r.Method != http.MethodHead || r.Header.Get("X-Amz-Content-Sha256") != emptyHash || !strings.HasPrefix(a, "AWS4-HMAC-SHA256 ") {
+			t.Errorf("unsigned request: %s %s %v", r.Method, r.URL, r.Header)
+		}
+		if r.URL.Path == "/other/out.mp4" && !strings.Contains(a, "/eu-west-1/s3/") {
+			w.Header().Set("X-Amz-Bucket-Region", "eu-west-1")
+			w.WriteHeader(http.StatusMovedPermanently)
+			return
+		}
+		switch r.URL.EscapedPath() {
+		case "/bucket/dir/out%20hd.mp4", "/other/out.mp4":
+			w.Header().Set("Content-Length", "42")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	s := &S3{
+		Client:      srv.Client(),
+		Credentials: aws.StaticCredentialsProvider{Value: aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}},
+		Region:      "us-east-1",
+		Endpoint:    srv.URL,
+	}
+	for _, tt := range []struct {
+		url  string
+		want int64
+	}{
+		{"s3://bucket/dir/out hd.mp4", 42},
+		{"s3://bucket/missing.mp4", -1},
+		{"s3://other/out.mp4", 42},
+		{"s3://other/out.mp4", 42},
+	} {
+		o, err := stat(t, s, tt.url)
+		check(t, tt.url, o, err, tt.want)
+	}
+	// the second stat in the other bucket goes straight to its region
+	if n := len(auth); n != 5 || !strings.Contains(auth[n-1], "/eu-west-1/s3/") {
+		t.Fatalf("bucket region wasn't remembered: %q", auth)
+	}
+}
+
+func TestS3URL(t *testing.T) {
+	s := &S3{}
+	for url, want := range map[string]string{
+		"s3://bucket/a/b.mp4":   "https://bucket.s3.us-west-2.amazonaws.com/a/b.mp4",
+		"s3://my.bucket/b.mp4":  "https://s3.us-west-2.amazonaws.com/my.bucket/b.mp4",
+		"s3://bucket/a+b(1).ts": "https://bucket.s3.us-west-2.amazonaws.com/a%2Bb%281%29.ts",
+	} {
+		l, _ := storage.Parse(url)
+		if have := s.url(l, "us-west-2"); have != want {
+			t.Errorf("%s: have %q, want %q", url, have, want)
+		}
+	}
+}
+
+type static string
+
+func (s static) Token(context.Context) (string, error) { return string(s), nil }
+
+func TestGCS(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.EscapedPath() {
+		case "/storage/v1/b/bucket/o/dir%2Fout.mp4":
+			w.Write([]byte(`{"size": "42"}`))
+		case "/storage/v1/b/bucket/o/bad.mp4":
+			w.Write([]byte(`{"size": "many"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	g := &GCS{Client: srv.Client(), Tokens: static("tok"), Endpoint: srv.URL}
+	for url, want := range map[string]int64{
+		"gs://bucket/dir/out.mp4": 42,
+		"gs://bucket/missing.mp4": -1,
+	} {
+		o, err := stat(t, g, url)
+		check(t, url, o, err, want)
+	}
+	if _, err := stat(t, g, "gs://bucket/bad.mp4"); err == nil {
+		t.Error("bad size: no error")
+	}
+	g.Tokens = static("expired")
+	if _, err := stat(t, g, "gs://bucket/dir/out.mp4"); err == nil || errors.Is(err, ErrNotExist) {
+		t.Errorf("bad token: have %v, want a status error", err)
+	}
+}
+
+func TestMux(t *testing.T) {
+	m := Mux{storage.S3: Fake{"s3://bucket/out.mp4": 42}}
+	o, err := stat(t, m, "s3://bucket/out.mp4")
+	check(t, "s3", o, err, 42)
+	o, err = stat(t, m, "s3://bucket/missing.mp4")
+	check(t, "s3 missing", o, err, -1)
+	if _, err := stat(t, m, "gs://bucket/out.mp4"); !errors.Is(err, storage.ErrUnsupported) {
+		t.Errorf("gs: have %v, want %v", err, storage.ErrUnsupported)
+	}
+}
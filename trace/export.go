@@ -0,0 +1,48 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Noop discards every span
+type Noop struct{}
+
+func (Noop) Export(*Span) {}
+
+// Stdout writes each span as a line of json to W, or os.Stdout
+// if W is nil. It's meant for local testing.
+type Stdout struct {
+	W  io.Writer
+	mu sync.Mutex
+}
+
+func (e *Stdout) Export(s *Span) {
+	s.mu.Lock()
+	data, err := json.Marshal(s)
+	s.mu.Unlock()
+	if err != nil {
+		return
+	}
+	w := e.W
+	if w == nil {
+		w = os.Stdout
+	}
+	e.mu.Lock()
+	w.Write(append(data, '\n'))
+	e.mu.Unlock()
+}
+
+// NewExporter returns the exporter by name, "noop" or "stdout"
+func NewExporter(name string) (Exporter, error) {
+	switch name {
+	case "", "noop":
+		return Noop{}, nil
+	case "stdout":
+		return &Stdout{}, nil
+	}
+	return nil, fmt.Errorf("trace: unknown exporter %q", name)
+}
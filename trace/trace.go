@@ -0,0 +1,158 @@
+// Package trace records spans for requests flowing through the
+// service and the provider drivers. A Tracer satisfies tracing.Tracer,
+// so it can be set as config.Config.Tracer and drivers calling
+// BeginSubsegment get child spans of the span in their context.
+package trace
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zsiec/pkg/tracing"
+)
+
+// Exporter receives every finished span
+type Exporter interface {
+	Export(s *Span)
+}
+
+// Span is a timed operation. Attributes set on a span are inherited by
+// its children, so the job id set on a request span follows every
+// store access and provider call made on behalf of that request.
+type Span struct {
+	Trace  string                 `json:"trace"`
+	ID     string                 `json:"id"`
+	Parent string                 `json:"parent,omitempty"`
+	Name   string                 `json:"name"`
+	Start  time.Time              `json:"start"`
+	End    time.Time              `json:"end"`
+	Attr   map[string]interface{} `json:"attr,omitempty"`
+	Err    string                 `json:"err,omitempty"`
+
+	mu sync.Mutex
+	t  *Tracer
+}
+
+// Set adds key-value pairs to the span's attributes
+func (s *Span) Set(kv ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i+1 < len(kv); i += 2 {
+		s.Attr[fmt.Sprint(kv[i])] = kv[i+1]
+	}
+}
+
+// Close ends the span and exports it
+func (s *Span) Close(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.End = time.Now()
+	if err != nil {
+		s.Err = err.Error()
+	}
+	s.mu.Unlock()
+	s.t.Exporter.Export(s)
+}
+
+// New returns a tracer exporting to e
+func New(e Exporter) *Tracer {
+	if e == nil {
+		e = Noop{}
+	}
+	return &Tracer{Exporter: e}
+}
+
+// Tracer creates spans and hands them to its Exporter
+type Tracer struct {
+	Exporter Exporter
+}
+
+type key struct{}
+
+// FromContext returns the current span, or nil
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(key{}).(*Span)
+	return s
+}
+
+// Start begins a span as a child of the span in ctx, if there is one
+func (t *Tracer) Start(ctx context.Context, name string, kv ...interface{}) (context.Context, *Span) {
+	s := &Span{
+		ID:    id(),
+		Name:  name,
+		Start: time.Now(),
+		Attr:  map[string]interface{}{},
+		t:     t,
+	}
+	if p := FromContext(ctx); p != nil {
+		p.mu.Lock()
+		s.Trace, s.Parent = p.Trace, p.ID
+		for k, v := range p.Attr {
+			s.Attr[k] = v
+		}
+		p.mu.Unlock()
+	} else {
+		s.Trace = id() + id()
+	}
+	s.Set(kv...)
+	return context.WithValue(ctx, key{}, s), s
+}
+
+// Init implements tracing.Tracer
+func (t *Tracer) Init() error { return nil }
+
+// Client implements tracing.Tracer
+func (t *Tracer) Client(c *http.Client) *http.Client { return c }
+
+// BeginSubsegment implements tracing.Tracer
+func (t *Tracer) BeginSubsegment(ctx context.Context, name string) interface{ Close(error) } {
+	_, s := t.Start(ctx, name)
+	return s
+}
+
+// Handle implements tracing.Tracer, it starts a span for every request
+func (t *Tracer) Handle(n interface{ Name(host string) string }, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, s := t.Start(r.Context(), n.Name(r.Host), "method", r.Method, "path", r.URL.Path)
+		defer s.Close(nil)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Begin starts a span under ctx using t. Tracers other than *Tracer
+// fall back to BeginSubsegment, their context is returned unchanged
+// and the attributes are dropped.
+func Begin(ctx context.Context, t tracing.Tracer, name string, kv ...interface{}) (context.Context, interface{ Close(error) }) {
+	switch t := t.(type) {
+	case *Tracer:
+		return t.Start(ctx, name, kv...)
+	case nil:
+		return ctx, tracing.NoopTracer{}.BeginSubsegment(ctx, name)
+	}
+	return ctx, t.BeginSubsegment(ctx, name)
+}
+
+// Set adds attributes to the span in ctx, if there is one
+func Set(ctx context.Context, kv ...interface{}) {
+	FromContext(ctx).Set(kv...)
+}
+
+var (
+	idmu  sync.Mutex
+	idsrc = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+func id() string {
+	idmu.Lock()
+	defer idmu.Unlock()
+	return fmt.Sprintf("%016x", idsrc.Uint64())
+}
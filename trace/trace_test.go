@@ -0,0 +1,86 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zsiec/pkg/tracing"
+)
+
+type recorder []*Span
+
+func (r *recorder) Export(s *Span) { *r = append(*r, s) }
+
+func TestSpans(t *testing.T) {
+	rec := &recorder{}
+	tr := New(rec)
+
+	ctx, root := tr.Start(context.Background(), "request", "rid", 1)
+	Set(ctx, "job", "j1")
+	sub := tr.BeginSubsegment(ctx, "db-get")
+	sub.Close(errors.New("not found"))
+	_, call := Begin(ctx, tr, "provider-status", "providerJob", "p1")
+	call.Close(nil)
+	root.Close(nil)
+
+	if len(*rec) != 3 {
+		t.Fatalf("want 3 spans, have %d", len(*rec))
+	}
+	db, call0, req := (*rec)[0], (*rec)[1], (*rec)[2]
+	if req.Parent != "" || db.Parent != req.ID || call0.Parent != req.ID {
+		t.Errorf("bad parents: root=%q db=%q call=%q", req.ID, db.Parent, call0.Parent)
+	}
+	if db.Trace != req.Trace || call0.Trace != req.Trace {
+		t.Errorf("spans not in the same trace")
+	}
+	if db.Attr["job"] != "j1" || db.Attr["rid"] != 1 {
+		t.Errorf("child did not inherit attributes: %v", db.Attr)
+	}
+	if call0.Attr["providerJob"] != "p1" || req.Attr["providerJob"] != nil {
+		t.Errorf("bad attributes: call=%v root=%v", call0.Attr, req.Attr)
+	}
+	if db.Err != "not found" || req.Err != "" {
+		t.Errorf("bad errors: db=%q root=%q", db.Err, req.Err)
+	}
+}
+
+func TestBeginFallback(t *testing.T) {
+	ctx := context.Background()
+	for _, tr := range []tracing.Tracer{nil, tracing.NoopTracer{}} {
+		ctx1, span := Begin(ctx, tr, "x", "k", "v")
+		if ctx1 != ctx {
+			t.Errorf("%T: context changed", tr)
+		}
+		span.Close(nil)
+	}
+	Set(ctx, "no", "span")
+}
+
+func TestStdout(t *testing.T) {
+	buf := &bytes.Buffer{}
+	tr := New(&Stdout{W: buf})
+	_, s := tr.Start(context.Background(), "a", "job", "j1")
+	s.Close(nil)
+	_, s = tr.Start(context.Background(), "b")
+	s.Close(nil)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("want 2 lines, have %d: %q", len(lines), buf)
+	}
+	span := &Span{}
+	if err := json.Unmarshal([]byte(lines[0]), span); err != nil {
+		t.Fatal(err)
+	}
+	if span.Name != "a" || span.Attr["job"] != "j1" || span.End.Before(span.Start) {
+		t.Errorf("bad span: %+v", span)
+	}
+
+	if _, err := NewExporter("zipkin"); err == nil {
+		t.Errorf("unknown exporter: expected error")
+	}
+}